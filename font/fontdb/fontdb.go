@@ -0,0 +1,547 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+/*
+Package fontdb implements a database of the font faces installed on a system
+or stored in arbitrary directories.
+
+A DB scans font files without loading their glyphs, and records the family,
+style and weight of every face it finds. Faces can be enumerated, matched
+against a font.Font using CSS-like fallback rules, and loaded on demand:
+
+	db, err := fontdb.Open(filepath.Join(cacheDir, "gio-fonts.json"))
+	...
+	err = db.ScanSystem()
+	...
+	face, ok := db.Match(font.Font{Typeface: "Noto Sans, sans-serif", Weight: font.Bold})
+	...
+	collection, err := db.Collection(...)
+	shaper := text.NewShaper(text.NoSystemFonts(), text.WithCollection(collection))
+
+The scan index may be cached on disk with Save; a DB opened from a cache file
+only re-reads font files that were added or modified since the index was
+written, which makes scanning the system fonts at startup cheap.
+
+A DB is independent of the system font index maintained by text.Shaper.
+*/
+package fontdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	giofont "gioui.org/font"
+	"gioui.org/font/opentype"
+	"github.com/go-text/typesetting/fontscan"
+	"github.com/go-text/typesetting/opentype/api/metadata"
+	"github.com/go-text/typesetting/opentype/loader"
+)
+
+// Face describes a font face found in a font file.
+type Face struct {
+	// Font describes the family, style and weight of the face. Its Typeface
+	// is the single family name recorded in the font file.
+	Font giofont.Font
+	// Path is the location of the font file.
+	Path string
+	// Index is the index of the face in the file. It is non-zero only for
+	// faces of font collections.
+	Index int
+}
+
+// DB is a database of font faces. It is safe for concurrent use.
+type DB struct {
+	mu        sync.Mutex
+	cachePath string
+	files     map[string]*file
+	loaded    map[Face]giofont.FontFace
+}
+
+// file is the index entry for a scanned font file.
+type file struct {
+	ModTime int64       `json:"modTime"`
+	Size    int64       `json:"size"`
+	Faces   []cacheFace `json:"faces,omitempty"`
+}
+
+// cacheFace is the serialized form of a Face, minus its path.
+type cacheFace struct {
+	Family string         `json:"family"`
+	Style  giofont.Style  `json:"style"`
+	Weight giofont.Weight `json:"weight"`
+	Index  int            `json:"index"`
+}
+
+// cacheIndex is the on-disk format of the scan index.
+type cacheIndex struct {
+	Version int              `json:"version"`
+	Files   map[string]*file `json:"files"`
+}
+
+// cacheVersion is incremented whenever the meaning of the index changes.
+const cacheVersion = 1
+
+// New returns an empty database without an on-disk cache.
+func New() *DB {
+	return &DB{files: make(map[string]*file)}
+}
+
+// Open returns a database backed by the index cached at path. A missing or
+// outdated cache is not an error; the database starts out empty in that case
+// and the cache is written by the next call to Save.
+func Open(path string) (*DB, error) {
+	db := New()
+	db.cachePath = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return db, nil
+		}
+		return nil, fmt.Errorf("fontdb: %w", err)
+	}
+	var idx cacheIndex
+	if err := json.Unmarshal(data, &idx); err != nil || idx.Version != cacheVersion {
+		return db, nil
+	}
+	for p, f := range idx.Files {
+		if f != nil {
+			db.files[p] = f
+		}
+	}
+	return db, nil
+}
+
+// Save writes the scan index to the cache path given to Open. It does nothing
+// for databases created by New.
+func (db *DB) Save() error {
+	db.mu.Lock()
+	data, err := json.Marshal(cacheIndex{Version: cacheVersion, Files: db.files})
+	path := db.cachePath
+	db.mu.Unlock()
+	if err != nil || path == "" {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("fontdb: %w", err)
+	}
+	// Write to a temporary file first to never leave a truncated cache behind.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("fontdb: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("fontdb: %w", err)
+	}
+	return nil
+}
+
+// ScanSystem scans the platform's standard font directories.
+func (db *DB) ScanSystem() error {
+	dirs, err := fontscan.DefaultFontDirectories(nopLogger{})
+	if err != nil {
+		return fmt.Errorf("fontdb: %w", err)
+	}
+	for _, dir := range dirs {
+		if err := db.ScanDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanDir recursively scans dir for font files and adds their faces to the
+// database. Files already in the index are re-read only if their size or
+// modification time changed, and indexed files no longer present under dir
+// are removed. Unreadable or invalid font files are skipped.
+func (db *DB) ScanDir(dir string) error {
+	dir = filepath.Clean(dir)
+	seen := make(map[string]bool)
+	var buf []byte
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable sub-directories, but report a missing root.
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() || !isFontFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+		}
+		seen[path] = true
+		mod, size := info.ModTime().UnixNano(), info.Size()
+		db.mu.Lock()
+		f := db.files[path]
+		db.mu.Unlock()
+		if f != nil && f.ModTime == mod && f.Size == size {
+			return nil
+		}
+		f = &file{ModTime: mod, Size: size}
+		f.Faces, buf = scanFile(path, buf)
+		db.mu.Lock()
+		db.files[path] = f
+		db.forget(path)
+		db.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("fontdb: %w", err)
+	}
+	prefix := dir + string(filepath.Separator)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for path := range db.files {
+		if !seen[path] && strings.HasPrefix(path, prefix) {
+			delete(db.files, path)
+			db.forget(path)
+		}
+	}
+	return nil
+}
+
+// forget drops the loaded faces of the file at path. db.mu must be held.
+func (db *DB) forget(path string) {
+	for f := range db.loaded {
+		if f.Path == path {
+			delete(db.loaded, f)
+		}
+	}
+}
+
+// scanFile reads the faces of the font file at path. A file that can't be
+// parsed yields no faces, so that it is recorded in the index and not read
+// again until it changes.
+func scanFile(path string, buf []byte) ([]cacheFace, []byte) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, buf
+	}
+	defer r.Close()
+	lds, err := loader.NewLoaders(r)
+	if err != nil {
+		return nil, buf
+	}
+	var faces []cacheFace
+	for i, ld := range lds {
+		var family string
+		var aspect metadata.Aspect
+		family, aspect, buf = metadata.Describe(ld, buf)
+		if family == "" {
+			continue
+		}
+		fnt := opentype.DescriptionToFont(metadata.Description{Family: family, Aspect: aspect})
+		faces = append(faces, cacheFace{
+			Family: family,
+			Style:  fnt.Style,
+			Weight: fnt.Weight,
+			Index:  i,
+		})
+	}
+	return faces, buf
+}
+
+// isFontFile reports whether path has the extension of a supported font
+// file format.
+func isFontFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ttf", ".otf", ".ttc", ".otc", ".woff":
+		return true
+	}
+	return false
+}
+
+// Faces returns every face in the database, ordered by family, weight and
+// style.
+func (db *DB) Faces() []Face {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var faces []Face
+	for path, f := range db.files {
+		for _, cf := range f.Faces {
+			faces = append(faces, cf.face(path))
+		}
+	}
+	sortFaces(faces)
+	return faces
+}
+
+// Families returns the sorted names of the font families in the database.
+func (db *DB) Families() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	names := make(map[string]string)
+	for _, f := range db.files {
+		for _, cf := range f.Faces {
+			key := metadata.NormalizeFamily(cf.Family)
+			if _, ok := names[key]; !ok {
+				names[key] = cf.Family
+			}
+		}
+	}
+	families := make([]string, 0, len(names))
+	for _, name := range names {
+		families = append(families, name)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return strings.ToLower(families[i]) < strings.ToLower(families[j])
+	})
+	return families
+}
+
+// Family returns the faces of the named family, ordered by weight and style.
+// Family names are matched case insensitively, ignoring spaces.
+func (db *DB) Family(name string) []Face {
+	key := metadata.NormalizeFamily(name)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var faces []Face
+	for path, f := range db.files {
+		for _, cf := range f.Faces {
+			if metadata.NormalizeFamily(cf.Family) == key {
+				faces = append(faces, cf.face(path))
+			}
+		}
+	}
+	sortFaces(faces)
+	return faces
+}
+
+// Match returns the face that best matches fnt. The families of the
+// Typeface are tried in order, with generic families such as "sans-serif"
+// expanded to common families of their kind. Within a family, the style and
+// weight are chosen following the CSS font matching algorithm. If no family
+// is available, Match falls back to a sans-serif family, then to any family.
+// Match returns false only when the database is empty.
+func (db *DB) Match(fnt giofont.Font) (Face, bool) {
+	families := splitFamilies(string(fnt.Typeface))
+	families = append(families, "sans-serif")
+	for _, name := range families {
+		for _, name := range expandGeneric(name) {
+			if faces := db.Family(name); len(faces) > 0 {
+				return closest(faces, fnt.Style, fnt.Weight), true
+			}
+		}
+	}
+	faces := db.Faces()
+	if len(faces) == 0 {
+		return Face{}, false
+	}
+	return closest(db.Family(string(faces[0].Font.Typeface)), fnt.Style, fnt.Weight), true
+}
+
+// Load parses the font file containing face and returns the face ready for
+// use by a text.Shaper. Loaded faces are cached by the database.
+func (db *DB) Load(face Face) (giofont.FontFace, error) {
+	db.mu.Lock()
+	ff, ok := db.loaded[face]
+	db.mu.Unlock()
+	if ok {
+		return ff, nil
+	}
+	data, err := os.ReadFile(face.Path)
+	if err != nil {
+		return giofont.FontFace{}, fmt.Errorf("fontdb: %w", err)
+	}
+	faces, err := opentype.ParseCollection(data)
+	if err != nil {
+		return giofont.FontFace{}, fmt.Errorf("fontdb: %s: %w", face.Path, err)
+	}
+	if face.Index < 0 || face.Index >= len(faces) {
+		return giofont.FontFace{}, fmt.Errorf("fontdb: %s: no face at index %d", face.Path, face.Index)
+	}
+	ff = giofont.FontFace{Font: face.Font, Face: faces[face.Index].Face}
+	db.mu.Lock()
+	if db.loaded == nil {
+		db.loaded = make(map[Face]giofont.FontFace)
+	}
+	db.loaded[face] = ff
+	db.mu.Unlock()
+	return ff, nil
+}
+
+// Collection matches and loads a face for each of fonts, skipping
+// duplicates. The result is suitable for text.WithCollection.
+func (db *DB) Collection(fonts ...giofont.Font) ([]giofont.FontFace, error) {
+	var collection []giofont.FontFace
+	added := make(map[Face]bool)
+	for _, fnt := range fonts {
+		face, ok := db.Match(fnt)
+		if !ok {
+			return nil, errors.New("fontdb: no font faces available")
+		}
+		if added[face] {
+			continue
+		}
+		added[face] = true
+		ff, err := db.Load(face)
+		if err != nil {
+			return nil, err
+		}
+		collection = append(collection, ff)
+	}
+	return collection, nil
+}
+
+func (cf cacheFace) face(path string) Face {
+	return Face{
+		Font: giofont.Font{
+			Typeface: giofont.Typeface(cf.Family),
+			Style:    cf.Style,
+			Weight:   cf.Weight,
+		},
+		Path:  path,
+		Index: cf.Index,
+	}
+}
+
+func sortFaces(faces []Face) {
+	sort.Slice(faces, func(i, j int) bool {
+		fi, fj := faces[i], faces[j]
+		if ti, tj := strings.ToLower(string(fi.Font.Typeface)), strings.ToLower(string(fj.Font.Typeface)); ti != tj {
+			return ti < tj
+		}
+		if fi.Font.Weight != fj.Font.Weight {
+			return fi.Font.Weight < fj.Font.Weight
+		}
+		if fi.Font.Style != fj.Font.Style {
+			return fi.Font.Style < fj.Font.Style
+		}
+		if fi.Path != fj.Path {
+			return fi.Path < fj.Path
+		}
+		return fi.Index < fj.Index
+	})
+}
+
+// closest selects the face of a family best matching style and weight, as
+// described by the CSS Fonts Module font matching algorithm. faces must not
+// be empty.
+func closest(faces []Face, style giofont.Style, weight giofont.Weight) Face {
+	// Prefer the requested style, falling back to the other one.
+	var candidates []Face
+	for _, f := range faces {
+		if f.Font.Style == style {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = faces
+	}
+	best := candidates[0]
+	for _, f := range candidates[1:] {
+		if weightRank(f.Font.Weight, weight) < weightRank(best.Font.Weight, weight) {
+			best = f
+		}
+	}
+	return best
+}
+
+// weightRank orders available weights by preference for the desired weight,
+// lower ranks being better. For desired weights between Normal and Medium,
+// heavier weights up to Medium are tried first, then lighter weights, then
+// heavier weights. Lighter desired weights prefer lighter weights, and
+// heavier desired weights prefer heavier ones.
+func weightRank(w, desired giofont.Weight) int {
+	const span = 10000
+	d := int(w - desired)
+	if d < 0 {
+		d = -d
+	}
+	switch {
+	case desired >= giofont.Normal && desired <= giofont.Medium:
+		switch {
+		case w >= desired && w <= giofont.Medium:
+			return d
+		case w < desired:
+			return span + d
+		default:
+			return 2*span + d
+		}
+	case desired < giofont.Normal:
+		if w <= desired {
+			return d
+		}
+		return span + d
+	default:
+		if w >= desired {
+			return d
+		}
+		return span + d
+	}
+}
+
+// genericFamilies lists common installed families for the generic CSS
+// families, in order of preference.
+var genericFamilies = map[string][]string{
+	"sans-serif": {"Noto Sans", "DejaVu Sans", "Liberation Sans", "Roboto", "Arial", "Helvetica", "Segoe UI", "Ubuntu", "Cantarell", "Go"},
+	"serif":      {"Noto Serif", "DejaVu Serif", "Liberation Serif", "Times New Roman", "Times", "Georgia", "Go"},
+	"monospace":  {"Noto Sans Mono", "DejaVu Sans Mono", "Liberation Mono", "Ubuntu Mono", "Consolas", "Menlo", "Courier New", "Go Mono"},
+	"cursive":    {"Comic Neue", "Comic Sans MS", "URW Chancery L", "Apple Chancery"},
+	"fantasy":    {"Impact", "Papyrus", "Luminari"},
+	"math":       {"Noto Sans Math", "STIX Two Math", "Cambria Math", "Latin Modern Math", "DejaVu Math TeX Gyre"},
+	"emoji":      {"Noto Color Emoji", "Apple Color Emoji", "Segoe UI Emoji", "Twemoji"},
+}
+
+// expandGeneric returns the families to try for name: the families of a
+// generic family, or name itself.
+func expandGeneric(name string) []string {
+	if fams, ok := genericFamilies[strings.ToLower(name)]; ok {
+		return fams
+	}
+	return []string{name}
+}
+
+// splitFamilies splits a Typeface into its family names. Malformed quoting is
+// tolerated; an unterminated quote extends to the end of the list.
+func splitFamilies(typeface string) []string {
+	var families []string
+	var name bytes.Buffer
+	quoted := false
+	flush := func() {
+		if n := strings.TrimSpace(name.String()); n != "" || quoted {
+			families = append(families, n)
+		}
+		name.Reset()
+		quoted = false
+	}
+	for i := 0; i < len(typeface); i++ {
+		c := typeface[i]
+		switch c {
+		case ',':
+			flush()
+		case '"', '\'':
+			quoted = true
+			for i++; i < len(typeface) && typeface[i] != c; i++ {
+				if typeface[i] == '\\' && i+1 < len(typeface) {
+					i++
+				}
+				name.WriteByte(typeface[i])
+			}
+		default:
+			name.WriteByte(c)
+		}
+	}
+	flush()
+	return families
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, args ...interface{}) {}
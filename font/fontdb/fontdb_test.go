@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package fontdb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+
+	giofont "gioui.org/font"
+)
+
+func writeFonts(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanAndMatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFonts(t, dir, map[string][]byte{
+		"regular.ttf":     goregular.TTF,
+		"sub/bold.ttf":    gobold.TTF,
+		"sub/italic.TTF":  goitalic.TTF,
+		"mono/mono.ttf":   gomono.TTF,
+		"notes.txt":       []byte("not a font"),
+		"broken/font.ttf": []byte("not a font either"),
+	})
+	db := New()
+	if err := db.ScanDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := db.Families(), []string{"Go", "Go Mono"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("families: got %q, want %q", got, want)
+	}
+	if n := len(db.Faces()); n != 4 {
+		t.Errorf("got %d faces, want 4", n)
+	}
+	if n := len(db.Family("go")); n != 3 {
+		t.Errorf("got %d faces in family Go, want 3", n)
+	}
+
+	tests := []struct {
+		font giofont.Font
+		file string
+	}{
+		{giofont.Font{Typeface: "Go"}, "regular.ttf"},
+		{giofont.Font{Typeface: "Go", Weight: giofont.SemiBold}, "sub/bold.ttf"},
+		{giofont.Font{Typeface: "Go", Weight: giofont.Medium}, "regular.ttf"},
+		{giofont.Font{Typeface: "Go", Weight: giofont.Bold, Style: giofont.Italic}, "sub/italic.TTF"},
+		{giofont.Font{Typeface: `Missing, "go mono"`}, "mono/mono.ttf"},
+		{giofont.Font{Typeface: "monospace"}, "mono/mono.ttf"},
+		{giofont.Font{Typeface: "Missing", Weight: giofont.Bold}, "sub/bold.ttf"},
+	}
+	for _, tc := range tests {
+		face, ok := db.Match(tc.font)
+		if !ok {
+			t.Errorf("%+v: no match", tc.font)
+			continue
+		}
+		if want := filepath.Join(dir, tc.file); face.Path != want {
+			t.Errorf("%+v: matched %s, want %s", tc.font, face.Path, want)
+		}
+	}
+
+	face, _ := db.Match(giofont.Font{Typeface: "Go", Weight: giofont.Bold})
+	ff, err := db.Load(face)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ff.Face == nil || ff.Font != face.Font {
+		t.Errorf("loaded %+v for %+v", ff.Font, face.Font)
+	}
+	coll, err := db.Collection(giofont.Font{Typeface: "Go"}, giofont.Font{}, giofont.Font{Typeface: "Go Mono"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coll) != 2 {
+		t.Errorf("got %d collection faces, want 2", len(coll))
+	}
+}
+
+func TestMatchEmpty(t *testing.T) {
+	if _, ok := New().Match(giofont.Font{}); ok {
+		t.Error("empty database matched a face")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFonts(t, dir, map[string][]byte{
+		"regular.ttf": goregular.TTF,
+		"bold.ttf":    gobold.TTF,
+	})
+	cache := filepath.Join(t.TempDir(), "cache", "fonts.json")
+	db, err := Open(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ScanDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+	want := db.Faces()
+
+	db, err = Open(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Faces(); !reflect.DeepEqual(got, want) {
+		t.Errorf("cached faces: got %+v, want %+v", got, want)
+	}
+	// Corrupt a font without changing its modification time or size; the
+	// cached entry must be trusted.
+	path := filepath.Join(dir, "bold.ttf")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, len(gobold.TTF)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ScanDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Faces(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rescanned faces: got %+v, want %+v", got, want)
+	}
+	// Removed files are dropped from the index.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ScanDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Faces(); len(got) != 1 || got[0].Font.Weight != giofont.Normal {
+		t.Errorf("faces after removal: %+v", got)
+	}
+}
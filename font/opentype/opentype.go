@@ -1,7 +1,7 @@
 // SPDX-License-Identifier: Unlicense OR MIT
 
 // Package opentype implements text layout and shaping for OpenType
-// files.
+// files. Fonts may also be provided in the WOFF and WOFF2 web font formats.
 //
 // NOTE: the OpenType specification allows for fonts to include bitmap images
 // in a variety of formats. In the interest of small binary sizes, the opentype
@@ -28,10 +28,19 @@ import (
 type Face struct {
 	face font.Font
 	font giofont.Font
+	ld   *loader.Loader
 }
 
-// Parse constructs a Face from source bytes.
+// Parse constructs a Face from source bytes. The source may be an OpenType,
+// WOFF or WOFF2 font file.
 func Parse(src []byte) (Face, error) {
+	if isWOFF2(src) {
+		fonts, err := decodeWOFF2(src)
+		if err != nil {
+			return Face{}, fmt.Errorf("failed decoding WOFF2 font: %w", err)
+		}
+		src = fonts[0]
+	}
 	ld, err := loader.NewLoader(bytes.NewReader(src))
 	if err != nil {
 		return Face{}, err
@@ -43,17 +52,19 @@ func Parse(src []byte) (Face, error) {
 	return Face{
 		face: font,
 		font: md,
+		ld:   ld,
 	}, nil
 }
 
 // ParseCollection parse an Opentype font file, with support for collections.
-// Single font files are supported, returning a slice with length 1.
+// Single font files are supported, returning a slice with length 1. WOFF and
+// WOFF2 files are supported as well.
 // The returned fonts are automatically wrapped in a text.FontFace with
 // inferred font metadata.
 // BUG(whereswaldon): the only Variant that can be detected automatically is
 // "Mono".
 func ParseCollection(src []byte) ([]giofont.FontFace, error) {
-	lds, err := loader.NewLoaders(bytes.NewReader(src))
+	lds, err := loadCollection(src)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +77,7 @@ func ParseCollection(src []byte) ([]giofont.FontFace, error) {
 		ff := Face{
 			face: face,
 			font: md,
+			ld:   ld,
 		}
 		out[i] = giofont.FontFace{
 			Face: ff,
@@ -76,6 +88,24 @@ func ParseCollection(src []byte) ([]giofont.FontFace, error) {
 	return out, nil
 }
 
+// loadCollection returns loaders for the fonts in src.
+func loadCollection(src []byte) ([]*loader.Loader, error) {
+	if !isWOFF2(src) {
+		return loader.NewLoaders(bytes.NewReader(src))
+	}
+	fonts, err := decodeWOFF2(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding WOFF2 font: %w", err)
+	}
+	lds := make([]*loader.Loader, len(fonts))
+	for i, f := range fonts {
+		if lds[i], err = loader.NewLoader(bytes.NewReader(f)); err != nil {
+			return nil, err
+		}
+	}
+	return lds, nil
+}
+
 func DescriptionToFont(md metadata.Description) giofont.Font {
 	return giofont.Font{
 		Typeface: giofont.Typeface(md.Family),
@@ -119,6 +149,24 @@ func (f Face) Font() giofont.Font {
 	return f.font
 }
 
+// RawTable returns the binary content of the OpenType table identified by the
+// four character tag, such as "COLR", or nil if the font has no such table.
+// It gives access to tables not interpreted by the font parser.
+func (f Face) RawTable(tag string) []byte {
+	if f.ld == nil || len(tag) != 4 {
+		return nil
+	}
+	t := loader.MustNewTag(tag)
+	if !f.ld.HasTable(t) {
+		return nil
+	}
+	data, err := f.ld.RawTable(t)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
 func gioStyle(s metadata.Style) giofont.Style {
 	switch s {
 	case metadata.StyleItalic:
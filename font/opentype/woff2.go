@@ -0,0 +1,676 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-text/typesetting/opentype/loader"
+)
+
+// This file implements a decoder for the WOFF2 font format, described at
+// https://www.w3.org/TR/WOFF2/. WOFF2 files are converted back to OpenType
+// files which are then parsed as usual. The older WOFF format is decoded
+// directly by the font loader.
+
+// woff2HeaderSize is the size of the fixed WOFF2 header.
+const woff2HeaderSize = 48
+
+// woff2MaxSize bounds the total size of the decompressed font data, to
+// guard against decompression bombs.
+const woff2MaxSize = 1 << 28
+
+var (
+	tagGlyf = loader.MustNewTag("glyf")
+	tagLoca = loader.MustNewTag("loca")
+	tagHmtx = loader.MustNewTag("hmtx")
+	tagHhea = loader.MustNewTag("hhea")
+	tagHead = loader.MustNewTag("head")
+	tagTTCF = loader.MustNewTag("ttcf")
+)
+
+// woff2KnownTags lists the table tags that may be encoded by their index in
+// a WOFF2 table directory.
+var woff2KnownTags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ",
+	"fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT", "EBLC", "gasp",
+	"hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF",
+	"GPOS", "GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL",
+	"SVG ", "sbix", "acnt", "avar", "bdat", "bloc", "bsln", "cvar", "fdsc",
+	"feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar", "mort", "morx",
+	"opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// woff2Table is an entry of the WOFF2 table directory.
+type woff2Table struct {
+	tag        loader.Tag
+	transform  uint8
+	origLength uint32
+	// offset and length locate the table in the decompressed data.
+	offset, length uint32
+}
+
+// transformed reports whether the table data is stored in a transformed
+// format. Transform version 0 is the null transform except for the glyf and
+// loca tables, where the null transform is version 3.
+func (t woff2Table) transformed() bool {
+	if t.tag == tagGlyf || t.tag == tagLoca {
+		return t.transform != 3
+	}
+	return t.transform != 0
+}
+
+// isWOFF2 reports whether src starts with the WOFF2 signature.
+func isWOFF2(src []byte) bool {
+	return len(src) >= 4 && string(src[:4]) == "wOF2"
+}
+
+// decodeWOFF2 converts WOFF2 data to OpenType font files, one for each font
+// of a collection.
+func decodeWOFF2(src []byte) ([][]byte, error) {
+	if len(src) < woff2HeaderSize || !isWOFF2(src) {
+		return nil, errors.New("woff2: invalid header")
+	}
+	r := &woff2Reader{data: src, off: 4}
+	flavor := loader.Tag(r.u32())
+	r.u32() // length
+	numTables := int(r.u16())
+	r.u16() // reserved
+	r.u32() // totalSfntSize
+	compLength := r.u32()
+	r.off = woff2HeaderSize
+	if numTables == 0 {
+		return nil, errors.New("woff2: no tables")
+	}
+
+	tables := make([]woff2Table, numTables)
+	var total uint64
+	for i := range tables {
+		t := &tables[i]
+		flags := r.u8()
+		if idx := flags & 0x3f; idx == 0x3f {
+			t.tag = loader.Tag(r.u32())
+		} else if int(idx) < len(woff2KnownTags) {
+			t.tag = loader.MustNewTag(woff2KnownTags[idx])
+		} else {
+			return nil, fmt.Errorf("woff2: invalid tag index %d", idx)
+		}
+		t.transform = flags >> 6
+		t.origLength = r.base128()
+		t.length = t.origLength
+		if t.transformed() {
+			t.length = r.base128()
+			if t.tag == tagLoca && t.length != 0 {
+				return nil, errors.New("woff2: transformed loca table is not empty")
+			}
+		}
+		t.offset = uint32(total)
+		total += uint64(t.length)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if total > woff2MaxSize {
+		return nil, errors.New("woff2: font too large")
+	}
+
+	// fonts lists the indices of the tables of every font.
+	var fonts [][]int
+	if flavor == tagTTCF {
+		r.u32() // version
+		numFonts := int(r.u255())
+		for i := 0; i < numFonts && r.err == nil; i++ {
+			n := int(r.u255())
+			r.u32() // flavor
+			indices := make([]int, 0, n)
+			for j := 0; j < n && r.err == nil; j++ {
+				idx := int(r.u255())
+				if idx >= numTables {
+					return nil, fmt.Errorf("woff2: invalid table index %d", idx)
+				}
+				indices = append(indices, idx)
+			}
+			fonts = append(fonts, indices)
+		}
+		if r.err == nil && len(fonts) == 0 {
+			return nil, errors.New("woff2: empty collection")
+		}
+	} else {
+		indices := make([]int, numTables)
+		for i := range indices {
+			indices[i] = i
+		}
+		fonts = [][]int{indices}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	comp := r.bytes(int(compLength))
+	if r.err != nil {
+		return nil, r.err
+	}
+	data := make([]byte, total)
+	if _, err := io.ReadFull(brotli.NewReader(bytes.NewReader(comp)), data); err != nil {
+		return nil, fmt.Errorf("woff2: decompressing tables: %w", err)
+	}
+
+	contents := &woff2Contents{
+		tables: make(map[int][]byte),
+		xMins:  make(map[int][]int16),
+	}
+	var out [][]byte
+	for _, indices := range fonts {
+		byTag := make(map[loader.Tag]int)
+		for _, idx := range indices {
+			byTag[tables[idx].tag] = idx
+		}
+		if err := reconstructTables(data, tables, byTag, contents); err != nil {
+			return nil, err
+		}
+		sfnt := make([]loader.Table, 0, len(byTag))
+		for tag, idx := range byTag {
+			sfnt = append(sfnt, loader.Table{Tag: tag, Content: contents.tables[idx]})
+		}
+		sort.Slice(sfnt, func(i, j int) bool { return sfnt[i].Tag < sfnt[j].Tag })
+		out = append(out, loader.WriteTTF(sfnt))
+	}
+	return out, nil
+}
+
+// woff2Contents caches the reconstructed tables of a WOFF2 file, which may be
+// shared by the fonts of a collection.
+type woff2Contents struct {
+	tables map[int][]byte
+	// xMins holds the minimum x coordinate of the glyphs of each
+	// reconstructed glyf table, for reconstructing hmtx tables.
+	xMins map[int][]int16
+}
+
+// reconstructTables reconstructs the tables of a font, undoing the WOFF2
+// transforms.
+func reconstructTables(data []byte, tables []woff2Table, byTag map[loader.Tag]int, c *woff2Contents) error {
+	glyf, hasGlyf := byTag[tagGlyf]
+	loca, hasLoca := byTag[tagLoca]
+	if hasGlyf != hasLoca {
+		return errors.New("woff2: glyf and loca tables must be present together")
+	}
+	if hasGlyf && tables[glyf].transformed() != tables[loca].transformed() {
+		return errors.New("woff2: glyf and loca tables must be transformed together")
+	}
+	for _, idx := range byTag {
+		if t := tables[idx]; !t.transformed() {
+			// Limit the capacity, because loader.WriteTTF appends
+			// padding to the tables.
+			c.tables[idx] = data[t.offset : t.offset+t.length : t.offset+t.length]
+		}
+	}
+	if hasGlyf && tables[glyf].transformed() {
+		if _, ok := c.tables[glyf]; !ok {
+			t := tables[glyf]
+			g, l, mins, err := reconstructGlyf(data[t.offset : t.offset+t.length])
+			if err != nil {
+				return err
+			}
+			if uint32(len(l)) != tables[loca].origLength {
+				return errors.New("woff2: reconstructed loca table has the wrong size")
+			}
+			c.tables[glyf], c.tables[loca], c.xMins[glyf] = g, l, mins
+		}
+	}
+	for _, idx := range byTag {
+		t := tables[idx]
+		if !t.transformed() || t.tag == tagGlyf || t.tag == tagLoca {
+			continue
+		}
+		if t.tag != tagHmtx || t.transform != 1 {
+			return fmt.Errorf("woff2: unsupported transform %d for table %s", t.transform, t.tag)
+		}
+		if _, ok := c.tables[idx]; ok {
+			continue
+		}
+		hheaIdx, ok := byTag[tagHhea]
+		hhea, xMins := c.tables[hheaIdx], c.xMins[glyf]
+		if !ok || len(hhea) < 36 || xMins == nil {
+			return errors.New("woff2: transformed hmtx table requires hhea and transformed glyf tables")
+		}
+		numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+		hmtx, err := reconstructHmtx(data[t.offset:t.offset+t.length], numHMetrics, xMins)
+		if err != nil {
+			return err
+		}
+		c.tables[idx] = hmtx
+	}
+	if head, ok := byTag[tagHead]; ok && len(c.tables[head]) >= 12 {
+		// The checksum adjustment is not valid for the reconstructed font.
+		h := append([]byte(nil), c.tables[head]...)
+		binary.BigEndian.PutUint32(h[8:], 0)
+		c.tables[head] = h
+	}
+	return nil
+}
+
+// Simple glyph flags.
+const (
+	glyfOnCurve     = 0x01
+	glyfXShort      = 0x02
+	glyfYShort      = 0x04
+	glyfRepeat      = 0x08
+	glyfXSame       = 0x10
+	glyfYSame       = 0x20
+	glyfOverlapping = 0x40
+)
+
+// Composite glyph flags.
+const (
+	compositeArgWords     = 0x0001
+	compositeScale        = 0x0008
+	compositeMore         = 0x0020
+	compositeXYScale      = 0x0040
+	compositeTwoByTwo     = 0x0080
+	compositeInstructions = 0x0100
+)
+
+// reconstructGlyf converts a transformed glyf table back to its glyf and loca
+// tables. It also returns the minimum x coordinate of each glyph.
+func reconstructGlyf(src []byte) (glyf, loca []byte, xMins []int16, err error) {
+	r := &woff2Reader{data: src}
+	r.u16() // reserved
+	options := r.u16()
+	numGlyphs := int(r.u16())
+	indexFormat := r.u16()
+	var sizes [7]uint32
+	for i := range sizes {
+		sizes[i] = r.u32()
+	}
+	var streams [7]*woff2Reader
+	for i, n := range sizes {
+		streams[i] = &woff2Reader{data: r.bytes(int(n))}
+	}
+	nContours, nPoints, flagStream, glyphStream, composite, bboxStream, instructions :=
+		streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+	bitmapLen := 4 * ((numGlyphs + 31) / 32)
+	bboxBitmap := bboxStream.bytes(bitmapLen)
+	var overlapBitmap []byte
+	if options&1 != 0 {
+		overlapBitmap = r.bytes((numGlyphs + 7) / 8)
+	}
+	if r.err != nil || bboxStream.err != nil {
+		return nil, nil, nil, errors.New("woff2: invalid glyf table header")
+	}
+	bit := func(bitmap []byte, i int) bool {
+		return bitmap != nil && bitmap[i>>3]&(0x80>>(i&7)) != 0
+	}
+
+	offsets := make([]uint32, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	var (
+		out         []byte
+		endPts      []uint16
+		flags       []byte
+		xs, ys      []int32
+		encodedFlag []byte
+	)
+	for i := 0; i < numGlyphs; i++ {
+		offsets[i] = uint32(len(out))
+		n := int16(nContours.u16())
+		switch {
+		case n == 0:
+			if bit(bboxBitmap, i) {
+				return nil, nil, nil, fmt.Errorf("woff2: empty glyph %d has a bounding box", i)
+			}
+		case n > 0:
+			endPts = endPts[:0]
+			total := 0
+			for c := 0; c < int(n); c++ {
+				total += int(nPoints.u255())
+				if total > 0xffff {
+					return nil, nil, nil, fmt.Errorf("woff2: glyph %d has too many points", i)
+				}
+				endPts = append(endPts, uint16(total-1))
+			}
+			flags = append(flags[:0], flagStream.bytes(total)...)
+			xs, ys = xs[:0], ys[:0]
+			for _, f := range flags {
+				dx, dy := decodeTriplet(f, glyphStream)
+				xs, ys = append(xs, dx), append(ys, dy)
+			}
+			instrLen := int(glyphStream.u255())
+			instr := instructions.bytes(instrLen)
+			var bbox [4]int16
+			if bit(bboxBitmap, i) {
+				for j := range bbox {
+					bbox[j] = int16(bboxStream.u16())
+				}
+			} else {
+				bbox = pointsBounds(xs, ys)
+			}
+			xMins[i] = bbox[0]
+			out = appendU16(out, uint16(n))
+			for _, v := range bbox {
+				out = appendU16(out, uint16(v))
+			}
+			for _, e := range endPts {
+				out = appendU16(out, e)
+			}
+			out = appendU16(out, uint16(instrLen))
+			out = append(out, instr...)
+			encodedFlag = encodedFlag[:0]
+			for j, f := range flags {
+				fl := byte(0)
+				if f&0x80 == 0 {
+					fl |= glyfOnCurve
+				}
+				if j == 0 && bit(overlapBitmap, i) {
+					fl |= glyfOverlapping
+				}
+				fl |= coordFlag(xs[j], glyfXShort, glyfXSame)
+				fl |= coordFlag(ys[j], glyfYShort, glyfYSame)
+				encodedFlag = append(encodedFlag, fl)
+			}
+			out = appendFlags(out, encodedFlag)
+			out = appendCoords(out, xs, encodedFlag, glyfXShort, glyfXSame)
+			out = appendCoords(out, ys, encodedFlag, glyfYShort, glyfYSame)
+		case n == -1:
+			if !bit(bboxBitmap, i) {
+				return nil, nil, nil, fmt.Errorf("woff2: composite glyph %d has no bounding box", i)
+			}
+			out = appendU16(out, uint16(n))
+			for j := 0; j < 4; j++ {
+				v := bboxStream.u16()
+				if j == 0 {
+					xMins[i] = int16(v)
+				}
+				out = appendU16(out, v)
+			}
+			hasInstr := false
+			for {
+				flags := composite.u16()
+				out = appendU16(out, flags)
+				out = appendU16(out, composite.u16()) // glyph index
+				size := 2
+				if flags&compositeArgWords != 0 {
+					size = 4
+				}
+				switch {
+				case flags&compositeScale != 0:
+					size += 2
+				case flags&compositeXYScale != 0:
+					size += 4
+				case flags&compositeTwoByTwo != 0:
+					size += 8
+				}
+				out = append(out, composite.bytes(size)...)
+				hasInstr = hasInstr || flags&compositeInstructions != 0
+				if flags&compositeMore == 0 || composite.err != nil {
+					break
+				}
+			}
+			if hasInstr {
+				instrLen := int(glyphStream.u255())
+				out = appendU16(out, uint16(instrLen))
+				out = append(out, instructions.bytes(instrLen)...)
+			}
+		default:
+			return nil, nil, nil, fmt.Errorf("woff2: invalid contour count for glyph %d", i)
+		}
+		for _, s := range streams {
+			if s.err != nil {
+				return nil, nil, nil, fmt.Errorf("woff2: glyph %d: %w", i, s.err)
+			}
+		}
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	offsets[numGlyphs] = uint32(len(out))
+
+	switch indexFormat {
+	case 0:
+		if len(out)/2 > 0xffff {
+			return nil, nil, nil, errors.New("woff2: glyf table too large for short loca format")
+		}
+		loca = make([]byte, 0, 2*len(offsets))
+		for _, o := range offsets {
+			loca = appendU16(loca, uint16(o/2))
+		}
+	case 1:
+		loca = make([]byte, 0, 4*len(offsets))
+		for _, o := range offsets {
+			loca = binary.BigEndian.AppendUint32(loca, o)
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("woff2: invalid loca format %d", indexFormat)
+	}
+	return out, loca, xMins, nil
+}
+
+// decodeTriplet reads the coordinate deltas of a point from the glyph
+// stream, in the triplet encoding selected by the point flag.
+func decodeTriplet(flag byte, r *woff2Reader) (dx, dy int32) {
+	withSign := func(f byte, v int32) int32 {
+		if f&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	f := flag & 0x7f
+	switch {
+	case f < 10:
+		b0 := int32(r.u8())
+		return 0, withSign(f, int32(f&14)<<7+b0)
+	case f < 20:
+		b0 := int32(r.u8())
+		return withSign(f, int32((f-10)&14)<<7+b0), 0
+	case f < 84:
+		b0, b1 := int32(f-20), int32(r.u8())
+		return withSign(f, 1+(b0&0x30)+b1>>4), withSign(f>>1, 1+(b0&0x0c)<<2+b1&0x0f)
+	case f < 120:
+		b0 := int32(f - 84)
+		b1, b2 := int32(r.u8()), int32(r.u8())
+		return withSign(f, 1+(b0/12)<<8+b1), withSign(f>>1, 1+((b0%12)>>2)<<8+b2)
+	case f < 124:
+		b1, b2, b3 := int32(r.u8()), int32(r.u8()), int32(r.u8())
+		return withSign(f, b1<<4+b2>>4), withSign(f>>1, (b2&0x0f)<<8+b3)
+	default:
+		b1, b2, b3, b4 := int32(r.u8()), int32(r.u8()), int32(r.u8()), int32(r.u8())
+		return withSign(f, b1<<8+b2), withSign(f>>1, b3<<8+b4)
+	}
+}
+
+// pointsBounds computes the bounding box of the points given by their
+// coordinate deltas, as xMin, yMin, xMax, yMax.
+func pointsBounds(dxs, dys []int32) [4]int16 {
+	if len(dxs) == 0 {
+		return [4]int16{}
+	}
+	var x, y int32
+	minX, minY := int32(1<<31-1), int32(1<<31-1)
+	maxX, maxY := -minX, -minY
+	for i := range dxs {
+		x, y = x+dxs[i], y+dys[i]
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+	}
+	return [4]int16{int16(minX), int16(minY), int16(maxX), int16(maxY)}
+}
+
+// coordFlag returns the simple glyph flags for encoding the delta d.
+func coordFlag(d int32, short, same byte) byte {
+	switch {
+	case d == 0:
+		return same
+	case d > 0 && d < 256:
+		return short | same
+	case d < 0 && d > -256:
+		return short
+	default:
+		return 0
+	}
+}
+
+// appendFlags appends simple glyph flags, compressing runs of identical
+// flags.
+func appendFlags(out []byte, flags []byte) []byte {
+	for i := 0; i < len(flags); {
+		f := flags[i]
+		n := 1
+		for i+n < len(flags) && flags[i+n] == f && n < 256 {
+			n++
+		}
+		if n > 1 {
+			out = append(out, f|glyfRepeat, byte(n-1))
+		} else {
+			out = append(out, f)
+		}
+		i += n
+	}
+	return out
+}
+
+// appendCoords appends the coordinate deltas of a simple glyph, in the
+// encoding selected by their flags.
+func appendCoords(out []byte, ds []int32, flags []byte, short, same byte) []byte {
+	for i, d := range ds {
+		switch f := flags[i]; {
+		case f&short != 0:
+			if d < 0 {
+				d = -d
+			}
+			out = append(out, byte(d))
+		case f&same == 0:
+			out = appendU16(out, uint16(d))
+		}
+	}
+	return out
+}
+
+// reconstructHmtx converts a transformed hmtx table back to its original
+// form, deriving omitted left side bearings from the glyph bounds.
+func reconstructHmtx(src []byte, numHMetrics int, xMins []int16) ([]byte, error) {
+	numGlyphs := len(xMins)
+	if numHMetrics < 1 || numHMetrics > numGlyphs {
+		return nil, errors.New("woff2: invalid number of horizontal metrics")
+	}
+	r := &woff2Reader{data: src}
+	flags := r.u8()
+	if flags&^3 != 0 || flags&3 == 0 {
+		return nil, errors.New("woff2: invalid hmtx transform flags")
+	}
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = r.u16()
+	}
+	lsbs := make([]int16, numGlyphs)
+	for i := range lsbs {
+		if (i < numHMetrics && flags&1 != 0) || (i >= numHMetrics && flags&2 != 0) {
+			lsbs[i] = xMins[i]
+		} else {
+			lsbs[i] = int16(r.u16())
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("woff2: hmtx: %w", r.err)
+	}
+	out := make([]byte, 0, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i, lsb := range lsbs {
+		if i < numHMetrics {
+			out = appendU16(out, advances[i])
+		}
+		out = appendU16(out, uint16(lsb))
+	}
+	return out, nil
+}
+
+func appendU16(b []byte, v uint16) []byte {
+	return binary.BigEndian.AppendUint16(b, v)
+}
+
+// woff2Reader reads the big endian and variable length integers of WOFF2
+// data. The first out of bounds read sets err; subsequent reads return zero.
+type woff2Reader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *woff2Reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.off {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *woff2Reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *woff2Reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *woff2Reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// u255 reads a 255UInt16 value.
+func (r *woff2Reader) u255() uint16 {
+	const (
+		wordCode         = 253
+		oneMoreByteCode2 = 254
+		oneMoreByteCode1 = 255
+		lowestUCode      = 253
+	)
+	switch c := r.u8(); c {
+	case wordCode:
+		return r.u16()
+	case oneMoreByteCode1:
+		return uint16(r.u8()) + lowestUCode
+	case oneMoreByteCode2:
+		return uint16(r.u8()) + 2*lowestUCode
+	default:
+		return uint16(c)
+	}
+}
+
+// base128 reads a UIntBase128 value.
+func (r *woff2Reader) base128() uint32 {
+	var v uint32
+	for i := 0; i < 5; i++ {
+		b := r.u8()
+		if r.err != nil {
+			return 0
+		}
+		if (i == 0 && b == 0x80) || v&0xfe000000 != 0 {
+			r.err = errors.New("woff2: invalid UIntBase128 value")
+			return 0
+		}
+		v = v<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = errors.New("woff2: UIntBase128 value too long")
+	return 0
+}
@@ -0,0 +1,472 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package opentype
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-text/typesetting/opentype/api"
+	"github.com/go-text/typesetting/opentype/loader"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestWOFF(t *testing.T) {
+	woff := encodeWOFF(t, goregular.TTF)
+	face, err := Parse(woff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareFaces(t, face, goregular.TTF, true)
+}
+
+func TestWOFF2(t *testing.T) {
+	for _, transform := range []bool{false, true} {
+		woff2 := encodeWOFF2(t, transform, goregular.TTF)
+		face, err := Parse(woff2)
+		if err != nil {
+			t.Fatalf("transform %v: %v", transform, err)
+		}
+		compareFaces(t, face, goregular.TTF, !transform)
+	}
+}
+
+func TestWOFF2Collection(t *testing.T) {
+	woff2 := encodeWOFF2(t, true, goregular.TTF, gobold.TTF)
+	faces, err := ParseCollection(woff2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faces) != 2 {
+		t.Fatalf("got %d faces, want 2", len(faces))
+	}
+	compareFaces(t, faces[0].Face.(Face), goregular.TTF, false)
+	compareFaces(t, faces[1].Face.(Face), gobold.TTF, false)
+}
+
+func TestWOFF2Invalid(t *testing.T) {
+	woff2 := encodeWOFF2(t, true, goregular.TTF)
+	for _, n := range []int{4, woff2HeaderSize, woff2HeaderSize + 10, len(woff2) / 2, len(woff2) - 1} {
+		if _, err := Parse(woff2[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: no error", n)
+		}
+	}
+}
+
+// compareFaces verifies that face has the same glyphs and metrics as the
+// font in ttf. If exact is set, all font tables must be identical.
+func compareFaces(t *testing.T, face Face, ttf []byte, exact bool) {
+	t.Helper()
+	want, err := Parse(ttf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := face.Font(), want.Font(); got != want {
+		t.Errorf("font: got %+v, want %+v", got, want)
+	}
+	for _, tag := range want.ld.Tables() {
+		wt, _ := want.ld.RawTable(tag)
+		gt, err := face.ld.RawTable(tag)
+		if err != nil {
+			t.Errorf("table %s: %v", tag, err)
+			continue
+		}
+		switch {
+		case tag == tagHead:
+			wt, gt = append([]byte(nil), wt...), append([]byte(nil), gt...)
+			binary.BigEndian.PutUint32(wt[8:], 0)
+			binary.BigEndian.PutUint32(gt[8:], 0)
+		case !exact && (tag == tagGlyf || tag == tagLoca):
+			continue
+		}
+		if !bytes.Equal(gt, wt) {
+			t.Errorf("table %s differs", tag)
+		}
+	}
+	gf, wf := face.Face(), want.Face()
+	for gid := 0; gid < numGlyphs(t, want); gid++ {
+		g, w := gf.GlyphData(api.GID(gid)), wf.GlyphData(api.GID(gid))
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("glyph %d: got %v, want %v", gid, g, w)
+		}
+	}
+}
+
+func numGlyphs(t *testing.T, f Face) int {
+	maxp, err := f.ld.RawTable(loader.MustNewTag("maxp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(binary.BigEndian.Uint16(maxp[4:]))
+}
+
+// encodeWOFF encodes an OpenType font as a WOFF file.
+func encodeWOFF(t *testing.T, ttf []byte) []byte {
+	ld, err := loader.NewLoader(bytes.NewReader(ttf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := ld.Tables()
+	header := make([]byte, 44+20*len(tags))
+	var data []byte
+	for i, tag := range tags {
+		table, _ := ld.RawTable(tag)
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(table)
+		w.Close()
+		stored := table
+		if buf.Len() < len(table) {
+			stored = buf.Bytes()
+		}
+		e := header[44+20*i:]
+		binary.BigEndian.PutUint32(e, uint32(tag))
+		binary.BigEndian.PutUint32(e[4:], uint32(len(header)+len(data)))
+		binary.BigEndian.PutUint32(e[8:], uint32(len(stored)))
+		binary.BigEndian.PutUint32(e[12:], uint32(len(table)))
+		data = append(data, stored...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	copy(header, "wOFF")
+	binary.BigEndian.PutUint32(header[4:], 0x00010000)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(header)+len(data)))
+	binary.BigEndian.PutUint16(header[12:], uint16(len(tags)))
+	return append(header, data...)
+}
+
+// encodeWOFF2 encodes OpenType fonts as a WOFF2 file, optionally applying
+// the glyf, loca and hmtx transforms. More than one font results in a
+// collection.
+func encodeWOFF2(t *testing.T, transform bool, ttfs ...[]byte) []byte {
+	type entry struct {
+		tag        loader.Tag
+		version    byte
+		origLength int
+		data       []byte
+	}
+	var (
+		entries []entry
+		fonts   [][]int
+	)
+	for _, ttf := range ttfs {
+		ld, err := loader.NewLoader(bytes.NewReader(ttf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := func(tag string) []byte {
+			table, err := ld.RawTable(loader.MustNewTag(tag))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return table
+		}
+		var glyf, hmtx []byte
+		if transform {
+			var xMins []int16
+			glyf, xMins = transformGlyf(t, raw("glyf"), raw("loca"), raw("head"), raw("maxp"))
+			numHMetrics := int(binary.BigEndian.Uint16(raw("hhea")[34:]))
+			hmtx = transformHmtx(t, raw("hmtx"), numHMetrics, xMins)
+		}
+		var indices []int
+		for _, tag := range ld.Tables() {
+			table, _ := ld.RawTable(tag)
+			e := entry{tag: tag, origLength: len(table), data: table}
+			switch tag {
+			case tagGlyf, tagLoca:
+				e.version = 3
+				if transform {
+					e.version = 0
+					e.data = nil
+					if tag == tagGlyf {
+						e.data = glyf
+					}
+				}
+			case tagHmtx:
+				if hmtx != nil {
+					e.version, e.data = 1, hmtx
+				}
+			}
+			indices = append(indices, len(entries))
+			entries = append(entries, e)
+		}
+		fonts = append(fonts, indices)
+	}
+
+	var dir, data []byte
+	for _, e := range entries {
+		idx := byte(0x3f)
+		for i, known := range woff2KnownTags {
+			if loader.MustNewTag(known) == e.tag {
+				idx = byte(i)
+			}
+		}
+		dir = append(dir, idx|e.version<<6)
+		if idx == 0x3f {
+			dir = binary.BigEndian.AppendUint32(dir, uint32(e.tag))
+		}
+		dir = appendBase128(dir, uint32(e.origLength))
+		if (woff2Table{tag: e.tag, transform: e.version}).transformed() {
+			dir = appendBase128(dir, uint32(len(e.data)))
+		}
+		data = append(data, e.data...)
+	}
+	flavor := uint32(0x00010000)
+	if len(fonts) > 1 {
+		flavor = uint32(tagTTCF)
+		dir = binary.BigEndian.AppendUint32(dir, 0x00020000)
+		dir = append255(dir, len(fonts))
+		for _, indices := range fonts {
+			dir = append255(dir, len(indices))
+			dir = binary.BigEndian.AppendUint32(dir, 0x00010000)
+			for _, idx := range indices {
+				dir = append255(dir, idx)
+			}
+		}
+	}
+	var comp bytes.Buffer
+	w := brotli.NewWriter(&comp)
+	w.Write(data)
+	w.Close()
+
+	header := make([]byte, woff2HeaderSize)
+	copy(header, "wOF2")
+	binary.BigEndian.PutUint32(header[4:], flavor)
+	binary.BigEndian.PutUint32(header[8:], uint32(woff2HeaderSize+len(dir)+comp.Len()))
+	binary.BigEndian.PutUint16(header[12:], uint16(len(entries)))
+	binary.BigEndian.PutUint32(header[20:], uint32(comp.Len()))
+	out := append(header, dir...)
+	return append(out, comp.Bytes()...)
+}
+
+// transformGlyf applies the WOFF2 glyf transform, returning the transformed
+// table and the minimum x coordinate of each glyph.
+func transformGlyf(t *testing.T, glyf, loca, head, maxp []byte) ([]byte, []int16) {
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	indexFormat := binary.BigEndian.Uint16(head[50:])
+	offset := func(i int) int {
+		if indexFormat == 0 {
+			return 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+		}
+		return int(binary.BigEndian.Uint32(loca[4*i:]))
+	}
+	var nContours, nPoints, flags, glyphs, composite, bboxes, instructions []byte
+	bboxBitmap := make([]byte, 4*((numGlyphs+31)/32))
+	xMins := make([]int16, numGlyphs)
+	for i := 0; i < numGlyphs; i++ {
+		g := glyf[offset(i):offset(i+1)]
+		if len(g) == 0 {
+			nContours = appendU16(nContours, 0)
+			continue
+		}
+		n := int16(binary.BigEndian.Uint16(g))
+		nContours = appendU16(nContours, uint16(n))
+		bbox := g[2:10]
+		xMins[i] = int16(binary.BigEndian.Uint16(bbox))
+		if n < 0 {
+			bboxBitmap[i>>3] |= 0x80 >> (i & 7)
+			bboxes = append(bboxes, bbox...)
+			off := 10
+			hasInstr := false
+			for {
+				fl := binary.BigEndian.Uint16(g[off:])
+				size := 6
+				if fl&compositeArgWords != 0 {
+					size = 8
+				}
+				switch {
+				case fl&compositeScale != 0:
+					size += 2
+				case fl&compositeXYScale != 0:
+					size += 4
+				case fl&compositeTwoByTwo != 0:
+					size += 8
+				}
+				composite = append(composite, g[off:off+size]...)
+				off += size
+				hasInstr = hasInstr || fl&compositeInstructions != 0
+				if fl&compositeMore == 0 {
+					break
+				}
+			}
+			if hasInstr {
+				n := int(binary.BigEndian.Uint16(g[off:]))
+				glyphs = append255(glyphs, n)
+				instructions = append(instructions, g[off+2:off+2+n]...)
+			}
+			continue
+		}
+		off := 10
+		last := -1
+		for c := 0; c < int(n); c++ {
+			end := int(binary.BigEndian.Uint16(g[off:]))
+			nPoints = append255(nPoints, end-last)
+			last = end
+			off += 2
+		}
+		numPoints := last + 1
+		instrLen := int(binary.BigEndian.Uint16(g[off:]))
+		instr := g[off+2 : off+2+instrLen]
+		off += 2 + instrLen
+		pointFlags := make([]byte, 0, numPoints)
+		for len(pointFlags) < numPoints {
+			f := g[off]
+			off++
+			pointFlags = append(pointFlags, f)
+			if f&glyfRepeat != 0 {
+				for r := g[off]; r > 0; r-- {
+					pointFlags = append(pointFlags, f)
+				}
+				off++
+			}
+		}
+		readCoords := func(short, same byte) []int32 {
+			ds := make([]int32, numPoints)
+			for j, f := range pointFlags {
+				switch {
+				case f&short != 0:
+					ds[j] = int32(g[off])
+					if f&same == 0 {
+						ds[j] = -ds[j]
+					}
+					off++
+				case f&same == 0:
+					ds[j] = int32(int16(binary.BigEndian.Uint16(g[off:])))
+					off += 2
+				}
+			}
+			return ds
+		}
+		dxs := readCoords(glyfXShort, glyfXSame)
+		dys := readCoords(glyfYShort, glyfYSame)
+		for j := range pointFlags {
+			flag, data := encodeTriplet(dxs[j], dys[j], pointFlags[j]&glyfOnCurve != 0)
+			flags = append(flags, flag)
+			glyphs = append(glyphs, data...)
+		}
+		glyphs = append255(glyphs, instrLen)
+		instructions = append(instructions, instr...)
+		// Exercise explicit bounding boxes for a fraction of the glyphs.
+		computed := pointsBounds(dxs, dys)
+		var want [4]int16
+		for j := range want {
+			want[j] = int16(binary.BigEndian.Uint16(bbox[2*j:]))
+		}
+		if computed != want || i%7 == 0 {
+			bboxBitmap[i>>3] |= 0x80 >> (i & 7)
+			bboxes = append(bboxes, bbox...)
+		}
+	}
+	bboxes = append(bboxBitmap, bboxes...)
+	out := appendU16(nil, 0)
+	out = appendU16(out, 0)
+	out = appendU16(out, uint16(numGlyphs))
+	out = appendU16(out, indexFormat)
+	streams := [][]byte{nContours, nPoints, flags, glyphs, composite, bboxes, instructions}
+	for _, s := range streams {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(s)))
+	}
+	for _, s := range streams {
+		out = append(out, s...)
+	}
+	return out, xMins
+}
+
+// encodeTriplet encodes a coordinate delta in the WOFF2 triplet encoding.
+func encodeTriplet(dx, dy int32, onCurve bool) (byte, []byte) {
+	var flag byte
+	if !onCurve {
+		flag = 0x80
+	}
+	ax, ay := dx, dy
+	var xSign, ySign byte
+	if dx < 0 {
+		ax = -dx
+	} else {
+		xSign = 1
+	}
+	if dy < 0 {
+		ay = -dy
+	} else {
+		ySign = 1
+	}
+	signs := xSign + 2*ySign
+	switch {
+	case dx == 0 && ay < 1280:
+		return flag + byte((ay&0xf00)>>7) + ySign, []byte{byte(ay)}
+	case dy == 0 && ax < 1280:
+		return flag + 10 + byte((ax&0xf00)>>7) + xSign, []byte{byte(ax)}
+	case ax < 65 && ay < 65:
+		return flag + 20 + byte((ax-1)&0x30) + byte(((ay-1)&0x30)>>2) + signs,
+			[]byte{byte((ax-1)&0xf)<<4 | byte((ay-1)&0xf)}
+	case ax < 769 && ay < 769:
+		return flag + 84 + 12*byte(((ax-1)&0x300)>>8) + byte(((ay-1)&0x300)>>6) + signs,
+			[]byte{byte(ax - 1), byte(ay - 1)}
+	case ax < 4096 && ay < 4096:
+		return flag + 120 + signs, []byte{byte(ax >> 4), byte(ax&0xf)<<4 | byte(ay>>8), byte(ay)}
+	default:
+		return flag + 124 + signs, []byte{byte(ax >> 8), byte(ax), byte(ay >> 8), byte(ay)}
+	}
+}
+
+// transformHmtx applies the WOFF2 hmtx transform, omitting the left side
+// bearings that equal the glyph minimum x coordinates.
+func transformHmtx(t *testing.T, hmtx []byte, numHMetrics int, xMins []int16) []byte {
+	lsb := func(i int) int16 {
+		if i < numHMetrics {
+			return int16(binary.BigEndian.Uint16(hmtx[4*i+2:]))
+		}
+		return int16(binary.BigEndian.Uint16(hmtx[4*numHMetrics+2*(i-numHMetrics):]))
+	}
+	flags := byte(3)
+	for i := range xMins {
+		if lsb(i) != xMins[i] {
+			if i < numHMetrics {
+				flags &^= 1
+			} else {
+				flags &^= 2
+			}
+		}
+	}
+	if flags == 0 {
+		t.Fatal("font has no left side bearings to omit")
+	}
+	out := []byte{flags}
+	for i := 0; i < numHMetrics; i++ {
+		out = append(out, hmtx[4*i:4*i+2]...)
+	}
+	for i := range xMins {
+		if (i < numHMetrics && flags&1 == 0) || (i >= numHMetrics && flags&2 == 0) {
+			out = appendU16(out, uint16(lsb(i)))
+		}
+	}
+	return out
+}
+
+func appendBase128(b []byte, v uint32) []byte {
+	var tmp []byte
+	for {
+		tmp = append([]byte{byte(v & 0x7f)}, tmp...)
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := range tmp[:len(tmp)-1] {
+		tmp[i] |= 0x80
+	}
+	return append(b, tmp...)
+}
+
+func append255(b []byte, v int) []byte {
+	if v < 253 {
+		return append(b, byte(v))
+	}
+	return appendU16(append(b, 253), uint16(v))
+}
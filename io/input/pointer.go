@@ -73,6 +73,9 @@ type pointerFilter struct {
 	kinds pointer.Kind
 	// min and max horizontal/vertical scroll
 	scrollX, scrollY pointer.ScrollRange
+	// dragOver tracks whether drags of pointers pressed elsewhere are
+	// delivered.
+	dragOver bool
 
 	sourceMimes []string
 	targetMimes []string
@@ -299,6 +302,7 @@ func (p *pointerFilter) Add(f event.Filter) {
 		p.kinds = p.kinds | f.Kinds
 		p.scrollX = p.scrollX.Union(f.ScrollX)
 		p.scrollY = p.scrollY.Union(f.ScrollY)
+		p.dragOver = p.dragOver || f.DragOver
 	}
 }
 
@@ -328,6 +332,7 @@ func (p *pointerFilter) Merge(p2 pointerFilter) {
 	p.kinds = p.kinds | p2.kinds
 	p.scrollX = p.scrollX.Union(p2.scrollX)
 	p.scrollY = p.scrollY.Union(p2.scrollY)
+	p.dragOver = p.dragOver || p2.dragOver
 	p.sourceMimes = append(p.sourceMimes, p2.sourceMimes...)
 	p.targetMimes = append(p.targetMimes, p2.targetMimes...)
 }
@@ -754,6 +759,7 @@ func (q *pointerQueue) Push(handlers map[event.Tag]*handler, state pointerState,
 		p, evts, state.cursor, _ = q.deliverEnterLeaveEvents(handlers, state.cursor, p, evts, e)
 		evts = q.deliverEvent(handlers, p, evts, e)
 		if p.pressed {
+			evts = q.deliverDragOverEvent(handlers, p, evts, e)
 			p, evts = q.deliverDragEvent(handlers, p, evts)
 		}
 	case pointer.Release:
@@ -813,6 +819,30 @@ func (q *pointerQueue) deliverEvent(handlers map[event.Tag]*handler, p pointerIn
 	return evts
 }
 
+// deliverDragOverEvent delivers the Drag event e to the handlers under the
+// pointer that accept drags over them and don't receive the events of the
+// pointer already.
+func (q *pointerQueue) deliverDragOverEvent(handlers map[event.Tag]*handler, p pointerInfo, evts []taggedEvent, e pointer.Event) []taggedEvent {
+	var hits []event.Tag
+	q.hitTest(e.Position, func(n *hitNode) bool {
+		h, ok := handlers[n.tag]
+		if !ok || !h.filter.pointer.dragOver || !h.filter.pointer.Matches(e) {
+			return true
+		}
+		if _, found := searchTag(p.handlers, n.tag); found {
+			return true
+		}
+		hits = addHandler(hits, n.tag)
+		return true
+	})
+	for _, k := range hits {
+		e := e
+		e.Position = q.invTransform(handlers[k].pointer.areaPlusOne-1, e.Position)
+		evts = append(evts, taggedEvent{event: e, tag: k})
+	}
+	return evts
+}
+
 func (q *pointerQueue) deliverEnterLeaveEvents(handlers map[event.Tag]*handler, cursor pointer.Cursor, p pointerInfo, evts []taggedEvent, e pointer.Event) (pointerInfo, []taggedEvent, pointer.Cursor, bool) {
 	changed := false
 	var hits []event.Tag
@@ -75,6 +75,43 @@ func TestPointerDrag(t *testing.T) {
 	assertEventPointerTypeSequence(t, events(&r, -1, f), pointer.Enter, pointer.Press, pointer.Leave, pointer.Drag)
 }
 
+func TestPointerDragOver(t *testing.T) {
+	handler1, handler2, handler3 := new(int), new(int), new(int)
+	var ops op.Ops
+	var r Router
+	f1 := addPointerHandler(&r, &ops, handler1, image.Rect(0, 0, 100, 100))
+	f2 := pointer.Filter{Target: handler2, Kinds: pointer.Drag, DragOver: true}
+	f3 := pointer.Filter{Target: handler3, Kinds: pointer.Drag}
+	events(&r, -1, f2, f3)
+	// Register both handlers in the area below the first.
+	stack := clip.Rect(image.Rect(0, 100, 100, 200)).Push(&ops)
+	event.Op(&ops, handler3)
+	event.Op(&ops, handler2)
+	stack.Pop()
+
+	r.Frame(&ops)
+	r.Queue(
+		pointer.Event{
+			Kind:     pointer.Press,
+			Position: f32.Pt(50, 50),
+		},
+		// Move over the other areas.
+		pointer.Event{
+			Kind:     pointer.Move,
+			Position: f32.Pt(50, 150),
+		},
+	)
+	assertEventPointerTypeSequence(t, events(&r, -1, f1), pointer.Enter, pointer.Press, pointer.Leave, pointer.Drag)
+	got := events(&r, -1, f2)
+	assertEventPointerTypeSequence(t, got, pointer.Drag)
+	if len(got) == 1 {
+		if pos := got[0].(pointer.Event).Position; pos != f32.Pt(50, 150) {
+			t.Errorf("drag over position %v, want %v", pos, f32.Pt(50, 150))
+		}
+	}
+	assertEventPointerTypeSequence(t, events(&r, -1, f3))
+}
+
 func TestPointerDragNegative(t *testing.T) {
 	handler := new(int)
 	var ops op.Ops
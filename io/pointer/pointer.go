@@ -67,6 +67,10 @@ type Filter struct {
 	// ScrollY.Min <= e.Scroll.Y <= ScrollY.Max (vertical axis)
 	ScrollX ScrollRange
 	ScrollY ScrollRange
+	// DragOver extends the Drag events delivered to Target to those of
+	// pointers pressed outside of it while they move over it, such as for
+	// extending a text selection across widgets.
+	DragOver bool
 }
 
 // ScrollRange describes the range of scrolling distances in an
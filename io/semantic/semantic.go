@@ -30,6 +30,10 @@ const (
 	Editor
 	RadioButton
 	Switch
+	Table
+	ColumnHeader
+	Row
+	Cell
 )
 
 // SelectedOp describes the selected state for components that have
@@ -85,6 +89,14 @@ func (c ClassOp) String() string {
 		return "RadioButton"
 	case Switch:
 		return "Switch"
+	case Table:
+		return "Table"
+	case ColumnHeader:
+		return "ColumnHeader"
+	case Row:
+		return "Row"
+	case Cell:
+		return "Cell"
 	default:
 		panic("invalid ClassOp")
 	}
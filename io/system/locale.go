@@ -12,6 +12,7 @@ type Locale struct {
 const (
 	axisShift = iota
 	progressionShift
+	lineProgressionShift
 )
 
 // TextDirection defines a direction for text flow.
@@ -22,6 +23,12 @@ const (
 	LTR TextDirection = TextDirection(Horizontal<<axisShift) | TextDirection(FromOrigin<<progressionShift)
 	// RTL is right-to-left text.
 	RTL TextDirection = TextDirection(Horizontal<<axisShift) | TextDirection(TowardOrigin<<progressionShift)
+	// VerticalRL is top-to-bottom text with lines stacked from right to left,
+	// as in traditional Chinese, Japanese and Korean text.
+	VerticalRL TextDirection = TextDirection(Vertical<<axisShift) | TextDirection(FromOrigin<<progressionShift) | TextDirection(TowardOrigin<<lineProgressionShift)
+	// VerticalLR is top-to-bottom text with lines stacked from left to right,
+	// as in traditional Mongolian text.
+	VerticalLR TextDirection = TextDirection(Vertical<<axisShift) | TextDirection(FromOrigin<<progressionShift) | TextDirection(FromOrigin<<lineProgressionShift)
 )
 
 // Axis returns the axis of the text layout.
@@ -34,10 +41,20 @@ func (d TextDirection) Progression() TextProgression {
 	return TextProgression((d & (1 << progressionShift)) >> progressionShift)
 }
 
+// LineProgression returns the way that successive lines of text are stacked
+// relative to the origin, along the axis perpendicular to Axis.
+func (d TextDirection) LineProgression() TextProgression {
+	return TextProgression((d & (1 << lineProgressionShift)) >> lineProgressionShift)
+}
+
 func (d TextDirection) String() string {
 	switch d {
 	case RTL:
 		return "RTL"
+	case VerticalRL:
+		return "VerticalRL"
+	case VerticalLR:
+		return "VerticalLR"
 	default:
 		return "LTR"
 	}
@@ -47,3 +47,20 @@ func TestFlexAllocs(t *testing.T) {
 		t.Errorf("expected no allocs, got %f", allocs)
 	}
 }
+
+func TestWrapAllocs(t *testing.T) {
+	var ops op.Ops
+	allocs := testing.AllocsPerRun(1, func() {
+		ops.Reset()
+		gtx := Context{
+			Ops:         &ops,
+			Constraints: Constraints{Max: image.Point{X: 100, Y: 100}},
+		}
+		Wrap{}.Layout(gtx, 3, func(gtx Context, i int) Dimensions {
+			return Dimensions{Size: image.Point{X: 50, Y: 50}}
+		})
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocs, got %f", allocs)
+	}
+}
@@ -3,6 +3,7 @@
 package layout
 
 import (
+	"sync"
 	"time"
 
 	"gioui.org/io/event"
@@ -30,10 +31,17 @@ type Context struct {
 	Locale system.Locale
 
 	disabled bool
+	// measuring is set for contexts created by Measure.
+	measuring bool
 	input.Source
 	*op.Ops
 }
 
+// measureOps is a pool of scratch operation lists for Measure.
+var measureOps = sync.Pool{
+	New: func() any { return new(op.Ops) },
+}
+
 // Dp converts v to pixels.
 func (c Context) Dp(v unit.Dp) int {
 	return c.Metric.Dp(v)
@@ -62,3 +70,41 @@ func (c Context) Disabled() Context {
 	c.disabled = true
 	return c
 }
+
+// Measure lays out w with the constraints of c in measurement mode, and
+// returns its dimensions. In measurement mode, w receives no events, doesn't
+// execute commands and its operations are discarded. Use Measure to size a
+// widget to its content before laying it out.
+func (c Context) Measure(w Widget) Dimensions {
+	ops := measureOps.Get().(*op.Ops)
+	defer func() {
+		ops.Reset()
+		measureOps.Put(ops)
+	}()
+	c.Source = input.Source{}
+	c.Ops = ops
+	c.measuring = true
+	return w(c)
+}
+
+// Measuring reports whether c is in measurement mode, where widgets may skip
+// work that doesn't affect their dimensions. Stateful widgets should not
+// update their state when measured.
+func (c Context) Measuring() bool {
+	return c.measuring
+}
+
+// Intrinsic measures the size of w along axis. The minimum size, min, is
+// measured with no minimum constraint along axis, and is the size w takes
+// when sized to its content within the maximum constraint of c. The maximum
+// size, max, is measured with no maximum constraint along axis, such as the
+// width of a label laid out on a single line.
+func (c Context) Intrinsic(axis Axis, w Widget) (min, max int) {
+	crossMin, crossMax := axis.crossConstraint(c.Constraints)
+	_, mainMax := axis.mainConstraint(c.Constraints)
+	c.Constraints = axis.constraints(0, mainMax, crossMin, crossMax)
+	min = axis.Convert(c.Measure(w).Size).X
+	c.Constraints = axis.constraints(0, inf, crossMin, crossMax)
+	max = axis.Convert(c.Measure(w).Size).X
+	return min, max
+}
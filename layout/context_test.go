@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package layout
+
+import (
+	"image"
+	"testing"
+
+	"gioui.org/io/input"
+	"gioui.org/io/key"
+	"gioui.org/io/semantic"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+)
+
+func TestMeasure(t *testing.T) {
+	var r input.Router
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Constraints{Max: image.Pt(100, 100)},
+		Source:      r.Source(),
+	}
+	tag := new(int)
+	measured := false
+	w := func(gtx Context) Dimensions {
+		measured = gtx.Measuring()
+		gtx.Execute(key.FocusCmd{Tag: tag})
+		if _, ok := gtx.Event(key.FocusFilter{Target: tag}); ok && measured {
+			t.Error("measured widget received an event")
+		}
+		sz := gtx.Constraints.Constrain(image.Pt(200, 20))
+		defer clip.Rect{Max: sz}.Push(gtx.Ops).Pop()
+		semantic.DescriptionOp("measured").Add(gtx.Ops)
+		return Dimensions{Size: sz}
+	}
+	dims := gtx.Measure(w)
+	if !measured {
+		t.Error("Measuring reported false")
+	}
+	if dims.Size != image.Pt(100, 20) {
+		t.Errorf("got size %v, want (100,20)", dims.Size)
+	}
+	r.Frame(gtx.Ops)
+	if gtx.Focused(tag) {
+		t.Error("measured widget executed a command")
+	}
+	for _, n := range r.AppendSemantics(nil) {
+		if n.Desc.Description != "" {
+			t.Errorf("measured widget emitted operations: %+v", n.Desc)
+		}
+	}
+
+	min, max := gtx.Intrinsic(Horizontal, w)
+	if min != 100 || max != 200 {
+		t.Errorf("got intrinsic widths %d, %d, want 100, 200", min, max)
+	}
+	if w(gtx); measured {
+		t.Error("Measuring reported true outside Measure")
+	}
+}
+
+func TestMeasureList(t *testing.T) {
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Exact(image.Pt(100, 100)),
+	}
+	l := List{Axis: Vertical, ScrollToEnd: true}
+	el := func(gtx Context, i int) Dimensions {
+		return Dimensions{Size: image.Pt(100, 30)}
+	}
+	l.Layout(gtx, 10, el)
+	pos := l.Position
+	gtx.Measure(func(gtx Context) Dimensions {
+		return l.Layout(gtx, 20, el)
+	})
+	if l.Position != pos {
+		t.Errorf("measuring changed the list position from %+v to %+v", pos, l.Position)
+	}
+}
@@ -43,7 +43,7 @@ This example both aligns and insets a child:
 		})
 	})
 
-More complex layouts such as Stack and Flex lay out multiple children,
-and stateful layouts such as List accept user input.
+More complex layouts such as Stack, Flex and Wrap lay out multiple children,
+and stateful layouts such as List and Grid accept user input.
 */
 package layout
@@ -6,16 +6,20 @@ import (
 	"image"
 
 	"gioui.org/op"
+	"gioui.org/unit"
 )
 
 // Flex lays out child elements along an axis,
-// according to alignment and weights.
+// according to alignment and weights. Use Wrap to break
+// children onto several lines.
 type Flex struct {
 	// Axis is the main axis, either Horizontal or Vertical.
 	Axis Axis
 	// Spacing controls the distribution of space left after
 	// layout.
 	Spacing Spacing
+	// Gap is the space between children along the main axis.
+	Gap unit.Dp
 	// Alignment is the alignment in the cross axis.
 	Alignment Alignment
 	// WeightSum is the sum of weights used for the weighted
@@ -28,6 +32,8 @@ type Flex struct {
 type FlexChild struct {
 	flex   bool
 	weight float32
+	// shrink is the weight of a Rigid child shrunk to fit.
+	shrink float32
 
 	widget Widget
 
@@ -65,6 +71,19 @@ func Rigid(widget Widget) FlexChild {
 	}
 }
 
+// Shrinkable returns a Flex child like Rigid, except that it is laid out
+// with a maximal constraint of the full Flex, and shrunk when the Rigid
+// children overflow the Flex. The overflow is taken from the Shrinkable
+// children in proportion to weight times their size, and a shrunk child is
+// laid out again with its shrunk size as maximal constraint, for example
+// for a label to wrap its text.
+func Shrinkable(weight float32, widget Widget) FlexChild {
+	return FlexChild{
+		shrink: weight,
+		widget: widget,
+	}
+}
+
 // Flexed returns a Flex child forced to take up weight fraction of the
 // space left over from Rigid children. The fraction is weight
 // divided by either the weight sum of all Flexed children or the Flex
@@ -79,13 +98,18 @@ func Flexed(weight float32, widget Widget) FlexChild {
 
 // Layout a list of children. The position of the children are
 // determined by the specified order, but Rigid children are laid out
-// before Flexed children.
+// before Shrinkable children, which are laid out before Flexed children.
 func (f Flex) Layout(gtx Context, children ...FlexChild) Dimensions {
-	size := 0
 	cs := gtx.Constraints
 	mainMin, mainMax := f.Axis.mainConstraint(cs)
 	crossMin, crossMax := f.Axis.crossConstraint(cs)
-	remaining := mainMax
+	gap := gtx.Dp(f.Gap)
+	// size includes the gaps between children.
+	size := 0
+	if len(children) > 1 {
+		size = gap * (len(children) - 1)
+	}
+	remaining := max(mainMax-size, 0)
 	var totalWeight float32
 	cgtx := gtx
 	// Lay out Rigid children.
@@ -94,6 +118,9 @@ func (f Flex) Layout(gtx Context, children ...FlexChild) Dimensions {
 			totalWeight += child.weight
 			continue
 		}
+		if child.shrink > 0 {
+			continue
+		}
 		macro := op.Record(gtx.Ops)
 		cgtx.Constraints = f.Axis.constraints(0, remaining, crossMin, crossMax)
 		dims := child.widget(cgtx)
@@ -107,6 +134,47 @@ func (f Flex) Layout(gtx Context, children ...FlexChild) Dimensions {
 		children[i].call = c
 		children[i].dims = dims
 	}
+	// Lay out Shrinkable children, and shrink them if they overflow.
+	shrinkSize, shrinkWeight := 0, float32(0)
+	for i, child := range children {
+		if child.flex || child.shrink <= 0 {
+			continue
+		}
+		macro := op.Record(gtx.Ops)
+		cgtx.Constraints = f.Axis.constraints(0, mainMax, crossMin, crossMax)
+		dims := child.widget(cgtx)
+		c := macro.Stop()
+		sz := f.Axis.Convert(dims.Size).X
+		shrinkSize += sz
+		shrinkWeight += child.shrink * float32(sz)
+		children[i].call = c
+		children[i].dims = dims
+	}
+	overflow := shrinkSize - remaining
+	// shrinkFraction is the rounding error from shrinking.
+	var shrinkFraction float32
+	for i, child := range children {
+		if child.flex || child.shrink <= 0 {
+			continue
+		}
+		if overflow > 0 && shrinkWeight > 0 {
+			sz := f.Axis.Convert(child.dims.Size).X
+			childShrink := float32(overflow)*child.shrink*float32(sz)/shrinkWeight + shrinkFraction
+			shrink := int(childShrink + .5)
+			shrinkFraction = childShrink - float32(shrink)
+			macro := op.Record(gtx.Ops)
+			cgtx.Constraints = f.Axis.constraints(0, max(sz-shrink, 0), crossMin, crossMax)
+			dims := child.widget(cgtx)
+			children[i].call = macro.Stop()
+			children[i].dims = dims
+		}
+		sz := f.Axis.Convert(children[i].dims.Size).X
+		size += sz
+		remaining -= sz
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
 	if w := f.WeightSum; w != 0 {
 		totalWeight = w
 	}
@@ -143,81 +211,57 @@ func (f Flex) Layout(gtx Context, children ...FlexChild) Dimensions {
 		children[i].dims = dims
 	}
 	maxCross := crossMin
-	var maxBaseline int
+	// maxBaseline and maxDescent are the largest distances from the aligned
+	// baselines to the top and bottom of the children.
+	var maxBaseline, maxDescent int
+	alignBaseline := f.Axis == Horizontal && (f.Alignment == Baseline || f.Alignment == LastBaseline)
 	for _, child := range children {
 		if c := f.Axis.Convert(child.dims.Size).Y; c > maxCross {
 			maxCross = c
 		}
-		if b := child.dims.Size.Y - child.dims.Baseline; b > maxBaseline {
-			maxBaseline = b
+		if alignBaseline {
+			b := f.Alignment.baseline(child.dims)
+			maxBaseline = max(maxBaseline, b)
+			maxDescent = max(maxDescent, child.dims.Size.Y-b)
 		}
 	}
+	if alignBaseline {
+		maxCross = max(maxCross, maxBaseline+maxDescent)
+	}
 	var space int
 	if mainMin > size {
 		space = mainMin - size
 	}
-	var mainSize int
-	switch f.Spacing {
-	case SpaceSides:
-		mainSize += space / 2
-	case SpaceStart:
-		mainSize += space
-	case SpaceEvenly:
-		mainSize += space / (1 + len(children))
-	case SpaceAround:
-		if len(children) > 0 {
-			mainSize += space / (len(children) * 2)
-		}
-	}
+	mainSize, between := f.Spacing.distribute(space, len(children))
+	var bl baselines
 	for i, child := range children {
 		dims := child.dims
-		b := dims.Size.Y - dims.Baseline
 		var cross int
 		switch f.Alignment {
 		case End:
 			cross = maxCross - f.Axis.Convert(dims.Size).Y
 		case Middle:
 			cross = (maxCross - f.Axis.Convert(dims.Size).Y) / 2
-		case Baseline:
-			if f.Axis == Horizontal {
-				cross = maxBaseline - b
+		case Baseline, LastBaseline:
+			if alignBaseline {
+				cross = maxBaseline - f.Alignment.baseline(dims)
 			}
 		}
 		pt := f.Axis.Convert(image.Pt(mainSize, cross))
+		bl.add(dims, pt.Y)
 		trans := op.Offset(pt).Push(gtx.Ops)
 		child.call.Add(gtx.Ops)
 		trans.Pop()
 		mainSize += f.Axis.Convert(dims.Size).X
 		if i < len(children)-1 {
-			switch f.Spacing {
-			case SpaceEvenly:
-				mainSize += space / (1 + len(children))
-			case SpaceAround:
-				if len(children) > 0 {
-					mainSize += space / len(children)
-				}
-			case SpaceBetween:
-				if len(children) > 1 {
-					mainSize += space / (len(children) - 1)
-				}
-			}
-		}
-	}
-	switch f.Spacing {
-	case SpaceSides:
-		mainSize += space / 2
-	case SpaceEnd:
-		mainSize += space
-	case SpaceEvenly:
-		mainSize += space / (1 + len(children))
-	case SpaceAround:
-		if len(children) > 0 {
-			mainSize += space / (len(children) * 2)
+			mainSize += gap + between
 		}
 	}
+	// Leave the remaining space at the end.
+	mainSize = max(mainSize, size+space)
 	sz := f.Axis.Convert(image.Pt(mainSize, maxCross))
 	sz = cs.Constrain(sz)
-	return Dimensions{Size: sz, Baseline: sz.Y - maxBaseline}
+	return bl.dimensions(sz)
 }
 
 func (s Spacing) String() string {
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package layout_test
+
+import (
+	"image"
+	"testing"
+
+	"gioui.org/font"
+	"gioui.org/font/gofont"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+	"gioui.org/widget"
+)
+
+func TestFlexShrinkWrap(t *testing.T) {
+	shaper := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(300, 1000)},
+	}
+	var labelDims layout.Dimensions
+	label := func(gtx layout.Context) layout.Dimensions {
+		labelDims = widget.Label{}.Layout(gtx, shaper, font.Font{}, 16, "a label wrapped over several lines", op.CallOp{})
+		return labelDims
+	}
+	line := label(gtx)
+	if line.Size.X <= 150 || line.Size.X >= 300 {
+		t.Fatalf("label width %d doesn't fit the test", line.Size.X)
+	}
+	rigid := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: image.Pt(150, 10)}
+	}
+	dims := layout.Flex{}.Layout(gtx, layout.Rigid(rigid), layout.Shrinkable(1, label))
+	if labelDims.Size.X > 150 {
+		t.Errorf("shrunk label width %d, want at most 150", labelDims.Size.X)
+	}
+	if labelDims.Size.Y <= line.Size.Y {
+		t.Errorf("shrunk label height %d, want more than a line of %d", labelDims.Size.Y, line.Size.Y)
+	}
+	if want := image.Pt(150+labelDims.Size.X, labelDims.Size.Y); dims.Size != want {
+		t.Errorf("got size %v, want %v", dims.Size, want)
+	}
+}
@@ -0,0 +1,469 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package layout
+
+import (
+	"image"
+
+	"gioui.org/gesture"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+)
+
+// Grid displays the visible cells of a potentially very large grid of rows
+// and columns. Grid accepts user input to scroll the cells horizontally and
+// vertically. Leading rows and columns can be locked in place, such as the
+// header row of a table.
+type Grid struct {
+	// LockedRows and LockedCols are the number of leading rows and columns
+	// that stay in view when the grid is scrolled.
+	LockedRows, LockedCols int
+	// RowHeight, if not nil, returns the height of a row. Otherwise, rows
+	// are as high as their highest cell.
+	RowHeight func(gtx Context, row int) int
+	// ColWidth, if not nil, returns the width of a column. Otherwise,
+	// columns are as wide as their widest cell laid out so far.
+	ColWidth func(gtx Context, col int) int
+	// Alignment is the alignment of the cells in their row. Cells are as
+	// high as their row for the default alignment, Start. For the other
+	// alignments, cells are laid out with a minimum height of zero and
+	// aligned within their row.
+	Alignment Alignment
+	// RowArea, if not nil, is called for every visible row with the clip
+	// area of the visible part of the row pushed, before the cells of the
+	// row are added inside it. It is for adding the operations of a row,
+	// such as its semantic description or a pointer handler for clicking
+	// the row. The coordinates are those of the grid.
+	RowArea func(gtx Context, row int)
+
+	// Position is updated during Layout. To save the grid scroll position,
+	// just save Position after Layout finishes. To scroll the grid
+	// programmatically, update Position (e.g. restore it from a saved value)
+	// before calling Layout.
+	Position GridPosition
+
+	hscroll, vscroll gesture.Scroll
+	// overflow is the size of the scrolled cells beyond the grid in the
+	// previous layout.
+	overflow image.Point
+	dx, dy   int
+
+	rows, cols int
+	cell       GridCell
+	// colWidths and rowHeights hold the measured sizes of columns and rows.
+	colWidths, rowHeights map[int]int
+	// visRows and visCols are the rows and columns being laid out.
+	visRows, visCols []gridLine
+	cells            []gridCell
+}
+
+// GridCell is a function that computes the dimensions of the cell at a row
+// and column of a grid. The constraints of a cell are at least the size of
+// its row and column, and at most that size unless the size is measured.
+type GridCell func(gtx Context, row, col int) Dimensions
+
+// GridPosition is a Grid scroll offset, represented as the offsets from the
+// edges of the first row and column scrolled into view, excluding the
+// locked rows and columns.
+type GridPosition struct {
+	// Row and Col are the indices of the first visible rows and columns
+	// after the locked ones.
+	Row, Col int
+	// OffsetY is the distance in pixels from the top edge of the scrolled
+	// area to the top edge of Row, and OffsetX the distance from its left
+	// edge to the left edge of Col.
+	OffsetX, OffsetY int
+	// Rows and Cols are the number of visible rows and columns, excluding
+	// the locked ones.
+	Rows, Cols int
+	// Width and Height are the estimated total size of the grid, in pixels.
+	Width, Height int
+}
+
+// gridLine is a visible row or column.
+type gridLine struct {
+	index int
+	size  int
+	// pos is the position of the line in the grid, set by layout.
+	pos int
+	// baseline is the distance from the top of a row to the baseline of its
+	// cells, for the baseline alignments.
+	baseline int
+}
+
+// gridCell is a laid out cell, identified by its visible row and column.
+type gridCell struct {
+	row, col int
+	call     op.CallOp
+	dims     Dimensions
+}
+
+// Layout a Grid of rows × cols cells, where each cell is implicitly defined
+// by the callback cell. Like List, Layout can handle very large grids
+// because it only calls cell for the visible cells, plus the cells measured
+// with [Context.Measure] when the sizes of rows and columns are not
+// specified.
+func (g *Grid) Layout(gtx Context, rows, cols int, cell GridCell) Dimensions {
+	if gtx.Measuring() {
+		defer func(p GridPosition, overflow image.Point) {
+			g.Position, g.overflow = p, overflow
+		}(g.Position, g.overflow)
+	}
+	g.rows, g.cols, g.cell = rows, cols, cell
+	lockedRows, lockedCols := min(g.LockedRows, rows), min(g.LockedCols, cols)
+	g.update(gtx, lockedRows, lockedCols)
+	view := gtx.Constraints.Max
+
+	g.visCols = g.visCols[:0]
+	lockedW := 0
+	for c := 0; c < lockedCols; c++ {
+		w := g.colWidth(gtx, c)
+		g.visCols = append(g.visCols, gridLine{index: c, size: w})
+		lockedW += w
+	}
+	colSize := func(c int) int { return g.colWidth(gtx, c) }
+	p := &g.Position
+	p.Cols = scrollLines(&p.Col, &p.OffsetX, lockedCols, cols, view.X-lockedW, colSize)
+	for c := p.Col; c < p.Col+p.Cols; c++ {
+		g.visCols = append(g.visCols, gridLine{index: c, size: g.colWidth(gtx, c)})
+	}
+
+	g.visRows = g.visRows[:0]
+	lockedH := 0
+	for r := 0; r < lockedRows; r++ {
+		h := g.rowHeight(gtx, r)
+		g.visRows = append(g.visRows, gridLine{index: r, size: h})
+		lockedH += h
+	}
+	rowSize := func(r int) int { return g.rowHeight(gtx, r) }
+	p.Rows = scrollLines(&p.Row, &p.OffsetY, lockedRows, rows, view.Y-lockedH, rowSize)
+	for r := p.Row; r < p.Row+p.Rows; r++ {
+		g.visRows = append(g.visRows, gridLine{index: r, size: g.rowHeight(gtx, r)})
+	}
+
+	// Lay out the cells, growing the measured rows and columns to fit them.
+	g.cells = g.cells[:0]
+	for i, row := range g.visRows {
+		for j, col := range g.visCols {
+			cgtx := gtx
+			cgtx.Constraints = Constraints{
+				Min: image.Pt(col.size, row.size),
+				Max: image.Pt(col.size, row.size),
+			}
+			if g.ColWidth == nil {
+				cgtx.Constraints.Max.X = inf
+			}
+			if g.RowHeight == nil {
+				cgtx.Constraints.Max.Y = inf
+			}
+			if g.Alignment != Start {
+				cgtx.Constraints.Min.Y = 0
+			}
+			macro := op.Record(gtx.Ops)
+			dims := cell(cgtx, row.index, col.index)
+			call := macro.Stop()
+			g.cells = append(g.cells, gridCell{row: i, col: j, call: call, dims: dims})
+			if g.ColWidth == nil && dims.Size.X > col.size {
+				g.visCols[j].size = dims.Size.X
+				g.colWidths[col.index] = dims.Size.X
+			}
+		}
+		cells := g.cells[len(g.cells)-len(g.visCols):]
+		h, b := g.alignRow(cells)
+		g.visRows[i].baseline = b
+		if g.RowHeight == nil && h > row.size {
+			g.visRows[i].size = h
+			g.rowHeights[row.index] = h
+		}
+	}
+	return g.layout(gtx, lockedRows, lockedCols)
+}
+
+// alignRow returns the height of the cells of a row, and the distance from
+// the top of the row to the baseline of the cells for the baseline
+// alignments.
+func (g *Grid) alignRow(cells []gridCell) (height, baseline int) {
+	var descent int
+	for _, c := range cells {
+		height = max(height, c.dims.Size.Y)
+		if g.Alignment == Baseline || g.Alignment == LastBaseline {
+			b := g.Alignment.baseline(c.dims)
+			baseline = max(baseline, b)
+			descent = max(descent, c.dims.Size.Y-b)
+		}
+	}
+	return max(height, baseline+descent), baseline
+}
+
+// cellOffset returns the vertical offset of a cell in its row.
+func (g *Grid) cellOffset(row gridLine, dims Dimensions) int {
+	var off int
+	switch g.Alignment {
+	case End:
+		off = row.size - dims.Size.Y
+	case Middle:
+		off = (row.size - dims.Size.Y) / 2
+	case Baseline, LastBaseline:
+		off = row.baseline - g.Alignment.baseline(dims)
+	}
+	// Keep the cell within its row.
+	return max(min(off, row.size-dims.Size.Y), 0)
+}
+
+// layout positions the laid out cells and returns the dimensions of the
+// grid.
+func (g *Grid) layout(gtx Context, lockedRows, lockedCols int) Dimensions {
+	p := &g.Position
+	var locked, end image.Point
+	// The locked lines come first, so their size is known when
+	// positioning the scrolled lines.
+	for i := range g.visCols {
+		c := &g.visCols[i]
+		if i < lockedCols {
+			c.pos = locked.X
+			locked.X += c.size
+		} else {
+			c.pos = locked.X + end.X - p.OffsetX
+			end.X += c.size
+		}
+	}
+	for i := range g.visRows {
+		r := &g.visRows[i]
+		if i < lockedRows {
+			r.pos = locked.Y
+			locked.Y += r.size
+		} else {
+			r.pos = locked.Y + end.Y - p.OffsetY
+			end.Y += r.size
+		}
+	}
+	end = end.Sub(image.Pt(p.OffsetX, p.OffsetY)).Add(locked)
+	dims := gtx.Constraints.Constrain(end)
+
+	// Estimate the total size from the average size of the visible lines.
+	p.Width, p.Height = locked.X, locked.Y
+	if p.Cols > 0 {
+		p.Width += (end.X - locked.X + p.OffsetX) * (g.cols - lockedCols) / p.Cols
+	}
+	if p.Rows > 0 {
+		p.Height += (end.Y - locked.Y + p.OffsetY) * (g.rows - lockedRows) / p.Rows
+	}
+
+	atStartX := p.Col == lockedCols && p.OffsetX <= 0
+	atStartY := p.Row == lockedRows && p.OffsetY <= 0
+	atEndX := p.Col+p.Cols == g.cols && end.X <= dims.X
+	atEndY := p.Row+p.Rows == g.rows && end.Y <= dims.Y
+	g.overflow = end.Sub(dims)
+	if atStartX && g.dx < 0 || atEndX && g.dx > 0 {
+		g.hscroll.Stop()
+	}
+	if atStartY && g.dy < 0 || atEndY && g.dy > 0 {
+		g.vscroll.Stop()
+	}
+
+	defer clip.Rect(image.Rectangle{Max: dims}).Push(gtx.Ops).Pop()
+	g.hscroll.Add(gtx.Ops)
+	g.vscroll.Add(gtx.Ops)
+	// Lay out the scrolled rows and columns first, below the locked ones,
+	// and clip the cells of each row to their quadrant of the grid.
+	colQuadrants := [...]struct {
+		lockedCol bool
+		clip      image.Rectangle
+	}{
+		{false, image.Rect(locked.X, 0, dims.X, dims.Y)},
+		{true, image.Rect(0, 0, locked.X, dims.Y)},
+	}
+	ncols := len(g.visCols)
+	layoutRows := func(from, to int, area image.Rectangle) {
+		for i := from; i < to; i++ {
+			row := g.visRows[i]
+			y := row.pos
+			var rowArea clip.Stack
+			if g.RowArea != nil {
+				r := image.Rect(0, y, dims.X, y+row.size).Intersect(area)
+				rowArea = clip.Rect(r).Push(gtx.Ops)
+				g.RowArea(gtx, row.index)
+			}
+			for _, q := range colQuadrants {
+				cl := clip.Rect(q.clip.Intersect(area)).Push(gtx.Ops)
+				for _, c := range g.cells[i*ncols : (i+1)*ncols] {
+					if (c.col < lockedCols) != q.lockedCol {
+						continue
+					}
+					pos := image.Pt(g.visCols[c.col].pos, y)
+					pos.Y += g.cellOffset(row, c.dims)
+					trans := op.Offset(pos).Push(gtx.Ops)
+					c.call.Add(gtx.Ops)
+					trans.Pop()
+				}
+				cl.Pop()
+			}
+			if g.RowArea != nil {
+				rowArea.Pop()
+			}
+		}
+	}
+	layoutRows(lockedRows, len(g.visRows), image.Rect(0, locked.Y, dims.X, dims.Y))
+	layoutRows(0, lockedRows, image.Rect(0, 0, dims.X, locked.Y))
+	return Dimensions{Size: dims}
+}
+
+func (g *Grid) update(gtx Context, lockedRows, lockedCols int) {
+	p := g.Position
+	xrange := scrollRange(p.Col <= lockedCols, p.Col+p.Cols >= g.cols, p.OffsetX, g.overflow.X)
+	yrange := scrollRange(p.Row <= lockedRows, p.Row+p.Rows >= g.rows, p.OffsetY, g.overflow.Y)
+	g.dx = g.hscroll.Update(gtx.Metric, gtx.Source, gtx.Now, gesture.Horizontal, xrange, pointer.ScrollRange{})
+	g.dy = g.vscroll.Update(gtx.Metric, gtx.Source, gtx.Now, gesture.Vertical, pointer.ScrollRange{}, yrange)
+	g.Position.OffsetX += g.dx
+	g.Position.OffsetY += g.dy
+	if g.colWidths == nil {
+		g.colWidths = make(map[int]int)
+		g.rowHeights = make(map[int]int)
+	}
+}
+
+// colWidth returns the width of column c, measuring it from the cells of
+// the locked rows and the first scrolled row if necessary.
+func (g *Grid) colWidth(gtx Context, c int) int {
+	if g.ColWidth != nil {
+		return g.ColWidth(gtx, c)
+	}
+	if w, ok := g.colWidths[c]; ok {
+		return w
+	}
+	locked := min(g.LockedRows, g.rows)
+	w := 0
+	measure := func(r int) {
+		cgtx := gtx
+		cgtx.Constraints = Constraints{Max: image.Pt(inf, inf)}
+		if g.RowHeight != nil {
+			h := g.RowHeight(gtx, r)
+			cgtx.Constraints.Min.Y, cgtx.Constraints.Max.Y = h, h
+		}
+		dims := cgtx.Measure(func(gtx Context) Dimensions {
+			return g.cell(gtx, r, c)
+		})
+		w = max(w, dims.Size.X)
+	}
+	for r := 0; r < locked; r++ {
+		measure(r)
+	}
+	if g.rows > locked {
+		measure(max(locked, min(g.Position.Row, g.rows-1)))
+	}
+	g.colWidths[c] = w
+	return w
+}
+
+// rowHeight returns the height of row r, measuring it from the cells of the
+// visible columns if necessary.
+func (g *Grid) rowHeight(gtx Context, r int) int {
+	if g.RowHeight != nil {
+		return g.RowHeight(gtx, r)
+	}
+	if h, ok := g.rowHeights[r]; ok {
+		return h
+	}
+	var cells [16]gridCell
+	measured := cells[:0]
+	for _, col := range g.visCols {
+		cgtx := gtx
+		cgtx.Constraints = Constraints{Min: image.Pt(col.size, 0), Max: image.Pt(col.size, inf)}
+		if g.ColWidth == nil {
+			cgtx.Constraints.Max.X = inf
+		}
+		dims := cgtx.Measure(func(gtx Context) Dimensions {
+			return g.cell(gtx, r, col.index)
+		})
+		measured = append(measured, gridCell{dims: dims})
+	}
+	h, _ := g.alignRow(measured)
+	g.rowHeights[r] = h
+	return h
+}
+
+// Remeasure discards the measured sizes of the rows and columns, for them
+// to be measured again by the next Layout. Use it when the cells change
+// size, such as after their content changes.
+func (g *Grid) Remeasure() {
+	clear(g.colWidths)
+	clear(g.rowHeights)
+}
+
+// Dragging reports whether the Grid is being dragged.
+func (g *Grid) Dragging() bool {
+	return g.hscroll.State() == gesture.StateDragging || g.vscroll.State() == gesture.StateDragging
+}
+
+// ScrollTo scrolls the cell at row and col to the top left of the scrolled
+// area. A row or column that is locked doesn't scroll its axis.
+func (g *Grid) ScrollTo(row, col int) {
+	if row >= g.LockedRows {
+		g.Position.Row, g.Position.OffsetY = row, 0
+	}
+	if col >= g.LockedCols {
+		g.Position.Col, g.Position.OffsetX = col, 0
+	}
+}
+
+// scrollLines clamps the first scrolled line and its offset along an axis of
+// n lines of which the leading locked lines are not scrolled, and returns
+// the number of lines visible in view.
+func scrollLines(first, offset *int, locked, n, view int, size func(i int) int) int {
+	if *first < locked {
+		*first, *offset = locked, 0
+	}
+	if *first >= n {
+		*first, *offset = n, 0
+	}
+	// Move to the line at the offset.
+	for *offset < 0 && *first > locked {
+		*first--
+		*offset += size(*first)
+	}
+	for *first < n {
+		s := size(*first)
+		if *offset < s {
+			break
+		}
+		*offset -= s
+		*first++
+	}
+	if *offset < 0 {
+		*offset = 0
+	}
+	// Count the lines filling the view.
+	count, end := 0, -*offset
+	for i := *first; i < n && end < view; i++ {
+		end += size(i)
+		count++
+	}
+	// Scroll back to fill the view with the last lines.
+	if *first+count == n && end < view {
+		*offset -= view - end
+		for *offset < 0 && *first > locked {
+			*first--
+			*offset += size(*first)
+			count++
+		}
+		if *offset < 0 {
+			*offset = 0
+		}
+	}
+	return count
+}
+
+// scrollRange returns the range of scrolling distances of an axis, where
+// offset is the offset of the first scrolled line and overflow is the size
+// of the last lines beyond the view.
+func scrollRange(atStart, atEnd bool, offset, overflow int) pointer.ScrollRange {
+	r := pointer.ScrollRange{Min: -inf, Max: inf}
+	if atStart {
+		r.Min = min(-offset, 0)
+	}
+	if atEnd {
+		r.Max = max(overflow, 0)
+	}
+	return r
+}
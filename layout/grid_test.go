@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package layout
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/io/input"
+	"gioui.org/io/pointer"
+	"gioui.org/io/semantic"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+)
+
+func TestGridVirtualization(t *testing.T) {
+	const rows, cols = 100000, 50
+	g := Grid{
+		LockedRows: 1,
+		LockedCols: 1,
+		RowHeight:  func(gtx Context, row int) int { return 10 },
+		ColWidth:   func(gtx Context, col int) int { return 20 },
+	}
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Exact(image.Pt(100, 50)),
+	}
+	var calls []image.Point
+	cell := func(gtx Context, row, col int) Dimensions {
+		calls = append(calls, image.Pt(col, row))
+		if gtx.Constraints.Min != image.Pt(20, 10) || gtx.Constraints.Max != image.Pt(20, 10) {
+			t.Errorf("cell (%d, %d) constraints %v", row, col, gtx.Constraints)
+		}
+		return Dimensions{Size: gtx.Constraints.Min}
+	}
+	dims := g.Layout(gtx, rows, cols, cell)
+	if dims.Size != image.Pt(100, 50) {
+		t.Errorf("got size %v, want (100,50)", dims.Size)
+	}
+	// 1 locked + 4 scrolled columns, 1 locked + 4 scrolled rows.
+	if len(calls) != 25 {
+		t.Errorf("laid out %d cells, want 25", len(calls))
+	}
+	if p := g.Position; p.Row != 1 || p.Col != 1 || p.Rows != 4 || p.Cols != 4 {
+		t.Errorf("got position %+v", p)
+	}
+	if got, want := g.Position.Height, rows*10; got != want {
+		t.Errorf("got height %d, want %d", got, want)
+	}
+
+	// Restore a position in the middle of the grid.
+	calls = calls[:0]
+	g.Position = GridPosition{Row: 5000, Col: 20, OffsetY: 15, OffsetX: 5}
+	g.Layout(gtx, rows, cols, cell)
+	if p := g.Position; p.Row != 5001 || p.OffsetY != 5 || p.Col != 20 || p.OffsetX != 5 || p.Rows != 5 || p.Cols != 5 {
+		t.Errorf("got position %+v", p)
+	}
+	locked := 0
+	for _, c := range calls {
+		if c.X == 0 || c.Y == 0 {
+			locked++
+		}
+	}
+	if locked != 11 {
+		t.Errorf("laid out %d locked cells, want 11", locked)
+	}
+
+	// Scrolling past the end shows the last cells.
+	g.Position = GridPosition{Row: rows + 10, Col: cols}
+	g.Layout(gtx, rows, cols, cell)
+	if p := g.Position; p.Row != rows-4 || p.Col != cols-4 || p.OffsetX != 0 || p.OffsetY != 0 {
+		t.Errorf("got position %+v", p)
+	}
+}
+
+func TestGridScroll(t *testing.T) {
+	r := new(input.Router)
+	g := Grid{
+		LockedRows: 1,
+		RowHeight:  func(gtx Context, row int) int { return 10 },
+		ColWidth:   func(gtx Context, col int) int { return 20 },
+	}
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Exact(image.Pt(100, 50)),
+		Source:      r.Source(),
+	}
+	cell := func(gtx Context, row, col int) Dimensions {
+		return Dimensions{Size: gtx.Constraints.Min}
+	}
+	g.Layout(gtx, 100, 10, cell)
+	r.Frame(gtx.Ops)
+	r.Queue(
+		pointer.Event{
+			Source:   pointer.Mouse,
+			Kind:     pointer.Move,
+			Position: f32.Pt(50, 25),
+		},
+		pointer.Event{
+			Source: pointer.Mouse,
+			Kind:   pointer.Scroll,
+			Scroll: f32.Pt(15, 25),
+		},
+	)
+	gtx.Ops.Reset()
+	g.Layout(gtx, 100, 10, cell)
+	if p := g.Position; p.Row != 3 || p.OffsetY != 5 || p.Col != 0 || p.OffsetX != 15 {
+		t.Errorf("got position %+v", p)
+	}
+}
+
+func TestGridRowArea(t *testing.T) {
+	r := new(input.Router)
+	g := Grid{
+		LockedRows: 1,
+		LockedCols: 1,
+		RowHeight:  func(gtx Context, row int) int { return 10 },
+		ColWidth:   func(gtx Context, col int) int { return 20 },
+		RowArea: func(gtx Context, row int) {
+			semantic.DescriptionOp(fmt.Sprintf("row %d", row)).Add(gtx.Ops)
+		},
+		Position: GridPosition{Row: 5, OffsetY: 5},
+	}
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Exact(image.Pt(100, 50)),
+	}
+	g.Layout(gtx, 100, 10, func(gtx Context, row, col int) Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+		semantic.DescriptionOp(fmt.Sprintf("cell %d", row)).Add(gtx.Ops)
+		return Dimensions{Size: gtx.Constraints.Min}
+	})
+	r.Frame(gtx.Ops)
+	nodes := r.AppendSemantics(nil)
+	rows := make(map[input.SemanticID]input.SemanticNode)
+	for _, n := range nodes {
+		var row int
+		if _, err := fmt.Sscanf(n.Desc.Description, "row %d", &row); err == nil {
+			rows[n.ID] = n
+		}
+	}
+	// The locked row and the visible part of 5 scrolled rows.
+	if len(rows) != 6 {
+		t.Errorf("got %d rows, want 6", len(rows))
+	}
+	cells := 0
+	for _, n := range nodes {
+		var row int
+		if _, err := fmt.Sscanf(n.Desc.Description, "cell %d", &row); err != nil {
+			continue
+		}
+		cells++
+		if p, want := rows[n.ParentID].Desc.Description, fmt.Sprintf("row %d", row); p != want {
+			t.Errorf("cell of row %d is in %q, want %q", row, p, want)
+		}
+	}
+	if cells != 6*5 {
+		t.Errorf("got %d cells, want %d", cells, 6*5)
+	}
+	for _, n := range rows {
+		if n.Desc.Description == "row 5" {
+			// The row is partly scrolled under the locked row.
+			if want := image.Rect(0, 10, 100, 15); n.Desc.Bounds != want {
+				t.Errorf("got row bounds %v, want %v", n.Desc.Bounds, want)
+			}
+		}
+	}
+}
+
+func TestGridMeasure(t *testing.T) {
+	g := Grid{LockedRows: 1}
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Exact(image.Pt(100, 100)),
+	}
+	widths := []int{10, 30, 20}
+	cell := func(gtx Context, row, col int) Dimensions {
+		sz := image.Pt(widths[(row+col)%len(widths)], 5+row%2*5)
+		return Dimensions{Size: gtx.Constraints.Constrain(sz)}
+	}
+	g.Layout(gtx, 3, 3, cell)
+	// The columns are measured from the header and first row, then grown
+	// to fit the laid out cells. The rows are measured from the cells of
+	// the visible columns.
+	for c, want := range []int{30, 30, 30} {
+		if got := g.colWidths[c]; got != want {
+			t.Errorf("column %d: got width %d, want %d", c, got, want)
+		}
+	}
+	for r, want := range []int{5, 10, 5} {
+		if got := g.rowHeights[r]; got != want {
+			t.Errorf("row %d: got height %d, want %d", r, got, want)
+		}
+	}
+	g.Remeasure()
+	if len(g.colWidths) != 0 || len(g.rowHeights) != 0 {
+		t.Error("Remeasure kept the measured sizes")
+	}
+}
+
+func TestGridAlignment(t *testing.T) {
+	cells := []Dimensions{
+		{Size: image.Pt(10, 20), Baseline: 5},
+		{Size: image.Pt(10, 10), Baseline: 2},
+	}
+	for _, tc := range []struct {
+		align Alignment
+		// y is the offset of the second cell of the first row.
+		y int
+	}{
+		{Start, 0},
+		{Middle, 5},
+		{End, 10},
+		{Baseline, 7},
+		{LastBaseline, 7},
+	} {
+		var r input.Router
+		g := Grid{Alignment: tc.align}
+		gtx := Context{
+			Ops:         new(op.Ops),
+			Constraints: Exact(image.Pt(100, 100)),
+		}
+		g.Layout(gtx, 2, 2, func(gtx Context, row, col int) Dimensions {
+			dims := cells[col]
+			dims.Size = gtx.Constraints.Constrain(dims.Size)
+			defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+			semantic.DescriptionOp(fmt.Sprintf("%d,%d", row, col)).Add(gtx.Ops)
+			return dims
+		})
+		r.Frame(gtx.Ops)
+		want := map[string]int{"0,0": 0, "0,1": tc.y, "1,0": 20, "1,1": 20 + tc.y}
+		for _, n := range r.AppendSemantics(nil) {
+			y, ok := want[n.Desc.Description]
+			if !ok {
+				continue
+			}
+			if got := n.Desc.Bounds.Min.Y; got != y {
+				t.Errorf("%v: cell %s: got y %d, want %d", tc.align, n.Desc.Description, got, y)
+			}
+		}
+		if got := g.rowHeights[0]; got != 20 {
+			t.Errorf("%v: got row height %d, want 20", tc.align, got)
+		}
+	}
+}
@@ -22,14 +22,17 @@ type Constraints struct {
 	Min, Max image.Point
 }
 
-// Dimensions are the resolved size and baseline for a widget.
+// Dimensions are the resolved size and baselines for a widget.
 //
 // Baseline is the distance from the bottom of a widget to the baseline of
-// any text it contains (or 0). The purpose is to be able to align text
-// that span multiple widgets.
+// the first line of any text it contains (or 0). The purpose is to be able
+// to align text that span multiple widgets. LastBaseline is the distance
+// from the bottom to the baseline of the last line of text, or 0 if it is
+// the same as Baseline.
 type Dimensions struct {
-	Size     image.Point
-	Baseline int
+	Size         image.Point
+	Baseline     int
+	LastBaseline int
 }
 
 // Axis is the Horizontal or Vertical direction.
@@ -50,7 +53,10 @@ const (
 	Start Alignment = iota
 	End
 	Middle
+	// Baseline aligns the baselines of the first lines of text.
 	Baseline
+	// LastBaseline aligns the baselines of the last lines of text.
+	LastBaseline
 )
 
 const (
@@ -168,10 +174,7 @@ func (in Inset) Layout(gtx Context, w Widget) Dimensions {
 	trans := op.Offset(image.Pt(left, top)).Push(gtx.Ops)
 	dims := w(gtx)
 	trans.Pop()
-	return Dimensions{
-		Size:     dims.Size.Add(image.Point{X: right + left, Y: top + bottom}),
-		Baseline: dims.Baseline + bottom,
-	}
+	return dims.resize(dims.Size.Add(image.Point{X: right + left, Y: top + bottom}), bottom)
 }
 
 // UniformInset returns an Inset with a single inset applied to all
@@ -207,10 +210,7 @@ func (d Direction) Layout(gtx Context, w Widget) Dimensions {
 	defer op.Offset(p).Push(gtx.Ops).Pop()
 	call.Add(gtx.Ops)
 
-	return Dimensions{
-		Size:     sz,
-		Baseline: dims.Baseline + sz.Y - dims.Size.Y - p.Y,
-	}
+	return dims.resize(sz, sz.Y-dims.Size.Y-p.Y)
 }
 
 // Position calculates widget position according to the direction.
@@ -234,6 +234,73 @@ func (d Direction) Position(widget, bounds image.Point) image.Point {
 	return p
 }
 
+// resize returns d with size sz, where the bottom of d is at distance bottom
+// from the bottom of sz. Baselines are moved accordingly, except when d has
+// none.
+func (d Dimensions) resize(sz image.Point, bottom int) Dimensions {
+	if d.Baseline != 0 {
+		d.Baseline += bottom
+		if d.LastBaseline != 0 {
+			d.LastBaseline += bottom
+		}
+	}
+	d.Size = sz
+	return d
+}
+
+// lastBaseline returns the distance from the bottom of d to the baseline of
+// its last line of text.
+func (d Dimensions) lastBaseline() int {
+	if d.LastBaseline != 0 {
+		return d.LastBaseline
+	}
+	return d.Baseline
+}
+
+// baseline returns the distance from the top of dims to the baseline
+// aligned by a, or the bottom of dims if it has no baseline.
+func (a Alignment) baseline(dims Dimensions) int {
+	if a == LastBaseline {
+		return dims.Size.Y - dims.lastBaseline()
+	}
+	return dims.Size.Y - dims.Baseline
+}
+
+// baselines tracks the first and last baselines of a set of children.
+type baselines struct {
+	// first and last are the distances from the top of the parent to the
+	// highest first baseline and the lowest last baseline.
+	first, last int
+	valid       bool
+}
+
+// add the baselines of a child with dimensions dims at vertical offset y.
+func (b *baselines) add(dims Dimensions, y int) {
+	if dims.Baseline == 0 {
+		return
+	}
+	first := y + dims.Size.Y - dims.Baseline
+	last := y + dims.Size.Y - dims.lastBaseline()
+	if !b.valid {
+		b.first, b.last, b.valid = first, last, true
+		return
+	}
+	b.first = min(b.first, first)
+	b.last = max(b.last, last)
+}
+
+// dimensions returns the Dimensions of size sz with the tracked baselines.
+func (b baselines) dimensions(sz image.Point) Dimensions {
+	dims := Dimensions{Size: sz}
+	if b.valid {
+		dims.Baseline = sz.Y - b.first
+		if b.last != b.first {
+			dims.LastBaseline = sz.Y - b.last
+		}
+	}
+	return dims
+}
+
 // Spacer adds space between widgets.
 type Spacer struct {
 	Width, Height unit.Dp
@@ -258,6 +325,8 @@ func (a Alignment) String() string {
 		return "Middle"
 	case Baseline:
 		return "Baseline"
+	case LastBaseline:
+		return "LastBaseline"
 	default:
 		panic("unreachable")
 	}
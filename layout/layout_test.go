@@ -4,6 +4,7 @@ package layout
 
 import (
 	"image"
+	"reflect"
 	"testing"
 
 	"gioui.org/op"
@@ -142,3 +143,144 @@ func TestConstraints(t *testing.T) {
 		})
 	}
 }
+
+func TestFlexBaseline(t *testing.T) {
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Constraints{Max: image.Pt(100, 100)},
+	}
+	children := []Dimensions{
+		{Size: image.Pt(10, 20), Baseline: 5},
+		{Size: image.Pt(10, 30), Baseline: 25, LastBaseline: 5},
+		{Size: image.Pt(10, 10)},
+	}
+	rigid := func(dims Dimensions) FlexChild {
+		return Rigid(func(gtx Context) Dimensions { return dims })
+	}
+	for _, tc := range []struct {
+		align Alignment
+		exp   Dimensions
+	}{
+		// The first baselines are 15 from the top, and the second child
+		// descends 25 below it.
+		{Baseline, Dimensions{Size: image.Pt(30, 40), Baseline: 25, LastBaseline: 5}},
+		// The last baselines are 25 from the top, and the first baseline of
+		// the second child is at the top of the first child.
+		{LastBaseline, Dimensions{Size: image.Pt(30, 30), Baseline: 25, LastBaseline: 5}},
+		{Start, Dimensions{Size: image.Pt(30, 30), Baseline: 25, LastBaseline: 5}},
+		{End, Dimensions{Size: image.Pt(30, 30), Baseline: 25, LastBaseline: 5}},
+	} {
+		dims := Flex{Alignment: tc.align}.Layout(gtx,
+			rigid(children[0]), rigid(children[1]), rigid(children[2]),
+		)
+		if dims != tc.exp {
+			t.Errorf("%v: got %+v, want %+v", tc.align, dims, tc.exp)
+		}
+	}
+
+	// Vertical flexes take the first baseline from the first child and the
+	// last baseline from the last child.
+	dims := Flex{Axis: Vertical}.Layout(gtx,
+		rigid(children[0]), rigid(children[1]), rigid(children[2]),
+	)
+	if exp := (Dimensions{Size: image.Pt(10, 60), Baseline: 45, LastBaseline: 15}); dims != exp {
+		t.Errorf("vertical: got %+v, want %+v", dims, exp)
+	}
+}
+
+func TestBaselinePropagation(t *testing.T) {
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Exact(image.Pt(20, 20)),
+	}
+	text := func(gtx Context) Dimensions {
+		return Dimensions{Size: image.Pt(10, 10), Baseline: 5, LastBaseline: 3}
+	}
+	empty := func(gtx Context) Dimensions {
+		return Dimensions{Size: image.Pt(10, 10)}
+	}
+	background := func(gtx Context) Dimensions {
+		return Dimensions{Size: gtx.Constraints.Min}
+	}
+	tests := []struct {
+		name string
+		dims Dimensions
+		exp  Dimensions
+	}{
+		{"inset", Inset{Top: 4, Bottom: 6}.Layout(gtx, text), Dimensions{Size: image.Pt(10, 20), Baseline: 11, LastBaseline: 9}},
+		{"inset without baseline", Inset{Bottom: 6}.Layout(gtx, empty), Dimensions{Size: image.Pt(10, 16)}},
+		{"direction", N.Layout(gtx, text), Dimensions{Size: image.Pt(20, 20), Baseline: 15, LastBaseline: 13}},
+		{"direction without baseline", N.Layout(gtx, empty), Dimensions{Size: image.Pt(20, 20)}},
+		{"stack", Stack{Alignment: S}.Layout(gtx, Expanded(empty), Stacked(text)), Dimensions{Size: image.Pt(20, 20), Baseline: 5, LastBaseline: 3}},
+		{"background", Background{}.Layout(gtx, background, text), Dimensions{Size: image.Pt(20, 20), Baseline: 10, LastBaseline: 8}},
+	}
+	for _, tc := range tests {
+		if tc.dims != tc.exp {
+			t.Errorf("%s: got %+v, want %+v", tc.name, tc.dims, tc.exp)
+		}
+	}
+}
+
+func TestFlexGap(t *testing.T) {
+	gtx := Context{
+		Ops: new(op.Ops),
+		Constraints: Constraints{
+			Min: image.Pt(100, 0),
+			Max: image.Pt(100, 100),
+		},
+	}
+	var maxes []int
+	child := func(gtx Context) Dimensions {
+		maxes = append(maxes, gtx.Constraints.Max.X)
+		return Dimensions{Size: image.Pt(10, 10)}
+	}
+	dims := Flex{Gap: 5, Spacing: SpaceBetween}.Layout(gtx, Rigid(child), Rigid(child), Rigid(child))
+	if dims.Size != image.Pt(100, 10) {
+		t.Errorf("got size %v, want (100,10)", dims.Size)
+	}
+	// The gaps are reserved before laying out the children.
+	if exp := []int{90, 80, 70}; !reflect.DeepEqual(maxes, exp) {
+		t.Errorf("got maximum constraints %v, want %v", maxes, exp)
+	}
+	gtx.Constraints.Min = image.Point{}
+	dims = Flex{Gap: 5}.Layout(gtx, Rigid(child), Rigid(child), Rigid(child))
+	if dims.Size != image.Pt(40, 10) {
+		t.Errorf("got size %v, want (40,10)", dims.Size)
+	}
+}
+
+func TestFlexShrink(t *testing.T) {
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Constraints{Max: image.Pt(100, 100)},
+	}
+	var sizes []int
+	child := func(width int) Widget {
+		return func(gtx Context) Dimensions {
+			w := min(width, gtx.Constraints.Max.X)
+			sizes = append(sizes, w)
+			return Dimensions{Size: image.Pt(w, 10)}
+		}
+	}
+	dims := Flex{}.Layout(gtx,
+		Shrinkable(1, child(60)),
+		Rigid(child(20)),
+		Shrinkable(2, child(40)),
+	)
+	if dims.Size != image.Pt(100, 10) {
+		t.Errorf("got size %v, want (100,10)", dims.Size)
+	}
+	// The Rigid child is laid out first, then the Shrinkable children at
+	// their natural size. The overflow of 20 is taken in proportion to 60×1
+	// and 40×2.
+	if exp := []int{20, 60, 40, 51, 29}; !reflect.DeepEqual(sizes, exp) {
+		t.Errorf("got sizes %v, want %v", sizes, exp)
+	}
+
+	// Shrinkable children that fit are not shrunk.
+	sizes = sizes[:0]
+	Flex{}.Layout(gtx, Shrinkable(1, child(30)), Rigid(child(20)))
+	if exp := []int{20, 30}; !reflect.DeepEqual(sizes, exp) {
+		t.Errorf("got sizes %v, want %v", sizes, exp)
+	}
+}
@@ -111,6 +111,9 @@ func (l *List) init(gtx Context, len int) {
 // by the callback w. Layout can handle very large lists because it only calls
 // w to fill its viewport and the distance scrolled, if any.
 func (l *List) Layout(gtx Context, len int, w ListElement) Dimensions {
+	if gtx.Measuring() {
+		defer func(p Position) { l.Position = p }(l.Position)
+	}
 	l.init(gtx, len)
 	crossMin, crossMax := l.Axis.crossConstraint(gtx.Constraints)
 	gtx.Constraints = l.Axis.constraints(0, inf, crossMin, crossMax)
@@ -88,7 +88,7 @@ func (s Stack) Layout(gtx Context, children ...StackChild) Dimensions {
 	}
 
 	maxSZ = gtx.Constraints.Constrain(maxSZ)
-	var baseline int
+	var dims Dimensions
 	for _, ch := range children {
 		sz := ch.dims.Size
 		var p image.Point
@@ -107,16 +107,11 @@ func (s Stack) Layout(gtx Context, children ...StackChild) Dimensions {
 		trans := op.Offset(p).Push(gtx.Ops)
 		ch.call.Add(gtx.Ops)
 		trans.Pop()
-		if baseline == 0 {
-			if b := ch.dims.Baseline; b != 0 {
-				baseline = b + maxSZ.Y - sz.Y - p.Y
-			}
+		if dims.Baseline == 0 {
+			dims = ch.dims.resize(maxSZ, maxSZ.Y-sz.Y-p.Y)
 		}
 	}
-	return Dimensions{
-		Size:     maxSZ,
-		Baseline: baseline,
-	}
+	return dims.resize(maxSZ, 0)
 }
 
 // Background lays out single child widget on top of a background,
@@ -127,27 +122,23 @@ type Background struct{}
 func (Background) Layout(gtx Context, background, widget Widget) Dimensions {
 	macro := op.Record(gtx.Ops)
 	wdims := widget(gtx)
-	baseline := wdims.Baseline
 	call := macro.Stop()
 
 	cgtx := gtx
 	cgtx.Constraints.Min = gtx.Constraints.Constrain(wdims.Size)
 	bdims := background(cgtx)
 
+	var p image.Point
 	if bdims.Size != wdims.Size {
-		p := image.Point{
+		p = image.Point{
 			X: (bdims.Size.X - wdims.Size.X) / 2,
 			Y: (bdims.Size.Y - wdims.Size.Y) / 2,
 		}
-		baseline += (bdims.Size.Y - wdims.Size.Y) / 2
 		trans := op.Offset(p).Push(gtx.Ops)
 		defer trans.Pop()
 	}
 
 	call.Add(gtx.Ops)
 
-	return Dimensions{
-		Size:     bdims.Size,
-		Baseline: baseline,
-	}
+	return wdims.resize(bdims.Size, bdims.Size.Y-wdims.Size.Y-p.Y)
 }
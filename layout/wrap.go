@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package layout
+
+import (
+	"image"
+
+	"gioui.org/op"
+	"gioui.org/unit"
+)
+
+// Wrap lays out child elements along an axis, breaking them onto new
+// lines when they don't fit the maximum constraint of the axis. Lines
+// follow each other along the cross axis.
+type Wrap struct {
+	// Axis is the main axis, either Horizontal or Vertical.
+	Axis Axis
+	// Gap is the space between the children of a line, and LineGap the
+	// space between lines.
+	Gap, LineGap unit.Dp
+	// Spacing controls the distribution of the space left in each line,
+	// relative to the longest line or the minimum constraint.
+	Spacing Spacing
+	// Alignment is the alignment of children in the cross axis of their
+	// line.
+	Alignment Alignment
+}
+
+// wrapChild is a laid out child of a Wrap.
+type wrapChild struct {
+	call op.CallOp
+	dims Dimensions
+}
+
+// Layout n children of the wrap, where each child is implicitly defined by
+// the callback el. The children are laid out with no minimum constraints.
+func (w Wrap) Layout(gtx Context, n int, el ListElement) Dimensions {
+	cs := gtx.Constraints
+	mainMin, mainMax := w.Axis.mainConstraint(cs)
+	_, crossMax := w.Axis.crossConstraint(cs)
+	gap, lineGap := gtx.Dp(w.Gap), gtx.Dp(w.LineGap)
+	cgtx := gtx
+	cgtx.Constraints = w.Axis.constraints(0, mainMax, 0, crossMax)
+	var buf [16]wrapChild
+	children := buf[:0]
+	mainSize := mainMin
+	lineSize := 0
+	for i := 0; i < n; i++ {
+		macro := op.Record(gtx.Ops)
+		dims := el(cgtx, i)
+		call := macro.Stop()
+		sz := w.Axis.Convert(dims.Size).X
+		if lineSize > 0 && lineSize+gap+sz > mainMax {
+			lineSize = 0
+		}
+		if lineSize > 0 {
+			lineSize += gap
+		}
+		lineSize += sz
+		mainSize = max(mainSize, lineSize)
+		children = append(children, wrapChild{call: call, dims: dims})
+	}
+	mainSize = min(mainSize, mainMax)
+
+	alignBaseline := w.Axis == Horizontal && (w.Alignment == Baseline || w.Alignment == LastBaseline)
+	var bl baselines
+	cross := 0
+	for len(children) > 0 {
+		// Find the children of the line.
+		end, size := 1, w.Axis.Convert(children[0].dims.Size).X
+		for ; end < len(children); end++ {
+			sz := w.Axis.Convert(children[end].dims.Size).X
+			if size+gap+sz > mainMax {
+				break
+			}
+			size += gap + sz
+		}
+		line := children[:end]
+		children = children[end:]
+		if cross > 0 {
+			cross += lineGap
+		}
+		// Align the children in the cross axis of the line.
+		lineCross, maxBaseline, maxDescent := 0, 0, 0
+		for _, c := range line {
+			lineCross = max(lineCross, w.Axis.Convert(c.dims.Size).Y)
+			if alignBaseline {
+				b := w.Alignment.baseline(c.dims)
+				maxBaseline = max(maxBaseline, b)
+				maxDescent = max(maxDescent, c.dims.Size.Y-b)
+			}
+		}
+		if alignBaseline {
+			lineCross = max(lineCross, maxBaseline+maxDescent)
+		}
+		start, between := w.Spacing.distribute(mainSize-size, len(line))
+		pos := start
+		for _, c := range line {
+			sz := w.Axis.Convert(c.dims.Size)
+			var off int
+			switch w.Alignment {
+			case End:
+				off = lineCross - sz.Y
+			case Middle:
+				off = (lineCross - sz.Y) / 2
+			case Baseline, LastBaseline:
+				if alignBaseline {
+					off = maxBaseline - w.Alignment.baseline(c.dims)
+				}
+			}
+			pt := w.Axis.Convert(image.Pt(pos, cross+off))
+			bl.add(c.dims, pt.Y)
+			trans := op.Offset(pt).Push(gtx.Ops)
+			c.call.Add(gtx.Ops)
+			trans.Pop()
+			pos += sz.X + gap + between
+		}
+		cross += lineCross
+	}
+	sz := cs.Constrain(w.Axis.Convert(image.Pt(mainSize, cross)))
+	return bl.dimensions(sz)
+}
+
+// distribute returns the space before the first of n children and the extra
+// space between children, for distributing space according to s.
+func (s Spacing) distribute(space, n int) (start, between int) {
+	if space <= 0 || n == 0 {
+		return 0, 0
+	}
+	switch s {
+	case SpaceStart:
+		start = space
+	case SpaceSides:
+		start = space / 2
+	case SpaceEvenly:
+		start = space / (1 + n)
+		between = start
+	case SpaceAround:
+		start = space / (n * 2)
+		between = space / n
+	case SpaceBetween:
+		if n > 1 {
+			between = space / (n - 1)
+		}
+	}
+	return start, between
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package layout
+
+import (
+	"image"
+	"strconv"
+	"testing"
+
+	"gioui.org/io/input"
+	"gioui.org/io/semantic"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+)
+
+func TestWrap(t *testing.T) {
+	sizes := []image.Point{
+		{30, 10}, {30, 20}, {30, 10},
+		{50, 10}, {40, 10},
+		{100, 5},
+	}
+	tests := []struct {
+		name   string
+		wrap   Wrap
+		size   image.Point
+		bounds []image.Rectangle
+	}{
+		{
+			name: "start",
+			wrap: Wrap{Gap: 5, LineGap: 2},
+			size: image.Pt(100, 39),
+			bounds: []image.Rectangle{
+				image.Rect(0, 0, 30, 10), image.Rect(35, 0, 65, 20), image.Rect(70, 0, 100, 10),
+				image.Rect(0, 22, 50, 32), image.Rect(55, 22, 95, 32),
+				image.Rect(0, 34, 100, 39),
+			},
+		},
+		{
+			name: "middle between",
+			wrap: Wrap{Gap: 5, Spacing: SpaceBetween, Alignment: Middle},
+			size: image.Pt(100, 35),
+			bounds: []image.Rectangle{
+				image.Rect(0, 5, 30, 15), image.Rect(35, 0, 65, 20), image.Rect(70, 5, 100, 15),
+				image.Rect(0, 20, 50, 30), image.Rect(60, 20, 100, 30),
+				image.Rect(0, 30, 100, 35),
+			},
+		},
+		{
+			name: "end sides",
+			wrap: Wrap{Spacing: SpaceSides, Alignment: End},
+			size: image.Pt(100, 35),
+			bounds: []image.Rectangle{
+				image.Rect(5, 10, 35, 20), image.Rect(35, 0, 65, 20), image.Rect(65, 10, 95, 20),
+				image.Rect(5, 20, 55, 30), image.Rect(55, 20, 95, 30),
+				image.Rect(0, 30, 100, 35),
+			},
+		},
+		{
+			name: "vertical",
+			wrap: Wrap{Axis: Vertical, Gap: 10},
+			size: image.Pt(180, 40),
+			bounds: []image.Rectangle{
+				image.Rect(0, 0, 30, 10), image.Rect(0, 20, 30, 40),
+				image.Rect(30, 0, 60, 10), image.Rect(30, 20, 80, 30),
+				image.Rect(80, 0, 120, 10), image.Rect(80, 20, 180, 25),
+			},
+		},
+	}
+	for _, test := range tests {
+		var r input.Router
+		gtx := Context{
+			Ops:         new(op.Ops),
+			Constraints: Constraints{Max: image.Pt(100, 45)},
+		}
+		if test.wrap.Axis == Vertical {
+			gtx.Constraints.Max = image.Pt(200, 45)
+		}
+		dims := test.wrap.Layout(gtx, len(sizes), func(gtx Context, i int) Dimensions {
+			defer clip.Rect{Max: sizes[i]}.Push(gtx.Ops).Pop()
+			semantic.DescriptionOp(strconv.Itoa(i)).Add(gtx.Ops)
+			return Dimensions{Size: sizes[i]}
+		})
+		if dims.Size != test.size {
+			t.Errorf("%s: got size %v, want %v", test.name, dims.Size, test.size)
+		}
+		r.Frame(gtx.Ops)
+		for _, n := range r.AppendSemantics(nil) {
+			i, err := strconv.Atoi(n.Desc.Description)
+			if err != nil {
+				continue
+			}
+			if got, want := n.Desc.Bounds, test.bounds[i]; got != want {
+				t.Errorf("%s: child %d: got bounds %v, want %v", test.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestWrapBaseline(t *testing.T) {
+	gtx := Context{
+		Ops:         new(op.Ops),
+		Constraints: Constraints{Max: image.Pt(100, 100)},
+	}
+	children := []Dimensions{
+		{Size: image.Pt(10, 20), Baseline: 5},
+		{Size: image.Pt(10, 10), Baseline: 8},
+		{Size: image.Pt(100, 10)},
+	}
+	dims := Wrap{Alignment: Baseline}.Layout(gtx, len(children), func(gtx Context, i int) Dimensions {
+		return children[i]
+	})
+	// The baselines of the first line are 15 from the top, and the second
+	// child descends 8 below it.
+	if want := (Dimensions{Size: image.Pt(100, 33), Baseline: 18}); dims != want {
+		t.Errorf("got %+v, want %+v", dims, want)
+	}
+}
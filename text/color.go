@@ -0,0 +1,428 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/opentype/api"
+	"github.com/go-text/typesetting/opentype/loader"
+
+	"gioui.org/f32"
+	f32internal "gioui.org/internal/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// maxGradientSize is the maximum width and height in pixels of the images
+// used to display gradients.
+const maxGradientSize = 256
+
+// rawTableSource is implemented by faces that give access to their
+// OpenType tables, such as the faces of package gioui.org/font/opentype.
+type rawTableSource interface {
+	RawTable(tag string) []byte
+}
+
+// colorFace holds the color glyph tables of a face.
+type colorFace struct {
+	// colr is the parsed COLR table, or nil.
+	colr *colrTable
+	// svg caches parsed SVG documents by the address of their source.
+	svg map[*byte]*svgDocument
+}
+
+// colorFace returns the color glyph tables of the face at faceIdx, loading
+// them on first use.
+func (s *shaperImpl) colorFace(faceIdx int) *colorFace {
+	for len(s.colorFaces) <= faceIdx {
+		s.colorFaces = append(s.colorFaces, nil)
+	}
+	if cf := s.colorFaces[faceIdx]; cf != nil {
+		return cf
+	}
+	cf := new(colorFace)
+	s.colorFaces[faceIdx] = cf
+	colr, cpal := s.colorTables(s.faces[faceIdx].Font)
+	if colr != nil {
+		t, err := parseCOLR(colr, cpal)
+		if err != nil {
+			s.logger.Printf("failed parsing color glyphs of %s: %v", s.faceMeta[faceIdx].Typeface, err)
+		}
+		cf.colr = t
+	}
+	return cf
+}
+
+// colorTables returns the raw COLR and CPAL tables of f. It uses the
+// tables of faces loaded with Load if available, and falls back to reading
+// the font file located by the font map.
+func (s *shaperImpl) colorTables(f font.Font) (colr, cpal []byte) {
+	if src, ok := s.tableSources[f]; ok {
+		return src.RawTable("COLR"), src.RawTable("CPAL")
+	}
+	loc := s.location(f)
+	if loc.File == "" {
+		return nil, nil
+	}
+	file, err := os.Open(loc.File)
+	if err != nil {
+		return nil, nil
+	}
+	defer file.Close()
+	lds, err := loader.NewLoaders(file)
+	if err != nil || int(loc.Index) >= len(lds) {
+		return nil, nil
+	}
+	ld := lds[loc.Index]
+	if t := loader.MustNewTag("COLR"); ld.HasTable(t) {
+		colr, _ = ld.RawTable(t)
+	}
+	if t := loader.MustNewTag("CPAL"); ld.HasTable(t) {
+		cpal, _ = ld.RawTable(t)
+	}
+	return colr, cpal
+}
+
+// colrGlyph returns the COLR paint graph for gid in the face at faceIdx, or
+// nil.
+func (s *shaperImpl) colrGlyph(faceIdx int, gid api.GID) (*colrTable, colrPaint) {
+	t := s.colorFace(faceIdx).colr
+	if t == nil {
+		return nil, nil
+	}
+	return t, t.glyph(gid)
+}
+
+// colrRenderer renders COLR paint graphs. Paint coordinates are in font
+// units with the y axis pointing up.
+type colrRenderer struct {
+	ops  *op.Ops
+	face font.Face
+	colr *colrTable
+}
+
+// colrState is the state of a colrRenderer at a node of a paint graph.
+type colrState struct {
+	// pixels maps the current coordinate space to pixels.
+	pixels f32.Affine2D
+	// clip is the bounds of the current clip in the current coordinate
+	// space. It is only valid if clipped is set.
+	clip    f32internal.Rectangle
+	clipped bool
+}
+
+// paint renders p. Layers painted with the foreground color are skipped,
+// because Shape includes them in the glyph outlines.
+func (r *colrRenderer) paint(p colrPaint, st colrState, depth int) {
+	if depth > colrMaxDepth {
+		return
+	}
+	switch p := p.(type) {
+	case colrLayers:
+		for _, l := range p {
+			r.paint(l, st, depth+1)
+		}
+	case colrSolid:
+		c, ok := r.colr.color(p.palette, p.alpha)
+		if !ok || !st.clipped {
+			return
+		}
+		paint.ColorOp{Color: c}.Add(r.ops)
+		paint.PaintOp{}.Add(r.ops)
+	case colrGradient:
+		if st.clipped {
+			paintGradient(r.ops, &p.gradient, st.clip, f32.Affine2D{}, pixelScale(st.pixels))
+		}
+	case colrGlyph:
+		outline, ok := r.face.GlyphData(p.gid).(api.GlyphOutline)
+		if !ok {
+			return
+		}
+		path, bounds := outlinePath(r.ops, outline, f32.Affine2D{})
+		if st.clipped {
+			bounds = bounds.Intersect(st.clip)
+		}
+		cl := clip.Outline{Path: path}.Op().Push(r.ops)
+		r.paint(p.paint, colrState{pixels: st.pixels, clip: bounds, clipped: true}, depth+1)
+		cl.Pop()
+	case colrColrGlyph:
+		r.paint(r.colr.glyph(p.gid), st, depth+1)
+	case colrTransform:
+		inv, ok := invert(p.tr)
+		if !ok {
+			return
+		}
+		child := colrState{pixels: st.pixels.Mul(p.tr), clipped: st.clipped}
+		if st.clipped {
+			child.clip = transformRect(inv, st.clip)
+		}
+		t := op.Affine(p.tr).Push(r.ops)
+		r.paint(p.paint, child, depth+1)
+		t.Pop()
+	case colrComposite:
+		// Composite modes other than source over are not supported.
+		r.paint(p.backdrop, st, depth+1)
+		r.paint(p.src, st, depth+1)
+	}
+}
+
+// foreground calls fn for every glyph of p that is painted with the
+// foreground color, along with the transformation from the glyph's
+// coordinates to tr's target space.
+func (r *colrRenderer) foreground(p colrPaint, tr f32.Affine2D, depth int, fn func(gid api.GID, tr f32.Affine2D)) {
+	if depth > colrMaxDepth {
+		return
+	}
+	switch p := p.(type) {
+	case colrLayers:
+		for _, l := range p {
+			r.foreground(l, tr, depth+1, fn)
+		}
+	case colrGlyph:
+		if s, ok := p.paint.(colrSolid); ok && s.palette == colrForeground {
+			fn(p.gid, tr)
+		}
+	case colrColrGlyph:
+		r.foreground(r.colr.glyph(p.gid), tr, depth+1, fn)
+	case colrTransform:
+		r.foreground(p.paint, tr.Mul(p.tr), depth+1, fn)
+	case colrComposite:
+		r.foreground(p.backdrop, tr, depth+1, fn)
+		r.foreground(p.src, tr, depth+1, fn)
+	}
+}
+
+// outlinePath records the outline transformed by tr as a path, and returns it
+// along with its bounds.
+func outlinePath(ops *op.Ops, outline api.GlyphOutline, tr f32.Affine2D) (clip.PathSpec, f32internal.Rectangle) {
+	var p clip.Path
+	p.Begin(ops)
+	bounds := appendOutline(&p, outline, tr)
+	return p.End(), bounds
+}
+
+// appendOutline adds the outline transformed by tr to p and returns its
+// bounds.
+func appendOutline(p *clip.Path, outline api.GlyphOutline, tr f32.Affine2D) f32internal.Rectangle {
+	var bounds f32internal.Rectangle
+	first := true
+	pt := func(a api.SegmentPoint) f32.Point {
+		q := tr.Transform(f32.Point{X: a.X, Y: a.Y})
+		bounds = includePoint(bounds, q, first)
+		first = false
+		return q
+	}
+	for _, seg := range outline.Segments {
+		switch seg.Op {
+		case api.SegmentOpMoveTo:
+			p.MoveTo(pt(seg.Args[0]))
+		case api.SegmentOpLineTo:
+			p.LineTo(pt(seg.Args[0]))
+		case api.SegmentOpQuadTo:
+			p.QuadTo(pt(seg.Args[0]), pt(seg.Args[1]))
+		case api.SegmentOpCubeTo:
+			p.CubeTo(pt(seg.Args[0]), pt(seg.Args[1]), pt(seg.Args[2]))
+		}
+	}
+	return bounds
+}
+
+// invert returns the inverse of tr, if it exists.
+func invert(tr f32.Affine2D) (f32.Affine2D, bool) {
+	sx, hx, _, hy, sy, _ := tr.Elems()
+	if det := sx*sy - hx*hy; det == 0 || math.IsNaN(float64(det)) || math.IsInf(float64(det), 0) {
+		return f32.Affine2D{}, false
+	}
+	return tr.Invert(), true
+}
+
+// pixelScale returns the average scale factor of tr.
+func pixelScale(tr f32.Affine2D) float32 {
+	sx, hx, _, hy, sy, _ := tr.Elems()
+	return float32(math.Sqrt(math.Abs(float64(sx*sy - hx*hy))))
+}
+
+// transformRect returns the bounds of r transformed by tr.
+func transformRect(tr f32.Affine2D, r f32internal.Rectangle) f32internal.Rectangle {
+	corners := [4]f32.Point{
+		tr.Transform(r.Min),
+		tr.Transform(f32.Point{X: r.Max.X, Y: r.Min.Y}),
+		tr.Transform(r.Max),
+		tr.Transform(f32.Point{X: r.Min.X, Y: r.Max.Y}),
+	}
+	var out f32internal.Rectangle
+	for i, c := range corners {
+		out = includePoint(out, c, i == 0)
+	}
+	return out
+}
+
+// includePoint returns the smallest rectangle containing r and p. If first is
+// set, r is ignored.
+func includePoint(r f32internal.Rectangle, p f32.Point, first bool) f32internal.Rectangle {
+	if first {
+		return f32internal.Rectangle{Min: p, Max: p}
+	}
+	r.Min.X = float32(math.Min(float64(r.Min.X), float64(p.X)))
+	r.Min.Y = float32(math.Min(float64(r.Min.Y), float64(p.Y)))
+	r.Max.X = float32(math.Max(float64(r.Max.X), float64(p.X)))
+	r.Max.Y = float32(math.Max(float64(r.Max.Y), float64(p.Y)))
+	return r
+}
+
+// paintGradient fills the current clip with g. The clip has the given bounds
+// in the current coordinate space, and toGradient maps the current coordinate
+// space to the coordinate space of g. The gradient is rendered to an image
+// with a resolution of scale pixels per unit.
+func paintGradient(ops *op.Ops, g *gradient, bounds f32internal.Rectangle, toGradient f32.Affine2D, scale float32) {
+	if len(g.stops) == 0 || bounds.Empty() {
+		return
+	}
+	size := bounds.Size()
+	w := int(math.Ceil(float64(size.X * scale)))
+	h := int(math.Ceil(float64(size.Y * scale)))
+	w = max(1, min(w, maxGradientSize))
+	h = max(1, min(h, maxGradientSize))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := f32.Point{
+				X: bounds.Min.X + (float32(x)+.5)/float32(w)*size.X,
+				Y: bounds.Min.Y + (float32(y)+.5)/float32(h)*size.Y,
+			}
+			t, ok := g.param(toGradient.Transform(p))
+			if !ok {
+				continue
+			}
+			img.SetRGBA(x, y, g.colorAt(t))
+		}
+	}
+	tr := f32.Affine2D{}.
+		Scale(f32.Point{}, f32.Point{X: size.X / float32(w), Y: size.Y / float32(h)}).
+		Offset(bounds.Min)
+	defer op.Affine(tr).Push(ops).Pop()
+	paint.NewImageOp(img).Add(ops)
+	paint.PaintOp{}.Add(ops)
+}
+
+// param returns the gradient parameter at p, or false if the gradient
+// doesn't cover p.
+func (g *gradient) param(p f32.Point) (float32, bool) {
+	switch g.kind {
+	case gradientLinear:
+		// The gradient runs from p0 to p3, the projection of p1 onto the
+		// line through p0 perpendicular to the line from p0 to p2.
+		p3 := g.p1
+		if d := g.p2.Sub(g.p0); d != (f32.Point{}) {
+			perp := f32.Point{X: d.Y, Y: -d.X}
+			p3 = g.p0.Add(perp.Mul(dot(g.p1.Sub(g.p0), perp) / dot(perp, perp)))
+		}
+		v := p3.Sub(g.p0)
+		l := dot(v, v)
+		if l == 0 {
+			return 0, false
+		}
+		return dot(p.Sub(g.p0), v) / l, true
+	case gradientRadial:
+		// Find the largest t for which p lies on the circle interpolated
+		// between the start and end circles.
+		cd := g.p1.Sub(g.p0)
+		pd := p.Sub(g.p0)
+		dr := g.r1 - g.r0
+		a := dot(cd, cd) - dr*dr
+		b := dot(pd, cd) + g.r0*dr
+		c := dot(pd, pd) - g.r0*g.r0
+		if math.Abs(float64(a)) < 1e-6 {
+			if b == 0 {
+				return 0, false
+			}
+			t := c / (2 * b)
+			return t, g.r0+t*dr >= 0
+		}
+		disc := b*b - a*c
+		if disc < 0 {
+			return 0, false
+		}
+		sq := float32(math.Sqrt(float64(disc)))
+		t1, t2 := (b+sq)/a, (b-sq)/a
+		if t1 < t2 {
+			t1, t2 = t2, t1
+		}
+		if g.r0+t1*dr >= 0 {
+			return t1, true
+		}
+		return t2, g.r0+t2*dr >= 0
+	case gradientSweep:
+		d := p.Sub(g.p0)
+		angle := float32(math.Atan2(float64(d.Y), float64(d.X)) * 180 / math.Pi)
+		if angle < 0 {
+			angle += 360
+		}
+		if g.end == g.start {
+			return 0, false
+		}
+		return (angle - g.start) / (g.end - g.start), true
+	}
+	return 0, false
+}
+
+// colorAt returns the premultiplied color of the gradient at parameter t.
+func (g *gradient) colorAt(t float32) color.RGBA {
+	stops := g.stops
+	lo, hi := stops[0].offset, stops[len(stops)-1].offset
+	if hi > lo && g.extend != extendPad {
+		u := float64((t - lo) / (hi - lo))
+		switch g.extend {
+		case extendRepeat:
+			u -= math.Floor(u)
+		case extendReflect:
+			u = math.Mod(math.Abs(u), 2)
+			if u > 1 {
+				u = 2 - u
+			}
+		}
+		t = lo + float32(u)*(hi-lo)
+	}
+	if t <= lo {
+		return premultiply(stops[0].color)
+	}
+	if t >= hi {
+		return premultiply(stops[len(stops)-1].color)
+	}
+	for i := 1; i < len(stops); i++ {
+		s0, s1 := stops[i-1], stops[i]
+		if t > s1.offset {
+			continue
+		}
+		f := float32(0)
+		if s1.offset > s0.offset {
+			f = (t - s0.offset) / (s1.offset - s0.offset)
+		}
+		c0, c1 := premultiply(s0.color), premultiply(s1.color)
+		lerp := func(a, b uint8) uint8 {
+			return uint8(float32(a) + (float32(b)-float32(a))*f + .5)
+		}
+		return color.RGBA{R: lerp(c0.R, c1.R), G: lerp(c0.G, c1.G), B: lerp(c0.B, c1.B), A: lerp(c0.A, c1.A)}
+	}
+	return premultiply(stops[len(stops)-1].color)
+}
+
+func premultiply(c color.NRGBA) color.RGBA {
+	a := uint32(c.A)
+	return color.RGBA{
+		R: uint8(uint32(c.R) * a / 255),
+		G: uint8(uint32(c.G) * a / 255),
+		B: uint8(uint32(c.B) * a / 255),
+		A: c.A,
+	}
+}
+
+func dot(a, b f32.Point) float32 {
+	return a.X*b.X + a.Y*b.Y
+}
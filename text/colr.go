@@ -0,0 +1,491 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"math"
+	"sort"
+
+	"gioui.org/f32"
+	"github.com/go-text/typesetting/opentype/api"
+)
+
+// colrForeground is the palette index denoting the text color.
+const colrForeground = 0xFFFF
+
+// colrMaxDepth bounds the nesting of COLR paint graphs, which protects against
+// cycles in malformed fonts.
+const colrMaxDepth = 64
+
+var errCOLRBounds = errors.New("COLR: offset out of bounds")
+
+// colrTable is a parsed COLR table along with the colors of its CPAL table.
+// COLRv1 paint graphs are decoded on demand.
+type colrTable struct {
+	data []byte
+	// palette is the first CPAL palette.
+	palette []color.NRGBA
+	// baseGlyphs and layers describe COLRv0 glyphs.
+	baseGlyphs []colrBaseGlyph
+	layers     []colrLayer
+	// baseGlyphList and layerList are the offsets of the COLRv1
+	// BaseGlyphList and LayerList, or 0 if absent.
+	baseGlyphList, layerList int
+	// paints caches decoded COLRv1 paint graphs.
+	paints map[api.GID]colrPaint
+}
+
+type colrBaseGlyph struct {
+	gid          api.GID
+	first, count int
+}
+
+type colrLayer struct {
+	gid     api.GID
+	palette uint16
+}
+
+// colrPaint is a node of a COLR paint graph. It is one of colrLayers,
+// colrSolid, colrGradient, colrGlyph, colrColrGlyph, colrTransform or
+// colrComposite.
+type colrPaint interface{}
+
+// colrLayers paints its layers on top of each other.
+type colrLayers []colrPaint
+
+// colrSolid fills the current clip with a palette color.
+type colrSolid struct {
+	palette uint16
+	alpha   float32
+}
+
+type gradientKind uint8
+
+const (
+	gradientLinear gradientKind = iota
+	gradientRadial
+	gradientSweep
+)
+
+type gradientExtend uint8
+
+const (
+	extendPad gradientExtend = iota
+	extendRepeat
+	extendReflect
+)
+
+// colorStop is a color stop of a gradient, with its color already
+// resolved.
+type colorStop struct {
+	offset float32
+	color  color.NRGBA
+}
+
+// gradient describes a linear, radial or sweep gradient. Linear gradients
+// run from p0 to p1, with p2 orienting their isolines. Radial gradients
+// extend from the circle at p0 with radius r0 to the circle at p1 with
+// radius r1. Sweep gradients are centered at p0 and sweep from angle start
+// to angle end, in degrees counter-clockwise.
+type gradient struct {
+	kind       gradientKind
+	extend     gradientExtend
+	stops      []colorStop
+	p0, p1, p2 f32.Point
+	r0, r1     float32
+	start, end float32
+}
+
+// colrGradient fills the current clip with a gradient.
+type colrGradient struct {
+	gradient
+}
+
+// colrGlyph clips paint to the outline of a glyph.
+type colrGlyph struct {
+	gid   api.GID
+	paint colrPaint
+}
+
+// colrColrGlyph paints another color glyph.
+type colrColrGlyph struct {
+	gid api.GID
+}
+
+// colrTransform paints its child transformed by tr.
+type colrTransform struct {
+	tr    f32.Affine2D
+	paint colrPaint
+}
+
+// colrComposite composes src over backdrop.
+type colrComposite struct {
+	src, backdrop colrPaint
+}
+
+// parseCOLR parses the COLR table colr along with the CPAL table cpal.
+func parseCOLR(colr, cpal []byte) (*colrTable, error) {
+	if len(colr) < 14 {
+		return nil, errCOLRBounds
+	}
+	t := &colrTable{data: colr}
+	var err error
+	if t.palette, err = parseCPAL(cpal); err != nil {
+		return nil, err
+	}
+	version := binary.BigEndian.Uint16(colr)
+	numBase := int(binary.BigEndian.Uint16(colr[2:]))
+	baseOff := int(binary.BigEndian.Uint32(colr[4:]))
+	layerOff := int(binary.BigEndian.Uint32(colr[8:]))
+	numLayers := int(binary.BigEndian.Uint16(colr[12:]))
+	if numBase > 0 {
+		if baseOff+numBase*6 > len(colr) {
+			return nil, errCOLRBounds
+		}
+		t.baseGlyphs = make([]colrBaseGlyph, numBase)
+		for i := range t.baseGlyphs {
+			rec := colr[baseOff+i*6:]
+			t.baseGlyphs[i] = colrBaseGlyph{
+				gid:   api.GID(binary.BigEndian.Uint16(rec)),
+				first: int(binary.BigEndian.Uint16(rec[2:])),
+				count: int(binary.BigEndian.Uint16(rec[4:])),
+			}
+		}
+	}
+	if numLayers > 0 {
+		if layerOff+numLayers*4 > len(colr) {
+			return nil, errCOLRBounds
+		}
+		t.layers = make([]colrLayer, numLayers)
+		for i := range t.layers {
+			rec := colr[layerOff+i*4:]
+			t.layers[i] = colrLayer{
+				gid:     api.GID(binary.BigEndian.Uint16(rec)),
+				palette: binary.BigEndian.Uint16(rec[2:]),
+			}
+		}
+	}
+	if version >= 1 {
+		if len(colr) < 34 {
+			return nil, errCOLRBounds
+		}
+		t.baseGlyphList = int(binary.BigEndian.Uint32(colr[14:]))
+		t.layerList = int(binary.BigEndian.Uint32(colr[18:]))
+		t.paints = make(map[api.GID]colrPaint)
+	}
+	return t, nil
+}
+
+// parseCPAL returns the colors of the first palette of a CPAL table.
+func parseCPAL(cpal []byte) ([]color.NRGBA, error) {
+	if len(cpal) < 12 {
+		return nil, nil
+	}
+	numEntries := int(binary.BigEndian.Uint16(cpal[2:]))
+	numPalettes := int(binary.BigEndian.Uint16(cpal[4:]))
+	recordsOff := int(binary.BigEndian.Uint32(cpal[8:]))
+	if numPalettes == 0 || len(cpal) < 14 {
+		return nil, nil
+	}
+	first := int(binary.BigEndian.Uint16(cpal[12:]))
+	if recordsOff+(first+numEntries)*4 > len(cpal) {
+		return nil, errors.New("CPAL: color records out of bounds")
+	}
+	palette := make([]color.NRGBA, numEntries)
+	for i := range palette {
+		rec := cpal[recordsOff+(first+i)*4:]
+		palette[i] = color.NRGBA{B: rec[0], G: rec[1], R: rec[2], A: rec[3]}
+	}
+	return palette, nil
+}
+
+// color resolves a palette index and alpha to a color. It reports false
+// for the foreground color.
+func (t *colrTable) color(idx uint16, alpha float32) (color.NRGBA, bool) {
+	if idx == colrForeground || int(idx) >= len(t.palette) {
+		return color.NRGBA{}, false
+	}
+	c := t.palette[idx]
+	c.A = uint8(float32(c.A)*clamp01(alpha) + .5)
+	return c, true
+}
+
+// glyph returns the paint graph of the color glyph gid, or nil if gid is
+// not a color glyph. COLRv1 glyphs take precedence over COLRv0 glyphs.
+func (t *colrTable) glyph(gid api.GID) colrPaint {
+	if t.paints != nil {
+		if p, ok := t.paints[gid]; ok {
+			return p
+		}
+		p, _ := t.parseV1Glyph(gid)
+		t.paints[gid] = p
+		if p != nil {
+			return p
+		}
+	}
+	i := sort.Search(len(t.baseGlyphs), func(i int) bool {
+		return t.baseGlyphs[i].gid >= gid
+	})
+	if i == len(t.baseGlyphs) || t.baseGlyphs[i].gid != gid {
+		return nil
+	}
+	b := t.baseGlyphs[i]
+	if b.first+b.count > len(t.layers) {
+		return nil
+	}
+	var layers colrLayers
+	for _, l := range t.layers[b.first : b.first+b.count] {
+		layers = append(layers, colrGlyph{
+			gid:   l.gid,
+			paint: colrSolid{palette: l.palette, alpha: 1},
+		})
+	}
+	return layers
+}
+
+// parseV1Glyph decodes the COLRv1 paint graph of gid.
+func (t *colrTable) parseV1Glyph(gid api.GID) (colrPaint, error) {
+	d := t.data
+	if t.baseGlyphList == 0 || t.baseGlyphList+4 > len(d) {
+		return nil, nil
+	}
+	n := int(binary.BigEndian.Uint32(d[t.baseGlyphList:]))
+	recs := t.baseGlyphList + 4
+	if n < 0 || recs+n*6 > len(d) {
+		return nil, errCOLRBounds
+	}
+	i := sort.Search(n, func(i int) bool {
+		return api.GID(binary.BigEndian.Uint16(d[recs+i*6:])) >= gid
+	})
+	if i == n || api.GID(binary.BigEndian.Uint16(d[recs+i*6:])) != gid {
+		return nil, nil
+	}
+	off := t.baseGlyphList + int(binary.BigEndian.Uint32(d[recs+i*6+2:]))
+	return t.parsePaint(off, 0)
+}
+
+// parsePaint decodes the Paint table at offset off.
+func (t *colrTable) parsePaint(off, depth int) (colrPaint, error) {
+	if depth > colrMaxDepth {
+		return nil, errors.New("COLR: paint graph too deep")
+	}
+	r := colrReader{data: t.data, base: off, off: off}
+	format := r.u8()
+	// Variable formats have odd numbers and share the layout of the
+	// preceding format, followed by variation indices that are ignored.
+	switch format {
+	case 1:
+		numLayers := int(r.u8())
+		first := int(r.u32())
+		if t.layerList == 0 || t.layerList+4 > len(t.data) {
+			return nil, errCOLRBounds
+		}
+		total := int(binary.BigEndian.Uint32(t.data[t.layerList:]))
+		if first+numLayers > total || t.layerList+4+(first+numLayers)*4 > len(t.data) {
+			return nil, errCOLRBounds
+		}
+		layers := make(colrLayers, 0, numLayers)
+		for i := first; i < first+numLayers; i++ {
+			loff := t.layerList + int(binary.BigEndian.Uint32(t.data[t.layerList+4+i*4:]))
+			p, err := t.parsePaint(loff, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, p)
+		}
+		return layers, r.err
+	case 2, 3:
+		return colrSolid{palette: r.u16(), alpha: r.f2dot14()}, r.err
+	case 4, 5:
+		line := r.offset24()
+		g := colrGradient{gradient{kind: gradientLinear}}
+		g.p0 = r.point()
+		g.p1 = r.point()
+		g.p2 = r.point()
+		g.extend, g.stops = t.colorLine(line, format == 5)
+		return g, r.err
+	case 6, 7:
+		line := r.offset24()
+		g := colrGradient{gradient{kind: gradientRadial}}
+		g.p0 = r.point()
+		g.r0 = float32(r.u16())
+		g.p1 = r.point()
+		g.r1 = float32(r.u16())
+		g.extend, g.stops = t.colorLine(line, format == 7)
+		return g, r.err
+	case 8, 9:
+		line := r.offset24()
+		g := colrGradient{gradient{kind: gradientSweep}}
+		g.p0 = r.point()
+		// Angles are encoded with a bias of 180°.
+		g.start = (r.f2dot14() + 1) * 180
+		g.end = (r.f2dot14() + 1) * 180
+		g.extend, g.stops = t.colorLine(line, format == 9)
+		return g, r.err
+	case 10:
+		child := r.offset24()
+		gid := api.GID(r.u16())
+		p, err := t.parsePaint(child, depth+1)
+		return colrGlyph{gid: gid, paint: p}, firstErr(r.err, err)
+	case 11:
+		return colrColrGlyph{gid: api.GID(r.u16())}, r.err
+	case 32:
+		src := r.offset24()
+		r.u8() // Composite mode.
+		backdrop := r.offset24()
+		s, err := t.parsePaint(src, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := t.parsePaint(backdrop, depth+1)
+		return colrComposite{src: s, backdrop: b}, firstErr(r.err, err)
+	}
+	if format < 12 || format > 31 {
+		// Unknown formats paint nothing.
+		return nil, nil
+	}
+	child := r.offset24()
+	var tr f32.Affine2D
+	switch format {
+	case 12, 13:
+		// The transform is stored in a separate Affine2x3 table.
+		a := colrReader{data: t.data, off: r.offset24()}
+		xx, yx, xy, yy, dx, dy := a.fixed(), a.fixed(), a.fixed(), a.fixed(), a.fixed(), a.fixed()
+		tr = f32.NewAffine2D(xx, xy, dx, yx, yy, dy)
+		r.err = firstErr(r.err, a.err)
+	case 14, 15:
+		tr = tr.Offset(r.point())
+	case 16, 17, 18, 19:
+		sx, sy := r.f2dot14(), r.f2dot14()
+		var c f32.Point
+		if format >= 18 {
+			c = r.point()
+		}
+		tr = aroundCenter(f32.NewAffine2D(sx, 0, 0, 0, sy, 0), c)
+	case 20, 21, 22, 23:
+		s := r.f2dot14()
+		var c f32.Point
+		if format >= 22 {
+			c = r.point()
+		}
+		tr = aroundCenter(f32.NewAffine2D(s, 0, 0, 0, s, 0), c)
+	case 24, 25, 26, 27:
+		a := r.f2dot14() * math.Pi
+		var c f32.Point
+		if format >= 26 {
+			c = r.point()
+		}
+		sin, cos := math.Sincos(float64(a))
+		tr = aroundCenter(f32.NewAffine2D(float32(cos), float32(-sin), 0, float32(sin), float32(cos), 0), c)
+	case 28, 29, 30, 31:
+		ax, ay := r.f2dot14()*math.Pi, r.f2dot14()*math.Pi
+		var c f32.Point
+		if format >= 30 {
+			c = r.point()
+		}
+		tx, ty := math.Tan(float64(ax)), math.Tan(float64(ay))
+		tr = aroundCenter(f32.NewAffine2D(1, float32(-tx), 0, float32(ty), 1, 0), c)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	p, err := t.parsePaint(child, depth+1)
+	return colrTransform{tr: tr, paint: p}, err
+}
+
+// colorLine decodes the ColorLine or VarColorLine at off.
+func (t *colrTable) colorLine(off int, variable bool) (gradientExtend, []colorStop) {
+	r := colrReader{data: t.data, off: off}
+	extend := gradientExtend(r.u8())
+	if extend > extendReflect {
+		extend = extendPad
+	}
+	n := int(r.u16())
+	stops := make([]colorStop, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		s := colorStop{offset: r.f2dot14()}
+		idx, alpha := r.u16(), r.f2dot14()
+		if variable {
+			r.u32()
+		}
+		s.color, _ = t.color(idx, alpha)
+		if idx == colrForeground {
+			// Gradients are painted without the text material, so stops
+			// of the text color are black.
+			s.color.A = uint8(clamp01(alpha)*255 + .5)
+		}
+		stops = append(stops, s)
+	}
+	if r.err != nil {
+		return extend, nil
+	}
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].offset < stops[j].offset
+	})
+	return extend, stops
+}
+
+// aroundCenter returns tr applied around center c.
+func aroundCenter(tr f32.Affine2D, c f32.Point) f32.Affine2D {
+	if c == (f32.Point{}) {
+		return tr
+	}
+	return f32.Affine2D{}.Offset(c).Mul(tr).Mul(f32.Affine2D{}.Offset(c.Mul(-1)))
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clamp01(v float32) float32 {
+	return float32(math.Max(0, math.Min(1, float64(v))))
+}
+
+// colrReader reads big endian values from a COLR table. Offsets are
+// relative to base. Out of bounds reads return zero and set err.
+type colrReader struct {
+	data      []byte
+	base, off int
+	err       error
+}
+
+func (r *colrReader) next(n int) []byte {
+	if r.err != nil || r.off < 0 || r.off+n > len(r.data) {
+		r.err = errCOLRBounds
+		return make([]byte, n)
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *colrReader) u8() uint8   { return r.next(1)[0] }
+func (r *colrReader) u16() uint16 { return binary.BigEndian.Uint16(r.next(2)) }
+func (r *colrReader) u32() uint32 { return binary.BigEndian.Uint32(r.next(4)) }
+
+func (r *colrReader) offset24() int {
+	b := r.next(3)
+	return r.base + (int(b[0])<<16 | int(b[1])<<8 | int(b[2]))
+}
+
+func (r *colrReader) f2dot14() float32 {
+	return float32(int16(r.u16())) / (1 << 14)
+}
+
+func (r *colrReader) fixed() float32 {
+	return float32(int32(r.u32())) / (1 << 16)
+}
+
+func (r *colrReader) point() f32.Point {
+	x := int16(r.u16())
+	y := int16(r.u16())
+	return f32.Point{X: float32(x), Y: float32(y)}
+}
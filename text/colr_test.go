@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+
+	"github.com/go-text/typesetting/opentype/api"
+	"github.com/go-text/typesetting/opentype/loader"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+
+	"gioui.org/f32"
+	"gioui.org/font/opentype"
+	f32internal "gioui.org/internal/f32"
+	"gioui.org/op"
+)
+
+// testCPAL is a CPAL table with a single palette of red and
+// translucent blue.
+func testCPAL() []byte {
+	b := binary.BigEndian.AppendUint16(nil, 0) // version
+	b = binary.BigEndian.AppendUint16(b, 2)    // numPaletteEntries
+	b = binary.BigEndian.AppendUint16(b, 1)    // numPalettes
+	b = binary.BigEndian.AppendUint16(b, 2)    // numColorRecords
+	b = binary.BigEndian.AppendUint32(b, 14)   // colorRecordsArrayOffset
+	b = binary.BigEndian.AppendUint16(b, 0)    // colorRecordIndices[0]
+	b = append(b, 0, 0, 0xff, 0xff)            // red, in BGRA order
+	b = append(b, 0xff, 0, 0, 0x80)            // translucent blue
+	return b
+}
+
+// testCOLRv0 is a COLR table describing glyph base as a layer of glyph
+// layer0 in palette color 1 on top of glyph layer1 in the text color.
+func testCOLRv0(base, layer0, layer1 api.GID) []byte {
+	b := binary.BigEndian.AppendUint16(nil, 0) // version
+	b = binary.BigEndian.AppendUint16(b, 1)    // numBaseGlyphRecords
+	b = binary.BigEndian.AppendUint32(b, 14)   // baseGlyphRecordsOffset
+	b = binary.BigEndian.AppendUint32(b, 20)   // layerRecordsOffset
+	b = binary.BigEndian.AppendUint16(b, 2)    // numLayerRecords
+	b = binary.BigEndian.AppendUint16(b, uint16(base))
+	b = binary.BigEndian.AppendUint16(b, 0) // firstLayerIndex
+	b = binary.BigEndian.AppendUint16(b, 2) // numLayers
+	b = binary.BigEndian.AppendUint16(b, uint16(layer1))
+	b = binary.BigEndian.AppendUint16(b, colrForeground)
+	b = binary.BigEndian.AppendUint16(b, uint16(layer0))
+	b = binary.BigEndian.AppendUint16(b, 1)
+	return b
+}
+
+func TestCOLRv0(t *testing.T) {
+	colr, err := parseCOLR(testCOLRv0(10, 20, 30), testCPAL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := colr.glyph(11); p != nil {
+		t.Errorf("glyph 11 is not a color glyph, got %#v", p)
+	}
+	layers, ok := colr.glyph(10).(colrLayers)
+	if !ok || len(layers) != 2 {
+		t.Fatalf("expected 2 layers, got %#v", colr.glyph(10))
+	}
+	fg := layers[0].(colrGlyph)
+	if fg.gid != 30 || fg.paint.(colrSolid).palette != colrForeground {
+		t.Errorf("unexpected first layer %#v", fg)
+	}
+	blue := layers[1].(colrGlyph)
+	if blue.gid != 20 {
+		t.Errorf("unexpected second layer %#v", blue)
+	}
+	c, ok := colr.color(blue.paint.(colrSolid).palette, 1)
+	if exp := (color.NRGBA{B: 0xff, A: 0x80}); !ok || c != exp {
+		t.Errorf("expected color %v, got %v", exp, c)
+	}
+}
+
+func TestCOLRv1(t *testing.T) {
+	// Header.
+	b := binary.BigEndian.AppendUint16(nil, 1) // version
+	b = binary.BigEndian.AppendUint16(b, 0)    // numBaseGlyphRecords
+	b = binary.BigEndian.AppendUint32(b, 0)    // baseGlyphRecordsOffset
+	b = binary.BigEndian.AppendUint32(b, 0)    // layerRecordsOffset
+	b = binary.BigEndian.AppendUint16(b, 0)    // numLayerRecords
+	b = binary.BigEndian.AppendUint32(b, 34)   // baseGlyphListOffset
+	b = binary.BigEndian.AppendUint32(b, 0)    // layerListOffset
+	b = binary.BigEndian.AppendUint32(b, 0)    // clipListOffset
+	b = binary.BigEndian.AppendUint32(b, 0)    // varIndexMapOffset
+	b = binary.BigEndian.AppendUint32(b, 0)    // itemVariationStoreOffset
+	// BaseGlyphList at 34 with a single record.
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint16(b, 5)
+	b = binary.BigEndian.AppendUint32(b, 10) // paintOffset
+	// PaintTranslate at 44.
+	b = append(b, 14, 0, 0, 8) // format, paintOffset
+	b = binary.BigEndian.AppendUint16(b, 100)
+	b = binary.BigEndian.AppendUint16(b, 0)
+	// PaintGlyph at 52.
+	b = append(b, 10, 0, 0, 6) // format, paintOffset
+	b = binary.BigEndian.AppendUint16(b, 7)
+	// PaintLinearGradient at 58.
+	b = append(b, 4, 0, 0, 16) // format, colorLineOffset
+	for _, v := range []uint16{0, 0, 100, 0, 0, 100} {
+		b = binary.BigEndian.AppendUint16(b, v)
+	}
+	// ColorLine at 74.
+	b = append(b, byte(extendReflect))
+	b = binary.BigEndian.AppendUint16(b, 2)
+	b = binary.BigEndian.AppendUint16(b, 1<<14) // stopOffset 1.0
+	b = binary.BigEndian.AppendUint16(b, 1)
+	b = binary.BigEndian.AppendUint16(b, 1<<14)
+	b = binary.BigEndian.AppendUint16(b, 0) // stopOffset 0.0
+	b = binary.BigEndian.AppendUint16(b, 0)
+	b = binary.BigEndian.AppendUint16(b, 1<<14)
+
+	colr, err := parseCOLR(b, testCPAL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, ok := colr.glyph(5).(colrTransform)
+	if !ok {
+		t.Fatalf("expected transform, got %#v", colr.glyph(5))
+	}
+	if got := tr.tr.Transform(f32.Point{}); got != (f32.Point{X: 100}) {
+		t.Errorf("expected translation by (100, 0), got %v", got)
+	}
+	g, ok := tr.paint.(colrGlyph)
+	if !ok || g.gid != 7 {
+		t.Fatalf("expected glyph 7, got %#v", tr.paint)
+	}
+	grad, ok := g.paint.(colrGradient)
+	if !ok {
+		t.Fatalf("expected gradient, got %#v", g.paint)
+	}
+	if grad.extend != extendReflect || len(grad.stops) != 2 {
+		t.Fatalf("unexpected gradient %#v", grad)
+	}
+	if s := grad.stops[0]; s.offset != 0 || s.color != (color.NRGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("stops not sorted: %#v", grad.stops)
+	}
+	for _, tc := range []struct {
+		x float32
+		c color.RGBA
+	}{
+		{x: 0, c: color.RGBA{R: 0xff, A: 0xff}},
+		{x: 100, c: color.RGBA{B: 0x80, A: 0x80}},
+		// Reflected.
+		{x: 200, c: color.RGBA{R: 0xff, A: 0xff}},
+	} {
+		param, ok := grad.param(f32.Point{X: tc.x, Y: 50})
+		if !ok {
+			t.Errorf("gradient doesn't cover x=%v", tc.x)
+			continue
+		}
+		if c := grad.colorAt(param); c != tc.c {
+			t.Errorf("color at x=%v: expected %v, got %v", tc.x, tc.c, c)
+		}
+	}
+}
+
+// TestColorFace checks that the color tables of faces loaded
+// from an opentype.Face are found.
+func TestColorFace(t *testing.T) {
+	ld, err := loader.NewLoader(bytes.NewReader(goregular.TTF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []loader.Table
+	for _, tag := range ld.Tables() {
+		data, err := ld.RawTable(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, loader.Table{Tag: tag, Content: data})
+	}
+	tables = append(tables,
+		loader.Table{Tag: loader.MustNewTag("COLR"), Content: testCOLRv0(36, 37, 38)},
+		loader.Table{Tag: loader.MustNewTag("CPAL"), Content: testCPAL()},
+	)
+	face, err := opentype.Parse(loader.WriteTTF(tables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shaper := NewShaper(NoSystemFonts(), WithCollection([]FontFace{{Face: face}}))
+	shaper.init()
+	if _, p := shaper.shaper.colrGlyph(0, 36); p == nil {
+		t.Error("color glyph not found")
+	}
+	if _, p := shaper.shaper.colrGlyph(0, 37); p != nil {
+		t.Error("unexpected color glyph")
+	}
+	// Render the color glyph to exercise the paint graph.
+	gs := []Glyph{{ID: newGlyphID(fixed.I(16), 0, 36), Advance: fixed.I(10)}}
+	shaper.Shape(gs)
+	shaper.Bitmaps(gs)
+}
+
+func TestSVGPath(t *testing.T) {
+	var p svgPath
+	if err := p.parse("M10-10l5,5h5v-5z m1.5.5L0 0a5 5 0 011 1"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []svgSegOp{svgMoveTo, svgLineTo, svgLineTo, svgLineTo, svgClose, svgMoveTo, svgLineTo}
+	if len(p.segs) < len(expected) {
+		t.Fatalf("expected at least %d segments, got %d", len(expected), len(p.segs))
+	}
+	for i, op := range expected {
+		if p.segs[i].op != op {
+			t.Errorf("segment %d: expected op %d, got %d", i, op, p.segs[i].op)
+		}
+	}
+	if got := p.segs[3].args[0]; got != (f32.Point{X: 20, Y: -10}) {
+		t.Errorf("expected vertical line to (20,-10), got %v", got)
+	}
+	// The relative move starts at the start of the closed subpath.
+	if got := p.segs[5].args[0]; got != (f32.Point{X: 11.5, Y: -9.5}) {
+		t.Errorf("expected move to (11.5,-9.5), got %v", got)
+	}
+	last := p.segs[len(p.segs)-1]
+	if last.op != svgCubeTo || last.args[2] != (f32.Point{X: 1, Y: 1}) {
+		t.Errorf("expected arc ending at (1,1), got %#v", last)
+	}
+	if err := p.parse("10 10"); err == nil {
+		t.Error("expected error for path data without command")
+	}
+}
+
+func TestSVGColor(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		out color.NRGBA
+	}{
+		{in: "#f00", out: color.NRGBA{R: 0xff, A: 0xff}},
+		{in: "#00ff0080", out: color.NRGBA{G: 0xff, A: 0x80}},
+		{in: "rgb(0, 0, 255)", out: color.NRGBA{B: 0xff, A: 0xff}},
+		{in: "rgba(255,255,255,0.5)", out: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x80}},
+		{in: "Orange", out: color.NRGBA{R: 0xff, G: 0xa5, A: 0xff}},
+	} {
+		c, ok := parseSVGColor(tc.in)
+		if !ok || c != tc.out {
+			t.Errorf("parseSVGColor(%q) = %v, %v, expected %v", tc.in, c, ok, tc.out)
+		}
+	}
+}
+
+func TestSVGRender(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">
+<defs>
+  <linearGradient id="g"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+  <radialGradient id="r" xlink:href="#g" cx="0.3"/>
+</defs>
+<g id="glyph3" transform="translate(10 0) scale(2)" opacity="0.5">
+  <rect width="100" height="100" fill="url(#g)"/>
+  <circle cx="50" cy="-50" r="20" style="fill: url(#r)"/>
+  <path d="M0 0 L10 0 L10 -10 Z" fill="#123456"/>
+</g>
+</svg>`
+	doc, err := parseSVG([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := doc.ids[svgGlyphID(3)]
+	if n == nil {
+		t.Fatal("glyph element not found")
+	}
+	r := &svgRenderer{ops: new(op.Ops), doc: doc}
+	r.render(n, svgStyle{fillOpacity: 1}, f32.Affine2D{}, 0)
+	bounds := f32internal.Rectangle{Max: f32.Point{X: 1, Y: 1}}
+	g, tr, ok := r.gradient(doc.ids["r"], bounds, 1)
+	if !ok || g.kind != gradientRadial || len(g.stops) != 2 {
+		t.Fatalf("inherited radial gradient not resolved: %#v", g)
+	}
+	if c := tr.Transform(f32.Point{X: 1, Y: 1}); c != (f32.Point{X: 1, Y: 1}) {
+		t.Errorf("unexpected bounding box transform %v", tr)
+	}
+}
@@ -0,0 +1,363 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-text/typesetting/opentype/api"
+	"golang.org/x/image/math/fixed"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+)
+
+// Decoration is a set of lines drawn along text.
+type Decoration uint8
+
+const (
+	// Underline draws a line below the baseline. The line is interrupted
+	// where it would cross the descenders of glyphs.
+	Underline Decoration = 1 << iota
+	// WavyUnderline draws a wavy line below the baseline, such as the
+	// squiggle marking misspelled words. It crosses descenders.
+	WavyUnderline
+	// Strikethrough draws a line through the middle of lowercase letters.
+	Strikethrough
+)
+
+func (d Decoration) String() string {
+	var b []byte
+	for _, f := range [...]struct {
+		d    Decoration
+		name string
+	}{{Underline, "Underline"}, {WavyUnderline, "WavyUnderline"}, {Strikethrough, "Strikethrough"}} {
+		if d&f.d == 0 {
+			continue
+		}
+		if len(b) > 0 {
+			b = append(b, '|')
+		}
+		b = append(b, f.name...)
+	}
+	if len(b) == 0 {
+		return "None"
+	}
+	return string(b)
+}
+
+// DecorationMetrics describes the lines of decorations as specified by a font
+// at a particular size. Offsets are the distances from the baseline to the top
+// edge of the lines, increasing downwards like the Y axis.
+type DecorationMetrics struct {
+	UnderlineOffset        fixed.Int26_6
+	UnderlineThickness     fixed.Int26_6
+	StrikethroughOffset    fixed.Int26_6
+	StrikethroughThickness fixed.Int26_6
+}
+
+// decorationMetrics reads the underline metrics of the face at faceIdx from
+// its post table, and the strikethrough metrics from its OS/2 table. Fonts
+// lacking the metrics are given positions and thicknesses relative to their
+// em size and x-height.
+func (s *shaperImpl) decorationMetrics(faceIdx int, ppem fixed.Int26_6) (DecorationMetrics, bool) {
+	if faceIdx >= len(s.faces) || s.faces[faceIdx] == nil {
+		return DecorationMetrics{}, false
+	}
+	face := s.faces[faceIdx]
+	upem := float32(face.Upem())
+	underlinePos := face.LineMetric(api.UnderlinePosition)
+	underlineSize := face.LineMetric(api.UnderlineThickness)
+	if underlineSize <= 0 {
+		underlineSize = upem / 14
+		if underlinePos == 0 {
+			underlinePos = -upem / 10
+		}
+	}
+	strikePos := face.LineMetric(api.StrikethroughPosition)
+	strikeSize := face.LineMetric(api.StrikethroughThickness)
+	if strikeSize <= 0 {
+		strikeSize = underlineSize
+		xHeight := face.LineMetric(api.XHeight)
+		if xHeight <= 0 {
+			xHeight = upem / 2
+		}
+		strikePos = (xHeight + strikeSize) / 2
+	}
+	scale := fixedToFloat(ppem) / upem
+	return DecorationMetrics{
+		UnderlineOffset:        floatToFixed(-underlinePos * scale),
+		UnderlineThickness:     floatToFixed(underlineSize * scale),
+		StrikethroughOffset:    floatToFixed(-strikePos * scale),
+		StrikethroughThickness: floatToFixed(strikeSize * scale),
+	}, true
+}
+
+// decorationKey extends the path cache key of gs with the decoration and the
+// glyph advances, which determine the length of the lines.
+func decorationKey(key uint64, gs []Glyph, d Decoration) uint64 {
+	key += uint64(d)
+	key *= 6585573582091643
+	for _, g := range gs {
+		key += uint64(g.Advance)
+		key *= 3650802748644053
+	}
+	return key
+}
+
+// Decorations returns the path of the lines of d drawn along gs, relative to
+// the dot of the first glyph like the path returned by Shape. Consecutive
+// glyphs on the same line with identical metrics share a line. Vertical glyphs
+// are not decorated.
+func (s *shaperImpl) Decorations(pathOps *op.Ops, gs []Glyph, d Decoration) clip.PathSpec {
+	var builder clip.Path
+	builder.Begin(pathOps)
+	if len(gs) == 0 {
+		return builder.End()
+	}
+	x, y := gs[0].X, gs[0].Y
+	for start := 0; start < len(gs); {
+		g := gs[start]
+		ppem, faceIdx, _ := splitGlyphID(g.ID)
+		m, ok := s.decorationMetrics(faceIdx, ppem)
+		if !ok || g.Flags&FlagVertical != 0 {
+			start++
+			continue
+		}
+		end := start + 1
+		left, right := g.X, g.X+g.Advance
+		for ; end < len(gs); end++ {
+			next := gs[end]
+			if next.Y != g.Y || next.Flags&FlagVertical != 0 {
+				break
+			}
+			ppem, faceIdx, _ := splitGlyphID(next.ID)
+			if nm, ok := s.decorationMetrics(faceIdx, ppem); !ok || nm != m {
+				break
+			}
+			if next.X < left {
+				left = next.X
+			}
+			if r := next.X + next.Advance; r > right {
+				right = r
+			}
+		}
+		span := gs[start:end]
+		start = end
+		if right <= left {
+			continue
+		}
+		x0, x1 := fixedToFloat(left-x), fixedToFloat(right-x)
+		baseline := float32(g.Y - y)
+		if d&Underline != 0 {
+			top, thick := decorationLine(m.UnderlineOffset, m.UnderlineThickness)
+			top += baseline
+			ink := s.inkIntervals(span, x, y, top-thick, top+2*thick)
+			for _, seg := range subtractIntervals(x0, x1, ink, thick) {
+				appendRect(&builder, seg[0], top, seg[1], top+thick)
+			}
+		}
+		if d&WavyUnderline != 0 {
+			top, thick := decorationLine(m.UnderlineOffset, m.UnderlineThickness)
+			appendWave(&builder, x0, x1, baseline+top, thick)
+		}
+		if d&Strikethrough != 0 {
+			top, thick := decorationLine(m.StrikethroughOffset, m.StrikethroughThickness)
+			top += baseline
+			appendRect(&builder, x0, top, x1, top+thick)
+		}
+	}
+	return builder.End()
+}
+
+// decorationLine converts the offset and thickness of a line to pixels,
+// making the line at least one pixel thick.
+func decorationLine(offset, thickness fixed.Int26_6) (top, thick float32) {
+	thick = fixedToFloat(thickness)
+	if thick < 1 {
+		thick = 1
+	}
+	return fixedToFloat(offset), thick
+}
+
+// inkIntervals returns the horizontal extents of the parts of the outlines of
+// gs between y0 and y1, relative to the dot (x, y).
+func (s *shaperImpl) inkIntervals(gs []Glyph, x fixed.Int26_6, y int32, y0, y1 float32) [][2]float32 {
+	var ink [][2]float32
+	for _, g := range gs {
+		if g.Flags&FlagSideways != 0 {
+			continue
+		}
+		lineY := float32(g.Y - y)
+		if fixedToFloat(g.Bounds.Max.Y)+lineY < y0 || fixedToFloat(g.Bounds.Min.Y)+lineY > y1 {
+			continue
+		}
+		ppem, faceIdx, gid := splitGlyphID(g.ID)
+		face := s.faces[faceIdx]
+		outline, ok := face.GlyphData(gid).(api.GlyphOutline)
+		if !ok {
+			continue
+		}
+		scale := glyphTransform(g, fixedToFloat(ppem)/float32(face.Upem()))
+		tr := scale.Offset(glyphOrigin(g, x, y).Add(f32.Pt(0, lineY)))
+		minX, maxX := float32(math.Inf(1)), float32(math.Inf(-1))
+		add := func(p0, p1 f32.Point) {
+			if x0, x1, ok := clipSegment(p0, p1, y0, y1); ok {
+				minX = float32(math.Min(float64(minX), float64(x0)))
+				maxX = float32(math.Max(float64(maxX), float64(x1)))
+			}
+		}
+		flattenOutline(outline, tr, add)
+		if minX <= maxX {
+			ink = append(ink, [2]float32{minX, maxX})
+		}
+	}
+	return ink
+}
+
+// flattenOutline calls line for every line segment of an approximation of
+// the closed contours of outline transformed by tr.
+func flattenOutline(outline api.GlyphOutline, tr f32.Affine2D, line func(p0, p1 f32.Point)) {
+	const steps = 8
+	var start, pen f32.Point
+	for i, seg := range outline.Segments {
+		var args [3]f32.Point
+		for j := range args {
+			args[j] = tr.Transform(f32.Point{X: seg.Args[j].X, Y: seg.Args[j].Y})
+		}
+		switch seg.Op {
+		case api.SegmentOpMoveTo:
+			if i > 0 {
+				line(pen, start)
+			}
+			start, pen = args[0], args[0]
+		case api.SegmentOpLineTo:
+			line(pen, args[0])
+			pen = args[0]
+		case api.SegmentOpQuadTo:
+			prev := pen
+			for k := 1; k <= steps; k++ {
+				t := float32(k) / steps
+				u := 1 - t
+				p := pen.Mul(u * u).Add(args[0].Mul(2 * u * t)).Add(args[1].Mul(t * t))
+				line(prev, p)
+				prev = p
+			}
+			pen = args[1]
+		case api.SegmentOpCubeTo:
+			prev := pen
+			for k := 1; k <= steps; k++ {
+				t := float32(k) / steps
+				u := 1 - t
+				p := pen.Mul(u * u * u).Add(args[0].Mul(3 * u * u * t)).Add(args[1].Mul(3 * u * t * t)).Add(args[2].Mul(t * t * t))
+				line(prev, p)
+				prev = p
+			}
+			pen = args[2]
+		}
+	}
+	if len(outline.Segments) > 0 {
+		line(pen, start)
+	}
+}
+
+// clipSegment returns the horizontal extent of the part of the line segment
+// from p0 to p1 between y0 and y1, if any.
+func clipSegment(p0, p1 f32.Point, y0, y1 float32) (x0, x1 float32, ok bool) {
+	if (p0.Y < y0 && p1.Y < y0) || (p0.Y > y1 && p1.Y > y1) {
+		return 0, 0, false
+	}
+	t0, t1 := float32(0), float32(1)
+	if dy := p1.Y - p0.Y; dy != 0 {
+		ta, tb := (y0-p0.Y)/dy, (y1-p0.Y)/dy
+		if ta > tb {
+			ta, tb = tb, ta
+		}
+		t0, t1 = clamp01(ta), clamp01(tb)
+	}
+	x0 = p0.X + (p1.X-p0.X)*t0
+	x1 = p0.X + (p1.X-p0.X)*t1
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	return x0, x1, true
+}
+
+// subtractIntervals returns the parts of the interval from x0 to x1 that are
+// farther than gap from every interval in cut.
+func subtractIntervals(x0, x1 float32, cut [][2]float32, gap float32) [][2]float32 {
+	sort.Slice(cut, func(i, j int) bool {
+		return cut[i][0] < cut[j][0]
+	})
+	var res [][2]float32
+	pos := x0
+	for _, c := range cut {
+		if c[0]-gap > pos {
+			res = append(res, [2]float32{pos, min32(c[0]-gap, x1)})
+		}
+		if c[1]+gap > pos {
+			pos = c[1] + gap
+		}
+		if pos >= x1 {
+			break
+		}
+	}
+	if pos < x1 {
+		res = append(res, [2]float32{pos, x1})
+	}
+	return res
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// appendRect adds the rectangle from (x0, y0) to (x1, y1) to p.
+func appendRect(p *clip.Path, x0, y0, x1, y1 float32) {
+	if x1 <= x0 {
+		return
+	}
+	p.MoveTo(f32.Pt(x0, y0))
+	p.LineTo(f32.Pt(x1, y0))
+	p.LineTo(f32.Pt(x1, y1))
+	p.LineTo(f32.Pt(x0, y1))
+	p.Close()
+}
+
+// appendWave adds a wavy line from x0 to x1 to p, oscillating around the
+// straight line of thickness thick whose top edge is at top.
+func appendWave(p *clip.Path, x0, x1, top, thick float32) {
+	if x1 <= x0 {
+		return
+	}
+	// Each half wave is twice as long as the line is thick, and rises or
+	// falls by its thickness.
+	n := int(math.Round(float64((x1 - x0) / (2 * thick))))
+	if n < 1 {
+		n = 1
+	}
+	half := (x1 - x0) / float32(n)
+	bottom := top + thick
+	dir := func(i int) float32 {
+		if i%2 == 0 {
+			return 2 * thick
+		}
+		return -2 * thick
+	}
+	p.MoveTo(f32.Pt(x0, top))
+	for i := 0; i < n; i++ {
+		xi := x0 + float32(i)*half
+		p.QuadTo(f32.Pt(xi+half/2, top+dir(i)), f32.Pt(xi+half, top))
+	}
+	p.LineTo(f32.Pt(x1, bottom))
+	for i := n - 1; i >= 0; i-- {
+		xi := x0 + float32(i)*half
+		p.QuadTo(f32.Pt(xi+half/2, bottom+dir(i)), f32.Pt(xi, bottom))
+	}
+	p.Close()
+}
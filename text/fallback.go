@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/go-text/typesetting/fontscan"
+	"github.com/go-text/typesetting/language"
+
+	giofont "gioui.org/font"
+)
+
+// Fallback is an ordered chain of font families that the shaper prefers for
+// the runes of some scripts, in text of some language. Fallbacks are
+// registered with [WithFallback].
+//
+// For example, Han characters are shared by Chinese and Japanese, but the
+// preferred glyph variants differ. The following fallbacks select Japanese
+// faces for Japanese text and Chinese faces otherwise:
+//
+//	text.WithFallback(
+//		text.Fallback{Language: "ja", Scripts: []string{"Hani", "Hira", "Kana"}, Typeface: "Noto Sans CJK JP, Hiragino Sans"},
+//		text.Fallback{Scripts: []string{"Hani"}, Typeface: "Noto Sans CJK SC, PingFang SC"},
+//	)
+type Fallback struct {
+	// Language is the BCP 47 language tag the Locale.Language of the text
+	// must match, either exactly or by its primary subtag; a fallback for
+	// "zh" applies to "zh-TW" text. An empty Language matches every language.
+	Language string
+	// Scripts lists the ISO 15924 codes, such as "Hani" or "Arab", of the
+	// scripts the fallback applies to. Runes of the Common and Inherited
+	// scripts, such as spaces and punctuation, take the script of the
+	// preceding rune. An empty list matches every script.
+	Scripts []string
+	// Typeface lists the font families to try, in order.
+	Typeface giofont.Typeface
+}
+
+// fallbackChain is a parsed Fallback.
+type fallbackChain struct {
+	language string
+	scripts  []language.Script
+	families []string
+}
+
+// fallbackKey identifies a lookup of the fallback families.
+type fallbackKey struct {
+	language string
+	script   language.Script
+}
+
+// setFallbacks parses and registers the fallback chains. Invalid chains
+// are logged and ignored.
+func (s *shaperImpl) setFallbacks(fallbacks []Fallback) {
+	for _, f := range fallbacks {
+		families, err := s.parser.parse(string(f.Typeface))
+		if err != nil {
+			s.logger.Printf("Unable to parse fallback typeface %q: %v", f.Typeface, err)
+			continue
+		}
+		c := fallbackChain{
+			language: strings.ToLower(f.Language),
+			families: slices.Clone(families),
+		}
+		for _, sc := range f.Scripts {
+			script, err := language.ParseScript(sc)
+			if err != nil {
+				s.logger.Printf("Invalid fallback script %q: %v", sc, err)
+				continue
+			}
+			c.scripts = append(c.scripts, script)
+		}
+		s.fallbacks = append(s.fallbacks, c)
+	}
+	s.fallbackCache = make(map[fallbackKey][]string)
+}
+
+// fallbackFamilies returns the concatenated families of the fallback chains
+// matching the language and script, most specific chains first. Language
+// matches take precedence over script matches, and chains of equal
+// specificity keep their registration order.
+func (s *shaperImpl) fallbackFamilies(lang string, script language.Script) []string {
+	key := fallbackKey{language: lang, script: script}
+	if families, ok := s.fallbackCache[key]; ok {
+		return families
+	}
+	primary := lang
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		primary = lang[:i]
+	}
+	type match struct {
+		score int
+		chain *fallbackChain
+	}
+	var matches []match
+	for i := range s.fallbacks {
+		c := &s.fallbacks[i]
+		var score int
+		switch c.language {
+		case lang:
+			score = 6
+		case primary:
+			score = 4
+		case "":
+			score = 2
+		default:
+			continue
+		}
+		switch {
+		case len(c.scripts) == 0:
+		case slices.Contains(c.scripts, script):
+			score++
+		default:
+			continue
+		}
+		matches = append(matches, match{score: score, chain: c})
+	}
+	slices.SortStableFunc(matches, func(a, b match) int {
+		return b.score - a.score
+	})
+	var families []string
+	for _, m := range matches {
+		for _, f := range m.chain.families {
+			if !slices.Contains(families, f) {
+				families = append(families, f)
+			}
+		}
+	}
+	s.fallbackCache[key] = families
+	return families
+}
+
+// useFallback updates the font map query to prefer the fallback families for
+// runes of script in the text being shaped.
+func (s *shaperImpl) useFallback(script language.Script) {
+	if len(s.fallbacks) == 0 {
+		return
+	}
+	if script == language.Common || script == language.Inherited {
+		script = s.fallbackScript
+	} else {
+		s.fallbackScript = script
+	}
+	families := s.fallbackFamilies(s.fallbackLanguage, script)
+	if slices.Equal(families, s.fallbackActive) {
+		return
+	}
+	s.fallbackActive = families
+	q := s.query
+	if len(families) > 0 {
+		q.Families = append(slices.Clip(families), q.Families...)
+	}
+	s.fontMap.SetQuery(q)
+}
+
+// setQuery sets the font families and aspect to use for the text being
+// shaped, and resets the fallback state.
+func (s *shaperImpl) setQuery(q fontscan.Query, lang string) {
+	s.query = q
+	s.fallbackLanguage = strings.ToLower(lang)
+	s.fallbackScript = language.Unknown
+	s.fallbackActive = nil
+	s.fontMap.SetQuery(q)
+}
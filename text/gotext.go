@@ -9,6 +9,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 
 	"github.com/go-text/typesetting/di"
 	"github.com/go-text/typesetting/font"
@@ -29,6 +30,7 @@ import (
 	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
+	"gioui.org/text/hyphen"
 )
 
 // document holds a collection of shaped lines and alignment information for
@@ -93,6 +95,9 @@ type line struct {
 	// runeCount is the number of text runes represented by this line's runs.
 	runeCount int
 
+	// yOffset is the distance of the baseline from the top of the text. For
+	// vertical lines, it is the distance of the baseline from the edge of the
+	// text the lines are stacked from; see lineBaselineX.
 	yOffset int
 }
 
@@ -205,20 +210,52 @@ type runLayout struct {
 	// truncator indicates that this run is a text truncator standing in for remaining
 	// text.
 	truncator bool
+	// sideways indicates that the glyphs of this vertical run are rotated 90°
+	// clockwise.
+	sideways bool
 }
 
 // shaperImpl implements the shaping and line-wrapping of opentype fonts.
 type shaperImpl struct {
 	// Fields for tracking fonts/faces.
-	fontMap      *fontscan.FontMap
-	faces        []font.Face
-	faceToIndex  map[font.Font]int
-	faceMeta     []giofont.Font
+	fontMap     *fontscan.FontMap
+	faces       []font.Face
+	faceToIndex map[font.Font]int
+	faceMeta    []giofont.Font
+	// tableSources maps faces registered with Load to their raw OpenType
+	// tables, if available.
+	tableSources map[font.Font]rawTableSource
+	// sourceToIndex maps the locations of the font files of faces resolved
+	// or imported from other shapers to their indices, for the faces of a
+	// file loaded by several shapers to be added once.
+	sourceToIndex map[fontscan.Location]int
+	// importedLocations are the locations of the faces imported from other
+	// shapers, unknown to fontMap.
+	importedLocations map[font.Font]fontscan.Location
+	// colorFaces holds the color glyph tables of faces, indexed like faces
+	// and loaded on first use.
+	colorFaces   []*colorFace
 	defaultFaces []string
 	logger       interface {
 		Printf(format string, args ...any)
 	}
 	parser parser
+	// hyphenation maps lowercase language tags to the patterns used to
+	// hyphenate text in that language.
+	hyphenation map[string]*hyphen.Patterns
+	// fallbacks are the font fallback chains, in registration order.
+	fallbacks     []fallbackChain
+	fallbackCache map[fallbackKey][]string
+	// query is the font query of the text being shaped, before fallback
+	// families are applied.
+	query fontscan.Query
+	// fallbackLanguage and fallbackScript are the lowercase language of the
+	// text being shaped and the script of the last resolved rune not in the
+	// Common or Inherited scripts.
+	fallbackLanguage string
+	fallbackScript   language.Script
+	// fallbackActive are the fallback families in the font map query.
+	fallbackActive []string
 
 	// Shaping and wrapping state.
 	shaper        shaping.HarfbuzzShaper
@@ -230,6 +267,10 @@ type shaperImpl struct {
 	splitScratch1, splitScratch2 []shaping.Input
 	outScratchBuf                []shaping.Output
 	scratchRunes                 []rune
+	hyphenatedRunes              []rune
+	hyphenatedSpans              []FontSpan
+	softHyphens                  []int
+	hyphenatedLines              []shaping.Line
 
 	// bitmapGlyphCache caches extracted bitmap glyph images.
 	bitmapGlyphCache bitmapCache
@@ -255,6 +296,9 @@ func newShaperImpl(systemFonts bool, collection []FontFace) *shaperImpl {
 	shaper.logger = newDebugLogger()
 	shaper.fontMap = fontscan.NewFontMap(shaper.logger)
 	shaper.faceToIndex = make(map[font.Font]int)
+	shaper.tableSources = make(map[font.Font]rawTableSource)
+	shaper.sourceToIndex = make(map[fontscan.Location]int)
+	shaper.importedLocations = make(map[font.Font]fontscan.Location)
 	if systemFonts {
 		str, err := os.UserCacheDir()
 		if err != nil {
@@ -278,8 +322,12 @@ func newShaperImpl(systemFonts bool, collection []FontFace) *shaperImpl {
 // in the order in which they are loaded, with the first face being the default.
 func (s *shaperImpl) Load(f FontFace) {
 	desc := opentype.FontToDescription(f.Font)
-	s.fontMap.AddFace(f.Face.Face(), fontscan.Location{File: fmt.Sprint(desc)}, desc)
-	s.addFace(f.Face.Face(), f.Font)
+	face := f.Face.Face()
+	if src, ok := f.Face.(rawTableSource); ok {
+		s.tableSources[face.Font] = src
+	}
+	s.fontMap.AddFace(face, fontscan.Location{File: fmt.Sprint(desc)}, desc)
+	s.addFace(face, f.Font)
 }
 
 func (s *shaperImpl) addFace(f font.Face, md giofont.Font) {
@@ -293,6 +341,32 @@ func (s *shaperImpl) addFace(f font.Face, md giofont.Font) {
 	s.faceMeta = append(s.faceMeta, md)
 }
 
+// addSourcedFace is addFace for a face loaded from the font file at loc. If
+// a face of the same file was added, f is made an alias of it.
+func (s *shaperImpl) addSourcedFace(f font.Face, md giofont.Font, loc fontscan.Location) {
+	if _, ok := s.faceToIndex[f.Font]; ok {
+		return
+	}
+	if loc.File == "" {
+		s.addFace(f, md)
+		return
+	}
+	if idx, ok := s.sourceToIndex[loc]; ok {
+		s.faceToIndex[f.Font] = idx
+		return
+	}
+	s.sourceToIndex[loc] = len(s.faces)
+	s.addFace(f, md)
+}
+
+// location returns the location of the font file of f, if known.
+func (s *shaperImpl) location(f font.Font) fontscan.Location {
+	if loc, ok := s.importedLocations[f]; ok {
+		return loc
+	}
+	return s.fontMap.FontLocation(f)
+}
+
 // splitByScript divides the inputs into new, smaller inputs on script boundaries
 // and correctly sets the text direction per-script. It will
 // use buf as the backing memory for the returned slice if buf is non-nil.
@@ -378,6 +452,7 @@ func (s *shaperImpl) splitBidi(input shaping.Input) []shaping.Input {
 // field and ensuring that any faces loaded as part of the search are registered with
 // ids so that they can be referred to by a GlyphID.
 func (s *shaperImpl) ResolveFace(r rune) font.Face {
+	s.useFallback(language.LookupScript(r))
 	face := s.fontMap.ResolveFace(r)
 	if face != nil {
 		family, aspect := s.fontMap.FontMetadata(face.Font)
@@ -385,7 +460,7 @@ func (s *shaperImpl) ResolveFace(r rune) font.Face {
 			Family: family,
 			Aspect: aspect,
 		})
-		s.addFace(face, md)
+		s.addSourcedFace(face, md, s.fontMap.FontLocation(face.Font))
 		return face
 	}
 	return nil
@@ -406,9 +481,58 @@ func (s *shaperImpl) splitByFaces(inputs []shaping.Input, buf []shaping.Input) [
 	return split
 }
 
+// splitBySpans is like splitByFaces, but divides the inputs on the boundaries
+// of the font spans first, and resolves the faces of each span from its font.
+func (s *shaperImpl) splitBySpans(inputs []shaping.Input, spans []FontSpan, lang string, buf []shaping.Input) []shaping.Input {
+	split := buf
+	query := s.query
+	for _, input := range inputs {
+		if input.RunStart == input.RunEnd {
+			split = append(split, shaping.SplitByFace(input, s)...)
+			continue
+		}
+		for start := input.RunStart; start < input.RunEnd; {
+			in := input
+			in.RunStart = start
+			q := query
+			i := sort.Search(len(spans), func(i int) bool { return spans[i].End > start })
+			switch {
+			case i < len(spans) && spans[i].Start <= start:
+				in.RunEnd = min(in.RunEnd, spans[i].End)
+				q = s.fontQuery(spans[i].Font)
+			case i < len(spans):
+				in.RunEnd = min(in.RunEnd, spans[i].Start)
+			}
+			s.setQuery(q, lang)
+			split = append(split, shaping.SplitByFace(in, s)...)
+			start = in.RunEnd
+		}
+	}
+	s.setQuery(query, lang)
+	return split
+}
+
+// fontQuery returns the font map query for the faces of f.
+func (s *shaperImpl) fontQuery(f giofont.Font) fontscan.Query {
+	families := s.defaultFaces
+	if f.Typeface != "" {
+		parsed, err := s.parser.parse(string(f.Typeface))
+		if err != nil {
+			s.logger.Printf("Unable to parse typeface %q: %v", f.Typeface, err)
+		} else {
+			families = parsed
+		}
+	}
+	return fontscan.Query{
+		Families: families,
+		Aspect:   opentype.FontToDescription(f).Aspect,
+	}
+}
+
 // shapeText invokes the text shaper and returns the raw text data in the shaper's native
-// format. It does not wrap lines.
-func (s *shaperImpl) shapeText(ppem fixed.Int26_6, lc system.Locale, txt []rune) []shaping.Output {
+// format. It does not wrap lines. The faces of the runes in spans are resolved from the
+// fonts of the spans.
+func (s *shaperImpl) shapeText(ppem fixed.Int26_6, lc system.Locale, orientation Orientation, txt []rune, spans []FontSpan) []shaping.Output {
 	lcfg := langConfig{
 		Language:  language.NewLanguage(lc.Language),
 		Direction: mapDirection(lc.Direction),
@@ -423,8 +547,15 @@ func (s *shaperImpl) shapeText(ppem fixed.Int26_6, lc system.Locale, txt []rune)
 	}
 	// Break input on font glyph coverage.
 	inputs := s.splitBidi(input)
-	inputs = s.splitByFaces(inputs, s.splitScratch1[:0])
+	if len(spans) > 0 {
+		inputs = s.splitBySpans(inputs, spans, lc.Language, s.splitScratch1[:0])
+	} else {
+		inputs = s.splitByFaces(inputs, s.splitScratch1[:0])
+	}
 	inputs = splitByScript(inputs, lcfg.Direction, s.splitScratch2[:0])
+	if lcfg.Direction.IsVertical() {
+		inputs = splitByOrientation(inputs, orientation, s.splitScratch1[:0])
+	}
 	// Shape all inputs.
 	if needed := len(inputs) - len(s.outScratchBuf); needed > 0 {
 		s.outScratchBuf = slices.Grow(s.outScratchBuf, needed)
@@ -432,7 +563,9 @@ func (s *shaperImpl) shapeText(ppem fixed.Int26_6, lc system.Locale, txt []rune)
 	s.outScratchBuf = s.outScratchBuf[:0]
 	for _, input := range inputs {
 		if input.Face != nil {
-			s.outScratchBuf = append(s.outScratchBuf, s.shaper.Shape(input))
+			out := s.shaper.Shape(input)
+			flipVerticalAdvances(&out)
+			s.outScratchBuf = append(s.outScratchBuf, out)
 		} else {
 			s.outScratchBuf = append(s.outScratchBuf, shaping.Output{
 				// Use the text size as the advance of the entire fake run so that
@@ -489,35 +622,38 @@ func wrapPolicyToGoText(p WrapPolicy) shaping.LineBreakPolicy {
 }
 
 // shapeAndWrapText invokes the text shaper and returns wrapped lines in the shaper's native format.
-func (s *shaperImpl) shapeAndWrapText(params Parameters, txt []rune) (_ []shaping.Line, truncated int) {
+// If hyphenated is set, space for a hyphen glyph is reserved at the end of the lines
+// broken at a soft hyphen.
+func (s *shaperImpl) shapeAndWrapText(params Parameters, txt []rune, hyphenated bool) (_ []shaping.Line, truncated int) {
 	wc := shaping.WrapConfig{
 		TruncateAfterLines: params.MaxLines,
 		TextContinues:      params.forceTruncate,
 		BreakPolicy:        wrapPolicyToGoText(params.WrapPolicy),
 	}
-	families := s.defaultFaces
-	if params.Font.Typeface != "" {
-		parsed, err := s.parser.parse(string(params.Font.Typeface))
-		if err != nil {
-			s.logger.Printf("Unable to parse typeface %q: %v", params.Font.Typeface, err)
-		} else {
-			families = parsed
-		}
-	}
-	s.fontMap.SetQuery(fontscan.Query{
-		Families: families,
-		Aspect:   opentype.FontToDescription(params.Font).Aspect,
-	})
+	s.setQuery(s.fontQuery(params.Font), params.Locale.Language)
 	if wc.TruncateAfterLines > 0 {
 		if len(params.Truncator) == 0 {
 			params.Truncator = "…"
 		}
 		// We only permit a single run as the truncator, regardless of whether more were generated.
 		// Just use the first one.
-		wc.Truncator = s.shapeText(params.PxPerEm, params.Locale, []rune(params.Truncator))[0]
+		wc.Truncator = s.shapeText(params.PxPerEm, params.Locale, params.Orientation, []rune(params.Truncator), nil)[0]
 	}
+	runs := s.shapeText(params.PxPerEm, params.Locale, params.Orientation, txt, params.FontSpans)
 	// Wrap outputs into lines.
-	return s.wrapper.WrapParagraph(wc, params.MaxWidth, txt, shaping.NewSliceIterator(s.shapeText(params.PxPerEm, params.Locale, txt)))
+	var lines []shaping.Line
+	if hyphenated {
+		lines, truncated = s.wrapHyphenated(wc, params.MaxWidth, txt, runs)
+	} else {
+		lines, truncated = s.wrapper.WrapParagraph(wc, params.MaxWidth, txt, shaping.NewSliceIterator(runs))
+	}
+	if params.Locale.Direction.Axis() == system.Vertical {
+		for _, l := range lines {
+			// Center sideways runs on the baseline of upright ones.
+			l.AdjustBaselines()
+		}
+	}
+	return lines, truncated
 }
 
 // replaceControlCharacters replaces problematic unicode
@@ -566,6 +702,10 @@ func calculateYOffsets(lines []line) {
 	// Ceil the first value to ensure that we don't baseline it too close to the top of the
 	// viewport and cut off the top pixel.
 	currentY := lines[0].ascent.Ceil()
+	if d := lines[0].direction; d.Axis() == system.Vertical && d.LineProgression() == system.FromOrigin {
+		// The descent of vertical lines is on their left side.
+		currentY = lines[0].descent.Ceil()
+	}
 	for i := range lines {
 		if i > 0 {
 			currentY += lines[i].lineHeight.Round()
@@ -587,9 +727,25 @@ func (s *shaperImpl) LayoutRunes(params Parameters, txt []rune) document {
 		// on the final line (if we hit the limit).
 		params.forceTruncate = true
 	}
-	ls, truncated = s.shapeAndWrapText(params, replaceControlCharacters(txt))
+	txt = replaceControlCharacters(txt)
+	var softHyphens []int
+	if params.WrapPolicy == WrapHyphenate {
+		if p := s.hyphenationPatterns(params.Locale.Language); p != nil {
+			txt, softHyphens = insertSoftHyphens(p, txt, s.hyphenatedRunes, s.softHyphens)
+			s.hyphenatedRunes, s.softHyphens = txt, softHyphens
+			if len(params.FontSpans) > 0 {
+				s.hyphenatedSpans = shiftSpans(params.FontSpans, softHyphens, s.hyphenatedSpans)
+				params.FontSpans = s.hyphenatedSpans
+			}
+		}
+	}
+	ls, truncated = s.shapeAndWrapText(params, txt, len(softHyphens) > 0)
 
 	hasTruncator := truncated > 0 || (params.forceTruncate && params.MaxLines == len(ls))
+	if len(softHyphens) > 0 {
+		s.finishHyphenation(ls, txt, softHyphens, hasTruncator)
+		truncated -= countBetween(softHyphens, len(txt)-truncated, len(txt))
+	}
 	if hasTruncator && hasNewline {
 		// We have a truncator at the end of the line, so the newline is logically
 		// truncated as well.
@@ -646,11 +802,12 @@ func alignWidth(minWidth int, lines []line) int {
 func (s *shaperImpl) Shape(pathOps *op.Ops, gs []Glyph) clip.PathSpec {
 	var lastPos f32.Point
 	var x fixed.Int26_6
+	var y int32
 	var builder clip.Path
 	builder.Begin(pathOps)
 	for i, g := range gs {
 		if i == 0 {
-			x = g.X
+			x, y = g.X, g.Y
 		}
 		ppem, faceIdx, gid := splitGlyphID(g.ID)
 		if faceIdx >= len(s.faces) {
@@ -661,54 +818,73 @@ func (s *shaperImpl) Shape(pathOps *op.Ops, gs []Glyph) clip.PathSpec {
 			continue
 		}
 		scaleFactor := fixedToFloat(ppem) / float32(face.Upem())
-		glyphData := face.GlyphData(gid)
-		switch glyphData := glyphData.(type) {
+		pos := glyphOrigin(g, x, y)
+		scale := glyphTransform(g, scaleFactor)
+		if colr, p := s.colrGlyph(faceIdx, gid); p != nil {
+			// Include the layers painted with the text color. The remaining
+			// layers are drawn by Bitmaps.
+			r := colrRenderer{face: face, colr: colr}
+			tr := scale.Offset(pos)
+			r.foreground(p, tr, 0, func(gid api.GID, tr f32.Affine2D) {
+				if outline, ok := face.GlyphData(gid).(api.GlyphOutline); ok {
+					appendOutline(&builder, outline, tr)
+				}
+			})
+			lastPos = builder.Pos()
+			continue
+		}
+		var outline api.GlyphOutline
+		switch glyphData := face.GlyphData(gid).(type) {
 		case api.GlyphOutline:
-			outline := glyphData
-			// Move to glyph position.
-			pos := f32.Point{
-				X: fixedToFloat((g.X - x) - g.Offset.X),
-				Y: -fixedToFloat(g.Offset.Y),
+			outline = glyphData
+		case api.GlyphSVG:
+			if s.colorFace(faceIdx).svgGlyph(glyphData.Source, svgGlyphID(gid)) != nil {
+				// Drawn by Bitmaps.
+				continue
 			}
-			builder.Move(pos.Sub(lastPos))
-			lastPos = pos
-			var lastArg f32.Point
-
-			// Convert fonts.Segments to relative segments.
-			for _, fseg := range outline.Segments {
-				nargs := 1
-				switch fseg.Op {
-				case api.SegmentOpQuadTo:
-					nargs = 2
-				case api.SegmentOpCubeTo:
-					nargs = 3
-				}
-				var args [3]f32.Point
-				for i := 0; i < nargs; i++ {
-					a := f32.Point{
-						X: fseg.Args[i].X * scaleFactor,
-						Y: -fseg.Args[i].Y * scaleFactor,
-					}
-					args[i] = a.Sub(lastArg)
-					if i == nargs-1 {
-						lastArg = a
-					}
-				}
-				switch fseg.Op {
-				case api.SegmentOpMoveTo:
-					builder.Move(args[0])
-				case api.SegmentOpLineTo:
-					builder.Line(args[0])
-				case api.SegmentOpQuadTo:
-					builder.Quad(args[0], args[1])
-				case api.SegmentOpCubeTo:
-					builder.Cube(args[0], args[1], args[2])
-				default:
-					panic("unsupported segment op")
+			outline = glyphData.Outline
+		default:
+			continue
+		}
+		// Move to glyph position.
+		builder.Move(pos.Sub(lastPos))
+		lastPos = pos
+		var lastArg f32.Point
+
+		// Convert fonts.Segments to relative segments.
+		for _, fseg := range outline.Segments {
+			nargs := 1
+			switch fseg.Op {
+			case api.SegmentOpQuadTo:
+				nargs = 2
+			case api.SegmentOpCubeTo:
+				nargs = 3
+			}
+			var args [3]f32.Point
+			for i := 0; i < nargs; i++ {
+				a := scale.Transform(f32.Point{
+					X: fseg.Args[i].X,
+					Y: fseg.Args[i].Y,
+				})
+				args[i] = a.Sub(lastArg)
+				if i == nargs-1 {
+					lastArg = a
 				}
 			}
-			lastPos = lastPos.Add(lastArg)
+			switch fseg.Op {
+			case api.SegmentOpMoveTo:
+				builder.Move(args[0])
+			case api.SegmentOpLineTo:
+				builder.Line(args[0])
+			case api.SegmentOpQuadTo:
+				builder.Quad(args[0], args[1])
+			case api.SegmentOpCubeTo:
+				builder.Cube(args[0], args[1], args[2])
+			default:
+				panic("unsupported segment op")
+			}
 		}
+		lastPos = lastPos.Add(lastArg)
 	}
 	return builder.End()
 }
@@ -721,18 +897,19 @@ func floatToFixed(f float32) fixed.Int26_6 {
 	return fixed.Int26_6(f * 64)
 }
 
-// Bitmaps returns an op.CallOp that will display all bitmap glyphs within gs.
+// Bitmaps returns an op.CallOp that will display all bitmap and color glyphs within gs.
 // The positioning of the bitmaps uses the same logic as Shape(), so the returned
 // CallOp can be added at the same offset as the path data returned by Shape()
 // and will align correctly.
 func (s *shaperImpl) Bitmaps(ops *op.Ops, gs []Glyph) op.CallOp {
 	var x fixed.Int26_6
+	var y int32
 	bitmapMacro := op.Record(ops)
 	for i, g := range gs {
 		if i == 0 {
-			x = g.X
+			x, y = g.X, g.Y
 		}
-		_, faceIdx, gid := splitGlyphID(g.ID)
+		ppem, faceIdx, gid := splitGlyphID(g.ID)
 		if faceIdx >= len(s.faces) {
 			continue
 		}
@@ -740,8 +917,32 @@ func (s *shaperImpl) Bitmaps(ops *op.Ops, gs []Glyph) op.CallOp {
 		if face == nil {
 			continue
 		}
+		scaleFactor := fixedToFloat(ppem) / float32(face.Upem())
+		pos := glyphOrigin(g, x, y)
+		scale := glyphTransform(g, scaleFactor)
+		if colr, p := s.colrGlyph(faceIdx, gid); p != nil {
+			// COLR glyphs are defined in font units with the y axis pointing up.
+			tr := scale.Offset(pos)
+			t := op.Affine(tr).Push(ops)
+			r := colrRenderer{ops: ops, face: face, colr: colr}
+			r.paint(p, colrState{pixels: tr}, 0)
+			t.Pop()
+			continue
+		}
 		glyphData := face.GlyphData(gid)
 		switch glyphData := glyphData.(type) {
+		case api.GlyphSVG:
+			id := svgGlyphID(gid)
+			doc := s.colorFace(faceIdx).svgGlyph(glyphData.Source, id)
+			if doc == nil {
+				continue
+			}
+			// SVG glyphs are defined in font units with the y axis pointing down.
+			tr := scale.Mul(f32.Affine2D{}.Scale(f32.Point{}, f32.Point{X: 1, Y: -1})).Offset(pos)
+			t := op.Affine(tr).Push(ops)
+			r := svgRenderer{ops: ops, doc: doc}
+			r.render(doc.ids[id], svgStyle{fillOpacity: 1}, tr, 0)
+			t.Pop()
 		case api.GlyphBitmap:
 			var imgOp paint.ImageOp
 			var imgSize image.Point
@@ -766,10 +967,18 @@ func (s *shaperImpl) Bitmaps(ops *op.Ops, gs []Glyph) op.CallOp {
 				imgOp = bitmapData.img
 				imgSize = bitmapData.size
 			}
-			off := op.Affine(f32.Affine2D{}.Offset(f32.Point{
+			origin := f32.Point{
 				X: fixedToFloat((g.X - x) - g.Offset.X),
 				Y: fixedToFloat(g.Offset.Y + g.Bounds.Min.Y),
-			})).Push(ops)
+			}
+			if g.Flags&FlagVertical != 0 {
+				// The bounds of vertical glyphs include their offsets.
+				origin = f32.Point{
+					X: fixedToFloat(g.X - x + g.Bounds.Min.X),
+					Y: float32(g.Y-y) + fixedToFloat(g.Bounds.Min.Y),
+				}
+			}
+			off := op.Affine(f32.Affine2D{}.Offset(origin)).Push(ops)
 			cl := clip.Rect{Max: imgSize}.Push(ops)
 
 			glyphSize := image.Rectangle{
@@ -782,10 +991,17 @@ func (s *shaperImpl) Bitmaps(ops *op.Ops, gs []Glyph) op.CallOp {
 					Y: g.Bounds.Max.Y.Round(),
 				},
 			}.Size()
-			aff := op.Affine(f32.Affine2D{}.Scale(f32.Point{}, f32.Point{
+			var rotate f32.Affine2D
+			if g.Flags&FlagSideways != 0 {
+				// Scale the image to the size of the glyph before rotating
+				// it 90° clockwise into its bounds.
+				rotate = f32.NewAffine2D(0, -1, float32(glyphSize.X), 1, 0, 0)
+				glyphSize.X, glyphSize.Y = glyphSize.Y, glyphSize.X
+			}
+			aff := op.Affine(rotate.Mul(f32.Affine2D{}.Scale(f32.Point{}, f32.Point{
 				X: float32(glyphSize.X) / float32(imgSize.X),
 				Y: float32(glyphSize.Y) / float32(imgSize.Y),
-			})).Push(ops)
+			}))).Push(ops)
 			imgOp.Add(ops)
 			paint.PaintOp{}.Add(ops)
 			aff.Pop()
@@ -826,6 +1042,8 @@ func mapDirection(d system.TextDirection) di.Direction {
 		return di.DirectionLTR
 	case system.RTL:
 		return di.DirectionRTL
+	case system.VerticalRL, system.VerticalLR:
+		return di.DirectionTTB
 	}
 	return di.DirectionLTR
 }
@@ -842,7 +1060,7 @@ func unmapDirection(d di.Direction) system.TextDirection {
 
 // toGioGlyphs converts text shaper glyphs into the minimal representation
 // that Gio needs.
-func toGioGlyphs(in []shaping.Glyph, ppem fixed.Int26_6, faceIdx int) []glyph {
+func toGioGlyphs(in []shaping.Glyph, ppem fixed.Int26_6, faceIdx int, vertical bool) []glyph {
 	out := make([]glyph, 0, len(in))
 	for _, g := range in {
 		// To better understand how to calculate the bounding box, see here:
@@ -850,6 +1068,12 @@ func toGioGlyphs(in []shaping.Glyph, ppem fixed.Int26_6, faceIdx int) []glyph {
 		var bounds fixed.Rectangle26_6
 		bounds.Min.X = g.XBearing
 		bounds.Min.Y = -g.YBearing
+		if vertical {
+			// The offsets of vertical glyphs move them from the center of
+			// the line to their horizontal origin.
+			bounds.Min.X += g.XOffset
+			bounds.Min.Y -= g.YOffset
+		}
 		bounds.Max = bounds.Min.Add(fixed.Point26_6{X: g.Width, Y: -g.Height})
 		out = append(out, glyph{
 			id:           newGlyphID(ppem, faceIdx, g.GlyphID),
@@ -875,6 +1099,7 @@ func toLine(faceToIndex map[font.Font]int, o shaping.Line, dir system.TextDirect
 		runs:      make([]runLayout, len(o)),
 		direction: dir,
 	}
+	vertical := dir.Axis() == system.Vertical
 	maxSize := fixed.Int26_6(0)
 	for i := range o {
 		run := o[i]
@@ -885,16 +1110,24 @@ func toLine(faceToIndex map[font.Font]int, o shaping.Line, dir system.TextDirect
 		if run.Face != nil {
 			font = run.Face.Font
 		}
+		runDir := unmapDirection(run.Direction)
+		if vertical {
+			runDir = dir
+			// The line wrapper zeroes the advance of trailing whitespace
+			// glyphs in vertical lines without updating the run.
+			run.RecomputeAdvance()
+		}
 		line.runs[i] = runLayout{
-			Glyphs: toGioGlyphs(run.Glyphs, run.Size, faceToIndex[font]),
+			Glyphs: toGioGlyphs(run.Glyphs, run.Size, faceToIndex[font], vertical),
 			Runes: Range{
 				Count:  run.Runes.Count,
 				Offset: line.runeCount,
 			},
-			Direction: unmapDirection(run.Direction),
+			Direction: runDir,
 			face:      run.Face,
 			Advance:   run.Advance,
 			PPEM:      run.Size,
+			sideways:  run.Direction.IsSideways(),
 		}
 		line.runeCount += run.Runes.Count
 		line.width += run.Advance
@@ -5,6 +5,7 @@ import (
 	"math"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	nsareg "eliasnaur.com/font/noto/sans/arabic/regular"
@@ -17,6 +18,7 @@ import (
 	giofont "gioui.org/font"
 	"gioui.org/font/opentype"
 	"gioui.org/io/system"
+	"gioui.org/text/hyphen"
 )
 
 var english = system.Locale{
@@ -300,13 +302,13 @@ func makeTestText(shaper *shaperImpl, primaryDir system.TextDirection, fontSize,
 		PxPerEm:  fixed.I(fontSize),
 		MaxWidth: lineWidth,
 		Locale:   locale,
-	}, []rune(simpleSource))
+	}, []rune(simpleSource), false)
 	simpleText = copyLines(simpleText)
 	complexText, _ := shaper.shapeAndWrapText(Parameters{
 		PxPerEm:  fixed.I(fontSize),
 		MaxWidth: lineWidth,
 		Locale:   locale,
-	}, []rune(complexSource))
+	}, []rune(complexSource), false)
 	complexText = copyLines(complexText)
 	testShaper(rtlFace, ltrFace)
 	return simpleText, complexText
@@ -633,6 +635,61 @@ func validateLines(t *testing.T, lines []line, expectedRuneCount int) {
 	}
 }
 
+// TestHyphenation ensures that WrapHyphenate breaks words at hyphenation points,
+// displays a hyphen glyph at such breaks and keeps the rune accounting intact.
+func TestHyphenation(t *testing.T) {
+	ltrFace, _ := opentype.Parse(goregular.TTF)
+	shaper := testShaper(ltrFace)
+	patterns, err := hyphen.Parse(strings.NewReader("hy3ph he2n hena4 hen5at 1na n2at 1tio 2io o2n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shaper.hyphenation = map[string]*hyphen.Patterns{"en": patterns}
+	const txt = "hyphenation hyphenation\n"
+	params := Parameters{
+		PxPerEm:    fixed.I(10),
+		MaxWidth:   40,
+		Locale:     english,
+		WrapPolicy: WrapHyphenate,
+	}
+	doc := shaper.LayoutRunes(params, []rune(txt))
+	validateLines(t, doc.lines, len([]rune(txt)))
+	var hyphens int
+	for i, line := range doc.lines {
+		if line.width.Ceil() > params.MaxWidth {
+			t.Errorf("line %d: width %v exceeds %d", i, line.width, params.MaxWidth)
+		}
+		lastRun := line.runs[len(line.runs)-1]
+		if g := lastRun.Glyphs[len(lastRun.Glyphs)-1]; g.runeCount == 0 && g.glyphCount == 1 {
+			hyphens++
+		}
+	}
+	if hyphens == 0 {
+		t.Errorf("expected hyphenated lines, got none in %d lines", len(doc.lines))
+	}
+
+	// Text in languages without patterns is wrapped like WrapHeuristically.
+	params.Locale = arabic
+	params.Locale.Direction = system.LTR
+	hyphenated := shaper.LayoutRunes(params, []rune(txt))
+	params.WrapPolicy = WrapHeuristically
+	heuristic := shaper.LayoutRunes(params, []rune(txt))
+	if len(hyphenated.lines) != len(heuristic.lines) {
+		t.Errorf("unsupported language wrapped to %d lines, expected %d", len(hyphenated.lines), len(heuristic.lines))
+	}
+
+	// Lines not broken at a soft hyphen use the full width.
+	params.Locale = english
+	params.MaxWidth = 1000
+	const words = "aaaa aaaa "
+	params.MaxWidth = shaper.LayoutRunes(params, []rune(words)).lines[0].width.Ceil()
+	params.WrapPolicy = WrapHyphenate
+	doc = shaper.LayoutRunes(params, []rune(words+"hyphenation"))
+	if got, want := doc.lines[0].runeCount, len(words); got != want {
+		t.Errorf("first line has %d runes, want %d", got, want)
+	}
+}
+
 // TestTextAppend ensures that appending two texts together correctly updates the new lines'
 // y offsets.
 func TestTextAppend(t *testing.T) {
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+/*
+Package hyphen implements Franklin Liang's hyphenation algorithm, as used by TeX.
+
+Hyphenation dictionaries are language specific and are not bundled with Gio.
+Applications can embed pattern files in the format distributed by the TeX
+hyph-utf8 project and parse them with [Parse]:
+
+	//go:embed hyph-de-1996.pat.txt
+	var dePatterns string
+
+	patterns, err := hyphen.Parse(strings.NewReader(dePatterns))
+
+The resulting [Patterns] are usually registered with a text shaper for a particular
+language; see text.WithHyphenation.
+*/
+package hyphen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Patterns is a parsed hyphenation dictionary for a single language. It is
+// safe for concurrent use once parsed.
+type Patterns struct {
+	// LeftMin is the minimum number of runes that must precede a hyphenation
+	// point within a word.
+	LeftMin int
+	// RightMin is the minimum number of runes that must follow a hyphenation
+	// point within a word.
+	RightMin int
+
+	// patterns maps letter sequences to their inter-letter values. The values
+	// slice is always one longer than the sequence.
+	patterns map[string][]uint8
+	// exceptions maps whole words to their hyphenation points, overriding
+	// the patterns.
+	exceptions map[string][]int
+	// maxLen is the length in runes of the longest pattern.
+	maxLen int
+}
+
+// Parse reads Liang patterns from r. The input is a whitespace separated list
+// of patterns such as "hy3ph" or ".ach4". Entries containing a '-' are treated
+// as exceptions that spell out the exact hyphenation of a word, for example
+// "ta-ble". Text following a '%' on a line is ignored, as are the TeX
+// \patterns{ and \hyphenation{ wrappers.
+//
+// The returned Patterns use the TeX defaults of LeftMin 2 and RightMin 3.
+func Parse(r io.Reader) (*Patterns, error) {
+	p := &Patterns{
+		LeftMin:    2,
+		RightMin:   3,
+		patterns:   make(map[string][]uint8),
+		exceptions: make(map[string][]int),
+	}
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		if i := strings.IndexByte(line, '%'); i >= 0 {
+			line = line[:i]
+		}
+		for _, field := range strings.Fields(line) {
+			if strings.HasPrefix(field, `\`) {
+				// Strip a \patterns{ or \hyphenation{ prefix.
+				i := strings.IndexByte(field, '{')
+				if i < 0 {
+					continue
+				}
+				field = field[i+1:]
+			}
+			field = strings.Trim(field, "{}")
+			if field == "" {
+				continue
+			}
+			var err error
+			if strings.ContainsRune(field, '-') {
+				err = p.addException(field)
+			} else {
+				err = p.addPattern(field)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("hyphen: line %d: %w", lineNo, err)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// addPattern parses a single pattern such as "hen5at" and records it.
+func (p *Patterns) addPattern(pat string) error {
+	var letters []rune
+	values := []uint8{0}
+	for _, r := range pat {
+		if r >= '0' && r <= '9' {
+			values[len(values)-1] = uint8(r - '0')
+			continue
+		}
+		if r == utf8.RuneError {
+			return fmt.Errorf("invalid UTF-8 in pattern %q", pat)
+		}
+		letters = append(letters, unicode.ToLower(r))
+		values = append(values, 0)
+	}
+	if len(letters) == 0 {
+		return fmt.Errorf("pattern %q has no letters", pat)
+	}
+	p.patterns[string(letters)] = values
+	if len(letters) > p.maxLen {
+		p.maxLen = len(letters)
+	}
+	return nil
+}
+
+// addException parses a hyphenated word such as "ta-ble" and records
+// its hyphenation points.
+func (p *Patterns) addException(word string) error {
+	var letters []rune
+	var points []int
+	for _, r := range word {
+		if r == '-' {
+			points = append(points, len(letters))
+			continue
+		}
+		letters = append(letters, unicode.ToLower(r))
+	}
+	if len(letters) == 0 {
+		return fmt.Errorf("exception %q has no letters", word)
+	}
+	p.exceptions[string(letters)] = points
+	return nil
+}
+
+// Hyphenate appends the positions at which word may be hyphenated to points
+// and returns the result. A position i means that a hyphen may be inserted
+// between word[i-1] and word[i]. Positions are returned in increasing order.
+// Matching is case insensitive.
+func (p *Patterns) Hyphenate(word []rune, points []int) []int {
+	if p == nil || len(word) < p.LeftMin+p.RightMin {
+		return points
+	}
+	lower := make([]rune, len(word)+2)
+	lower[0] = '.'
+	lower[len(lower)-1] = '.'
+	for i, r := range word {
+		lower[i+1] = unicode.ToLower(r)
+	}
+	if ex, ok := p.exceptions[string(lower[1:len(lower)-1])]; ok {
+		for _, pos := range ex {
+			if pos >= p.LeftMin && pos <= len(word)-p.RightMin {
+				points = append(points, pos)
+			}
+		}
+		return points
+	}
+	// values[i] is the priority of a break before lower[i].
+	values := make([]uint8, len(lower)+1)
+	for i := range lower {
+		end := min(len(lower), i+p.maxLen)
+		for j := i + 1; j <= end; j++ {
+			pat, ok := p.patterns[string(lower[i:j])]
+			if !ok {
+				continue
+			}
+			for k, v := range pat {
+				if v > values[i+k] {
+					values[i+k] = v
+				}
+			}
+		}
+	}
+	// A break before word[pos] corresponds to a break before lower[pos+1].
+	for pos := max(p.LeftMin, 1); pos <= len(word)-p.RightMin; pos++ {
+		if values[pos+1]%2 == 1 {
+			points = append(points, pos)
+		}
+	}
+	return points
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package hyphen
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// knuthPatterns are the patterns used to hyphenate "hyphenation" in
+// appendix H of The TeXbook.
+const knuthPatterns = `
+% Patterns from The TeXbook.
+\patterns{
+hy3ph he2n hena4 hen5at 1na n2at 1tio 2io o2n
+}
+\hyphenation{ta-ble}
+`
+
+func TestHyphenate(t *testing.T) {
+	p, err := Parse(strings.NewReader(knuthPatterns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		word     string
+		expected []int
+	}{
+		{word: "hyphenation", expected: []int{2, 6}},
+		{word: "Hyphenation", expected: []int{2, 6}},
+		{word: "table", expected: []int{2}},
+		{word: "tables", expected: nil},
+		{word: "hy", expected: nil},
+	} {
+		got := p.Hyphenate([]rune(tc.word), nil)
+		if !slices.Equal(got, tc.expected) {
+			t.Errorf("Hyphenate(%q) = %v, expected %v", tc.word, got, tc.expected)
+		}
+	}
+	p.RightMin = 4
+	if got := p.Hyphenate([]rune("table"), nil); len(got) != 0 {
+		t.Errorf("Hyphenate(%q) with RightMin 4 = %v, expected none", "table", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{"123", "-"} {
+		if _, err := Parse(strings.NewReader(src)); err == nil {
+			t.Errorf("Parse(%q) succeeded, expected error", src)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+
+	"gioui.org/text/hyphen"
+)
+
+// softHyphen is U+00AD SOFT HYPHEN. It is invisible, but provides a UAX#14 line
+// breaking opportunity after itself.
+const softHyphen = '\u00AD'
+
+// hyphenationPatterns returns the patterns registered for lang, falling back
+// to the patterns of its primary language subtag. It returns nil if no patterns
+// apply.
+func (s *shaperImpl) hyphenationPatterns(lang string) *hyphen.Patterns {
+	if len(s.hyphenation) == 0 {
+		return nil
+	}
+	lang = strings.ToLower(lang)
+	if p, ok := s.hyphenation[lang]; ok {
+		return p
+	}
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		return s.hyphenation[lang[:i]]
+	}
+	return nil
+}
+
+// isWordRune reports whether r can be part of a hyphenatable word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r) || r == softHyphen
+}
+
+// insertSoftHyphens returns txt with a soft hyphen inserted at every hyphenation
+// point of every word, along with the indices of the inserted runes within the
+// result in increasing order. Words that already contain soft hyphens are left
+// untouched. The result uses buf and inserted as backing storage when they have
+// sufficient capacity.
+func insertSoftHyphens(p *hyphen.Patterns, txt []rune, buf []rune, inserted []int) ([]rune, []int) {
+	buf = buf[:0]
+	inserted = inserted[:0]
+	var points []int
+	for i := 0; i < len(txt); {
+		if !isWordRune(txt[i]) {
+			buf = append(buf, txt[i])
+			i++
+			continue
+		}
+		end := i
+		authored := false
+		for end < len(txt) && isWordRune(txt[end]) {
+			authored = authored || txt[end] == softHyphen
+			end++
+		}
+		word := txt[i:end]
+		points = points[:0]
+		if !authored {
+			points = p.Hyphenate(word, points)
+		}
+		last := 0
+		for _, pt := range points {
+			buf = append(buf, word[last:pt]...)
+			inserted = append(inserted, len(buf))
+			buf = append(buf, softHyphen)
+			last = pt
+		}
+		buf = append(buf, word[last:]...)
+		i = end
+	}
+	return buf, inserted
+}
+
+// shiftSpans offsets the spans of a text to the text with the soft hyphens
+// inserted at the sorted indices. It uses buf as the backing storage of the
+// returned slice.
+func shiftSpans(spans []FontSpan, inserted []int, buf []FontSpan) []FontSpan {
+	buf = buf[:0]
+	shift := func(idx int) int {
+		n := 0
+		for _, h := range inserted {
+			if h > idx+n {
+				break
+			}
+			n++
+		}
+		return idx + n
+	}
+	for _, s := range spans {
+		s.Start, s.End = shift(s.Start), shift(s.End)
+		buf = append(buf, s)
+	}
+	return buf
+}
+
+// countBetween returns the number of elements of the sorted slice
+// that fall in [start, end).
+func countBetween(sorted []int, start, end int) int {
+	return sort.SearchInts(sorted, end) - sort.SearchInts(sorted, start)
+}
+
+// hyphenGlyph shapes a hyphen with the face and size of run. The returned
+// glyph represents no runes and forms its own cluster identified by cluster.
+func (s *shaperImpl) hyphenGlyph(run shaping.Output, cluster int) (shaping.Glyph, bool) {
+	if run.Face == nil {
+		return shaping.Glyph{}, false
+	}
+	out := s.shaper.Shape(shaping.Input{
+		Text:      []rune{'-'},
+		RunStart:  0,
+		RunEnd:    1,
+		Direction: run.Direction,
+		Face:      run.Face,
+		Size:      run.Size,
+		Script:    language.Latin,
+	})
+	if len(out.Glyphs) != 1 {
+		return shaping.Glyph{}, false
+	}
+	g := out.Glyphs[0]
+	g.ClusterIndex = cluster
+	g.RuneCount = 0
+	g.GlyphCount = 1
+	return g, true
+}
+
+// wrapHyphenated wraps the runs of the hyphenated text txt to maxWidth,
+// narrowing the lines broken at a soft hyphen by the width of the hyphen
+// glyph where it wouldn't fit otherwise. Narrowing a line changes the lines
+// after it and the wrapper can't resume from a line, so the text is wrapped
+// again from the start after every narrowed line.
+func (s *shaperImpl) wrapHyphenated(wc shaping.WrapConfig, maxWidth int, txt []rune, runs []shaping.Output) (_ []shaping.Line, truncated int) {
+	// widths holds the maximum widths of the leading lines, narrowed or
+	// known to fit.
+	var widths []int
+	for {
+		s.wrapper.Prepare(wc, txt, shaping.NewSliceIterator(runs))
+		lines := s.hyphenatedLines[:0]
+		narrowed := false
+		for i := 0; ; i++ {
+			w := maxWidth
+			if i < len(widths) {
+				w = widths[i]
+			}
+			line, done := s.wrapper.WrapNextLine(w)
+			if line.Line != nil {
+				lines = append(lines, line.Line)
+			}
+			truncated = line.Truncated
+			if done {
+				break
+			}
+			if i < len(widths) {
+				continue
+			}
+			widths = append(widths, maxWidth)
+			if hw := s.brokenHyphenAdvance(line.Line, txt); hw > 0 && (lineAdvance(line.Line)+hw).Ceil() > maxWidth {
+				widths[i] = maxWidth - hw.Ceil()
+				narrowed = true
+				break
+			}
+		}
+		s.hyphenatedLines = lines
+		if !narrowed {
+			return lines, truncated
+		}
+	}
+}
+
+// brokenHyphenAdvance returns the width of the hyphen glyph displayed at the
+// end of line if it is broken at a soft hyphen of txt, or zero.
+func (s *shaperImpl) brokenHyphenAdvance(line shaping.Line, txt []rune) fixed.Int26_6 {
+	if len(line) == 0 {
+		return 0
+	}
+	run := line[len(line)-1]
+	lastRune := run.Runes.Offset + run.Runes.Count - 1
+	if lastRune < 0 || lastRune >= len(txt) || txt[lastRune] != softHyphen || run.Direction.Progression() != di.FromTopLeft {
+		return 0
+	}
+	g, ok := s.hyphenGlyph(run, 0)
+	if !ok {
+		return 0
+	}
+	return g.XAdvance
+}
+
+// lineAdvance returns the width of line.
+func lineAdvance(line shaping.Line) fixed.Int26_6 {
+	var w fixed.Int26_6
+	for _, run := range line {
+		w += run.Advance
+	}
+	return w
+}
+
+// finishHyphenation removes the soft hyphens at the inserted indices of txt from
+// the rune accounting of the wrapped lines and displays a hyphen glyph at the end of
+// every line broken at a soft hyphen. If hasTruncator is set, the final run of the
+// final line is a truncator and is left untouched.
+func (s *shaperImpl) finishHyphenation(lines []shaping.Line, txt []rune, inserted []int, hasTruncator bool) {
+	for i, line := range lines {
+		for j := range line {
+			if hasTruncator && i == len(lines)-1 && j == len(line)-1 {
+				break
+			}
+			run := &line[j]
+			lastRune := run.Runes.Offset + run.Runes.Count - 1
+			breaksWord := i < len(lines)-1 && j == len(line)-1 &&
+				lastRune >= 0 && lastRune < len(txt) && txt[lastRune] == softHyphen &&
+				run.Direction.Progression() == di.FromTopLeft
+			n := countBetween(inserted, run.Runes.Offset, run.Runes.Offset+run.Runes.Count)
+			if n > 0 {
+				// Copy the glyphs, as their backing array is shared with
+				// neighbouring lines.
+				glyphs := make([]shaping.Glyph, 0, len(run.Glyphs)+1)
+				for _, g := range run.Glyphs {
+					c := countBetween(inserted, g.ClusterIndex, g.ClusterIndex+g.RuneCount)
+					if c > 0 && c == g.RuneCount {
+						// The glyph displays nothing but inserted soft hyphens.
+						continue
+					}
+					g.RuneCount -= c
+					glyphs = append(glyphs, g)
+				}
+				run.Glyphs = glyphs
+			}
+			if breaksWord {
+				if g, ok := s.hyphenGlyph(*run, lastRune); ok {
+					run.Glyphs = append(run.Glyphs[:len(run.Glyphs):len(run.Glyphs)], g)
+				}
+			}
+			if n > 0 || breaksWord {
+				run.RecomputeAdvance()
+			}
+			run.Runes.Offset -= countBetween(inserted, 0, run.Runes.Offset)
+			run.Runes.Count -= n
+		}
+	}
+}
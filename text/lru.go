@@ -81,6 +81,15 @@ type bitmap struct {
 
 type layoutCache = lru[layoutKey, document]
 
+type measureCache = lru[measureKey, Measurement]
+
+// measureKey is the cache key of a measurement, which unlike the layout
+// depends on the alignment.
+type measureKey struct {
+	layoutKey
+	alignment Alignment
+}
+
 type glyphValue[V any] struct {
 	v      V
 	glyphs []glyphInfo
@@ -93,8 +102,8 @@ type glyphLRU[V any] struct {
 
 var seed uint32
 
-// hashGlyphs computes a hash key based on the ID and X offset of
-// every glyph in the slice.
+// hashGlyphs computes a hash key based on the ID, orientation, and X and Y
+// offsets of every glyph in the slice.
 func (c *glyphLRU[V]) hashGlyphs(gs []Glyph) uint64 {
 	if c.seed == 0 {
 		c.seed = uint64(atomic.AddUint32(&seed, 3900798947))
@@ -104,12 +113,16 @@ func (c *glyphLRU[V]) hashGlyphs(gs []Glyph) uint64 {
 	}
 
 	h := c.seed
-	firstX := gs[0].X
+	firstX, firstY := gs[0].X, gs[0].Y
 	for _, g := range gs {
 		h += uint64(g.X - firstX)
 		h *= 6585573582091643
+		h += uint64(g.Y - firstY)
+		h *= 6585573582091643
 		h += uint64(g.ID)
 		h *= 3650802748644053
+		h += uint64(g.Flags & orientationFlags)
+		h *= 3650802748644053
 	}
 
 	return h
@@ -126,12 +139,18 @@ func (c *glyphLRU[V]) Get(key uint64, gs []Glyph) (V, bool) {
 func (c *glyphLRU[V]) Put(key uint64, glyphs []Glyph, v V) {
 	gids := make([]glyphInfo, len(glyphs))
 	firstX := fixed.I(0)
+	firstY := int32(0)
 	for i, glyph := range glyphs {
 		if i == 0 {
-			firstX = glyph.X
+			firstX, firstY = glyph.X, glyph.Y
+		}
+		// Cache glyph offsets relative to the first glyph.
+		gids[i] = glyphInfo{
+			ID:    glyph.ID,
+			X:     glyph.X - firstX,
+			Y:     glyph.Y - firstY,
+			Flags: glyph.Flags & orientationFlags,
 		}
-		// Cache glyph X offsets relative to the first glyph.
-		gids[i] = glyphInfo{ID: glyph.ID, X: glyph.X - firstX}
 	}
 	val := glyphValue[V]{
 		glyphs: gids,
@@ -147,8 +166,15 @@ type bitmapShapeCache = glyphLRU[op.CallOp]
 type glyphInfo struct {
 	ID GlyphID
 	X  fixed.Int26_6
+	Y  int32
+	// Flags holds the orientation flags of the glyph, which affect its
+	// shape.
+	Flags Flags
 }
 
+// orientationFlags are the glyph flags that affect the shape of glyphs.
+const orientationFlags = FlagVertical | FlagSideways
+
 type layoutKey struct {
 	ppem               fixed.Int26_6
 	maxWidth, minWidth int
@@ -156,7 +182,9 @@ type layoutKey struct {
 	str                string
 	truncator          string
 	locale             system.Locale
+	orientation        Orientation
 	font               giofont.Font
+	spans              string
 	forceTruncate      bool
 	wrapPolicy         WrapPolicy
 	lineHeight         fixed.Int26_6
@@ -170,12 +198,14 @@ func gidsEqual(a []glyphInfo, glyphs []Glyph) bool {
 		return false
 	}
 	firstX := fixed.Int26_6(0)
+	firstY := int32(0)
 	for i := range a {
 		if i == 0 {
-			firstX = glyphs[i].X
+			firstX, firstY = glyphs[i].X, glyphs[i].Y
 		}
-		// Cache glyph X offsets relative to the first glyph.
-		if a[i].ID != glyphs[i].ID || a[i].X != (glyphs[i].X-firstX) {
+		// Cache glyph offsets relative to the first glyph.
+		g := glyphs[i]
+		if a[i].ID != g.ID || a[i].X != g.X-firstX || a[i].Y != g.Y-firstY || a[i].Flags != g.Flags&orientationFlags {
 			return false
 		}
 	}
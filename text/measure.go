@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"image"
+
+	"gioui.org/io/system"
+	"golang.org/x/image/math/fixed"
+)
+
+// Measurement describes the size of laid out text, without its glyphs.
+type Measurement struct {
+	// Lines describes the lines of the text, in order.
+	Lines []LineMetrics
+	// Bounds is the logical bounding box of the text, in the coordinates of
+	// the glyphs returned by Shaper.NextGlyph.
+	Bounds image.Rectangle
+	// Baseline is the distance from the top of Bounds to the baseline of the
+	// first line, or from its left edge for vertical text.
+	Baseline int
+}
+
+// LineMetrics describes a line of text.
+type LineMetrics struct {
+	// Offset is the distance of the start of the line from the start of the
+	// text, given the alignment of the text.
+	Offset fixed.Int26_6
+	// Width is the advance of the line, that is its height for vertical
+	// text.
+	Width fixed.Int26_6
+	// Ascent is the distance of the top of the line from its baseline, and
+	// Descent the distance of its bottom, including the line gap. Vertical
+	// lines ascend to the right.
+	Ascent, Descent fixed.Int26_6
+	// Baseline is the position of the baseline of the line, from the top of
+	// the text for horizontal text and from its left edge for vertical text.
+	Baseline int
+	// Runes is the number of runes of the line.
+	Runes int
+}
+
+// Measure lays out str with params and returns its measurement, more
+// efficiently than summing the glyphs of LayoutString. Measurements are
+// cached, and their Lines must not be modified. Measure doesn't affect the
+// glyphs returned by NextGlyph.
+func (l *Shaper) Measure(params Parameters, str string) Measurement {
+	l.init()
+	key := measureKey{layoutKey: params.layoutKey(str), alignment: params.Alignment}
+	if m, ok := l.measureCache.Get(key); ok {
+		return m
+	}
+	// Lay out the text without disturbing the glyph iterator.
+	txt, line, run, glyph, advance := l.txt, l.line, l.run, l.glyph, l.advance
+	done, err, broke, start := l.done, l.err, l.brokeParagraph, l.pararagraphStart
+	l.txt = l.measured
+	l.layoutText(params, nil, str)
+	m := measure(l.txt)
+	l.measured = l.txt
+	l.txt, l.line, l.run, l.glyph, l.advance = txt, line, run, glyph, advance
+	l.done, l.err, l.brokeParagraph, l.pararagraphStart = done, err, broke, start
+	l.measureCache.Put(key, m)
+	return m
+}
+
+// measure computes the measurement of doc.
+func measure(doc document) Measurement {
+	m := Measurement{Lines: make([]LineMetrics, len(doc.lines))}
+	for i, line := range doc.lines {
+		align := doc.alignment.Align(line.direction, line.width, doc.alignWidth)
+		lm := LineMetrics{
+			Offset:   align,
+			Width:    line.width,
+			Ascent:   line.ascent,
+			Descent:  line.descent,
+			Baseline: line.yOffset,
+			Runes:    line.runeCount,
+		}
+		bounds := image.Rectangle{
+			Min: image.Pt(align.Floor(), line.yOffset-line.ascent.Ceil()),
+			Max: image.Pt((align + line.width).Ceil(), line.yOffset+line.descent.Ceil()),
+		}
+		if line.direction.Axis() == system.Vertical {
+			x := lineBaselineX(doc.lines, i)
+			lm.Baseline = x.Round()
+			bounds = image.Rectangle{
+				Min: image.Pt((x - line.descent).Floor(), align.Round()),
+				Max: image.Pt((x + line.ascent).Ceil(), align.Round()+line.width.Ceil()),
+			}
+		}
+		if i == 0 {
+			m.Bounds = bounds
+		}
+		// Union the bounds explicitly, to include empty lines.
+		m.Bounds.Min.X = min(m.Bounds.Min.X, bounds.Min.X)
+		m.Bounds.Min.Y = min(m.Bounds.Min.Y, bounds.Min.Y)
+		m.Bounds.Max.X = max(m.Bounds.Max.X, bounds.Max.X)
+		m.Bounds.Max.Y = max(m.Bounds.Max.Y, bounds.Max.Y)
+		m.Lines[i] = lm
+	}
+	if len(doc.lines) > 0 {
+		// Measure the first baseline from the bounds of all lines.
+		first := m.Lines[0].Baseline
+		if doc.lines[0].direction.Axis() == system.Vertical {
+			m.Baseline = first - m.Bounds.Min.X
+		} else {
+			m.Baseline = first - m.Bounds.Min.Y
+		}
+	}
+	return m
+}
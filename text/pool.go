@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"sync"
+
+	giofont "gioui.org/font"
+	"github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/fontscan"
+)
+
+// ShaperPool lays out text on any goroutine, such as background workers
+// preparing the paragraphs of a large document. Unlike a Shaper, a
+// ShaperPool is safe for concurrent use: each layout borrows a Shaper of
+// the pool, which creates more Shapers as needed.
+//
+// The Shapers of a pool share the faces of the collection of its options,
+// and the index of the system fonts. Faces of the same system font file
+// loaded by several Shapers are added once to the Shaper drawing the
+// paragraphs.
+type ShaperPool struct {
+	options []ShaperOption
+
+	mu   sync.Mutex
+	free []*Shaper
+}
+
+// Paragraph is text laid out by a ShaperPool. A Paragraph is immutable, and
+// safe to share between goroutines.
+type Paragraph struct {
+	glyphs      []Glyph
+	measurement Measurement
+	// faces are the faces of the glyphs, indexed by the face index of their
+	// IDs.
+	faces []paragraphFace
+}
+
+// paragraphFace is a face used by a Paragraph.
+type paragraphFace struct {
+	face font.Face
+	meta giofont.Font
+	src  rawTableSource
+	// loc is the location of the font file of the face, if loaded from
+	// the system fonts.
+	loc fontscan.Location
+}
+
+// NewShaperPool constructs a pool of Shapers created with options. The
+// restrictions of NewShaper apply to the first layout of the pool.
+func NewShaperPool(options ...ShaperOption) *ShaperPool {
+	return &ShaperPool{options: options}
+}
+
+// LayoutString lays out str with params. It may be called from any
+// goroutine.
+func (p *ShaperPool) LayoutString(params Parameters, str string) *Paragraph {
+	l := p.get()
+	defer p.put(l)
+	l.LayoutString(params, str)
+	para := &Paragraph{measurement: l.Measure(params, str)}
+	// Renumber the faces of the glyphs in the order they appear.
+	faces := make(map[int]int)
+	for g, ok := l.NextGlyph(); ok; g, ok = l.NextGlyph() {
+		ppem, faceIdx, gid := splitGlyphID(g.ID)
+		if faceIdx < len(l.shaper.faces) {
+			idx, ok := faces[faceIdx]
+			if !ok {
+				idx = len(para.faces)
+				faces[faceIdx] = idx
+				para.faces = append(para.faces, l.shaper.exportFace(faceIdx))
+			}
+			g.ID = newGlyphID(ppem, idx, gid)
+		}
+		para.glyphs = append(para.glyphs, g)
+	}
+	// The measurement is shared with the cache of the Shaper.
+	para.measurement.Lines = append([]LineMetrics(nil), para.measurement.Lines...)
+	return para
+}
+
+// get returns a free Shaper, creating one if necessary.
+func (p *ShaperPool) get() *Shaper {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.free); n > 0 {
+		l := p.free[n-1]
+		p.free = p.free[:n-1]
+		return l
+	}
+	return NewShaper(p.options...)
+}
+
+// put returns l to the free Shapers.
+func (p *ShaperPool) put(l *Shaper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = append(p.free, l)
+}
+
+// Measurement returns the measurement of the paragraph. Its Lines must not be
+// modified.
+func (p *Paragraph) Measurement() Measurement {
+	return p.measurement
+}
+
+// Len returns the number of glyphs of the paragraph.
+func (p *Paragraph) Len() int {
+	return len(p.glyphs)
+}
+
+// Glyphs appends the glyphs of the paragraph to gs and returns the result.
+// The glyphs are those NextGlyph would return after laying out the paragraph
+// with s, and are drawn with the Shape and Bitmaps methods of s. Glyphs must
+// be called on the goroutine of s; the faces of the paragraph are added to s
+// as needed.
+func (p *Paragraph) Glyphs(s *Shaper, gs []Glyph) []Glyph {
+	s.init()
+	var scratch [8]int
+	faces := scratch[:0]
+	for _, f := range p.faces {
+		faces = append(faces, s.shaper.importFace(f))
+	}
+	for _, g := range p.glyphs {
+		if ppem, faceIdx, gid := splitGlyphID(g.ID); faceIdx < len(faces) {
+			g.ID = newGlyphID(ppem, faces[faceIdx], gid)
+		}
+		gs = append(gs, g)
+	}
+	return gs
+}
+
+// exportFace returns a copy of the face at index idx, to be imported into
+// another shaper.
+func (s *shaperImpl) exportFace(idx int) paragraphFace {
+	// Faces are not safe for concurrent use, but their fonts are.
+	face := *s.faces[idx]
+	face.Coords = append(face.Coords[:0:0], face.Coords...)
+	return paragraphFace{
+		face: &face,
+		meta: s.faceMeta[idx],
+		src:  s.tableSources[face.Font],
+		loc:  s.location(face.Font),
+	}
+}
+
+// importFace adds the face f, unless it or a face of the same font file
+// was added, and returns its index.
+func (s *shaperImpl) importFace(f paragraphFace) int {
+	if idx, ok := s.faceToIndex[f.face.Font]; ok {
+		return idx
+	}
+	// Copy the face, for the paragraph may be imported by other shapers.
+	face := *f.face
+	if f.src != nil {
+		s.tableSources[face.Font] = f.src
+	}
+	if f.loc.File != "" {
+		s.importedLocations[face.Font] = f.loc
+	}
+	s.addSourcedFace(&face, f.meta, f.loc)
+	return s.faceToIndex[face.Font]
+}
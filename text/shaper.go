@@ -4,6 +4,7 @@ package text
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"strings"
 	"unicode/utf8"
@@ -12,6 +13,7 @@ import (
 	"gioui.org/io/system"
 	"gioui.org/op"
 	"gioui.org/op/clip"
+	"gioui.org/text/hyphen"
 	"github.com/go-text/typesetting/font"
 	"golang.org/x/image/math/fixed"
 )
@@ -35,12 +37,21 @@ const (
 	// breaking any word across lines on UAX#29 grapheme cluster boundaries to maximize the number of
 	// grapheme clusters on each line.
 	WrapGraphemes
+	// WrapHyphenate behaves like WrapHeuristically, but additionally permits breaking
+	// words at the hyphenation points found by the patterns registered for the text's
+	// language with [WithHyphenation]. A hyphen glyph is displayed at the end of lines
+	// broken within a word. Text in languages without registered patterns is wrapped
+	// exactly like WrapHeuristically.
+	WrapHyphenate
 )
 
 // Parameters are static text shaping attributes applied to the entire shaped text.
 type Parameters struct {
 	// Font describes the preferred typeface.
 	Font giofont.Font
+	// FontSpans overrides Font for spans of the text, such as to embolden
+	// or italicize words. The spans must be sorted and must not overlap.
+	FontSpans []FontSpan
 	// Alignment characterizes the positioning of text within the line. It does not directly
 	// impact shaping, but is provided in order to allow efficient offset computation.
 	Alignment Alignment
@@ -57,10 +68,14 @@ type Parameters struct {
 	WrapPolicy WrapPolicy
 
 	// MinWidth and MaxWidth provide the minimum and maximum horizontal space constraints
-	// for the shaped text.
+	// for the shaped text. For vertical text, they constrain the vertical space, that is
+	// the length of the lines.
 	MinWidth, MaxWidth int
 	// Locale provides primary direction and language information for the shaped text.
+	// Text with a vertical Direction is laid out in vertical lines.
 	Locale system.Locale
+	// Orientation controls the orientation of the glyphs of vertical text.
+	Orientation Orientation
 
 	// LineHeightScale is a scaling factor applied to the LineHeight of a paragraph. If zero, a default
 	// value of 1.2 will be used.
@@ -80,6 +95,40 @@ type Parameters struct {
 
 type FontFace = giofont.FontFace
 
+// FontSpan describes the typeface of the runes in [Start, End), counted from
+// the start of the text.
+type FontSpan struct {
+	Start, End int
+	Font       giofont.Font
+}
+
+// spansKey encodes spans for use in a layout cache key.
+func spansKey(spans []FontSpan) string {
+	if len(spans) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, s := range spans {
+		fmt.Fprintf(&b, "%d %d %q %d %d;", s.Start, s.End, s.Font.Typeface, s.Font.Style, s.Font.Weight)
+	}
+	return b.String()
+}
+
+// spansWithin returns the parts of the spans in [start, end), offset to start
+// at start. It uses buf as the backing storage of the returned slice.
+func spansWithin(spans []FontSpan, start, end int, buf []FontSpan) []FontSpan {
+	buf = buf[:0]
+	for _, s := range spans {
+		if s.End <= start || s.Start >= end {
+			continue
+		}
+		s.Start = max(s.Start, start) - start
+		s.End = min(s.End, end) - start
+		buf = append(buf, s)
+	}
+	return buf
+}
+
 // Glyph describes a shaped font glyph. Many fields are distances relative
 // to the "dot", which is a point on the baseline (the line upon which glyphs
 // visually rest) for the line of text containing the glyph.
@@ -104,13 +153,18 @@ type Glyph struct {
 	Y int32
 
 	// Advance is the logical width of the glyph. The glyph may be visually
-	// wider than this.
+	// wider than this. For glyphs of vertical lines, Advance is the logical
+	// height of the glyph below the dot.
 	Advance fixed.Int26_6
 	// Ascent is the distance from the dot to the logical top of glyphs in
 	// this glyph's face. The specific glyph may be shorter than this.
+	// For glyphs of vertical lines, whose dot is on the vertical baseline,
+	// Ascent is the distance to the right edge of the line.
 	Ascent fixed.Int26_6
 	// Descent is the distance from the dot to the logical bottom of glyphs
 	// in this glyph's face. The specific glyph may descend less than this.
+	// For glyphs of vertical lines, Descent is the distance to the left edge
+	// of the line.
 	Descent fixed.Int26_6
 	// Offset encodes the origin of the drawing coordinate space for this glyph
 	// relative to the dot. This value is used when converting glyphs to paths.
@@ -155,6 +209,12 @@ const (
 	// FlagTruncator and FlagClusterBreak will have a Runes field accounting for all
 	// runes truncated.
 	FlagTruncator
+	// FlagVertical is set for glyphs in vertical lines, which flow from top
+	// to bottom.
+	FlagVertical
+	// FlagSideways is set for glyphs in vertical lines that are rotated 90°
+	// clockwise.
+	FlagSideways
 )
 
 func (f Flags) String() string {
@@ -194,6 +254,16 @@ func (f Flags) String() string {
 	} else {
 		b.WriteString("_")
 	}
+	if f&FlagVertical != 0 {
+		b.WriteString("V")
+	} else {
+		b.WriteString("_")
+	}
+	if f&FlagSideways != 0 {
+		b.WriteString("↻")
+	} else {
+		b.WriteString("_")
+	}
 	return b.String()
 }
 
@@ -207,20 +277,29 @@ type GlyphID uint64
 // concurrent access to said map, resulting in a panic.
 //
 // Practically speaking, this means you should use different Shapers for
-// different top-level windows.
+// different top-level windows. Use a [ShaperPool] to lay out text on other
+// goroutines.
 type Shaper struct {
 	config struct {
 		disableSystemFonts bool
 		collection         []FontFace
+		hyphenation        map[string]*hyphen.Patterns
+		fallbacks          []Fallback
 	}
 	initialized      bool
 	shaper           shaperImpl
 	pathCache        pathCache
+	decorationCache  pathCache
 	bitmapShapeCache bitmapShapeCache
 	layoutCache      layoutCache
+	measureCache     measureCache
+	// measured holds the layout of the text being measured.
+	measured document
 
 	reader    *bufio.Reader
 	paragraph []byte
+	// spans holds the font spans of the paragraph being laid out.
+	spans []FontSpan
 
 	// Iterator state.
 	brokeParagraph   bool
@@ -253,6 +332,31 @@ func WithCollection(collection []FontFace) ShaperOption {
 	}
 }
 
+// WithHyphenation registers hyphenation patterns for the language identified by
+// the BCP-47 tag lang. They are used to wrap text with the [WrapHyphenate] policy
+// whenever the Language of the text's Locale matches lang exactly or by its primary
+// subtag; patterns registered for "de" apply to "de-CH" text unless patterns for
+// "de-CH" are also registered.
+func WithHyphenation(lang string, patterns *hyphen.Patterns) ShaperOption {
+	return func(s *Shaper) {
+		if s.config.hyphenation == nil {
+			s.config.hyphenation = make(map[string]*hyphen.Patterns)
+		}
+		s.config.hyphenation[strings.ToLower(lang)] = patterns
+	}
+}
+
+// WithFallback configures ordered chains of font families to prefer for the
+// runes of particular scripts and languages, ahead of the families of the
+// text's Font. The chains of all matching fallbacks are tried, those matching
+// the language of the text most precisely first, then those matching the
+// script of the rune. The options of multiple WithFallback calls accumulate.
+func WithFallback(fallbacks ...Fallback) ShaperOption {
+	return func(s *Shaper) {
+		s.config.fallbacks = append(s.config.fallbacks, fallbacks...)
+	}
+}
+
 // NewShaper constructs a shaper with the provided options.
 //
 // NewShaper must be called after [app.NewWindow], unless the [NoSystemFonts]
@@ -274,6 +378,8 @@ func (l *Shaper) init() {
 	l.initialized = true
 	l.reader = bufio.NewReader(nil)
 	l.shaper = *newShaperImpl(!l.config.disableSystemFonts, l.config.collection)
+	l.shaper.hyphenation = l.config.hyphenation
+	l.shaper.setFallbacks(l.config.fallbacks)
 }
 
 // Layout text from an io.Reader according to a set of options. Results can be retrieved by
@@ -292,6 +398,7 @@ func (l *Shaper) LayoutString(params Parameters, str string) {
 func (l *Shaper) reset(align Alignment) {
 	l.line, l.run, l.glyph, l.advance = 0, 0, 0, 0
 	l.done = false
+	l.brokeParagraph = false
 	l.txt.reset()
 	l.txt.alignment = align
 }
@@ -307,8 +414,9 @@ func (l *Shaper) layoutText(params Parameters, txt io.Reader, str string) {
 	}
 	l.reader.Reset(txt)
 	truncating := params.MaxLines > 0
+	spans := params.FontSpans
 	var done bool
-	var endByte int
+	var endByte, runeOff int
 	for !done {
 		l.paragraph = l.paragraph[:0]
 		if txt != nil {
@@ -343,6 +451,13 @@ func (l *Shaper) layoutText(params Parameters, txt io.Reader, str string) {
 		}
 		if len(str[:endByte]) > 0 || (len(l.paragraph) > 0 || len(l.txt.lines) == 0) {
 			params.forceTruncate = truncating && !done
+			if len(spans) > 0 {
+				// Offset the spans to the start of the paragraph.
+				n := utf8.RuneCountInString(str[:endByte]) + utf8.RuneCount(l.paragraph)
+				l.spans = spansWithin(spans, runeOff, runeOff+n, l.spans)
+				params.FontSpans = l.spans
+				runeOff += n
+			}
 			lines := l.layoutParagraph(params, str[:endByte], l.paragraph)
 			if truncating {
 				params.MaxLines -= len(lines.lines)
@@ -384,21 +499,7 @@ func (l *Shaper) layoutParagraph(params Parameters, asStr string, asBytes []byte
 	if len(asStr) == 0 && len(asBytes) > 0 {
 		asStr = string(asBytes)
 	}
-	// Alignment is not part of the cache key because changing it does not impact shaping.
-	lk := layoutKey{
-		ppem:            params.PxPerEm,
-		maxWidth:        params.MaxWidth,
-		minWidth:        params.MinWidth,
-		maxLines:        params.MaxLines,
-		truncator:       params.Truncator,
-		locale:          params.Locale,
-		font:            params.Font,
-		forceTruncate:   params.forceTruncate,
-		wrapPolicy:      params.WrapPolicy,
-		str:             asStr,
-		lineHeight:      params.LineHeight,
-		lineHeightScale: params.LineHeightScale,
-	}
+	lk := params.layoutKey(asStr)
 	if l, ok := l.layoutCache.Get(lk); ok {
 		return l
 	}
@@ -407,6 +508,27 @@ func (l *Shaper) layoutParagraph(params Parameters, asStr string, asBytes []byte
 	return lines
 }
 
+// layoutKey returns the cache key for the layout of str with p. Alignment is
+// not part of the key because changing it does not impact shaping.
+func (p Parameters) layoutKey(str string) layoutKey {
+	return layoutKey{
+		ppem:            p.PxPerEm,
+		maxWidth:        p.MaxWidth,
+		minWidth:        p.MinWidth,
+		maxLines:        p.MaxLines,
+		truncator:       p.Truncator,
+		locale:          p.Locale,
+		orientation:     p.Orientation,
+		font:            p.Font,
+		spans:           spansKey(p.FontSpans),
+		forceTruncate:   p.forceTruncate,
+		wrapPolicy:      p.WrapPolicy,
+		str:             str,
+		lineHeight:      p.LineHeight,
+		lineHeightScale: p.LineHeightScale,
+	}
+}
+
 // NextGlyph returns the next glyph from the most recent shaping operation, if
 // any. If there are no more glyphs, ok will be false.
 func (l *Shaper) NextGlyph() (_ Glyph, ok bool) {
@@ -433,19 +555,25 @@ func (l *Shaper) NextGlyph() (_ Glyph, ok bool) {
 		}
 		run := line.runs[l.run]
 		align := l.txt.alignment.Align(line.direction, line.width, l.txt.alignWidth)
+		vertical := line.direction.Axis() == system.Vertical
 		if l.line == 0 && l.run == 0 && len(run.Glyphs) == 0 {
 			// The very first run is empty, which will only happen when the
 			// entire text is a shaped empty string. Return a single synthetic
 			// glyph to provide ascent/descent information to the caller.
 			l.done = true
-			return Glyph{
+			g := Glyph{
 				X:       align,
 				Y:       int32(line.yOffset),
 				Runes:   0,
 				Flags:   FlagLineBreak | FlagClusterBreak | FlagRunBreak,
 				Ascent:  line.ascent,
 				Descent: line.descent,
-			}, true
+			}
+			if vertical {
+				g.X, g.Y = lineBaselineX(l.txt.lines, l.line), int32(align.Round())
+				g.Flags |= FlagVertical
+			}
+			return g, true
 		}
 		if l.glyph == len(run.Glyphs) {
 			l.run++
@@ -460,10 +588,14 @@ func (l *Shaper) NextGlyph() (_ Glyph, ok bool) {
 			glyphIdx = len(run.Glyphs) - 1 - glyphIdx
 		}
 		g := run.Glyphs[glyphIdx]
+		advance := g.xAdvance
+		if vertical {
+			advance = g.yAdvance
+		}
 		if rtl {
 			// Modify the advance prior to computing runOffset to ensure that the
 			// current glyph's width is subtracted in RTL.
-			l.advance += g.xAdvance
+			l.advance += advance
 		}
 		// runOffset computes how far into the run the dot should be positioned.
 		runOffset := l.advance
@@ -476,7 +608,7 @@ func (l *Shaper) NextGlyph() (_ Glyph, ok bool) {
 			Y:       int32(line.yOffset),
 			Ascent:  line.ascent,
 			Descent: line.descent,
-			Advance: g.xAdvance,
+			Advance: advance,
 			Runes:   uint16(g.runeCount),
 			Offset: fixed.Point26_6{
 				X: g.xOffset,
@@ -484,12 +616,20 @@ func (l *Shaper) NextGlyph() (_ Glyph, ok bool) {
 			},
 			Bounds: g.bounds,
 		}
+		if vertical {
+			// Vertical lines flow down the Y axis from their baseline.
+			glyph.X, glyph.Y = lineBaselineX(l.txt.lines, l.line), int32(glyph.X.Round())
+			glyph.Flags |= FlagVertical
+			if run.sideways {
+				glyph.Flags |= FlagSideways
+			}
+		}
 		if run.truncator {
 			glyph.Flags |= FlagTruncator
 		}
 		l.glyph++
 		if !rtl {
-			l.advance += g.xAdvance
+			l.advance += advance
 		}
 
 		endOfRun := l.glyph == len(run.Glyphs)
@@ -540,6 +680,16 @@ func (l *Shaper) NextGlyph() (_ Glyph, ok bool) {
 				// taking text alignment into account.
 				l.pararagraphStart.X = l.txt.alignment.Align(line.direction, 0, l.txt.alignWidth)
 				l.pararagraphStart.Y = glyph.Y + int32((glyph.Ascent + glyph.Descent).Ceil())
+				if vertical {
+					// Start a new line next to the final one.
+					next := (glyph.Ascent + glyph.Descent).Ceil()
+					if line.direction.LineProgression() == system.TowardOrigin {
+						next = -next
+					}
+					l.pararagraphStart.Flags |= FlagVertical
+					l.pararagraphStart.Y = int32(l.pararagraphStart.X.Round())
+					l.pararagraphStart.X = glyph.X + fixed.I(next)
+				}
 			}
 		}
 		return glyph, true
@@ -577,8 +727,22 @@ func splitGlyphID(g GlyphID) (fixed.Int26_6, int, font.GID) {
 	return ppem, faceIdx, gid
 }
 
+// GlyphFont returns the font of the face that shaped the glyph identified by
+// id. All glyphs of a run, which ends with a glyph flagged with [FlagRunBreak],
+// share a face, so GlyphFont reveals the face chosen for each run of text.
+// GlyphFont returns false if id doesn't refer to a face known to the shaper.
+func (l *Shaper) GlyphFont(id GlyphID) (giofont.Font, bool) {
+	l.init()
+	_, faceIdx, _ := splitGlyphID(id)
+	if faceIdx >= len(l.shaper.faceMeta) {
+		return giofont.Font{}, false
+	}
+	return l.shaper.faceMeta[faceIdx], true
+}
+
 // Shape converts the provided glyphs into a path. The path will enclose the forms
-// of all vector glyphs.
+// of all vector glyphs. Of color glyphs, only the parts painted with the text
+// color are included; the rest is displayed by Bitmaps.
 // All glyphs are expected to be from a single line of text (their Y offsets are ignored).
 func (l *Shaper) Shape(gs []Glyph) clip.PathSpec {
 	l.init()
@@ -593,9 +757,38 @@ func (l *Shaper) Shape(gs []Glyph) clip.PathSpec {
 	return shape
 }
 
-// Bitmaps extracts bitmap glyphs from the provided slice and creates an op.CallOp to present
-// them. The returned op.CallOp will align correctly with the return value of Shape() for the
-// same gs slice.
+// DecorationMetrics returns the underline and strikethrough metrics of the
+// face and size of the glyph identified by id. All glyphs of a run share
+// their metrics. DecorationMetrics returns false if id doesn't refer to a
+// face known to the shaper.
+func (l *Shaper) DecorationMetrics(id GlyphID) (DecorationMetrics, bool) {
+	l.init()
+	ppem, faceIdx, _ := splitGlyphID(id)
+	return l.shaper.decorationMetrics(faceIdx, ppem)
+}
+
+// Decorations converts the provided glyphs into the path of the decoration
+// lines of d, positioned relative to the first glyph like the path of Shape.
+// Underlines are interrupted where they cross the descenders of vector glyphs.
+// Unlike Shape, glyphs may span several lines of text. Vertical glyphs are
+// not decorated.
+func (l *Shaper) Decorations(gs []Glyph, d Decoration) clip.PathSpec {
+	l.init()
+	key := decorationKey(l.decorationCache.hashGlyphs(gs), gs, d)
+	shape, ok := l.decorationCache.Get(key, gs)
+	if ok {
+		return shape
+	}
+	pathOps := new(op.Ops)
+	shape = l.shaper.Decorations(pathOps, gs, d)
+	l.decorationCache.Put(key, gs, shape)
+	return shape
+}
+
+// Bitmaps extracts bitmap and color glyphs from the provided slice and creates an op.CallOp
+// to present them. Color glyphs are defined by the COLR (versions 0 and 1) and SVG tables of
+// OpenType fonts, and are painted with the colors of the font's default palette. The returned
+// op.CallOp will align correctly with the return value of Shape() for the same gs slice.
 // All glyphs are expected to be from a single line of text (their Y offsets are ignored).
 func (l *Shaper) Bitmaps(gs []Glyph) op.CallOp {
 	l.init()
@@ -2,7 +2,9 @@ package text
 
 import (
 	"fmt"
+	"image"
 	"strings"
+	"sync"
 	"testing"
 
 	nsareg "eliasnaur.com/font/noto/sans/arabic/regular"
@@ -10,7 +12,10 @@ import (
 	"gioui.org/font/gofont"
 	"gioui.org/font/opentype"
 	"gioui.org/io/system"
+	"gioui.org/op/clip"
+	"github.com/go-text/typesetting/fontscan"
 	"golang.org/x/exp/slices"
+	"golang.org/x/image/font/gofont/gomono"
 	"golang.org/x/image/font/gofont/goregular"
 	"golang.org/x/image/math/fixed"
 )
@@ -581,3 +586,390 @@ func TestShapeStringRuneAccounting(t *testing.T) {
 		})
 	}
 }
+
+// TestFallback checks that fallback chains select faces by script and
+// language, and that the chosen faces can be inspected.
+func TestFallback(t *testing.T) {
+	regular, _ := opentype.Parse(goregular.TTF)
+	mono, _ := opentype.Parse(gomono.TTF)
+	shaper := NewShaper(NoSystemFonts(),
+		WithCollection([]font.FontFace{
+			{Font: font.Font{Typeface: "Go"}, Face: regular},
+			{Font: font.Font{Typeface: "Go Mono"}, Face: mono},
+		}),
+		WithFallback(
+			Fallback{Language: "ja", Scripts: []string{"Latn"}, Typeface: "Go Mono"},
+			Fallback{Scripts: []string{"Grek"}, Typeface: "Go Mono, Go"},
+		),
+	)
+	tests := []struct {
+		lang string
+		txt  string
+		want []font.Typeface
+	}{
+		{lang: "en", txt: "hello world", want: []font.Typeface{"Go"}},
+		{lang: "ja-JP", txt: "hello world", want: []font.Typeface{"Go Mono"}},
+		{lang: "en", txt: "abc αβγ abc", want: []font.Typeface{"Go", "Go Mono", "Go"}},
+	}
+	for _, tc := range tests {
+		shaper.LayoutString(Parameters{
+			PxPerEm:  fixed.I(10),
+			MaxWidth: 1000,
+			Locale:   system.Locale{Language: tc.lang},
+		}, tc.txt)
+		var got []font.Typeface
+		newRun := true
+		for g, ok := shaper.NextGlyph(); ok; g, ok = shaper.NextGlyph() {
+			if newRun {
+				f, ok := shaper.GlyphFont(g.ID)
+				if !ok {
+					t.Fatalf("%s %q: no font for glyph %v", tc.lang, tc.txt, g.ID)
+				}
+				if len(got) == 0 || got[len(got)-1] != f.Typeface {
+					got = append(got, f.Typeface)
+				}
+			}
+			newRun = g.Flags&FlagRunBreak != 0
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s %q: got faces %q, want %q", tc.lang, tc.txt, got, tc.want)
+		}
+	}
+}
+
+// TestFontSpans checks that spans of text are shaped with the faces of their
+// fonts, in every paragraph of the text.
+func TestFontSpans(t *testing.T) {
+	shaper := NewShaper(NoSystemFonts(), WithCollection(gofont.Collection()))
+	shaper.LayoutString(Parameters{
+		PxPerEm:  fixed.I(10),
+		MaxWidth: 1000,
+		FontSpans: []FontSpan{
+			{Start: 6, End: 11, Font: font.Font{Weight: font.Bold}},
+			{Start: 12, End: 17, Font: font.Font{Style: font.Italic}},
+		},
+	}, "hello world\nabcde fgh")
+	var got []font.Font
+	newRun := true
+	for g, ok := shaper.NextGlyph(); ok; g, ok = shaper.NextGlyph() {
+		if newRun && g.Runes > 0 {
+			f, _ := shaper.GlyphFont(g.ID)
+			if f.Weight > font.Normal {
+				// Accept the boldest face of the collection.
+				f.Weight = font.Bold
+			}
+			got = append(got, font.Font{Style: f.Style, Weight: f.Weight})
+		}
+		newRun = g.Flags&FlagRunBreak != 0
+	}
+	want := []font.Font{{}, {Weight: font.Bold}, {Style: font.Italic}, {}}
+	if !slices.Equal(got, want) {
+		t.Errorf("got run fonts %v, want %v", got, want)
+	}
+}
+
+// TestVerticalLayout checks that vertical text is wrapped into lines along the
+// Y axis, stacked in the direction of the locale, with the requested glyph
+// orientation.
+func TestVerticalLayout(t *testing.T) {
+	ltrFace, _ := opentype.Parse(goregular.TTF)
+	shaper := NewShaper(NoSystemFonts(), WithCollection([]FontFace{{Face: ltrFace}}))
+	const maxWidth = 60
+	for _, tc := range []struct {
+		dir         system.TextDirection
+		orientation Orientation
+		sideways    bool
+	}{
+		{system.VerticalRL, OrientMixed, true},
+		{system.VerticalLR, OrientMixed, true},
+		{system.VerticalRL, OrientUpright, false},
+		{system.VerticalLR, OrientSideways, true},
+	} {
+		t.Run(fmt.Sprintf("%v/%v", tc.dir, tc.orientation), func(t *testing.T) {
+			shaper.LayoutString(Parameters{
+				PxPerEm:     fixed.I(16),
+				MaxWidth:    maxWidth,
+				Locale:      system.Locale{Direction: tc.dir},
+				Orientation: tc.orientation,
+			}, "vertical text wraps\ninto lines")
+			var lines []fixed.Int26_6
+			lineStart := true
+			runes := 0
+			for g, ok := shaper.NextGlyph(); ok; g, ok = shaper.NextGlyph() {
+				runes += int(g.Runes)
+				if g.Flags&FlagVertical == 0 {
+					t.Fatalf("glyph %+v is not vertical", g)
+				}
+				if sideways := g.Flags&FlagSideways != 0; sideways != tc.sideways && g.Runes > 0 {
+					t.Errorf("glyph %+v: sideways %v, want %v", g, sideways, tc.sideways)
+				}
+				if lineStart {
+					lines = append(lines, g.X)
+				} else if x := lines[len(lines)-1]; g.X != x {
+					t.Errorf("glyph %+v: baseline at x %v, want %v", g, g.X, x)
+				}
+				if end := int(g.Y) + g.Advance.Round(); g.Runes > 0 && end > maxWidth+1 {
+					t.Errorf("glyph %+v ends at y %d, beyond %d", g, end, maxWidth)
+				}
+				lineStart = g.Flags&FlagLineBreak != 0
+			}
+			if want := len([]rune("vertical text wraps\ninto lines")); runes != want {
+				t.Errorf("got %d runes, want %d", runes, want)
+			}
+			if len(lines) < 3 {
+				t.Fatalf("got %d lines, want at least 3", len(lines))
+			}
+			for i := 1; i < len(lines); i++ {
+				if d := lines[i] - lines[i-1]; (d < 0) != (tc.dir == system.VerticalRL) || d == 0 {
+					t.Errorf("line %d at x %v follows line at x %v", i, lines[i], lines[i-1])
+				}
+			}
+			if tc.dir == system.VerticalLR && lines[0] <= 0 {
+				t.Errorf("first line at x %v, want positive", lines[0])
+			}
+			if tc.dir == system.VerticalRL && lines[len(lines)-1] <= 0 {
+				t.Errorf("last line at x %v, want positive", lines[len(lines)-1])
+			}
+		})
+	}
+}
+
+// TestVerticalTruncation checks that vertical lines are truncated.
+func TestVerticalTruncation(t *testing.T) {
+	ltrFace, _ := opentype.Parse(goregular.TTF)
+	shaper := NewShaper(NoSystemFonts(), WithCollection([]FontFace{{Face: ltrFace}}))
+	const txt = "vertical text is truncated"
+	shaper.LayoutString(Parameters{
+		PxPerEm:  fixed.I(16),
+		MaxWidth: 60,
+		MaxLines: 1,
+		Locale:   system.Locale{Direction: system.VerticalRL},
+	}, txt)
+	var truncated, runes int
+	for g, ok := shaper.NextGlyph(); ok; g, ok = shaper.NextGlyph() {
+		if g.Flags&FlagTruncator != 0 && g.Flags&FlagClusterBreak != 0 {
+			truncated = int(g.Runes)
+		}
+		runes += int(g.Runes)
+		if end := int(g.Y) + g.Advance.Round(); end > 61 {
+			t.Errorf("glyph %+v ends at y %d, beyond 60", g, end)
+		}
+	}
+	if truncated == 0 {
+		t.Error("text was not truncated")
+	}
+	if want := len([]rune(txt)); runes != want {
+		t.Errorf("got %d runes, want %d", runes, want)
+	}
+}
+
+// TestDecorations checks the decoration metrics of a font and that underlines
+// skip descenders.
+func TestDecorations(t *testing.T) {
+	ltrFace, _ := opentype.Parse(goregular.TTF)
+	shaper := NewShaper(NoSystemFonts(), WithCollection([]FontFace{{Face: ltrFace}}))
+	shaper.LayoutString(Parameters{
+		PxPerEm:  fixed.I(32),
+		MaxWidth: 1000,
+	}, "ag")
+	var glyphs []Glyph
+	for g, ok := shaper.NextGlyph(); ok; g, ok = shaper.NextGlyph() {
+		glyphs = append(glyphs, g)
+	}
+	if len(glyphs) != 2 {
+		t.Fatalf("got %d glyphs, want 2", len(glyphs))
+	}
+	m, ok := shaper.DecorationMetrics(glyphs[0].ID)
+	if !ok {
+		t.Fatal("no decoration metrics")
+	}
+	if m.UnderlineOffset <= 0 || m.UnderlineThickness <= 0 {
+		t.Errorf("underline at %v with thickness %v, want below the baseline", m.UnderlineOffset, m.UnderlineThickness)
+	}
+	if m.StrikethroughOffset >= 0 || m.StrikethroughThickness <= 0 {
+		t.Errorf("strikethrough at %v with thickness %v, want above the baseline", m.StrikethroughOffset, m.StrikethroughThickness)
+	}
+	if _, ok := shaper.DecorationMetrics(newGlyphID(fixed.I(32), 10, 0)); ok {
+		t.Error("got decoration metrics for unknown face")
+	}
+
+	top, thick := decorationLine(m.UnderlineOffset, m.UnderlineThickness)
+	ink := shaper.shaper.inkIntervals(glyphs, glyphs[0].X, glyphs[0].Y, top-thick, top+2*thick)
+	if len(ink) != 1 {
+		t.Fatalf("got ink intervals %v, want the descender of g only", ink)
+	}
+	gx := fixedToFloat(glyphs[1].X - glyphs[0].X)
+	if ink[0][0] < gx || ink[0][1] > gx+fixedToFloat(glyphs[1].Advance) {
+		t.Errorf("ink interval %v outside glyph at %v", ink[0], gx)
+	}
+	if p := shaper.Decorations(glyphs, Underline|Strikethrough); p == (clip.PathSpec{}) {
+		t.Error("empty decoration path")
+	}
+}
+
+func TestSubtractIntervals(t *testing.T) {
+	for _, tc := range []struct {
+		cut  [][2]float32
+		want [][2]float32
+	}{
+		{nil, [][2]float32{{0, 10}}},
+		{[][2]float32{{4, 5}}, [][2]float32{{0, 3}, {6, 10}}},
+		{[][2]float32{{6, 7}, {2, 3}}, [][2]float32{{0, 1}, {4, 5}, {8, 10}}},
+		{[][2]float32{{-5, 20}}, nil},
+		{[][2]float32{{8, 12}}, [][2]float32{{0, 7}}},
+	} {
+		if got := subtractIntervals(0, 10, tc.cut, 1); !slices.Equal(got, tc.want) {
+			t.Errorf("subtracting %v: got %v, want %v", tc.cut, got, tc.want)
+		}
+	}
+}
+
+// TestMeasure checks that measurements agree with the glyphs of the layout.
+func TestMeasure(t *testing.T) {
+	ltrFace, _ := opentype.Parse(goregular.TTF)
+	collection := []FontFace{{Face: ltrFace}}
+	cache := NewShaper(NoSystemFonts(), WithCollection(collection))
+	params := Parameters{
+		Alignment: Middle,
+		PxPerEm:   fixed.I(10),
+		MaxWidth:  100,
+		Locale:    english,
+	}
+	const txt = "Lorem ipsum dolor sit amet,\nconsectetur adipiscing elit"
+	cache.LayoutString(params, txt)
+	var lines []LineMetrics
+	var bounds image.Rectangle
+	first := true
+	for g, ok := cache.NextGlyph(); ok; g, ok = cache.NextGlyph() {
+		logical := image.Rectangle{
+			Min: image.Pt(g.X.Floor(), int(g.Y)-g.Ascent.Ceil()),
+			Max: image.Pt((g.X + g.Advance).Ceil(), int(g.Y)+g.Descent.Ceil()),
+		}
+		if first {
+			bounds, first = logical, false
+			lines = append(lines, LineMetrics{Offset: g.X, Baseline: int(g.Y)})
+		}
+		bounds = bounds.Union(logical)
+		l := &lines[len(lines)-1]
+		l.Width += g.Advance
+		l.Runes += int(g.Runes)
+		if g.Flags&FlagLineBreak != 0 {
+			lines = append(lines, LineMetrics{})
+		} else if l.Width == g.Advance {
+			l.Offset, l.Baseline = g.X, int(g.Y)
+		}
+	}
+	lines = lines[:len(lines)-1]
+
+	// Measure a different text first, to check that measuring doesn't
+	// disturb the layout.
+	cache.LayoutString(params, txt)
+	cache.Measure(params, "other text")
+	m := cache.Measure(params, txt)
+	if len(m.Lines) != len(lines) || len(lines) < 3 {
+		t.Fatalf("measured %d lines, laid out %d", len(m.Lines), len(lines))
+	}
+	for i, l := range lines {
+		ml := m.Lines[i]
+		if ml.Offset != l.Offset || ml.Width != l.Width || ml.Baseline != l.Baseline || ml.Runes != l.Runes {
+			t.Errorf("line %d: measured %+v, laid out %+v", i, ml, l)
+		}
+	}
+	if m.Bounds != bounds {
+		t.Errorf("measured bounds %v, laid out %v", m.Bounds, bounds)
+	}
+	if want := lines[0].Baseline - bounds.Min.Y; m.Baseline != want {
+		t.Errorf("measured baseline %d, want %d", m.Baseline, want)
+	}
+	n := 0
+	for _, ok := cache.NextGlyph(); ok; _, ok = cache.NextGlyph() {
+		n++
+	}
+	if n == 0 {
+		t.Error("measuring disturbed the glyph iterator")
+	}
+	if m2 := cache.Measure(params, txt); &m2.Lines[0] != &m.Lines[0] {
+		t.Error("measurement wasn't cached")
+	}
+}
+
+func TestShaperPool(t *testing.T) {
+	monoFace, _ := opentype.Parse(gomono.TTF)
+	regularFace, _ := opentype.Parse(goregular.TTF)
+	pool := NewShaperPool(NoSystemFonts(), WithCollection([]FontFace{
+		{Font: font.Font{Typeface: "Mono"}, Face: monoFace},
+	}))
+	params := Parameters{
+		PxPerEm:  fixed.I(10),
+		MaxWidth: 100,
+		Locale:   english,
+	}
+	const txt = "Lorem ipsum dolor sit amet,\nconsectetur adipiscing elit"
+	paras := make([]*Paragraph, 8)
+	var wg sync.WaitGroup
+	for i := range paras {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paras[i] = pool.LayoutString(params, txt)
+		}(i)
+	}
+	wg.Wait()
+
+	// The shaper drawing the paragraphs doesn't know their face.
+	shaper := NewShaper(NoSystemFonts(), WithCollection([]FontFace{
+		{Font: font.Font{Typeface: "Regular"}, Face: regularFace},
+	}))
+	shaper.LayoutString(params, txt)
+	var want []Glyph
+	for g, ok := shaper.NextGlyph(); ok; g, ok = shaper.NextGlyph() {
+		want = append(want, g)
+	}
+	for _, p := range paras {
+		gs := p.Glyphs(shaper, nil)
+		if len(gs) != p.Len() || len(gs) == 0 {
+			t.Fatalf("got %d glyphs, want %d", len(gs), p.Len())
+		}
+		for _, g := range gs {
+			f, ok := shaper.GlyphFont(g.ID)
+			if !ok || f.Typeface != "Mono" {
+				t.Fatalf("glyph %v has font %v, want Mono", g, f)
+			}
+		}
+		if gs[0].ID == want[0].ID {
+			t.Error("paragraph glyphs use the face of the shaper")
+		}
+		shaper.Shape(gs[:1])
+		if m := p.Measurement(); len(m.Lines) < 3 {
+			t.Errorf("measured %d lines", len(m.Lines))
+		}
+	}
+	if n := len(shaper.shaper.faces); n != 2 {
+		t.Errorf("shaper has %d faces after importing paragraphs, want 2", n)
+	}
+}
+
+func TestImportFaceSource(t *testing.T) {
+	// Shapers loading the same system font file have distinct faces.
+	loc := fontscan.Location{File: "/fonts/mono.ttf"}
+	var faces []paragraphFace
+	for i := 0; i < 2; i++ {
+		face, _ := opentype.Parse(gomono.TTF)
+		faces = append(faces, paragraphFace{
+			face: face.Face(),
+			meta: font.Font{Typeface: "Mono"},
+			loc:  loc,
+		})
+	}
+	if faces[0].face.Font == faces[1].face.Font {
+		t.Fatal("faces share their font")
+	}
+	shaper := testShaper()
+	idx0, idx1 := shaper.importFace(faces[0]), shaper.importFace(faces[1])
+	if idx0 != idx1 {
+		t.Errorf("faces of the same file imported at %d and %d", idx0, idx1)
+	}
+	if n := len(shaper.faces); n != 1 {
+		t.Errorf("shaper has %d faces, want 1", n)
+	}
+}
@@ -0,0 +1,891 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-text/typesetting/opentype/api"
+
+	"gioui.org/f32"
+	f32internal "gioui.org/internal/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// svgMaxDepth bounds the nesting of SVG elements and references.
+const svgMaxDepth = 32
+
+// svgDocument is a parsed SVG document from an OpenType SVG table. Only
+// the subset of SVG commonly used by color fonts is supported: paths and basic
+// shapes filled with solid colors or gradients, groups, transforms, opacity
+// and use elements. Strokes, masks, clip paths and filters are ignored.
+type svgDocument struct {
+	root *svgNode
+	ids  map[string]*svgNode
+}
+
+type svgNode struct {
+	name     string
+	attrs    map[string]string
+	children []*svgNode
+}
+
+// parseSVG parses an SVG document, which may be gzip compressed.
+func parseSVG(src []byte) (*svgDocument, error) {
+	var r io.Reader = bytes.NewReader(src)
+	if len(src) > 2 && src[0] == 0x1f && src[1] == 0x8b {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	}
+	doc := &svgDocument{ids: make(map[string]*svgNode)}
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	var stack []*svgNode
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &svgNode{name: tok.Name.Local, attrs: make(map[string]string)}
+			for _, a := range tok.Attr {
+				n.attrs[a.Name.Local] = strings.TrimSpace(a.Value)
+			}
+			// Presentation properties in style attributes override
+			// attributes.
+			for _, decl := range strings.Split(n.attrs["style"], ";") {
+				if k, v, ok := strings.Cut(decl, ":"); ok {
+					n.attrs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+				}
+			}
+			if id := n.attrs["id"]; id != "" {
+				doc.ids[id] = n
+			}
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.children = append(p.children, n)
+			} else if doc.root == nil {
+				doc.root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if doc.root == nil {
+		return nil, errors.New("svg: empty document")
+	}
+	return doc, nil
+}
+
+// svgGlyph returns the parsed SVG document containing the glyph with the
+// given id, or nil.
+func (cf *colorFace) svgGlyph(src []byte, id string) *svgDocument {
+	if len(src) == 0 {
+		return nil
+	}
+	doc, ok := cf.svg[&src[0]]
+	if !ok {
+		doc, _ = parseSVG(src)
+		if cf.svg == nil {
+			cf.svg = make(map[*byte]*svgDocument)
+		}
+		cf.svg[&src[0]] = doc
+	}
+	if doc == nil || doc.ids[id] == nil {
+		return nil
+	}
+	return doc
+}
+
+// svgGlyphID returns the id of the SVG element describing the glyph gid.
+func svgGlyphID(gid api.GID) string {
+	return "glyph" + strconv.Itoa(int(gid))
+}
+
+// svgStyle is the inherited style of an SVG element.
+type svgStyle struct {
+	fill        string
+	fillOpacity float32
+}
+
+// svgRenderer renders SVG documents.
+type svgRenderer struct {
+	ops *op.Ops
+	doc *svgDocument
+}
+
+// render renders n and its children. The pixels transform maps the current
+// coordinate space to pixels.
+func (r *svgRenderer) render(n *svgNode, st svgStyle, pixels f32.Affine2D, depth int) {
+	if depth > svgMaxDepth {
+		return
+	}
+	switch n.name {
+	case "defs", "linearGradient", "radialGradient", "clipPath", "mask", "pattern",
+		"symbol", "style", "title", "desc", "metadata", "filter":
+		return
+	}
+	if v, ok := n.attrs["display"]; ok && v == "none" {
+		return
+	}
+	if v, ok := n.attrs["fill"]; ok && v != "inherit" {
+		st.fill = v
+	}
+	if v, ok := n.attrs["fill-opacity"]; ok {
+		st.fillOpacity = parseSVGNumber(v, 1)
+	}
+	if v, ok := n.attrs["transform"]; ok {
+		tr := parseSVGTransform(v)
+		pixels = pixels.Mul(tr)
+		defer op.Affine(tr).Push(r.ops).Pop()
+	}
+	if v, ok := n.attrs["opacity"]; ok {
+		if o := parseSVGNumber(v, 1); o < 1 {
+			defer paint.PushOpacity(r.ops, clamp01(o)).Pop()
+		}
+	}
+	switch n.name {
+	case "svg", "g", "a", "switch":
+		for _, c := range n.children {
+			r.render(c, st, pixels, depth+1)
+		}
+	case "use":
+		href := n.attrs["href"]
+		target := r.doc.ids[strings.TrimPrefix(href, "#")]
+		if target == nil || !strings.HasPrefix(href, "#") {
+			return
+		}
+		off := f32.Point{X: svgAttr(n, "x", 0), Y: svgAttr(n, "y", 0)}
+		tr := f32.Affine2D{}.Offset(off)
+		defer op.Affine(tr).Push(r.ops).Pop()
+		r.render(target, st, pixels.Mul(tr), depth+1)
+	default:
+		var p svgPath
+		if !p.shape(n) {
+			return
+		}
+		r.fill(&p, st, pixels)
+	}
+}
+
+// fill fills p according to st.
+func (r *svgRenderer) fill(p *svgPath, st svgStyle, pixels f32.Affine2D) {
+	if st.fill == "none" || len(p.segs) == 0 {
+		return
+	}
+	spec, bounds := p.record(r.ops)
+	defer clip.Outline{Path: spec}.Op().Push(r.ops).Pop()
+	if id, ok := parseSVGURL(st.fill); ok {
+		g, tr, ok := r.gradient(r.doc.ids[id], bounds, st.fillOpacity)
+		if !ok {
+			return
+		}
+		inv, ok := invert(tr)
+		if !ok {
+			return
+		}
+		paintGradient(r.ops, g, bounds, inv, pixelScale(pixels))
+		return
+	}
+	c, ok := parseSVGColor(st.fill)
+	if !ok {
+		return
+	}
+	c.A = uint8(float32(c.A)*clamp01(st.fillOpacity) + .5)
+	paint.ColorOp{Color: c}.Add(r.ops)
+	paint.PaintOp{}.Add(r.ops)
+}
+
+// gradient converts a linearGradient or radialGradient element into a
+// gradient along with the transformation from gradient coordinates to the
+// current coordinate space. The bounds of the filled shape determine the
+// gradient coordinates in the default objectBoundingBox units.
+func (r *svgRenderer) gradient(n *svgNode, bounds f32internal.Rectangle, opacity float32) (*gradient, f32.Affine2D, bool) {
+	if n == nil || (n.name != "linearGradient" && n.name != "radialGradient") {
+		return nil, f32.Affine2D{}, false
+	}
+	// Attributes and stops may be inherited from a referenced gradient.
+	attr := func(name string) (string, bool) {
+		for m, d := n, 0; m != nil && d < svgMaxDepth; d++ {
+			if v, ok := m.attrs[name]; ok {
+				return v, true
+			}
+			m = r.doc.ids[strings.TrimPrefix(m.attrs["href"], "#")]
+		}
+		return "", false
+	}
+	num := func(name string, def float32) float32 {
+		if v, ok := attr(name); ok {
+			return parseSVGNumber(v, def)
+		}
+		return def
+	}
+	g := new(gradient)
+	switch v, _ := attr("spreadMethod"); v {
+	case "repeat":
+		g.extend = extendRepeat
+	case "reflect":
+		g.extend = extendReflect
+	}
+	stopsNode := n
+	for d := 0; stopsNode != nil && d < svgMaxDepth; d++ {
+		if hasStops(stopsNode) {
+			break
+		}
+		stopsNode = r.doc.ids[strings.TrimPrefix(stopsNode.attrs["href"], "#")]
+	}
+	if stopsNode != nil {
+		for _, s := range stopsNode.children {
+			if s.name != "stop" {
+				continue
+			}
+			c, ok := parseSVGColor(s.attrs["stop-color"])
+			if !ok {
+				c = color.NRGBA{A: 0xff}
+			}
+			a := parseSVGNumber(s.attrs["stop-opacity"], 1) * clamp01(opacity)
+			c.A = uint8(float32(c.A)*clamp01(a) + .5)
+			off := clamp01(parseSVGNumber(s.attrs["offset"], 0))
+			if n := len(g.stops); n > 0 && off < g.stops[n-1].offset {
+				off = g.stops[n-1].offset
+			}
+			g.stops = append(g.stops, colorStop{offset: off, color: c})
+		}
+	}
+	if len(g.stops) == 0 {
+		return nil, f32.Affine2D{}, false
+	}
+	if n.name == "linearGradient" {
+		g.kind = gradientLinear
+		g.p0 = f32.Point{X: num("x1", 0), Y: num("y1", 0)}
+		g.p1 = f32.Point{X: num("x2", 1), Y: num("y2", 0)}
+		d := g.p1.Sub(g.p0)
+		g.p2 = g.p0.Add(f32.Point{X: -d.Y, Y: d.X})
+	} else {
+		g.kind = gradientRadial
+		c := f32.Point{X: num("cx", .5), Y: num("cy", .5)}
+		g.p0 = f32.Point{X: num("fx", c.X), Y: num("fy", c.Y)}
+		g.r0 = num("fr", 0)
+		g.p1 = c
+		g.r1 = num("r", .5)
+	}
+	var tr f32.Affine2D
+	if v, _ := attr("gradientUnits"); v != "userSpaceOnUse" {
+		size := bounds.Size()
+		tr = tr.Scale(f32.Point{}, size).Offset(bounds.Min)
+	}
+	if v, ok := attr("gradientTransform"); ok {
+		tr = tr.Mul(parseSVGTransform(v))
+	}
+	return g, tr, true
+}
+
+func hasStops(n *svgNode) bool {
+	for _, c := range n.children {
+		if c.name == "stop" {
+			return true
+		}
+	}
+	return false
+}
+
+func svgAttr(n *svgNode, name string, def float32) float32 {
+	return parseSVGNumber(n.attrs[name], def)
+}
+
+// parseSVGNumber parses a number, with an optional unit or percentage. It
+// returns def if s is not a number.
+func parseSVGNumber(s string, def float32) float32 {
+	s = strings.TrimSpace(s)
+	scale := float32(1)
+	if strings.HasSuffix(s, "%") {
+		s = s[:len(s)-1]
+		scale = .01
+	} else {
+		s = strings.TrimSuffix(s, "px")
+	}
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return def
+	}
+	return float32(v) * scale
+}
+
+// parseSVGURL parses a paint reference of the form url(#id).
+func parseSVGURL(s string) (string, bool) {
+	if !strings.HasPrefix(s, "url(") {
+		return "", false
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "url("), ")")
+	s = strings.Trim(s, `'" `)
+	return strings.TrimPrefix(s, "#"), strings.HasPrefix(s, "#")
+}
+
+// svgColors lists the most common SVG color keywords.
+var svgColors = map[string]color.NRGBA{
+	"black":   {A: 0xff},
+	"white":   {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	"red":     {R: 0xff, A: 0xff},
+	"green":   {G: 0x80, A: 0xff},
+	"lime":    {G: 0xff, A: 0xff},
+	"blue":    {B: 0xff, A: 0xff},
+	"yellow":  {R: 0xff, G: 0xff, A: 0xff},
+	"cyan":    {G: 0xff, B: 0xff, A: 0xff},
+	"magenta": {R: 0xff, B: 0xff, A: 0xff},
+	"gray":    {R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+	"grey":    {R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+	"silver":  {R: 0xc0, G: 0xc0, B: 0xc0, A: 0xff},
+	"maroon":  {R: 0x80, A: 0xff},
+	"navy":    {B: 0x80, A: 0xff},
+	"olive":   {R: 0x80, G: 0x80, A: 0xff},
+	"purple":  {R: 0x80, B: 0x80, A: 0xff},
+	"teal":    {G: 0x80, B: 0x80, A: 0xff},
+	"orange":  {R: 0xff, G: 0xa5, A: 0xff},
+	"brown":   {R: 0xa5, G: 0x2a, B: 0x2a, A: 0xff},
+	"pink":    {R: 0xff, G: 0xc0, B: 0xcb, A: 0xff},
+	"gold":    {R: 0xff, G: 0xd7, A: 0xff},
+	// The text color is not known when rendering color glyphs.
+	"currentcolor": {A: 0xff},
+	"transparent":  {},
+}
+
+// parseSVGColor parses a color in hexadecimal, rgb() or keyword notation.
+func parseSVGColor(s string) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		// The initial value of fill is black.
+		return color.NRGBA{A: 0xff}, true
+	}
+	if c, ok := svgColors[s]; ok {
+		return c, true
+	}
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			var b strings.Builder
+			for _, r := range hex {
+				b.WriteRune(r)
+				b.WriteRune(r)
+			}
+			hex = b.String()
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 8 {
+			return color.NRGBA{}, false
+		}
+		return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+	}
+	for _, fn := range []string{"rgba(", "rgb("} {
+		if !strings.HasPrefix(s, fn) || !strings.HasSuffix(s, ")") {
+			continue
+		}
+		args := strings.FieldsFunc(s[len(fn):len(s)-1], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '/'
+		})
+		if len(args) < 3 {
+			return color.NRGBA{}, false
+		}
+		var ch [4]uint8
+		ch[3] = 0xff
+		for i, a := range args[:min(len(args), 4)] {
+			v := parseSVGNumber(a, 0)
+			switch {
+			case i == 3:
+				v *= 255
+			case strings.HasSuffix(a, "%"):
+				v *= 255
+			}
+			ch[i] = uint8(math.Max(0, math.Min(255, float64(v)+.5)))
+		}
+		return color.NRGBA{R: ch[0], G: ch[1], B: ch[2], A: ch[3]}, true
+	}
+	return color.NRGBA{}, false
+}
+
+// parseSVGTransform parses the value of a transform attribute.
+func parseSVGTransform(s string) f32.Affine2D {
+	var tr f32.Affine2D
+	for {
+		s = strings.TrimLeft(s, " ,\t\n")
+		open := strings.IndexByte(s, '(')
+		end := strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			return tr
+		}
+		name := strings.TrimSpace(s[:open])
+		var args []float32
+		for _, f := range strings.FieldsFunc(s[open+1:end], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n'
+		}) {
+			args = append(args, parseSVGNumber(f, 0))
+		}
+		s = s[end+1:]
+		arg := func(i int, def float32) float32 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+		var t f32.Affine2D
+		switch name {
+		case "matrix":
+			if len(args) == 6 {
+				t = f32.NewAffine2D(args[0], args[2], args[4], args[1], args[3], args[5])
+			}
+		case "translate":
+			t = t.Offset(f32.Point{X: arg(0, 0), Y: arg(1, 0)})
+		case "scale":
+			sx := arg(0, 1)
+			t = t.Scale(f32.Point{}, f32.Point{X: sx, Y: arg(1, sx)})
+		case "rotate":
+			a := float64(arg(0, 0)) * math.Pi / 180
+			sin, cos := math.Sincos(a)
+			t = aroundCenter(f32.NewAffine2D(float32(cos), float32(-sin), 0, float32(sin), float32(cos), 0),
+				f32.Point{X: arg(1, 0), Y: arg(2, 0)})
+		case "skewX":
+			t = f32.NewAffine2D(1, float32(math.Tan(float64(arg(0, 0))*math.Pi/180)), 0, 0, 1, 0)
+		case "skewY":
+			t = f32.NewAffine2D(1, 0, 0, float32(math.Tan(float64(arg(0, 0))*math.Pi/180)), 1, 0)
+		}
+		tr = tr.Mul(t)
+	}
+}
+
+type svgSegOp uint8
+
+const (
+	svgMoveTo svgSegOp = iota
+	svgLineTo
+	svgQuadTo
+	svgCubeTo
+	svgClose
+)
+
+type svgSeg struct {
+	op   svgSegOp
+	args [3]f32.Point
+}
+
+// svgPath is a path in absolute coordinates.
+type svgPath struct {
+	segs []svgSeg
+}
+
+func (p *svgPath) moveTo(a f32.Point) {
+	p.segs = append(p.segs, svgSeg{op: svgMoveTo, args: [3]f32.Point{a}})
+}
+func (p *svgPath) lineTo(a f32.Point) {
+	p.segs = append(p.segs, svgSeg{op: svgLineTo, args: [3]f32.Point{a}})
+}
+func (p *svgPath) close() { p.segs = append(p.segs, svgSeg{op: svgClose}) }
+
+func (p *svgPath) quadTo(c, a f32.Point) {
+	p.segs = append(p.segs, svgSeg{op: svgQuadTo, args: [3]f32.Point{c, a}})
+}
+
+func (p *svgPath) cubeTo(c0, c1, a f32.Point) {
+	p.segs = append(p.segs, svgSeg{op: svgCubeTo, args: [3]f32.Point{c0, c1, a}})
+}
+
+// record records p as a clip path and returns it along with its bounds.
+func (p *svgPath) record(ops *op.Ops) (clip.PathSpec, f32internal.Rectangle) {
+	var b clip.Path
+	b.Begin(ops)
+	var bounds f32internal.Rectangle
+	first := true
+	pt := func(a f32.Point) f32.Point {
+		bounds = includePoint(bounds, a, first)
+		first = false
+		return a
+	}
+	for _, s := range p.segs {
+		switch s.op {
+		case svgMoveTo:
+			b.MoveTo(pt(s.args[0]))
+		case svgLineTo:
+			b.LineTo(pt(s.args[0]))
+		case svgQuadTo:
+			b.QuadTo(pt(s.args[0]), pt(s.args[1]))
+		case svgCubeTo:
+			b.CubeTo(pt(s.args[0]), pt(s.args[1]), pt(s.args[2]))
+		case svgClose:
+			b.Close()
+		}
+	}
+	return b.End(), bounds
+}
+
+// shape converts a shape element to a path. It reports false if n is not a
+// supported shape.
+func (p *svgPath) shape(n *svgNode) bool {
+	switch n.name {
+	case "path":
+		return p.parse(n.attrs["d"]) == nil
+	case "rect":
+		x, y := svgAttr(n, "x", 0), svgAttr(n, "y", 0)
+		w, h := svgAttr(n, "width", 0), svgAttr(n, "height", 0)
+		if w <= 0 || h <= 0 {
+			return false
+		}
+		p.moveTo(f32.Point{X: x, Y: y})
+		p.lineTo(f32.Point{X: x + w, Y: y})
+		p.lineTo(f32.Point{X: x + w, Y: y + h})
+		p.lineTo(f32.Point{X: x, Y: y + h})
+		p.close()
+	case "circle", "ellipse":
+		c := f32.Point{X: svgAttr(n, "cx", 0), Y: svgAttr(n, "cy", 0)}
+		rx, ry := svgAttr(n, "rx", 0), svgAttr(n, "ry", 0)
+		if n.name == "circle" {
+			rx = svgAttr(n, "r", 0)
+			ry = rx
+		}
+		if rx <= 0 || ry <= 0 {
+			return false
+		}
+		p.ellipse(c, rx, ry)
+	case "polygon", "polyline":
+		var nums []float32
+		for _, f := range strings.FieldsFunc(n.attrs["points"], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n'
+		}) {
+			nums = append(nums, parseSVGNumber(f, 0))
+		}
+		if len(nums) < 4 {
+			return false
+		}
+		for i := 0; i+1 < len(nums); i += 2 {
+			pt := f32.Point{X: nums[i], Y: nums[i+1]}
+			if i == 0 {
+				p.moveTo(pt)
+			} else {
+				p.lineTo(pt)
+			}
+		}
+		p.close()
+	default:
+		return false
+	}
+	return true
+}
+
+// ellipse adds an ellipse approximated by cubic Béziers.
+func (p *svgPath) ellipse(c f32.Point, rx, ry float32) {
+	const k = 0.5522847498
+	kx, ky := rx*k, ry*k
+	p.moveTo(f32.Point{X: c.X + rx, Y: c.Y})
+	p.cubeTo(f32.Point{X: c.X + rx, Y: c.Y + ky}, f32.Point{X: c.X + kx, Y: c.Y + ry}, f32.Point{X: c.X, Y: c.Y + ry})
+	p.cubeTo(f32.Point{X: c.X - kx, Y: c.Y + ry}, f32.Point{X: c.X - rx, Y: c.Y + ky}, f32.Point{X: c.X - rx, Y: c.Y})
+	p.cubeTo(f32.Point{X: c.X - rx, Y: c.Y - ky}, f32.Point{X: c.X - kx, Y: c.Y - ry}, f32.Point{X: c.X, Y: c.Y - ry})
+	p.cubeTo(f32.Point{X: c.X + kx, Y: c.Y - ry}, f32.Point{X: c.X + rx, Y: c.Y - ky}, f32.Point{X: c.X + rx, Y: c.Y})
+	p.close()
+}
+
+// parse parses SVG path data.
+func (p *svgPath) parse(d string) error {
+	sc := svgScanner{s: d}
+	var cmd byte
+	var pen, start, lastCtrl f32.Point
+	var prev byte
+	for {
+		sc.skipSpace()
+		if sc.done() {
+			return nil
+		}
+		if c := sc.s[sc.i]; isSVGCommand(c) {
+			cmd = c
+			sc.i++
+		} else if cmd == 0 {
+			return errors.New("svg: path data must start with a command")
+		}
+		rel := cmd >= 'a'
+		abs := func(pt f32.Point) f32.Point {
+			if rel {
+				return pen.Add(pt)
+			}
+			return pt
+		}
+		switch cmd {
+		case 'M', 'm':
+			pt, err := sc.point()
+			if err != nil {
+				return err
+			}
+			pen = abs(pt)
+			start = pen
+			p.moveTo(pen)
+			// Subsequent coordinate pairs are implicit line commands.
+			if rel {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+		case 'L', 'l':
+			pt, err := sc.point()
+			if err != nil {
+				return err
+			}
+			pen = abs(pt)
+			p.lineTo(pen)
+		case 'H', 'h':
+			x, err := sc.number()
+			if err != nil {
+				return err
+			}
+			if rel {
+				x += pen.X
+			}
+			pen.X = x
+			p.lineTo(pen)
+		case 'V', 'v':
+			y, err := sc.number()
+			if err != nil {
+				return err
+			}
+			if rel {
+				y += pen.Y
+			}
+			pen.Y = y
+			p.lineTo(pen)
+		case 'C', 'c', 'S', 's':
+			var c0 f32.Point
+			if cmd == 'C' || cmd == 'c' {
+				pt, err := sc.point()
+				if err != nil {
+					return err
+				}
+				c0 = abs(pt)
+			} else {
+				// Reflect the previous control point.
+				c0 = pen
+				if prev == 'C' || prev == 'S' {
+					c0 = pen.Mul(2).Sub(lastCtrl)
+				}
+			}
+			c1, err := sc.point()
+			if err != nil {
+				return err
+			}
+			to, err := sc.point()
+			if err != nil {
+				return err
+			}
+			c1, to = abs(c1), abs(to)
+			p.cubeTo(c0, c1, to)
+			lastCtrl, pen = c1, to
+		case 'Q', 'q', 'T', 't':
+			var c f32.Point
+			if cmd == 'Q' || cmd == 'q' {
+				pt, err := sc.point()
+				if err != nil {
+					return err
+				}
+				c = abs(pt)
+			} else {
+				c = pen
+				if prev == 'Q' || prev == 'T' {
+					c = pen.Mul(2).Sub(lastCtrl)
+				}
+			}
+			to, err := sc.point()
+			if err != nil {
+				return err
+			}
+			to = abs(to)
+			p.quadTo(c, to)
+			lastCtrl, pen = c, to
+		case 'A', 'a':
+			var v [5]float32
+			for i := range v {
+				var err error
+				if i == 3 || i == 4 {
+					v[i], err = sc.flag()
+				} else {
+					v[i], err = sc.number()
+				}
+				if err != nil {
+					return err
+				}
+			}
+			to, err := sc.point()
+			if err != nil {
+				return err
+			}
+			to = abs(to)
+			p.arcTo(pen, to, v[0], v[1], v[2], v[3] != 0, v[4] != 0)
+			pen = to
+		case 'Z', 'z':
+			p.close()
+			pen = start
+		default:
+			return errors.New("svg: invalid path command")
+		}
+		// Track the previous command in upper case, for control point
+		// reflection.
+		prev = cmd &^ 0x20
+	}
+}
+
+// arcTo adds an elliptical arc from 'from' to 'to', as described by the SVG
+// arc command, approximated by cubic Béziers.
+func (p *svgPath) arcTo(from, to f32.Point, rx, ry, rotation float32, large, sweep bool) {
+	if from == to {
+		return
+	}
+	rx, ry = float32(math.Abs(float64(rx))), float32(math.Abs(float64(ry)))
+	if rx == 0 || ry == 0 {
+		p.lineTo(to)
+		return
+	}
+	// Convert from endpoint to center parameterization, following the
+	// implementation notes of the SVG specification.
+	phi := float64(rotation) * math.Pi / 180
+	sinPhi, cosPhi := math.Sincos(phi)
+	dx, dy := float64(from.X-to.X)/2, float64(from.Y-to.Y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+	frx, fry := float64(rx), float64(ry)
+	if l := x1*x1/(frx*frx) + y1*y1/(fry*fry); l > 1 {
+		s := math.Sqrt(l)
+		frx *= s
+		fry *= s
+	}
+	num := frx*frx*fry*fry - frx*frx*y1*y1 - fry*fry*x1*x1
+	den := frx*frx*y1*y1 + fry*fry*x1*x1
+	co := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		co = -co
+	}
+	cx1 := co * frx * y1 / fry
+	cy1 := -co * fry * x1 / frx
+	cx := cosPhi*cx1 - sinPhi*cy1 + float64(from.X+to.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + float64(from.Y+to.Y)/2
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1-cx1)/frx, (y1-cy1)/fry)
+	delta := angle((x1-cx1)/frx, (y1-cy1)/fry, (-x1-cx1)/frx, (-y1-cy1)/fry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+	// Split the arc into segments of at most 90°.
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(t float64) (f32.Point, f32.Point) {
+		sin, cos := math.Sincos(t)
+		x, y := frx*cos, fry*sin
+		tx, ty := -frx*sin, fry*cos
+		return f32.Point{
+			X: float32(cosPhi*x - sinPhi*y + cx),
+			Y: float32(sinPhi*x + cosPhi*y + cy),
+		}, f32.Point{
+			X: float32(cosPhi*tx - sinPhi*ty),
+			Y: float32(sinPhi*tx + cosPhi*ty),
+		}
+	}
+	t := theta
+	p0, d0 := point(t)
+	for i := 0; i < n; i++ {
+		t += step
+		p1, d1 := point(t)
+		if i == n-1 {
+			p1 = to
+		}
+		p.cubeTo(p0.Add(d0.Mul(float32(k))), p1.Sub(d1.Mul(float32(k))), p1)
+		p0, d0 = p1, d1
+	}
+}
+
+func isSVGCommand(c byte) bool {
+	return strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0
+}
+
+// svgScanner tokenizes numbers in SVG path data.
+type svgScanner struct {
+	s string
+	i int
+}
+
+func (sc *svgScanner) done() bool { return sc.i >= len(sc.s) }
+
+func (sc *svgScanner) skipSpace() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+}
+
+// number scans a number. Numbers may be separated by whitespace, commas,
+// or nothing at all if the next number starts with a sign or decimal point.
+func (sc *svgScanner) number() (float32, error) {
+	sc.skipSpace()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '-' || sc.s[sc.i] == '+') {
+		sc.i++
+	}
+	dot, exp := false, false
+loop:
+	for ; sc.i < len(sc.s); sc.i++ {
+		switch c := sc.s[sc.i]; {
+		case c >= '0' && c <= '9':
+		case c == '.' && !dot && !exp:
+			dot = true
+		case (c == 'e' || c == 'E') && !exp && sc.i > start:
+			exp = true
+			if sc.i+1 < len(sc.s) && (sc.s[sc.i+1] == '-' || sc.s[sc.i+1] == '+') {
+				sc.i++
+			}
+		default:
+			break loop
+		}
+	}
+	v, err := strconv.ParseFloat(sc.s[start:sc.i], 32)
+	if err != nil {
+		return 0, errors.New("svg: invalid number in path data")
+	}
+	return float32(v), nil
+}
+
+// flag scans an arc flag, which may be followed by a number without
+// separator.
+func (sc *svgScanner) flag() (float32, error) {
+	sc.skipSpace()
+	if sc.done() || (sc.s[sc.i] != '0' && sc.s[sc.i] != '1') {
+		return 0, errors.New("svg: invalid arc flag")
+	}
+	sc.i++
+	return float32(sc.s[sc.i-1] - '0'), nil
+}
+
+func (sc *svgScanner) point() (f32.Point, error) {
+	x, err := sc.number()
+	if err != nil {
+		return f32.Point{}, err
+	}
+	y, err := sc.number()
+	return f32.Point{X: x, Y: y}, err
+}
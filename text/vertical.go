@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package text
+
+import (
+	"github.com/go-text/typesetting/shaping"
+	"github.com/go-text/typesetting/unicodedata"
+	"golang.org/x/image/math/fixed"
+
+	"gioui.org/f32"
+	"gioui.org/io/system"
+)
+
+// Orientation controls the orientation of glyphs in vertical text, that is
+// text laid out with a [system.Locale] Direction on the [system.Vertical]
+// axis. It is ignored for horizontal text.
+type Orientation uint8
+
+const (
+	// OrientMixed displays the glyphs of vertical scripts such as Han and
+	// Hiragana upright, and rotates the glyphs of horizontal scripts such as
+	// Latin 90° clockwise, according to the Unicode vertical orientation
+	// property (UAX #50) of their runes.
+	OrientMixed Orientation = iota
+	// OrientUpright displays all glyphs upright.
+	OrientUpright
+	// OrientSideways rotates all glyphs 90° clockwise, as if horizontal text
+	// was laid out and rotated.
+	OrientSideways
+)
+
+func (o Orientation) String() string {
+	switch o {
+	case OrientMixed:
+		return "Mixed"
+	case OrientUpright:
+		return "Upright"
+	case OrientSideways:
+		return "Sideways"
+	default:
+		return "Unknown"
+	}
+}
+
+// splitByOrientation divides vertical inputs into new, smaller inputs of
+// glyphs set either upright or sideways, according to o. Inputs must have
+// their script resolved. It will use buf as the backing memory for the
+// returned slice if buf is non-nil.
+func splitByOrientation(inputs []shaping.Input, o Orientation, buf []shaping.Input) []shaping.Input {
+	split := buf[:0]
+	for _, input := range inputs {
+		switch o {
+		case OrientUpright, OrientSideways:
+			input.Direction.SetSideways(o == OrientSideways)
+			split = append(split, input)
+			continue
+		}
+		vo := unicodedata.LookupVerticalOrientation(input.Script)
+		current := input
+		for i := input.RunStart; i < input.RunEnd; i++ {
+			sideways := vo.Orientation(input.Text[i])
+			if i == input.RunStart {
+				current.Direction.SetSideways(sideways)
+				continue
+			}
+			if sideways != current.Direction.IsSideways() {
+				current.RunEnd = i
+				split = append(split, current)
+				current.RunStart = i
+				current.Direction.SetSideways(sideways)
+			}
+		}
+		current.RunEnd = input.RunEnd
+		split = append(split, current)
+	}
+	return split
+}
+
+// flipVerticalAdvances negates the advances of vertically shaped text, which
+// move down the negative Y axis of font space, so that they measure the
+// distance covered along the line like the advances of horizontal text.
+func flipVerticalAdvances(out *shaping.Output) {
+	if !out.Direction.IsVertical() {
+		return
+	}
+	for i := range out.Glyphs {
+		out.Glyphs[i].YAdvance = -out.Glyphs[i].YAdvance
+	}
+	out.Advance = -out.Advance
+}
+
+// lineBaselineX returns the X coordinate of the baseline of the vertical line
+// at index i of lines. The first line is placed against the left or right edge
+// of the document, according to the line progression of its direction.
+func lineBaselineX(lines []line, i int) fixed.Int26_6 {
+	if lines[0].direction.LineProgression() == system.FromOrigin {
+		return fixed.I(lines[i].yOffset)
+	}
+	last := lines[len(lines)-1]
+	width := last.yOffset + last.descent.Ceil()
+	return fixed.I(width - lines[i].yOffset)
+}
+
+// glyphTransform returns the linear transformation from the font units of the
+// face of g to pixels, where scale is the number of pixels per font unit.
+func glyphTransform(g Glyph, scale float32) f32.Affine2D {
+	if g.Flags&FlagSideways != 0 {
+		// Rotate 90° clockwise, so that the top of the glyph faces right.
+		return f32.NewAffine2D(0, scale, 0, scale, 0, 0)
+	}
+	return f32.NewAffine2D(scale, 0, 0, 0, -scale, 0)
+}
+
+// glyphOrigin returns the position of the drawing origin of g relative to the
+// dot (x, y) of the first glyph of its line.
+func glyphOrigin(g Glyph, x fixed.Int26_6, y int32) f32.Point {
+	if g.Flags&FlagVertical == 0 {
+		return f32.Point{
+			X: fixedToFloat((g.X - x) - g.Offset.X),
+			Y: -fixedToFloat(g.Offset.Y),
+		}
+	}
+	pos := f32.Point{
+		X: fixedToFloat(g.X - x + g.Offset.X),
+		Y: float32(g.Y - y),
+	}
+	if g.Flags&FlagSideways == 0 {
+		// The rotation of sideways glyphs already places them below the
+		// dot, which is what their vertical offset describes.
+		pos.Y -= fixedToFloat(g.Offset.Y)
+	}
+	return pos
+}
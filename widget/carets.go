@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"sort"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// Caret is a caret and the selection it extends, in runes. Start is the
+// position of the caret and End the other end of the selection, so Start can
+// be > End.
+type Caret struct {
+	Start, End int
+}
+
+// textCaret is a caret of a textView.
+type textCaret struct {
+	// xoff is the offset to the current position when moving between lines.
+	xoff fixed.Int26_6
+	// start is the current caret position in runes, and also the start position of
+	// selected text. end is the end position of selected text. If start
+	// == end, then there's no selection. Note that it's possible (and
+	// common) that the caret (start) is after the end, e.g. after
+	// Shift-DownArrow.
+	start int
+	end   int
+}
+
+// bounds returns the start and end of the selection of c in order.
+func (c textCaret) bounds() (lo, hi int) {
+	return min(c.start, c.end), max(c.start, c.end)
+}
+
+// overlaps reports whether the selections of c and o overlap. A caret without
+// selection overlaps a caret at the same position and the selections
+// surrounding it.
+func (c textCaret) overlaps(o textCaret) bool {
+	lo, hi := c.bounds()
+	olo, ohi := o.bounds()
+	switch {
+	case lo == olo && hi == ohi:
+		return true
+	case lo == hi:
+		return olo < lo && lo < ohi
+	case olo == ohi:
+		return lo < olo && olo < hi
+	}
+	return max(lo, olo) < min(hi, ohi)
+}
+
+// union extends the selection of c to cover the selection of o, keeping its
+// direction.
+func (c *textCaret) union(o textCaret) {
+	lo, hi := c.bounds()
+	olo, ohi := o.bounds()
+	lo, hi = min(lo, olo), max(hi, ohi)
+	if c.start <= c.end {
+		c.start, c.end = lo, hi
+	} else {
+		c.start, c.end = hi, lo
+	}
+}
+
+// caretAt returns the additional caret at index i, or the primary caret for
+// i == len(e.carets).
+func (e *textView) caretAt(i int) textCaret {
+	if i < len(e.carets) {
+		return e.carets[i]
+	}
+	return e.caret
+}
+
+// ForEachCaret calls f with every caret in document order made the primary
+// caret, and the index of the caret in that order. Carets that overlap
+// afterwards are merged.
+func (e *textView) ForEachCaret(f func(i int)) {
+	if len(e.carets) == 0 {
+		f(0)
+		return
+	}
+	order := make([]int, len(e.carets)+1)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		ci, _ := e.caretAt(order[i]).bounds()
+		cj, _ := e.caretAt(order[j]).bounds()
+		return ci < cj
+	})
+	for n, i := range order {
+		if i == len(e.carets) {
+			f(n)
+			continue
+		}
+		e.caret, e.carets[i] = e.carets[i], e.caret
+		f(n)
+		e.caret, e.carets[i] = e.carets[i], e.caret
+	}
+	e.mergeCarets()
+}
+
+// mergeCarets merges overlapping carets, favoring the primary caret.
+func (e *textView) mergeCarets() {
+	kept := e.carets[:0]
+carets:
+	for _, c := range e.carets {
+		if e.caret.overlaps(c) {
+			e.caret.union(c)
+			continue
+		}
+		for i := range kept {
+			if kept[i].overlaps(c) {
+				kept[i].union(c)
+				continue carets
+			}
+		}
+		kept = append(kept, c)
+	}
+	e.carets = kept
+}
+
+// AddCaret adds a caret at start with a selection to end, and makes it the
+// primary caret.
+func (e *textView) AddCaret(start, end int) {
+	e.carets = append(e.carets, e.caret)
+	e.SetCaret(start, end)
+	e.caret.xoff = 0
+	e.mergeCarets()
+}
+
+// ClearCarets removes the additional carets.
+func (e *textView) ClearCarets() {
+	e.carets = e.carets[:0]
+}
+
+// Carets appends the carets in document order to carets and returns the
+// result.
+func (e *textView) Carets(carets []Caret) []Caret {
+	start := len(carets)
+	for i := 0; i <= len(e.carets); i++ {
+		c := e.caretAt(i)
+		carets = append(carets, Caret{Start: c.start, End: c.end})
+	}
+	sort.Slice(carets[start:], func(i, j int) bool {
+		ci, cj := carets[start+i], carets[start+j]
+		return min(ci.Start, ci.End) < min(cj.Start, cj.End)
+	})
+	return carets
+}
+
+// SelectColumn replaces the primary caret with a rectangular selection
+// between the points from and to, made of a caret with a selection on every
+// line between them. The caret on the line of to becomes the primary caret.
+func (e *textView) SelectColumn(from, to image.Point) {
+	fromX, toX := fixed.I(from.X+e.scrollOff.X), fixed.I(to.X+e.scrollOff.X)
+	first := e.closestToXY(fromX, from.Y+e.scrollOff.Y).lineCol.line
+	last := e.closestToXY(toX, to.Y+e.scrollOff.Y).lineCol.line
+	step := 1
+	if last < first {
+		step = -1
+	}
+	for l := first; ; l += step {
+		y := e.closestToLineCol(l, 0).y
+		c := textCaret{
+			start: e.closestToXYGraphemes(toX, y).runes,
+			end:   e.closestToXYGraphemes(fromX, y).runes,
+		}
+		if l == last {
+			e.caret = c
+			break
+		}
+		e.carets = append(e.carets, c)
+	}
+	e.mergeCarets()
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"math"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/text"
+)
+
+// KeyEvent is generated by an Editor for a key press matching one of its
+// Intercept bindings, instead of performing the bound action.
+type KeyEvent struct {
+	key.Event
+}
+
+// CaretRect returns the bounds of the primary caret relative to the editor,
+// as a zero-width rectangle spanning the height of its line. Use it to place
+// a completion popup next to the caret.
+func (e *Editor) CaretRect() image.Rectangle {
+	e.initBuffer()
+	pos, ascent, descent := e.text.CaretInfo()
+	return image.Rectangle{
+		Min: pos.Sub(image.Pt(0, ascent)),
+		Max: pos.Add(image.Pt(0, descent)),
+	}
+}
+
+// WordRange returns the rune offsets of the word around the primary caret,
+// made of letters, digits and underscores. The text between start and the
+// caret is the prefix of the word being typed; start == end if the caret is
+// not next to a word.
+func (e *Editor) WordRange() (start, end int) {
+	e.initBuffer()
+	caret, _ := e.text.Selection()
+	start, end = caret, caret
+	for off := e.text.ByteOffset(start); start > 0; start-- {
+		r, n, _ := e.text.ReadRuneBefore(off)
+		if !isWordRune(r) {
+			break
+		}
+		off -= int64(n)
+	}
+	for off := e.text.ByteOffset(end); end < e.text.Len(); end++ {
+		r, n, _ := e.text.ReadRuneAt(off)
+		if !isWordRune(r) {
+			break
+		}
+		off += int64(n)
+	}
+	return start, end
+}
+
+// SetSuggestion sets the ghost text displayed after the primary caret, such
+// as an inline completion. The suggestion is not part of the text: it is
+// shown while the caret stays where it was when the suggestion was set and
+// is at the end of its line, until the text is modified, and is inserted by
+// AcceptSuggestion or the key bound to ActionAcceptSuggestion, Tab by
+// default. An empty s removes the suggestion.
+func (e *Editor) SetSuggestion(s string) {
+	e.initBuffer()
+	e.suggestion = s
+	e.suggestionAt, _ = e.text.Selection()
+}
+
+// Suggestion returns the suggestion set by SetSuggestion, or the empty
+// string if it is not shown.
+func (e *Editor) Suggestion() string {
+	e.initBuffer()
+	start, end := e.text.Selection()
+	if e.suggestion == "" || len(e.text.carets) > 0 || start != end || start != e.suggestionAt {
+		return ""
+	}
+	// Show the suggestion only where it covers no text.
+	if r, n, _ := e.text.ReadRuneAt(e.text.ByteOffset(start)); n > 0 && r != '\n' {
+		return ""
+	}
+	return e.suggestion
+}
+
+// AcceptSuggestion inserts the shown suggestion at the caret, and reports
+// whether there was one.
+func (e *Editor) AcceptSuggestion() bool {
+	s := e.Suggestion()
+	if s == "" || e.ReadOnly {
+		return false
+	}
+	return e.Insert(s) != 0
+}
+
+// PaintSuggestion paints the shown suggestion after the caret with material,
+// if any. It must be called after Layout, in the coordinates of the editor.
+// The suggestion is not wrapped, and the lines of a suggestion with line
+// breaks are aligned with the caret.
+func (e *Editor) PaintSuggestion(gtx layout.Context, material op.CallOp) {
+	s := e.Suggestion()
+	if s == "" || e.text.shaper == nil {
+		return
+	}
+	e.text.PaintSuggestion(gtx, s, material)
+}
+
+// PaintSuggestion paints s at the primary caret with material.
+func (e *textView) PaintSuggestion(gtx layout.Context, s string, material op.CallOp) {
+	params := e.params
+	params.Alignment = text.Start
+	params.MaxLines = 0
+	params.MinWidth = 0
+	// Don't wrap the suggestion.
+	params.MaxWidth = 1e6
+	e.shaper.LayoutString(params, s)
+	m := op.Record(gtx.Ops)
+	it := textIterator{
+		viewport: image.Rectangle{Max: image.Pt(math.MaxInt32, math.MaxInt32)},
+		material: material,
+	}
+	var glyphs [32]text.Glyph
+	line := glyphs[:0]
+	for g, ok := e.shaper.NextGlyph(); ok; g, ok = e.shaper.NextGlyph() {
+		var ok bool
+		if line, ok = it.paintGlyph(gtx, e.shaper, g, line); !ok {
+			break
+		}
+	}
+	call := m.Stop()
+	defer clip.Rect(image.Rectangle{Max: e.viewSize}).Push(gtx.Ops).Pop()
+	pos, _, _ := e.CaretInfo()
+	defer op.Offset(pos.Sub(image.Pt(0, it.baseline))).Push(gtx.Ops).Pop()
+	call.Add(gtx.Ops)
+}
+
+// interceptFilters returns filters extended with the filters for the
+// Intercept bindings.
+func (e *Editor) interceptFilters(filters []event.Filter) []event.Filter {
+	if len(e.Intercept) == 0 {
+		return filters
+	}
+	e.intercepts = append(e.intercepts[:0], filters...)
+	for _, b := range e.Intercept {
+		e.intercepts = append(e.intercepts, key.Filter{Focus: e, Name: b.Name, Required: b.Modifiers})
+	}
+	return e.intercepts
+}
+
+// intercepted reports whether k matches one of the Intercept bindings.
+func (e *Editor) intercepted(k key.Event) bool {
+	for _, b := range e.Intercept {
+		if b.Name == k.Name && b.Modifiers == k.Modifiers {
+			return true
+		}
+	}
+	return false
+}
+
+func (KeyEvent) isEditorEvent() {}
@@ -64,10 +64,39 @@ type Editor struct {
 	// Filter is the list of characters allowed in the Editor. If Filter is empty,
 	// all characters are allowed.
 	Filter string
+	// Formatter, if set, transforms or rejects the modifications of the text
+	// at the carets, typed by the user or made by Insert and Delete, after
+	// Filter and MaxLen apply. Use it to validate and format input such as
+	// dates and numbers as it is typed. SetText, ReplaceMatch, ReplaceAll,
+	// undo and redo are not formatted.
+	Formatter Formatter
 	// WrapPolicy configures how displayed text will be broken into lines.
 	WrapPolicy text.WrapPolicy
-
-	buffer *editBuffer
+	// Decoration selects the lines drawn along the text, such as a
+	// WavyUnderline marking misspelled text.
+	Decoration text.Decoration
+	// Highlighter, if set, styles the text paragraph by paragraph, such as
+	// to highlight syntax. Only the paragraphs changed by edits are
+	// highlighted anew; call Restyle if the highlighting rules change.
+	Highlighter Highlighter
+	// Keymap maps keys to the actions of the editor. If nil, the keymap
+	// returned by DefaultKeymap is used.
+	Keymap *Keymap
+	// Intercept lists the keys reported in KeyEvents instead of being
+	// handled by the editor, such as the keys navigating a completion popup
+	// while it is open.
+	Intercept []KeyBinding
+	// MaxHistory limits the number of modifications, counting the
+	// modifications of a transaction as one, that can be undone. Zero means
+	// no limit.
+	MaxHistory int
+	// Storage holds the text of the editor. If nil, the text is kept in a
+	// gap buffer suited to short and medium texts. A PieceTable avoids
+	// copying large texts such as opened files. Changing Storage replaces
+	// the text of the editor and clears its undo history.
+	Storage TextStorage
+
+	buffer TextStorage
 	// scratch is a byte buffer that is reused to efficiently read portions of text
 	// from the textView.
 	scratch    []byte
@@ -79,8 +108,22 @@ type Editor struct {
 		scratch []byte
 	}
 
-	dragging    bool
-	dragger     gesture.Drag
+	dragging bool
+	// columnDrag tracks whether dragging selects a column from columnAnchor,
+	// in document coordinates, replacing the carets after the first
+	// columnBase additional carets.
+	columnDrag   bool
+	columnAnchor image.Point
+	columnBase   int
+	dragger      gesture.Drag
+	keyFilters   keyFilters
+	// intercepts holds the key filters extended with the Intercept keys.
+	intercepts []event.Filter
+	// suggestion is the ghost text set at the rune offset suggestionAt.
+	suggestion   string
+	suggestionAt int
+	// gutterLines holds the visible lines of the gutter.
+	gutterLines []EditorLine
 	scroller    gesture.Scroll
 	scrollCaret bool
 	showCaret   bool
@@ -88,11 +131,19 @@ type Editor struct {
 	clicker gesture.Click
 
 	// history contains undo history.
-	history []modification
+	history []Modification
 	// nextHistoryIdx is the index within the history of the next modification. This
 	// is only not len(history) immediately after undo operations occur. It is framed as the "next" value
 	// to make the zero value consistent.
 	nextHistoryIdx int
+	// historyGroup is the group of the latest modification.
+	historyGroup int
+	// transactions counts the nested calls to Transaction.
+	transactions int
+	// typing tracks whether the text being replaced is typed, and coalesce
+	// whether the next typed text may join the group of the latest
+	// modification.
+	typing, coalesce bool
 
 	pending []EditorEvent
 }
@@ -113,7 +164,7 @@ type imeState struct {
 
 type maskReader struct {
 	// rr is the underlying reader.
-	rr      io.RuneReader
+	rr      *bufio.Reader
 	maskBuf [utf8.UTFMax]byte
 	// mask is the utf-8 encoded mask rune.
 	mask []byte
@@ -129,7 +180,12 @@ const (
 )
 
 func (m *maskReader) Reset(r io.Reader, mr rune) {
-	m.rr = bufio.NewReader(r)
+	if m.rr == nil {
+		m.rr = bufio.NewReader(r)
+	} else {
+		m.rr.Reset(r)
+	}
+	m.overflow = nil
 	n := utf8.EncodeRune(m.maskBuf[:], mr)
 	m.mask = m.maskBuf[:n]
 }
@@ -284,10 +340,20 @@ func (e *Editor) processPointerEvent(gtx layout.Context, ev event.Event) (Editor
 			evt.Kind == gesture.KindClick && evt.Source != pointer.Mouse:
 			prevCaretPos, _ := e.text.Selection()
 			e.blinkStart = gtx.Now
-			e.text.MoveCoord(image.Point{
+			pos := image.Point{
 				X: int(math.Round(float64(evt.Position.X))),
 				Y: int(math.Round(float64(evt.Position.Y))),
-			})
+			}
+			e.columnDrag = evt.Modifiers == key.ModAlt && evt.NumClicks == 1
+			if e.columnDrag {
+				// Add a caret, or a column selection when dragging.
+				e.text.carets = append(e.text.carets, e.text.caret)
+				e.columnAnchor = pos.Add(e.text.ScrollOff())
+				e.columnBase = len(e.text.carets)
+			} else {
+				e.text.ClearCarets()
+			}
+			e.text.MoveCoord(pos)
 			gtx.Execute(key.FocusCmd{Tag: e})
 			if !e.ReadOnly {
 				gtx.Execute(key.SoftKeyboardCmd{Show: true})
@@ -306,6 +372,7 @@ func (e *Editor) processPointerEvent(gtx layout.Context, ev event.Event) (Editor
 			} else {
 				e.text.ClearSelection()
 			}
+			e.text.mergeCarets()
 			e.dragging = true
 
 			// Process multi-clicks.
@@ -329,10 +396,16 @@ func (e *Editor) processPointerEvent(gtx layout.Context, ev event.Event) (Editor
 		case evt.Kind == pointer.Drag && evt.Source == pointer.Mouse:
 			if e.dragging {
 				e.blinkStart = gtx.Now
-				e.text.MoveCoord(image.Point{
+				pos := image.Point{
 					X: int(math.Round(float64(evt.Position.X))),
 					Y: int(math.Round(float64(evt.Position.Y))),
-				})
+				}
+				if e.columnDrag {
+					e.text.carets = e.text.carets[:min(e.columnBase, len(e.text.carets))]
+					e.text.SelectColumn(e.columnAnchor.Sub(e.text.ScrollOff()), pos)
+				} else {
+					e.text.MoveCoord(pos)
+				}
 				e.scrollCaret = true
 
 				if release {
@@ -344,48 +417,48 @@ func (e *Editor) processPointerEvent(gtx layout.Context, ev event.Event) (Editor
 	return nil, false
 }
 
-func condFilter(pred bool, f key.Filter) event.Filter {
-	if pred {
-		return f
-	} else {
-		return nil
-	}
-}
-
 func (e *Editor) processKey(gtx layout.Context) (EditorEvent, bool) {
 	if e.text.Changed() {
 		return ChangeEvent{}, true
 	}
 	caret, _ := e.text.Selection()
-	atBeginning := caret == 0
-	atEnd := caret == e.text.Len()
+	multi := len(e.text.carets) > 0
+	atBeginning := caret == 0 && !multi
+	atEnd := caret == e.text.Len() && !multi
 	if gtx.Locale.Direction.Progression() != system.FromOrigin {
 		atEnd, atBeginning = atBeginning, atEnd
 	}
-	filters := []event.Filter{
-		key.FocusFilter{Target: e},
-		transfer.TargetFilter{Target: e, Type: "application/text"},
-		key.Filter{Focus: e, Name: key.NameEnter, Optional: key.ModShift},
-		key.Filter{Focus: e, Name: key.NameReturn, Optional: key.ModShift},
-
-		key.Filter{Focus: e, Name: "Z", Required: key.ModShortcut, Optional: key.ModShift},
-		key.Filter{Focus: e, Name: "C", Required: key.ModShortcut},
-		key.Filter{Focus: e, Name: "V", Required: key.ModShortcut},
-		key.Filter{Focus: e, Name: "X", Required: key.ModShortcut},
-		key.Filter{Focus: e, Name: "A", Required: key.ModShortcut},
-
-		key.Filter{Focus: e, Name: key.NameDeleteBackward, Optional: key.ModShortcutAlt | key.ModShift},
-		key.Filter{Focus: e, Name: key.NameDeleteForward, Optional: key.ModShortcutAlt | key.ModShift},
-
-		key.Filter{Focus: e, Name: key.NameHome, Optional: key.ModShortcut | key.ModShift},
-		key.Filter{Focus: e, Name: key.NameEnd, Optional: key.ModShortcut | key.ModShift},
-		key.Filter{Focus: e, Name: key.NamePageDown, Optional: key.ModShift},
-		key.Filter{Focus: e, Name: key.NamePageUp, Optional: key.ModShift},
-		condFilter(!atBeginning, key.Filter{Focus: e, Name: key.NameLeftArrow, Optional: key.ModShortcutAlt | key.ModShift}),
-		condFilter(!atBeginning, key.Filter{Focus: e, Name: key.NameUpArrow, Optional: key.ModShortcutAlt | key.ModShift}),
-		condFilter(!atEnd, key.Filter{Focus: e, Name: key.NameRightArrow, Optional: key.ModShortcutAlt | key.ModShift}),
-		condFilter(!atEnd, key.Filter{Focus: e, Name: key.NameDownArrow, Optional: key.ModShortcutAlt | key.ModShift}),
+	suggesting := e.Suggestion() != "" && !e.ReadOnly
+	conds := 0
+	for i, c := range []bool{atBeginning, atEnd, multi, suggesting} {
+		if c {
+			conds |= 1 << i
+		}
 	}
+	filters := e.keyFilters.update(e.keymap(), conds, func(filters []event.Filter) []event.Filter {
+		filters = append(filters,
+			key.FocusFilter{Target: e},
+			transfer.TargetFilter{Target: e, Type: "application/text"},
+			key.Filter{Focus: e, Name: key.NameEnter, Optional: key.ModShift},
+			key.Filter{Focus: e, Name: key.NameReturn, Optional: key.ModShift},
+		)
+		return e.keymap().filters(e, filters, func(b KeyBinding, a Action) bool {
+			switch b.Name {
+			case key.NameLeftArrow, key.NameUpArrow:
+				return !atBeginning
+			case key.NameRightArrow, key.NameDownArrow:
+				return !atEnd
+			}
+			switch a {
+			case ActionClearCarets:
+				return multi
+			case ActionAcceptSuggestion:
+				return suggesting
+			}
+			return true
+		})
+	})
+	filters = e.interceptFilters(filters)
 	// adjust keeps track of runes dropped because of MaxLen.
 	var adjust int
 	for {
@@ -405,6 +478,9 @@ func (e *Editor) processKey(gtx layout.Context) (EditorEvent, bool) {
 			if !gtx.Focused(e) || ke.State != key.Press {
 				break
 			}
+			if e.intercepted(ke) {
+				return KeyEvent{Event: ke}, true
+			}
 			if !e.ReadOnly && e.Submit && (ke.Name == key.NameReturn || ke.Name == key.NameEnter) {
 				if !ke.Modifiers.Contain(key.ModShift) {
 					e.scratch = e.text.Text(e.scratch)
@@ -440,8 +516,19 @@ func (e *Editor) processKey(gtx layout.Context) (EditorEvent, bool) {
 			case e.SingleLine:
 				s = strings.ReplaceAll(s, "\n", " ")
 			}
-			moves += e.replace(ke.Range.Start, ke.Range.End, s, true)
-			adjust += utf8.RuneCountInString(ke.Text) - moves
+			e.typing = true
+			if len(e.text.carets) > 0 {
+				e.replaceAtCarets(ke.Range.Start, ke.Range.End, s)
+				// Keep the caret after the edit of the primary caret,
+				// wherever other carets moved it.
+				caret, _ := e.text.Selection()
+				adjust += ke.Range.Start + utf8.RuneCountInString(ke.Text) - caret
+			} else {
+				_, inserted, _ := e.edit(ke.Range.Start, ke.Range.End, s)
+				moves += inserted
+				adjust += utf8.RuneCountInString(ke.Text) - moves
+			}
+			e.typing = false
 			// Reset caret xoff.
 			e.text.MoveCaret(0, 0)
 			if submit {
@@ -461,7 +548,7 @@ func (e *Editor) processKey(gtx layout.Context) (EditorEvent, bool) {
 			e.scroller.Stop()
 			content, err := io.ReadAll(ke.Open())
 			if err == nil {
-				if e.Insert(string(content)) != 0 {
+				if e.paste(string(content)) != 0 {
 					return ChangeEvent{}, true
 				}
 			}
@@ -481,128 +568,118 @@ func (e *Editor) processKey(gtx layout.Context) (EditorEvent, bool) {
 }
 
 func (e *Editor) command(gtx layout.Context, k key.Event) (EditorEvent, bool) {
-	direction := 1
-	if gtx.Locale.Direction.Progression() == system.TowardOrigin {
-		direction = -1
-	}
-	moveByWord := k.Modifiers.Contain(key.ModShortcutAlt)
-	selAct := selectionClear
-	if k.Modifiers.Contain(key.ModShift) {
-		selAct = selectionExtend
-	}
-	if k.Modifiers.Contain(key.ModShortcut) {
-		switch k.Name {
-		// Initiate a paste operation, by requesting the clipboard contents; other
-		// half is in Editor.processKey() under clipboard.Event.
-		case "V":
-			if !e.ReadOnly {
-				gtx.Execute(clipboard.ReadCmd{Tag: e})
-			}
-		// Copy or Cut selection -- ignored if nothing selected.
-		case "C", "X":
-			e.scratch = e.text.SelectedText(e.scratch)
-			if text := string(e.scratch); text != "" {
-				gtx.Execute(clipboard.WriteCmd{Type: "application/text", Data: io.NopCloser(strings.NewReader(text))})
-				if k.Name == "X" && !e.ReadOnly {
-					if e.Delete(1) != 0 {
-						return ChangeEvent{}, true
-					}
-				}
-			}
-		// Select all
-		case "A":
-			e.text.SetCaret(0, e.text.Len())
-		case "Z":
-			if !e.ReadOnly {
-				if k.Modifiers.Contain(key.ModShift) {
-					if ev, ok := e.redo(); ok {
-						return ev, ok
-					}
-				} else {
-					if ev, ok := e.undo(); ok {
-						return ev, ok
-					}
-				}
-			}
-		case key.NameHome:
-			e.text.MoveTextStart(selAct)
-		case key.NameEnd:
-			e.text.MoveTextEnd(selAct)
+	a, ok := e.keymap().Action(KeyBinding{Name: k.Name, Modifiers: k.Modifiers})
+	if !ok {
+		return nil, false
+	}
+	return e.perform(gtx, a)
+}
+
+// keymap returns the keymap of the editor.
+func (e *Editor) keymap() *Keymap {
+	if e.Keymap != nil {
+		return e.Keymap
+	}
+	return defaultKeymap
+}
+
+// perform performs the action a at every caret.
+func (e *Editor) perform(gtx layout.Context, a Action) (EditorEvent, bool) {
+	if move, selAct, ok := movement(a); ok {
+		direction := 1
+		if gtx.Locale.Direction.Progression() == system.TowardOrigin {
+			direction = -1
 		}
+		e.forEachCaret(func(int) {
+			moveText(&e.text, move, selAct, direction)
+		})
 		return nil, false
 	}
-	switch k.Name {
-	case key.NameReturn, key.NameEnter:
+	changed := false
+	switch a {
+	// Initiate a paste operation, by requesting the clipboard contents; other
+	// half is in Editor.processKey() under clipboard.Event.
+	case ActionPaste:
 		if !e.ReadOnly {
-			if e.Insert("\n") != 0 {
-				return ChangeEvent{}, true
+			gtx.Execute(clipboard.ReadCmd{Tag: e})
+		}
+	// Copy or Cut selections -- ignored if nothing selected.
+	case ActionCopy, ActionCut:
+		if text := e.selectedTexts(); text != "" {
+			gtx.Execute(clipboard.WriteCmd{Type: "application/text", Data: io.NopCloser(strings.NewReader(text))})
+			if a == ActionCut && !e.ReadOnly {
+				e.forEachCaret(func(int) {
+					if e.text.SelectionLen() > 0 {
+						changed = e.delete(1) != 0 || changed
+					}
+				})
 			}
 		}
-	case key.NameDeleteBackward:
+	case ActionSelectAll:
+		e.text.ClearCarets()
+		e.text.SetCaret(0, e.text.Len())
+	case ActionSelectNextOccurrence:
+		e.SelectNextOccurrence()
+	case ActionClearCarets:
+		e.text.ClearCarets()
+	case ActionAcceptSuggestion:
+		changed = e.AcceptSuggestion()
+	case ActionUndo:
 		if !e.ReadOnly {
-			if moveByWord {
-				if e.deleteWord(-1) != 0 {
-					return ChangeEvent{}, true
-				}
-			} else {
-				if e.Delete(-1) != 0 {
-					return ChangeEvent{}, true
-				}
-			}
+			return e.undo()
 		}
-	case key.NameDeleteForward:
+	case ActionRedo:
 		if !e.ReadOnly {
-			if moveByWord {
-				if e.deleteWord(1) != 0 {
-					return ChangeEvent{}, true
-				}
-			} else {
-				if e.Delete(1) != 0 {
-					return ChangeEvent{}, true
-				}
-			}
+			return e.redo()
 		}
-	case key.NameUpArrow:
-		e.text.MoveLines(-1, selAct)
-	case key.NameDownArrow:
-		e.text.MoveLines(+1, selAct)
-	case key.NameLeftArrow:
-		if moveByWord {
-			e.text.MoveWord(-1*direction, selAct)
-		} else {
-			if selAct == selectionClear {
-				e.text.ClearSelection()
-			}
-			e.text.MoveCaret(-1*direction, -1*direction*int(selAct))
+	case ActionInsertNewline:
+		if !e.ReadOnly {
+			changed = e.Insert("\n") != 0
 		}
-	case key.NameRightArrow:
-		if moveByWord {
-			e.text.MoveWord(1*direction, selAct)
-		} else {
-			if selAct == selectionClear {
-				e.text.ClearSelection()
-			}
-			e.text.MoveCaret(1*direction, int(selAct)*direction)
+	case ActionDeleteBackward, ActionDeleteForward, ActionDeleteWordBackward, ActionDeleteWordForward, ActionDeleteLineEnd:
+		if !e.ReadOnly {
+			e.forEachCaret(func(int) {
+				changed = e.deleteAction(a) != 0 || changed
+			})
 		}
-	case key.NamePageUp:
-		e.text.MovePages(-1, selAct)
-	case key.NamePageDown:
-		e.text.MovePages(+1, selAct)
-	case key.NameHome:
-		e.text.MoveLineStart(selAct)
-	case key.NameEnd:
-		e.text.MoveLineEnd(selAct)
+	default:
+		return ActionEvent{Action: a}, true
+	}
+	if changed {
+		return ChangeEvent{}, true
 	}
 	return nil, false
 }
 
+// deleteAction performs the delete action a at the primary caret, and
+// returns the number of runes deleted.
+func (e *Editor) deleteAction(a Action) int {
+	switch a {
+	case ActionDeleteBackward:
+		return e.delete(-1)
+	case ActionDeleteForward:
+		return e.delete(1)
+	case ActionDeleteWordBackward:
+		return e.deleteWord(-1)
+	case ActionDeleteWordForward:
+		return e.deleteWord(1)
+	case ActionDeleteLineEnd:
+		if e.text.SelectionLen() == 0 {
+			e.text.MoveLineEnd(selectionExtend)
+		}
+		return e.delete(1)
+	}
+	return 0
+}
+
 // initBuffer should be invoked first in every exported function that accesses
 // text state. It ensures that the underlying text widget is both ready to use
 // and has its fields synced with the editor.
 func (e *Editor) initBuffer() {
-	if e.buffer == nil {
-		e.buffer = new(editBuffer)
-		e.text.SetSource(e.buffer)
+	if _, ok := e.buffer.(*editBuffer); e.Storage == nil && !ok {
+		e.setStorage(new(editBuffer))
+	} else if e.Storage != nil && e.Storage != e.buffer {
+		e.setStorage(e.Storage)
 	}
 	e.text.Alignment = e.Alignment
 	e.text.LineHeight = e.LineHeight
@@ -610,6 +687,20 @@ func (e *Editor) initBuffer() {
 	e.text.SingleLine = e.SingleLine
 	e.text.Mask = e.Mask
 	e.text.WrapPolicy = e.WrapPolicy
+	e.text.Decoration = e.Decoration
+	e.text.Highlighter = e.Highlighter
+}
+
+// setStorage replaces the text of the editor with the text of s.
+func (e *Editor) setStorage(s TextStorage) {
+	replaced := e.buffer != nil
+	e.buffer = s
+	e.text.SetSource(s)
+	if replaced {
+		e.text.ClearCarets()
+		e.text.SetCaret(0, 0)
+		e.ClearHistory()
+	}
 }
 
 // Update the state of the editor in response to input events. Update consumes editor
@@ -645,6 +736,10 @@ func (e *Editor) Update(gtx layout.Context) (EditorEvent, bool) {
 // for the text glyphs+caret and the selectMaterial as the paint material for the
 // selection rectangle.
 func (e *Editor) Layout(gtx layout.Context, lt *text.Shaper, font font.Font, size unit.Sp, textMaterial, selectMaterial op.CallOp) layout.Dimensions {
+	if gtx.Measuring() {
+		e.initBuffer()
+		return e.text.Measure(gtx, lt, font, size)
+	}
 	for {
 		_, ok := e.Update(gtx)
 		if !ok {
@@ -732,6 +827,7 @@ func (e *Editor) layout(gtx layout.Context, textMaterial, selectMaterial op.Call
 	}
 	semantic.Editor.Add(gtx.Ops)
 	if e.Len() > 0 {
+		e.text.PaintBackgrounds(gtx)
 		e.paintSelection(gtx, selectMaterial)
 		e.paintText(gtx, textMaterial)
 	}
@@ -814,7 +910,14 @@ func (e *Editor) Delete(graphemeClusters int) (deletedRunes int) {
 	if graphemeClusters == 0 {
 		return 0
 	}
+	e.forEachCaret(func(int) {
+		deletedRunes += e.delete(graphemeClusters)
+	})
+	return deletedRunes
+}
 
+// delete is Delete for the primary caret.
+func (e *Editor) delete(graphemeClusters int) (deletedRunes int) {
 	start, end := e.text.Selection()
 	if start != end {
 		graphemeClusters -= sign(graphemeClusters)
@@ -824,34 +927,103 @@ func (e *Editor) Delete(graphemeClusters int) (deletedRunes int) {
 	e.text.MoveCaret(0, graphemeClusters)
 	// Get the new rune offsets of the selection.
 	start, end = e.text.Selection()
-	e.replace(start, end, "", true)
+	deletedRunes, _, _ = e.edit(start, end, "")
 	// Reset xoff.
 	e.text.MoveCaret(0, 0)
-	e.ClearSelection()
-	return end - start
+	e.text.ClearSelection()
+	return deletedRunes
 }
 
+// Insert inserts text at every caret, replacing the selections, if any. It
+// returns the number of runes inserted.
 func (e *Editor) Insert(s string) (insertedRunes int) {
 	e.initBuffer()
 	if e.SingleLine {
 		s = strings.ReplaceAll(s, "\n", " ")
 	}
+	e.forEachCaret(func(int) {
+		insertedRunes += e.insert(s)
+	})
+	e.scrollCaret = true
+	e.scroller.Stop()
+	return insertedRunes
+}
+
+// insert is Insert for the primary caret.
+func (e *Editor) insert(s string) int {
 	start, end := e.text.Selection()
-	moves := e.replace(start, end, s, true)
-	if end < start {
-		start = end
-	}
+	_, inserted, caret := e.edit(start, end, s)
 	// Reset xoff.
 	e.text.MoveCaret(0, 0)
-	e.SetCaret(start+moves, start+moves)
+	e.text.SetCaret(caret, caret)
+	return inserted
+}
+
+// paste inserts s at every caret. If s has as many lines as there are carets,
+// every caret receives a line.
+func (e *Editor) paste(s string) int {
+	lines := strings.Split(s, "\n")
+	if n := len(e.text.carets) + 1; n == 1 || len(lines) != n {
+		return e.Insert(s)
+	}
+	moves := 0
+	e.forEachCaret(func(i int) {
+		moves += e.insert(lines[i])
+	})
 	e.scrollCaret = true
 	return moves
 }
 
-// modification represents a change to the contents of the editor buffer.
+// replaceAtCarets replaces the runes between start and end, relative to the
+// primary caret, with s relative to every caret, leaving the carets after
+// the inserted text.
+func (e *Editor) replaceAtCarets(start, end int, s string) {
+	caret, selEnd := e.text.Selection()
+	lo := min(caret, selEnd)
+	start, end = start-lo, end-lo
+	e.forEachCaret(func(int) {
+		caret, selEnd := e.text.Selection()
+		lo := min(caret, selEnd)
+		rs, re := max(lo+start, 0), max(lo+end, 0)
+		_, _, caret = e.edit(rs, re, s)
+		e.text.SetCaret(caret, caret)
+	})
+}
+
+// selectedTexts returns the selected texts of the carets in document order,
+// one per line.
+func (e *Editor) selectedTexts() string {
+	var b strings.Builder
+	e.text.ForEachCaret(func(int) {
+		if e.text.SelectionLen() == 0 {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		e.scratch = e.text.SelectedText(e.scratch)
+		b.Write(e.scratch)
+	})
+	return b.String()
+}
+
+// forEachCaret calls f with every caret in turn made the primary caret, as
+// by textView.ForEachCaret, and groups the modifications of f into a single
+// transaction.
+func (e *Editor) forEachCaret(f func(i int)) {
+	if len(e.text.carets) == 0 {
+		f(0)
+		return
+	}
+	e.Transaction(func() {
+		e.text.ForEachCaret(f)
+	})
+}
+
+// Modification represents a change to the contents of the editor buffer.
 // It contains the necessary information to both apply the change and
 // reverse it, and is useful for implementing undo/redo.
-type modification struct {
+type Modification struct {
 	// StartRune is the inclusive index of the first rune
 	// modified.
 	StartRune int
@@ -861,46 +1033,212 @@ type modification struct {
 	// ReverseContent is the data inserted at StartRune to
 	// apply this operation. It overwrites len([]rune(ApplyContent)) runes.
 	ReverseContent string
+	// Group identifies the transaction of the modification. Consecutive
+	// modifications of the same group are undone and redone together.
+	Group int
 }
 
-// undo applies the modification at e.history[e.historyIdx] and decrements
-// e.historyIdx.
-func (e *Editor) undo() (EditorEvent, bool) {
+// EditorHistory is the undo history of an Editor, such as for saving and
+// restoring an editing session.
+type EditorHistory struct {
+	// Modifications holds the modifications of the history, oldest first.
+	Modifications []Modification
+	// Next is the index of the next modification to redo. The modifications
+	// before it can be undone.
+	Next int
+}
+
+// CanUndo reports whether there is a modification to undo.
+func (e *Editor) CanUndo() bool {
+	return e.nextHistoryIdx > 0
+}
+
+// CanRedo reports whether there is an undone modification to redo.
+func (e *Editor) CanRedo() bool {
+	return e.nextHistoryIdx < len(e.history)
+}
+
+// Undo reverses the latest modification, or all the modifications of the
+// latest transaction, and reports whether there was one.
+func (e *Editor) Undo() bool {
+	e.initBuffer()
+	if !e.CanUndo() {
+		return false
+	}
+	e.coalesce = false
+	group := e.history[e.nextHistoryIdx-1].Group
+	for e.CanUndo() && e.history[e.nextHistoryIdx-1].Group == group {
+		mod := e.history[e.nextHistoryIdx-1]
+		replaceEnd := mod.StartRune + utf8.RuneCountInString(mod.ApplyContent)
+		e.replace(mod.StartRune, replaceEnd, mod.ReverseContent, false)
+		caretEnd := mod.StartRune + utf8.RuneCountInString(mod.ReverseContent)
+		e.SetCaret(caretEnd, mod.StartRune)
+		e.nextHistoryIdx--
+	}
+	return true
+}
+
+// Redo applies the latest undone modification, or all the modifications of
+// the latest undone transaction, and reports whether there was one.
+func (e *Editor) Redo() bool {
 	e.initBuffer()
-	if len(e.history) < 1 || e.nextHistoryIdx == 0 {
+	if !e.CanRedo() {
+		return false
+	}
+	e.coalesce = false
+	group := e.history[e.nextHistoryIdx].Group
+	for e.CanRedo() && e.history[e.nextHistoryIdx].Group == group {
+		mod := e.history[e.nextHistoryIdx]
+		end := mod.StartRune + utf8.RuneCountInString(mod.ReverseContent)
+		e.replace(mod.StartRune, end, mod.ApplyContent, false)
+		caretEnd := mod.StartRune + utf8.RuneCountInString(mod.ApplyContent)
+		e.SetCaret(caretEnd, mod.StartRune)
+		e.nextHistoryIdx++
+	}
+	return true
+}
+
+// undo is Undo for key events.
+func (e *Editor) undo() (EditorEvent, bool) {
+	if !e.Undo() {
 		return nil, false
 	}
-	mod := e.history[e.nextHistoryIdx-1]
-	replaceEnd := mod.StartRune + utf8.RuneCountInString(mod.ApplyContent)
-	e.replace(mod.StartRune, replaceEnd, mod.ReverseContent, false)
-	caretEnd := mod.StartRune + utf8.RuneCountInString(mod.ReverseContent)
-	e.SetCaret(caretEnd, mod.StartRune)
-	e.nextHistoryIdx--
 	return ChangeEvent{}, true
 }
 
-// redo applies the modification at e.history[e.historyIdx] and increments
-// e.historyIdx.
+// redo is Redo for key events.
 func (e *Editor) redo() (EditorEvent, bool) {
-	e.initBuffer()
-	if len(e.history) < 1 || e.nextHistoryIdx == len(e.history) {
+	if !e.Redo() {
 		return nil, false
 	}
-	mod := e.history[e.nextHistoryIdx]
-	end := mod.StartRune + utf8.RuneCountInString(mod.ReverseContent)
-	e.replace(mod.StartRune, end, mod.ApplyContent, false)
-	caretEnd := mod.StartRune + utf8.RuneCountInString(mod.ApplyContent)
-	e.SetCaret(caretEnd, mod.StartRune)
-	e.nextHistoryIdx++
 	return ChangeEvent{}, true
 }
 
+// Transaction calls f, and groups the modifications it makes, such as by
+// Insert and Delete, to be undone and redone together. Transactions nest.
+func (e *Editor) Transaction(f func()) {
+	e.initBuffer()
+	if e.transactions == 0 {
+		e.historyGroup++
+	}
+	e.transactions++
+	defer func() {
+		e.transactions--
+		e.coalesce = false
+	}()
+	f()
+}
+
+// ClearHistory removes all modifications from the undo history.
+func (e *Editor) ClearHistory() {
+	e.history = nil
+	e.nextHistoryIdx = 0
+	e.coalesce = false
+}
+
+// History returns a copy of the undo history.
+func (e *Editor) History() EditorHistory {
+	return EditorHistory{
+		Modifications: append([]Modification(nil), e.history...),
+		Next:          e.nextHistoryIdx,
+	}
+}
+
+// SetHistory replaces the undo history with a copy of h, such as one
+// returned by History when an editing session was saved. The text of the
+// editor must match the text after the modifications before h.Next.
+func (e *Editor) SetHistory(h EditorHistory) {
+	e.history = append(e.history[:0], h.Modifications...)
+	e.nextHistoryIdx = max(0, min(h.Next, len(e.history)))
+	e.coalesce = false
+	for _, m := range e.history {
+		e.historyGroup = max(e.historyGroup, m.Group)
+	}
+}
+
+// addHistory records a modification replacing the runes from start with s,
+// grouping typed text into words.
+func (e *Editor) addHistory(start int, deleted, s string) {
+	if e.nextHistoryIdx < len(e.history) {
+		e.history = e.history[:e.nextHistoryIdx]
+	}
+	if e.transactions == 0 && !(e.coalesce && e.typing && deleted == "" && e.joinsTyping(start, s)) {
+		e.historyGroup++
+	}
+	e.history = append(e.history, Modification{
+		StartRune:      start,
+		ApplyContent:   s,
+		ReverseContent: deleted,
+		Group:          e.historyGroup,
+	})
+	e.nextHistoryIdx++
+	e.coalesce = e.typing && deleted == ""
+	if e.MaxHistory > 0 {
+		e.trimHistory()
+	}
+}
+
+// joinsTyping reports whether text s typed at start continues the word
+// typed by the latest modification.
+func (e *Editor) joinsTyping(start int, s string) bool {
+	if e.nextHistoryIdx == 0 {
+		return false
+	}
+	prev := e.history[e.nextHistoryIdx-1]
+	if prev.StartRune+utf8.RuneCountInString(prev.ApplyContent) != start {
+		return false
+	}
+	// Start a new word after whitespace.
+	last, _ := utf8.DecodeLastRuneInString(prev.ApplyContent)
+	first, _ := utf8.DecodeRuneInString(s)
+	return !unicode.IsSpace(last) || unicode.IsSpace(first)
+}
+
+// trimHistory removes the oldest groups of modifications in excess of
+// MaxHistory.
+func (e *Editor) trimHistory() {
+	groups := 0
+	for i := range e.history {
+		if i == 0 || e.history[i].Group != e.history[i-1].Group {
+			groups++
+		}
+	}
+	n := 0
+	for ; groups > e.MaxHistory && n < e.nextHistoryIdx; n++ {
+		if n+1 == len(e.history) || e.history[n+1].Group != e.history[n].Group {
+			groups--
+		}
+	}
+	if n > 0 {
+		e.history = append(e.history[:0], e.history[n:]...)
+		e.nextHistoryIdx -= n
+	}
+}
+
 // replace the text between start and end with s. Indices are in runes.
 // It returns the number of runes inserted.
 // addHistory controls whether this modification is recorded in the undo
 // history. replace can modify text in positions unrelated to the cursor
 // position.
 func (e *Editor) replace(start, end int, s string, addHistory bool) int {
+	_, inserted, _ := e.replaceText(start, end, s, addHistory, false)
+	return inserted
+}
+
+// edit replaces the text between start and end with s as an edit at a caret,
+// formatted by the Formatter, if any, and leaves the caret where the
+// Formatter places it. It returns the numbers of runes deleted and inserted,
+// which are zero if the Formatter rejected the edit, and the caret after the
+// edit.
+func (e *Editor) edit(start, end int, s string) (deleted, inserted, caret int) {
+	return e.replaceText(start, end, s, true, e.Formatter != nil)
+}
+
+// replaceText is replace that formats the replacement if format is set. It
+// returns the numbers of runes deleted and inserted, and the offset of the
+// caret after the edit: the end of the inserted text if not formatted.
+func (e *Editor) replaceText(start, end int, s string, addHistory, format bool) (int, int, int) {
+	e.suggestion = ""
 	length := e.text.Len()
 	if start > end {
 		start, end = end, start
@@ -925,6 +1263,22 @@ func (e *Editor) replace(start, end int, s string, addHistory bool) int {
 		sc++
 	}
 
+	caret := 0
+	if format {
+		fstart, fend, fs, fcaret, ok := e.format(start, end, s)
+		if !ok {
+			caret, _ := e.text.Selection()
+			return 0, 0, caret
+		}
+		defer e.text.SetCaret(fcaret, fcaret)
+		if fstart == fend && fs == "" {
+			return 0, 0, fcaret
+		}
+		start, end, s = fstart, fend, fs
+		replaceSize = end - start
+		caret = fcaret
+	}
+
 	if addHistory {
 		deleted := make([]rune, 0, replaceSize)
 		readPos := e.text.ByteOffset(start)
@@ -933,15 +1287,7 @@ func (e *Editor) replace(start, end int, s string, addHistory bool) int {
 			readPos += int64(s)
 			deleted = append(deleted, ru)
 		}
-		if e.nextHistoryIdx < len(e.history) {
-			e.history = e.history[:e.nextHistoryIdx]
-		}
-		e.history = append(e.history, modification{
-			StartRune:      start,
-			ApplyContent:   s,
-			ReverseContent: string(deleted),
-		})
-		e.nextHistoryIdx++
+		e.addHistory(start, string(deleted), s)
 	}
 
 	sc = e.text.Replace(start, end, s)
@@ -958,7 +1304,10 @@ func (e *Editor) replace(start, end int, s string, addHistory bool) int {
 	}
 	e.ime.start = adjust(e.ime.start)
 	e.ime.end = adjust(e.ime.end)
-	return sc
+	if !format {
+		caret = newEnd
+	}
+	return replaceSize, sc, caret
 }
 
 // MoveCaret moves the caret (aka selection start) and the selection end
@@ -968,7 +1317,9 @@ func (e *Editor) replace(start, end int, s string, addHistory bool) int {
 // characters are multiple code points long.
 func (e *Editor) MoveCaret(startDelta, endDelta int) {
 	e.initBuffer()
-	e.text.MoveCaret(startDelta, endDelta)
+	e.forEachCaret(func(int) {
+		e.text.MoveCaret(startDelta, endDelta)
+	})
 }
 
 // deleteWord deletes the next word(s) in the specified direction.
@@ -983,7 +1334,7 @@ func (e *Editor) deleteWord(distance int) (deletedRunes int) {
 
 	start, end := e.text.Selection()
 	if start != end {
-		deletedRunes = e.Delete(1)
+		deletedRunes = e.delete(1)
 		distance -= sign(distance)
 	}
 	if distance == 0 {
@@ -1027,7 +1378,7 @@ func (e *Editor) deleteWord(distance int) (deletedRunes int) {
 			runes += 1
 		}
 	}
-	deletedRunes += e.Delete(runes * direction)
+	deletedRunes += e.delete(runes * direction)
 	return deletedRunes
 }
 
@@ -1046,26 +1397,99 @@ func (e *Editor) Selection() (start, end int) {
 }
 
 // SetCaret moves the caret to start, and sets the selection end to end. start
-// and end are in runes, and represent offsets into the editor text.
+// and end are in runes, and represent offsets into the editor text. The
+// additional carets, if any, are removed.
 func (e *Editor) SetCaret(start, end int) {
 	e.initBuffer()
+	e.text.ClearCarets()
 	e.text.SetCaret(start, end)
 	e.scrollCaret = true
 	e.scroller.Stop()
 }
 
-// SelectedText returns the currently selected text (if any) from the editor.
+// AddCaret adds a caret at start with a selection to end, and makes it the
+// primary caret, whose position is reported by Selection. Carets that
+// overlap are merged. start and end are in runes.
+func (e *Editor) AddCaret(start, end int) {
+	e.initBuffer()
+	e.text.AddCaret(start, end)
+	e.scrollCaret = true
+	e.scroller.Stop()
+}
+
+// ClearCarets removes all carets but the primary caret.
+func (e *Editor) ClearCarets() {
+	e.initBuffer()
+	e.text.ClearCarets()
+}
+
+// Carets appends the carets of the editor, including the primary caret, in
+// document order to carets and returns the result.
+func (e *Editor) Carets(carets []Caret) []Caret {
+	e.initBuffer()
+	return e.text.Carets(carets)
+}
+
+// SelectNextOccurrence adds a caret selecting the next occurrence of the text
+// selected by the primary caret, wrapping around the end of the text. If
+// the primary caret has no selection, it selects the word containing or
+// touching it instead.
+// SelectNextOccurrence reports whether a selection was added.
+func (e *Editor) SelectNextOccurrence() bool {
+	e.initBuffer()
+	start, end := e.text.Selection()
+	if start == end {
+		start, end = e.WordRange()
+		e.text.SetCaret(end, start)
+		return start != end
+	}
+	e.scratch = e.text.SelectedText(e.scratch)
+	needle := e.scratch
+	runes := utf8.RuneCount(needle)
+	from := e.text.ByteOffset(max(start, end))
+	size := e.text.rr.Size()
+	carets := e.text.Carets(nil)
+	// Search after the selection first, then wrap around to the occurrences
+	// starting before it.
+	off, limit, wrapped := from, size, false
+	for {
+		i := e.text.indexBytes(needle, off, limit)
+		if i == -1 {
+			if wrapped {
+				return false
+			}
+			off, limit, wrapped = 0, min(from+int64(len(needle))-1, size), true
+			continue
+		}
+		start := e.text.runeAtByte(i)
+		end := start + runes
+		selected := false
+		for _, c := range carets {
+			selected = selected || min(c.Start, c.End) == start && max(c.Start, c.End) == end
+		}
+		if !selected {
+			e.AddCaret(end, start)
+			return true
+		}
+		off = i + int64(len(needle))
+	}
+}
+
+// SelectedText returns the currently selected text (if any) of the primary
+// caret.
 func (e *Editor) SelectedText() string {
 	e.initBuffer()
 	e.scratch = e.text.SelectedText(e.scratch)
 	return string(e.scratch)
 }
 
-// ClearSelection clears the selection, by setting the selection end equal to
-// the selection start.
+// ClearSelection clears the selections, by setting the selection end equal to
+// the selection start of every caret.
 func (e *Editor) ClearSelection() {
 	e.initBuffer()
-	e.text.ClearSelection()
+	e.text.ForEachCaret(func(int) {
+		e.text.ClearSelection()
+	})
 }
 
 // WriteTo implements io.WriterTo.
@@ -1092,18 +1516,97 @@ func (e *Editor) Regions(start, end int, regions []Region) []Region {
 	return e.text.Regions(start, end, regions)
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
+// FindAll appends the matches of s in the text to matches and returns the
+// result.
+func (e *Editor) FindAll(s *Search, matches []Match) []Match {
+	e.initBuffer()
+	e.scratch = e.text.Text(e.scratch)
+	return s.FindAll(e.scratch, matches)
+}
+
+// FindNext returns the first match of s starting at or after the rune offset
+// from, wrapping around the end of the text.
+func (e *Editor) FindNext(s *Search, from int) (Match, bool) {
+	e.initBuffer()
+	e.scratch = e.text.Text(e.scratch)
+	return s.findNext(e.scratch, from)
+}
+
+// FindPrevious returns the last match of s ending at or before the rune
+// offset before, wrapping around the start of the text.
+func (e *Editor) FindPrevious(s *Search, before int) (Match, bool) {
+	e.initBuffer()
+	e.scratch = e.text.Text(e.scratch)
+	return s.findPrevious(e.scratch, before)
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// MatchRegions appends the visible regions covering matches to regions and
+// returns the result, such as for highlighting the matches of a search.
+func (e *Editor) MatchRegions(matches []Match, regions []Region) []Region {
+	e.initBuffer()
+	return matchRegions(&e.text, matches, regions)
+}
+
+// ReplaceMatch replaces m, a match of s, with template, expanded as by
+// regexp.Regexp.Expand if s is a regular expression search. It returns the
+// range of the replacement, or false if m no longer matches.
+func (e *Editor) ReplaceMatch(s *Search, m Match, template string) (Match, bool) {
+	e.initBuffer()
+	e.scratch = e.text.Text(e.scratch)
+	repl, found := "", false
+	s.each(e.scratch, func(sm Match, submatches []int) {
+		if sm == m && !found {
+			repl, found = s.expand(e.scratch, template, submatches), true
+		}
+	})
+	if !found {
+		return Match{}, false
 	}
-	return b
+	n := e.replace(m.Start, m.End, repl, true)
+	return Match{Start: m.Start, End: m.Start + n}, true
+}
+
+// ReplaceAll replaces every match of s with template, as by ReplaceMatch,
+// in a single transaction. It returns the number of matches replaced.
+func (e *Editor) ReplaceAll(s *Search, template string) int {
+	e.initBuffer()
+	e.scratch = e.text.Text(e.scratch)
+	n := 0
+	e.Transaction(func() {
+		s.replacements(e.scratch, template, func(m Match, repl string) {
+			e.replace(m.Start, m.End, repl, true)
+			n++
+		})
+	})
+	return n
+}
+
+// SetStyles replaces the styles of the text with ranges, whose offsets are
+// in runes. The ranges are adjusted as the text is edited.
+func (e *Editor) SetStyles(ranges []StyleRange) {
+	e.initBuffer()
+	e.text.SetStyles(ranges)
+}
+
+// AddStyle adds a style range, such as a WavyUnderline under a misspelled
+// word, to the styles of the text.
+func (e *Editor) AddStyle(r StyleRange) {
+	e.initBuffer()
+	e.text.AddStyle(r)
+}
+
+// Styles appends the style ranges of the text, adjusted by the edits since
+// they were set, to ranges and returns the result.
+func (e *Editor) Styles(ranges []StyleRange) []StyleRange {
+	e.initBuffer()
+	return e.text.Styles(ranges)
+}
+
+// Restyle styles the text anew with the Highlighter, such as after its
+// rules changed.
+func (e *Editor) Restyle() {
+	e.initBuffer()
+	e.text.Restyle()
 }
 
 func abs(n int) int {
@@ -1127,3 +1630,4 @@ func sign(n int) int {
 func (s ChangeEvent) isEditorEvent() {}
 func (s SubmitEvent) isEditorEvent() {}
 func (s SelectEvent) isEditorEvent() {}
+func (s ActionEvent) isEditorEvent() {}
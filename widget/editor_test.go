@@ -6,9 +6,11 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/color"
 	"io"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 	"testing/quick"
 	"time"
@@ -78,6 +80,527 @@ func TestEditorHistory(t *testing.T) {
 	assertContents(t, e, text, start, end)
 }
 
+func TestEditorTransactions(t *testing.T) {
+	e := new(Editor)
+	r := new(input.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(100, 100)},
+		Source:      r.Source(),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	gtx.Execute(key.FocusCmd{Tag: e})
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+	if e.CanUndo() || e.CanRedo() {
+		t.Fatal("empty editor has history")
+	}
+	// Typing coalesces into words.
+	for i, s := range []string{"a", "b", " ", "c", "d"} {
+		r.Queue(key.EditEvent{Range: key.Range{Start: i, End: i}, Text: s})
+	}
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	if got := e.Text(); got != "ab cd" {
+		t.Fatalf("typed %q, want %q", got, "ab cd")
+	}
+	if !e.Undo() {
+		t.Fatal("Undo failed")
+	}
+	assertContents(t, e, "ab ", 3, 3)
+	e.Undo()
+	assertContents(t, e, "", 0, 0)
+	if e.CanUndo() || !e.CanRedo() {
+		t.Error("unexpected history after undoing all")
+	}
+	e.Redo()
+	assertContents(t, e, "ab ", 3, 2)
+
+	// Programmatic edits group into transactions.
+	e.SetText("hello")
+	e.Transaction(func() {
+		e.SetCaret(0, 0)
+		e.Insert("<")
+		e.SetCaret(e.Len(), e.Len())
+		e.Insert(">")
+	})
+	assertContents(t, e, "<hello>", 7, 7)
+	e.Undo()
+	assertContents(t, e, "hello", 0, 0)
+	e.Redo()
+	assertContents(t, e, "<hello>", 7, 6)
+
+	// Saved history restores.
+	h := e.History()
+	e2 := new(Editor)
+	e2.SetText(e.Text())
+	e2.SetHistory(h)
+	e2.Undo()
+	e2.Undo()
+	assertContents(t, e2, "ab ", 3, 0)
+
+	// History is capped.
+	e.MaxHistory = 2
+	e.Insert("!")
+	e.Insert("?")
+	if got := len(e.History().Modifications); got != 2 {
+		t.Errorf("capped history has %d modifications, want 2", got)
+	}
+	e.ClearHistory()
+	if e.CanUndo() || e.Undo() {
+		t.Error("cleared history can undo")
+	}
+}
+
+func TestEditorMultiCaret(t *testing.T) {
+	e := new(Editor)
+	e.SetText("one\ntwo\nthree")
+	e.SetCaret(0, 0)
+	e.AddCaret(4, 4)
+	e.AddCaret(8, 8)
+	e.Insert("> ")
+	if got, want := e.Text(), "> one\n> two\n> three"; got != want {
+		t.Fatalf("got %q after insert, want %q", got, want)
+	}
+	want := []Caret{{2, 2}, {8, 8}, {14, 14}}
+	if got := e.Carets(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got carets %v, want %v", got, want)
+	}
+	e.Delete(-2)
+	if got, want := e.Text(), "one\ntwo\nthree"; got != want {
+		t.Fatalf("got %q after delete, want %q", got, want)
+	}
+	// Edits at all carets undo together.
+	e.Undo()
+	if got, want := e.Text(), "> one\n> two\n> three"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+	if got := len(e.Carets(nil)); got != 1 {
+		t.Errorf("got %d carets after undo, want 1", got)
+	}
+
+	// Carets that meet merge.
+	e.SetText("ab")
+	e.SetCaret(1, 1)
+	e.AddCaret(2, 2)
+	e.MoveCaret(-1, -1)
+	e.MoveCaret(-1, -1)
+	if got, want := e.Carets(nil), []Caret{{0, 0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got carets %v, want %v", got, want)
+	}
+
+	// Select occurrences.
+	e.SetText("foo bar foo baz foo")
+	e.SetCaret(1, 1)
+	for i := 0; i < 4; i++ {
+		e.SelectNextOccurrence()
+	}
+	want = []Caret{{3, 0}, {11, 8}, {19, 16}}
+	if got := e.Carets(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got carets %v, want %v", got, want)
+	}
+	if got, want := e.selectedTexts(), "foo\nfoo\nfoo"; got != want {
+		t.Errorf("got selected texts %q, want %q", got, want)
+	}
+	// A caret at the start of a word selects that word.
+	word := new(Editor)
+	word.SetText("foo bar")
+	word.SetCaret(4, 4)
+	if !word.SelectNextOccurrence() || word.SelectedText() != "bar" {
+		t.Errorf("selected %q from the start of a word, want %q", word.SelectedText(), "bar")
+	}
+	// Paste distributes lines to carets.
+	e.paste("1\n2\n3")
+	if got, want := e.Text(), "1 bar 2 baz 3"; got != want {
+		t.Errorf("got %q after paste, want %q", got, want)
+	}
+
+	// Occurrences are found across read chunks and in multi-byte text,
+	// wrapping around the end.
+	long := strings.Repeat("é", 3000)
+	e.SetText("fö" + long + "fö" + long + "fö")
+	e.SetCaret(3004, 3002)
+	for i := 0; i < 2; i++ {
+		e.SelectNextOccurrence()
+	}
+	want = []Caret{{2, 0}, {3004, 3002}, {6006, 6004}}
+	if got := e.Carets(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got carets %v, want %v", got, want)
+	}
+
+	// Input method edits apply at every caret.
+	r := new(input.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 200)),
+		Source:      r.Source(),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e.SetText("abc\ndef")
+	e.SetCaret(0, 0)
+	e.AddCaret(4, 4)
+	gtx.Execute(key.FocusCmd{Tag: e})
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	r.Frame(gtx.Ops)
+	r.Queue(key.EditEvent{Range: key.Range{Start: 4, End: 4}, Text: "x"})
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	if got, want := e.Text(), "xabc\nxdef"; got != want {
+		t.Errorf("got %q after edit, want %q", got, want)
+	}
+	want = []Caret{{1, 1}, {6, 6}}
+	if got := e.Carets(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got carets %v after edit, want %v", got, want)
+	}
+}
+
+func TestEditorColumnSelection(t *testing.T) {
+	e := new(Editor)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 200)),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e.SetText("aaaa\nbbbb\ncccc")
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	from := e.text.closestToRune(1)
+	to := e.text.closestToRune(13)
+	e.text.SelectColumn(image.Pt(from.x.Round(), from.y), image.Pt(to.x.Round(), to.y))
+	want := []Caret{{3, 1}, {8, 6}, {13, 11}}
+	if got := e.Carets(nil); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got carets %v, want %v", got, want)
+	}
+	if start, end := e.Selection(); start != 13 || end != 11 {
+		t.Errorf("got primary selection (%d, %d), want (13, 11)", start, end)
+	}
+	e.Insert("x")
+	if got, want := e.Text(), "axa\nbxb\ncxc"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEditorSearch(t *testing.T) {
+	e := new(Editor)
+	e.SetText("Foo föo foobar foo")
+	tests := []struct {
+		query string
+		opts  SearchOptions
+		want  []Match
+	}{
+		{"foo", SearchOptions{}, []Match{{0, 3}, {8, 11}, {15, 18}}},
+		{"foo", SearchOptions{MatchCase: true}, []Match{{8, 11}, {15, 18}}},
+		{"foo", SearchOptions{WholeWord: true}, []Match{{0, 3}, {15, 18}}},
+		{"f.o", SearchOptions{}, nil},
+		{"f.o", SearchOptions{Regexp: true}, []Match{{0, 3}, {4, 7}, {8, 11}, {15, 18}}},
+		{"x*", SearchOptions{Regexp: true}, nil},
+	}
+	for _, test := range tests {
+		s, err := NewSearch(test.query, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.FindAll(s, nil); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q %+v: got %v, want %v", test.query, test.opts, got, test.want)
+		}
+	}
+	// A rejected match doesn't hide the whole word matches overlapping it.
+	overlap := new(Editor)
+	overlap.SetText("xa a a")
+	s, _ := NewSearch("a a", SearchOptions{WholeWord: true})
+	if got, want := overlap.FindAll(s, nil), []Match{{3, 6}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got whole word matches %v, want %v", got, want)
+	}
+	if m, ok := overlap.FindNext(s, 0); !ok || m != (Match{3, 6}) {
+		t.Errorf("FindNext of whole word got %v, %v", m, ok)
+	}
+	// Assertions are matched in the context of the whole text, regardless
+	// of where the search starts.
+	for _, test := range []struct {
+		text, query string
+		opts        SearchOptions
+		from        int
+	}{
+		{"xx foo", "^foo", SearchOptions{Regexp: true}, 3},
+		{"bar", `\bar`, SearchOptions{Regexp: true}, 1},
+		{" ab", "^ a|^ab", SearchOptions{Regexp: true, WholeWord: true}, 0},
+	} {
+		anchored := new(Editor)
+		anchored.SetText(test.text)
+		s, _ := NewSearch(test.query, test.opts)
+		if got := anchored.FindAll(s, nil); got != nil {
+			t.Errorf("%q in %q: got matches %v", test.query, test.text, got)
+		}
+		if m, ok := anchored.FindNext(s, test.from); ok {
+			t.Errorf("%q in %q: FindNext got %v", test.query, test.text, m)
+		}
+		if m, ok := anchored.FindPrevious(s, len(test.text)); ok {
+			t.Errorf("%q in %q: FindPrevious got %v", test.query, test.text, m)
+		}
+	}
+	if _, err := NewSearch("(", SearchOptions{Regexp: true}); err == nil {
+		t.Error("invalid regexp compiled")
+	}
+
+	s, _ = NewSearch("foo", SearchOptions{MatchCase: true})
+	if m, _ := e.FindNext(s, 12); m != (Match{15, 18}) {
+		t.Errorf("FindNext got %v", m)
+	}
+	if m, _ := e.FindNext(s, 16); m != (Match{8, 11}) {
+		t.Errorf("FindNext didn't wrap around, got %v", m)
+	}
+	if m, _ := e.FindPrevious(s, 15); m != (Match{8, 11}) {
+		t.Errorf("FindPrevious got %v", m)
+	}
+	if m, _ := e.FindPrevious(s, 10); m != (Match{15, 18}) {
+		t.Errorf("FindPrevious didn't wrap around, got %v", m)
+	}
+	whole, _ := NewSearch("foo", SearchOptions{WholeWord: true})
+	if m, _ := e.FindNext(whole, 1); m != (Match{15, 18}) {
+		t.Errorf("FindNext of whole word got %v", m)
+	}
+	if m, ok := e.ReplaceMatch(s, Match{8, 11}, "qu"); !ok || m != (Match{8, 10}) {
+		t.Errorf("ReplaceMatch got %v, %v", m, ok)
+	}
+	if _, ok := e.ReplaceMatch(s, Match{8, 11}, "qu"); ok {
+		t.Error("ReplaceMatch replaced a stale match")
+	}
+	if got, want := e.Text(), "Foo föo qubar foo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	s, _ = NewSearch(`(\w)o+`, SearchOptions{Regexp: true})
+	if n := e.ReplaceAll(s, "<$1>"); n != 2 {
+		t.Errorf("ReplaceAll replaced %d matches, want 2", n)
+	}
+	if got, want := e.Text(), "<F> föo qubar <f>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	e.Undo()
+	if got, want := e.Text(), "Foo föo qubar foo"; got != want {
+		t.Errorf("got %q after undo, want %q", got, want)
+	}
+}
+
+func TestEditorKeymap(t *testing.T) {
+	r := new(input.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 200)),
+		Locale:      english,
+		Source:      r.Source(),
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e := new(Editor)
+	e.Keymap = EmacsKeymap()
+	e.Keymap.Bind(KeyBinding{Name: "S", Modifiers: key.ModCtrl}, "save")
+	e.SetText("one two\nthree")
+	gtx.Execute(key.FocusCmd{Tag: e})
+	layoutEditor := func() {
+		e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+		r.Frame(gtx.Ops)
+		gtx.Ops.Reset()
+	}
+	layoutEditor()
+	// Delete to the end of the line, then the line break.
+	r.Queue(
+		key.Event{Name: "F", Modifiers: key.ModAlt, State: key.Press},
+		key.Event{Name: "K", Modifiers: key.ModCtrl, State: key.Press},
+	)
+	layoutEditor()
+	assertContents(t, e, "one\nthree", 3, 3)
+	r.Queue(key.Event{Name: "K", Modifiers: key.ModCtrl, State: key.Press})
+	layoutEditor()
+	assertContents(t, e, "onethree", 3, 3)
+	r.Queue(
+		key.Event{Name: "A", Modifiers: key.ModCtrl, State: key.Press},
+		key.Event{Name: "K", Modifiers: key.ModCtrl, State: key.Press},
+	)
+	layoutEditor()
+	assertContents(t, e, "", 0, 0)
+	r.Queue(key.Event{Name: "S", Modifiers: key.ModCtrl, State: key.Press})
+	var got []EditorEvent
+	for {
+		ev, ok := e.Update(gtx)
+		if !ok {
+			break
+		}
+		got = append(got, ev)
+	}
+	if want := []EditorEvent{ActionEvent{Action: "save"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+
+	// Changes to the bindings take effect.
+	e.Keymap.Unbind(KeyBinding{Name: "S", Modifiers: key.ModCtrl})
+	e.Keymap.Bind(KeyBinding{Name: "R", Modifiers: key.ModCtrl}, "save")
+	layoutEditor()
+	r.Queue(key.Event{Name: "R", Modifiers: key.ModCtrl, State: key.Press})
+	got = got[:0]
+	for {
+		ev, ok := e.Update(gtx)
+		if !ok {
+			break
+		}
+		got = append(got, ev)
+	}
+	if want := []EditorEvent{ActionEvent{Action: "save"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v after rebinding, want %v", got, want)
+	}
+}
+
+func TestEditorCompletion(t *testing.T) {
+	r := new(input.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(200, 200)),
+		Locale:      english,
+		Source:      r.Source(),
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e := new(Editor)
+	e.SetText("say hel\nthere")
+	e.SetCaret(7, 7)
+	gtx.Execute(key.FocusCmd{Tag: e})
+	events := func() []EditorEvent {
+		var got []EditorEvent
+		for {
+			ev, ok := e.Update(gtx)
+			if !ok {
+				break
+			}
+			got = append(got, ev)
+		}
+		e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+		e.PaintSuggestion(gtx, op.CallOp{})
+		r.Frame(gtx.Ops)
+		gtx.Ops.Reset()
+		return got
+	}
+	events()
+	if start, end := e.WordRange(); start != 4 || end != 7 {
+		t.Errorf("word range (%d, %d), want (4, 7)", start, end)
+	}
+	if rect, pos := e.CaretRect(), e.CaretCoords(); rect.Dx() != 0 || rect.Min.Y >= int(pos.Y) || rect.Max.Y <= int(pos.Y) {
+		t.Errorf("caret rectangle %v doesn't span caret %v", rect, pos)
+	}
+
+	// Intercepted keys are reported instead of moving the caret.
+	e.Intercept = []KeyBinding{{Name: key.NameDownArrow}, {Name: key.NameReturn}}
+	r.Queue(
+		key.Event{Name: key.NameDownArrow, State: key.Press},
+		key.Event{Name: key.NameReturn, State: key.Press},
+	)
+	want := []EditorEvent{
+		KeyEvent{key.Event{Name: key.NameDownArrow, State: key.Press}},
+		KeyEvent{key.Event{Name: key.NameReturn, State: key.Press}},
+	}
+	if got := events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+	assertContents(t, e, "say hel\nthere", 7, 7)
+	e.Intercept = nil
+
+	e.SetSuggestion("lo")
+	if got := e.Suggestion(); got != "lo" {
+		t.Errorf("suggestion %q, want %q", got, "lo")
+	}
+	events()
+	r.Queue(key.Event{Name: key.NameTab, State: key.Press})
+	events()
+	assertContents(t, e, "say hello\nthere", 9, 9)
+	if got := e.Suggestion(); got != "" {
+		t.Errorf("suggestion %q remains after accepting it", got)
+	}
+
+	// Suggestions are not shown over the text following the caret.
+	e.SetCaret(4, 4)
+	e.SetSuggestion("well, ")
+	if got := e.Suggestion(); got != "" {
+		t.Errorf("suggestion %q shown before text", got)
+	}
+	e.SetCaret(e.Len(), e.Len())
+	e.SetSuggestion("!")
+	if got := e.Suggestion(); got != "!" {
+		t.Errorf("suggestion %q at the end of the text, want %q", got, "!")
+	}
+
+	// Moving the caret hides the suggestion.
+	e.SetSuggestion("!")
+	e.SetCaret(0, 0)
+	if got := e.Suggestion(); got != "" {
+		t.Errorf("suggestion %q shown away from the caret", got)
+	}
+	if e.AcceptSuggestion() {
+		t.Error("accepted a hidden suggestion")
+	}
+}
+
+func TestEditorVisibleLines(t *testing.T) {
+	for _, align := range []text.Alignment{text.Start, text.Middle} {
+		gtx := layout.Context{
+			Ops:         new(op.Ops),
+			Constraints: layout.Exact(image.Pt(60, 500)),
+			Locale:      english,
+		}
+		cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+		e := &Editor{Alignment: align}
+		e.SetText("a\nthe line that wraps\n\nb\n")
+		e.SetCaret(5, 5)
+		e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+		if n := e.LineCount(); n != 5 {
+			t.Errorf("%v: got %d lines, want 5", align, n)
+		}
+		lines := e.VisibleLines(nil)
+		if len(lines) != 5 {
+			t.Fatalf("%v: got %d visible lines, want 5", align, len(lines))
+		}
+		ranges := [][2]int{{0, 2}, {2, 22}, {22, 23}, {23, 25}, {25, 25}}
+		for i, l := range lines {
+			if l.Index != i || l.Start != ranges[i][0] || l.End != ranges[i][1] {
+				t.Errorf("%v: line %d: got index %d, range [%d, %d)", align, i, l.Index, l.Start, l.End)
+			}
+			if l.Current != (i == 1) {
+				t.Errorf("%v: line %d: got current %v", align, i, l.Current)
+			}
+			if l.Top >= l.Baseline || l.Baseline >= l.Bottom {
+				t.Errorf("%v: line %d: invalid bounds %d, %d, %d", align, i, l.Top, l.Baseline, l.Bottom)
+			}
+			if i > 0 && l.Top < lines[i-1].Bottom-1 {
+				t.Errorf("%v: line %d overlaps the line above", align, i)
+			}
+		}
+		// The wrapped line spans several visual lines.
+		if h, h0 := lines[1].Bottom-lines[1].Top, lines[0].Bottom-lines[0].Top; h < h0*3/2 {
+			t.Errorf("%v: wrapped line is %d high, a line %d", align, h, h0)
+		}
+		e.SetCaret(25, 25)
+		if lines := e.VisibleLines(nil); !lines[4].Current {
+			t.Errorf("%v: caret at the end is not in the last line", align)
+		}
+		var laidOut []int
+		dims := e.LayoutGutter(gtx, func(gtx layout.Context, l EditorLine) layout.Dimensions {
+			laidOut = append(laidOut, l.Index)
+			return layout.Dimensions{Size: image.Pt(10*(l.Index+1), 5)}
+		})
+		if want := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(laidOut, want) {
+			t.Errorf("%v: gutter laid out lines %v, want %v", align, laidOut, want)
+		}
+		if dims.Size.X != 50 {
+			t.Errorf("%v: got gutter width %d, want 50", align, dims.Size.X)
+		}
+		// The count follows edits, before and after the next layout.
+		e.Insert("\n\n")
+		if n := e.LineCount(); n != 7 {
+			t.Errorf("%v: got %d lines after an edit, want 7", align, n)
+		}
+		e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+		if n := e.LineCount(); n != 7 {
+			t.Errorf("%v: got %d lines after layout, want 7", align, n)
+		}
+	}
+}
+
 func assertContents(t *testing.T, e *Editor, contents string, selectionStart, selectionEnd int) {
 	t.Helper()
 	actualContents := e.Text()
@@ -1283,3 +1806,228 @@ func textBaseline(e *Editor, lineNum int) float32 {
 	start := e.text.closestToLineCol(lineNum, 0)
 	return float32(start.y)
 }
+
+// TestEditorParagraphs ensures that text laid out paragraph by paragraph has
+// the caret positions of text laid out as a whole.
+func TestEditorParagraphs(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e := new(Editor)
+	e.SetText("The quick brown fox\njumps over\n\nthe lazy dog, which is longer than a line\n")
+	check := func(paragraphs int) {
+		t.Helper()
+		e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+		if got := len(e.text.paragraphs); got != paragraphs {
+			t.Errorf("got %d paragraphs, expected %d", got, paragraphs)
+		}
+		var index glyphIndex
+		cache.LayoutString(e.text.params, e.Text())
+		for g, ok := cache.NextGlyph(); ok; g, ok = cache.NextGlyph() {
+			index.Glyph(g)
+		}
+		for r := 0; r <= e.Len(); r++ {
+			exp, _ := index.closestToRune(r)
+			got := e.text.closestToRune(r)
+			if got.runes != exp.runes || got.lineCol != exp.lineCol || got.x != exp.x || got.y != exp.y {
+				t.Errorf("rune %d: got position %+v, expected %+v", r, got, exp)
+			}
+		}
+		if got, exp := e.text.Len(), utf8.RuneCountInString(e.Text()); got != exp {
+			t.Errorf("got length %d, expected %d", got, exp)
+		}
+	}
+	check(4)
+	e.SetCaret(4, 4)
+	e.Insert("red\n")
+	check(5)
+	e.SetCaret(0, 24)
+	e.Delete(1)
+	check(3)
+	e.SetCaret(e.Len(), e.Len())
+	e.Insert("end")
+	check(4)
+}
+
+func TestEditorStorage(t *testing.T) {
+	const txt = "æbc\naøå••"
+	e := new(Editor)
+	e.SetText("replaced")
+	e.Storage = NewPieceTable(strings.NewReader(txt), int64(len(txt)))
+	if got := e.Text(); got != txt {
+		t.Errorf("got text %q, expected %q", got, txt)
+	}
+	if len(e.history) > 0 {
+		t.Error("storage kept the undo history of the replaced text")
+	}
+	e.SetCaret(3, 3)
+	e.Insert("d")
+	if got, exp := e.Text(), "æbcd\naøå••"; got != exp {
+		t.Errorf("got text %q, expected %q", got, exp)
+	}
+	e.Storage = nil
+	if got := e.Text(); got != "" {
+		t.Errorf("got text %q, expected empty text", got)
+	}
+}
+
+// TestPieceTable compares random edits of a PieceTable with edits of a string.
+func TestPieceTable(t *testing.T) {
+	const original = "Hello, 世界!\nThe quick brown fox"
+	p := NewPieceTable(strings.NewReader(original), int64(len(original)))
+	model := []rune(original)
+	r := rand.New(rand.NewSource(1))
+	inserts := []string{"", "a", "ø", "世界", "\n", "longer text"}
+	for i := 0; i < 1000; i++ {
+		start := r.Intn(len(model) + 1)
+		count := r.Intn(len(model) - start + 1)
+		s := inserts[r.Intn(len(inserts))]
+		off := len(string(model[:start]))
+		p.ReplaceRunes(int64(off), int64(count), s)
+		model = append(model[:start], append([]rune(s), model[start+count:]...)...)
+
+		exp := string(model)
+		if got := p.Size(); got != int64(len(exp)) {
+			t.Fatalf("edit %d: got size %d, expected %d", i, got, len(exp))
+		}
+		buf := make([]byte, len(exp)+1)
+		n, err := p.ReadAt(buf, 0)
+		if got := string(buf[:n]); got != exp || err != io.EOF {
+			t.Fatalf("edit %d: got %q, %v, expected %q, EOF", i, got, err, exp)
+		}
+		if len(exp) > 0 {
+			from := r.Intn(len(exp))
+			to := from + r.Intn(len(exp)-from) + 1
+			n, err := p.ReadAt(buf[:to-from], int64(from))
+			if got := string(buf[:n]); got != exp[from:to] || err != nil {
+				t.Fatalf("edit %d: got %q, %v, expected %q", i, got, err, exp[from:to])
+			}
+		}
+	}
+	if !p.Changed() || p.Changed() {
+		t.Error("Changed didn't report and reset the change")
+	}
+}
+
+// TestEditorLargeText ensures that only the paragraphs around the viewport
+// are shaped.
+func TestEditorLargeText(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e := new(Editor)
+	e.SetText(strings.Repeat("a line of text\n", 10000))
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	if e.text.shaped > 100 {
+		t.Errorf("%d paragraphs shaped after layout", e.text.shaped)
+	}
+	e.SetCaret(e.Len(), e.Len())
+	e.Insert("end")
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	if e.text.shaped > 200 {
+		t.Errorf("%d paragraphs shaped after scrolling to the end", e.text.shaped)
+	}
+	if line, col := e.CaretPos(); line != 10000 || col != 3 {
+		t.Errorf("got caret at %d:%d, expected 10000:3", line, col)
+	}
+}
+
+// countingHighlighter styles every paragraph in bold, and counts the
+// paragraphs it highlighted.
+type countingHighlighter struct {
+	paragraphs int
+}
+
+func (h *countingHighlighter) Highlight(text []byte, styles []StyleRange) []StyleRange {
+	h.paragraphs++
+	return append(styles, StyleRange{Start: 0, End: utf8.RuneCount(text), Style: TextStyle{Weight: font.Bold}})
+}
+
+func TestEditorStyles(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e := new(Editor)
+	e.SetText("abc def\nghi")
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	e.SetStyles([]StyleRange{
+		{Start: 0, End: 3, Style: TextStyle{Color: red}},
+		{Start: 4, End: 7, Style: TextStyle{Background: red}},
+	})
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	regular := e.text.closestToRune(7).x
+
+	// Edits before, inside and after the ranges.
+	e.SetCaret(0, 0)
+	e.Insert("xy")
+	e.SetCaret(6, 7)
+	e.Insert("")
+	e.SetCaret(e.Len(), e.Len())
+	e.Insert("jkl")
+	want := []StyleRange{
+		{Start: 2, End: 5, Style: TextStyle{Color: red}},
+		{Start: 6, End: 8, Style: TextStyle{Background: red}},
+	}
+	if got := e.Styles(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got styles %v, expected %v", got, want)
+	}
+
+	h := new(countingHighlighter)
+	e.SetText("abc def\nghi")
+	e.Highlighter = h
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	if h.paragraphs != 2 {
+		t.Errorf("highlighted %d paragraphs, expected 2", h.paragraphs)
+	}
+	if bold := e.text.closestToRune(7).x; bold <= regular {
+		t.Errorf("bold text at %v not wider than regular text at %v", bold, regular)
+	}
+	e.SetCaret(e.Len(), e.Len())
+	e.Insert("x")
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	if h.paragraphs != 3 {
+		t.Errorf("highlighted %d paragraphs after editing one, expected 3", h.paragraphs)
+	}
+}
+
+func TestEditorMeasure(t *testing.T) {
+	e := new(Editor)
+	e.SetText(strings.Repeat("line\n", 200))
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(100, 100)),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	e.text.ScrollRel(0, 500)
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	off := e.text.ScrollOff()
+	if off.Y == 0 {
+		t.Fatal("editor didn't scroll")
+	}
+	mgtx := gtx
+	mgtx.Constraints = layout.Constraints{Max: image.Pt(100, 1e6)}
+	dims := mgtx.Measure(func(gtx layout.Context) layout.Dimensions {
+		return e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	})
+	if dims.Size.Y <= 100 {
+		t.Errorf("measured height %d, want the height of the text", dims.Size.Y)
+	}
+	if got := e.text.ScrollOff(); got != off {
+		t.Errorf("measuring scrolled the editor from %v to %v", off, got)
+	}
+	e.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	if got := e.text.ScrollOff(); got != off {
+		t.Errorf("the editor scrolled from %v to %v after measuring", off, got)
+	}
+}
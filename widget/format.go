@@ -0,0 +1,298 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Edit is a proposed modification of the text of an Editor, replacing the
+// runes [Start, End) of Text with Insert.
+type Edit struct {
+	Text       string
+	Start, End int
+	Insert     string
+}
+
+// Formatter transforms or rejects the modifications of the text of an
+// Editor.
+type Formatter interface {
+	// Format returns the text resulting from edit and the rune offset of the
+	// caret in it, or false to reject the edit.
+	Format(edit Edit) (text string, caret int, ok bool)
+}
+
+// FormatterFunc adapts a function to a Formatter.
+type FormatterFunc func(edit Edit) (text string, caret int, ok bool)
+
+// PatternMask formats text to a fixed pattern, such as "(999) 999-9999" for
+// phone numbers. In the pattern, '9' stands for a digit, 'a' for a letter and
+// '*' for a letter or digit; other runes are literals inserted as the text is
+// typed. Typed runes equal to literals are skipped, and edits that don't fit
+// the pattern are rejected.
+type PatternMask struct {
+	Pattern string
+}
+
+// DateMask formats dates to a layout of package time made of the numeric
+// elements "2006", "01" and "02" for the year, month and day, such as
+// "2006-01-02" or "02/01/2006". Edits resulting in invalid months, days or
+// dates are rejected.
+type DateMask struct {
+	Layout string
+}
+
+// NumberMask formats numbers, such as amounts of currency. Edits resulting in
+// malformed numbers are rejected.
+type NumberMask struct {
+	// Decimals is the maximum number of digits after the decimal point. Zero
+	// allows only integers.
+	Decimals int
+	// Min and Max bound the value, unless both are zero. Values between zero
+	// and the bound nearest to it are allowed, as incomplete input.
+	Min, Max float64
+	// Prefix is displayed before the number, such as a currency symbol.
+	Prefix string
+	// Separator, if not zero, separates the thousands of the integer part.
+	Separator rune
+	// Point is the decimal point, '.' if zero.
+	Point rune
+}
+
+// IPv4Mask accepts IPv4 addresses in dotted decimal notation, such as
+// "192.168.0.1", and their prefixes.
+type IPv4Mask struct{}
+
+// Apply returns the text resulting from e and the rune offset after the
+// inserted text.
+func (e Edit) Apply() (text string, caret int) {
+	r := []rune(e.Text)
+	start, end := min(e.Start, len(r)), min(e.End, len(r))
+	return string(r[:start]) + e.Insert + string(r[end:]), start + utf8.RuneCountInString(e.Insert)
+}
+
+func (f FormatterFunc) Format(edit Edit) (text string, caret int, ok bool) {
+	return f(edit)
+}
+
+func (m PatternMask) Format(edit Edit) (string, int, bool) {
+	pattern := []rune(m.Pattern)
+	old := []rune(edit.Text)
+	start, end := min(edit.Start, len(old)), min(edit.End, len(old))
+	// Collect the input runes, skipping the literals of the formatted text
+	// and of the typed text. The runes of the formatted text are matched
+	// against the pattern by position.
+	type input struct {
+		r     rune
+		typed bool
+	}
+	var inputs []input
+	addOld := func(from, to int) {
+		for i := from; i < to; i++ {
+			if i < len(pattern) && !isPlaceholder(pattern[i]) && old[i] == pattern[i] {
+				continue
+			}
+			inputs = append(inputs, input{r: old[i]})
+		}
+	}
+	addOld(0, start)
+	for _, r := range edit.Insert {
+		inputs = append(inputs, input{r: r, typed: true})
+	}
+	caret := len(inputs)
+	addOld(end, len(old))
+
+	var out []rune
+	pi, newCaret := 0, 0
+	for i, in := range inputs {
+		next := pi
+		for next < len(pattern) && !isPlaceholder(pattern[next]) {
+			next++
+		}
+		switch {
+		case next < len(pattern) && placeholderMatches(pattern[next], in.r):
+			// Insert the literals before the placeholder.
+			out = append(out, pattern[pi:next]...)
+			out = append(out, in.r)
+			pi = next + 1
+		case in.typed && strings.ContainsRune(m.Pattern, in.r) && !isPlaceholder(in.r):
+			// Skip typed literals.
+		default:
+			return "", 0, false
+		}
+		if i+1 == caret {
+			newCaret = len(out)
+		}
+	}
+	return string(out), newCaret, true
+}
+
+func isPlaceholder(r rune) bool {
+	return r == '9' || r == 'a' || r == '*'
+}
+
+func placeholderMatches(p, r rune) bool {
+	switch p {
+	case '9':
+		return unicode.IsDigit(r)
+	case 'a':
+		return unicode.IsLetter(r)
+	default:
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+}
+
+func (m DateMask) Format(edit Edit) (string, int, bool) {
+	pattern := strings.NewReplacer("2006", "9999", "01", "99", "02", "99").Replace(m.Layout)
+	text, caret, ok := PatternMask{Pattern: pattern}.Format(edit)
+	if !ok {
+		return "", 0, false
+	}
+	// Check the fields entered completely. The runes of the text match
+	// those of the pattern by position.
+	runes := []rune(text)
+	fields := []struct {
+		elem     string
+		min, max int
+	}{{"01", 1, 12}, {"02", 1, 31}}
+	for _, f := range fields {
+		i := strings.Index(m.Layout, f.elem)
+		if i == -1 {
+			continue
+		}
+		i = utf8.RuneCountInString(m.Layout[:i])
+		if len(runes) < i+len(f.elem) {
+			continue
+		}
+		v, err := strconv.Atoi(string(runes[i : i+len(f.elem)]))
+		if err != nil || v < f.min || v > f.max {
+			return "", 0, false
+		}
+	}
+	if len(runes) == utf8.RuneCountInString(pattern) {
+		if _, err := time.Parse(m.Layout, text); err != nil {
+			return "", 0, false
+		}
+	}
+	return text, caret, true
+}
+
+func (m NumberMask) Format(edit Edit) (string, int, bool) {
+	text, caret := edit.Apply()
+	point := m.Point
+	if point == 0 {
+		point = '.'
+	}
+	runes := []rune(text)
+	i := 0
+	if prefix := []rune(m.Prefix); len(prefix) > 0 && strings.HasPrefix(text, m.Prefix) {
+		i = len(prefix)
+	}
+	// Collect the significant runes, and count those before the caret.
+	var num []rune
+	ints, decimals, sig := 0, 0, 0
+	hasPoint := false
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case '0' <= r && r <= '9':
+			if hasPoint {
+				decimals++
+			} else {
+				ints++
+			}
+		case r == '-' && len(num) == 0 && m.Min < 0:
+		case r == point && m.Decimals > 0 && !hasPoint:
+			hasPoint = true
+		case r == m.Separator && m.Separator != 0:
+			continue
+		default:
+			return "", 0, false
+		}
+		num = append(num, r)
+		if i < caret {
+			sig++
+		}
+	}
+	if decimals > m.Decimals {
+		return "", 0, false
+	}
+	if len(num) == 0 {
+		return "", 0, true
+	}
+	if m.Min != 0 || m.Max != 0 {
+		s := strings.Replace(string(num), string(point), ".", 1)
+		if v, err := strconv.ParseFloat(s, 64); err == nil && (v < math.Min(m.Min, 0) || v > math.Max(m.Max, 0)) {
+			return "", 0, false
+		}
+	}
+	out := []rune(m.Prefix)
+	newCaret := len(out)
+	digit := 0
+	for j, r := range num {
+		if '0' <= r && r <= '9' && digit < ints {
+			if m.Separator != 0 && digit > 0 && (ints-digit)%3 == 0 {
+				out = append(out, m.Separator)
+			}
+			digit++
+		}
+		out = append(out, r)
+		if j+1 == sig {
+			newCaret = len(out)
+		}
+	}
+	return string(out), newCaret, true
+}
+
+func (IPv4Mask) Format(edit Edit) (string, int, bool) {
+	text, caret := edit.Apply()
+	fields := strings.Split(text, ".")
+	if len(fields) > 4 {
+		return "", 0, false
+	}
+	for _, f := range fields {
+		if len(f) > 3 || strings.TrimLeft(f, "0123456789") != "" {
+			return "", 0, false
+		}
+		if v, err := strconv.Atoi(f); err == nil && v > 255 {
+			return "", 0, false
+		}
+	}
+	return text, caret, true
+}
+
+// format applies the Formatter of e to the replacement of the runes
+// [start, end) with s. It returns the smallest equivalent replacement and the
+// resulting caret, or false if the formatter rejected the edit.
+func (e *Editor) format(start, end int, s string) (newStart, newEnd int, news string, caret int, ok bool) {
+	e.scratch = e.text.Text(e.scratch)
+	old := string(e.scratch)
+	text, caret, ok := e.Formatter.Format(Edit{Text: old, Start: start, End: end, Insert: s})
+	if !ok {
+		return 0, 0, "", 0, false
+	}
+	// Replace only the runes that differ.
+	prefix := 0
+	for prefix < len(old) && prefix < len(text) && old[prefix] == text[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(text)-prefix && old[len(old)-1-suffix] == text[len(text)-1-suffix] {
+		suffix++
+	}
+	// Align the differing bytes to runes.
+	for prefix > 0 && prefix < len(old) && !utf8.RuneStart(old[prefix]) {
+		prefix--
+	}
+	for suffix > 0 && !utf8.RuneStart(old[len(old)-suffix]) {
+		suffix--
+	}
+	newStart = utf8.RuneCountInString(old[:prefix])
+	newEnd = newStart + utf8.RuneCountInString(old[prefix:len(old)-suffix])
+	return newStart, newEnd, text[prefix : len(text)-suffix], caret, true
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"testing"
+)
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     Formatter
+		edit  Edit
+		text  string
+		caret int
+		ok    bool
+	}{
+		{"phone", PatternMask{Pattern: "(999) 999-9999"}, Edit{Text: "(555", Start: 4, End: 4, Insert: "1"}, "(555) 1", 7, true},
+		{"phone paste", PatternMask{Pattern: "(999) 999-9999"}, Edit{Insert: "555-123-4567"}, "(555) 123-4567", 14, true},
+		{"phone letter", PatternMask{Pattern: "(999) 999-9999"}, Edit{Text: "(555", Start: 4, End: 4, Insert: "x"}, "", 0, false},
+		{"phone too long", PatternMask{Pattern: "(999) 999-9999"}, Edit{Text: "(555) 123-4567", Start: 14, End: 14, Insert: "8"}, "", 0, false},
+		// Deleting a literal moves the caret before it.
+		{"phone literal", PatternMask{Pattern: "(999) 999-9999"}, Edit{Text: "(555) 1", Start: 5, End: 6}, "(555) 1", 4, true},
+		{"digit literal", PatternMask{Pattern: "+1 (999) 999-9999"}, Edit{Text: "+1 (5", Start: 5, End: 5, Insert: "5"}, "+1 (55", 6, true},
+		{"digit literal paste", PatternMask{Pattern: "+1 (999) 999-9999"}, Edit{Insert: "555-123-4567"}, "+1 (555) 123-4567", 17, true},
+		{"date", DateMask{Layout: "2006-01-02"}, Edit{Text: "2024-0", Start: 6, End: 6, Insert: "2"}, "2024-02", 7, true},
+		{"date month", DateMask{Layout: "2006-01-02"}, Edit{Text: "2024-1", Start: 6, End: 6, Insert: "3"}, "", 0, false},
+		{"date day", DateMask{Layout: "2006-01-02"}, Edit{Text: "2024-02-3", Start: 9, End: 9, Insert: "0"}, "", 0, false},
+		{"date leap day", DateMask{Layout: "2006-01-02"}, Edit{Text: "2024-02-2", Start: 9, End: 9, Insert: "9"}, "2024-02-29", 10, true},
+		{"date literals", DateMask{Layout: "2006年01月02日"}, Edit{Text: "2024年1", Start: 6, End: 6, Insert: "2"}, "2024年12", 7, true},
+		{"date literals month", DateMask{Layout: "2006年01月02日"}, Edit{Text: "2024年1", Start: 6, End: 6, Insert: "3"}, "", 0, false},
+		{"date digits", DateMask{Layout: "2006-01-02"}, Edit{Text: "٢٠٢٤-1", Start: 6, End: 6, Insert: "2"}, "٢٠٢٤-12", 7, true},
+		{"date digits month", DateMask{Layout: "2006-01-02"}, Edit{Text: "٢٠٢٤-1", Start: 6, End: 6, Insert: "3"}, "", 0, false},
+		{"currency", NumberMask{Decimals: 2, Prefix: "$", Separator: ','}, Edit{Text: "$123", Start: 4, End: 4, Insert: "4"}, "$1,234", 6, true},
+		{"currency middle", NumberMask{Decimals: 2, Prefix: "$", Separator: ','}, Edit{Text: "$1,234", Start: 1, End: 1, Insert: "9"}, "$91,234", 2, true},
+		{"currency delete", NumberMask{Decimals: 2, Prefix: "$", Separator: ','}, Edit{Text: "$1,234", Start: 1, End: 2}, "$234", 1, true},
+		{"currency decimals", NumberMask{Decimals: 2, Prefix: "$"}, Edit{Text: "$1.23", Start: 5, End: 5, Insert: "4"}, "", 0, false},
+		{"integer", NumberMask{}, Edit{Text: "12", Start: 2, End: 2, Insert: "."}, "", 0, false},
+		{"range", NumberMask{Min: 10, Max: 100}, Edit{Text: "10", Start: 2, End: 2, Insert: "1"}, "", 0, false},
+		{"range incomplete", NumberMask{Min: 10, Max: 100}, Edit{Insert: "5"}, "5", 1, true},
+		{"negative", NumberMask{Min: -5, Max: 5}, Edit{Insert: "-"}, "-", 1, true},
+		{"ip", IPv4Mask{}, Edit{Text: "192.168.0", Start: 9, End: 9, Insert: ".1"}, "192.168.0.1", 11, true},
+		{"ip range", IPv4Mask{}, Edit{Text: "192.25", Start: 6, End: 6, Insert: "6"}, "", 0, false},
+	}
+	for _, test := range tests {
+		text, caret, ok := test.f.Format(test.edit)
+		if text != test.text || caret != test.caret || ok != test.ok {
+			t.Errorf("%s: got (%q, %d, %v), want (%q, %d, %v)", test.name, text, caret, ok, test.text, test.caret, test.ok)
+		}
+	}
+}
+
+func TestEditorFormatter(t *testing.T) {
+	e := &Editor{Formatter: NumberMask{Decimals: 2, Separator: ','}}
+	e.SetText("1234")
+	e.SetCaret(5, 5)
+	e.Insert("5")
+	assertContents(t, e, "12,345", 6, 6)
+	e.Insert("x")
+	assertContents(t, e, "12,345", 6, 6)
+	// Deleting a digit regroups the others.
+	e.SetCaret(1, 1)
+	e.Delete(1)
+	assertContents(t, e, "1,345", 1, 1)
+	if !e.Undo() {
+		t.Fatal("no formatted modification to undo")
+	}
+	if got, want := e.Text(), "12,345"; got != want {
+		t.Errorf("undo: got %q, want %q", got, want)
+	}
+
+	// A formatter may move the caret before the edit.
+	e = &Editor{Formatter: FormatterFunc(func(edit Edit) (string, int, bool) {
+		text, caret := edit.Apply()
+		return text, caret - 2, true
+	})}
+	e.SetText("abc")
+	e.SetCaret(3, 3)
+	e.Insert("d")
+	assertContents(t, e, "abcd", 2, 2)
+
+	// Only edits at the carets are formatted, and rejected edits change
+	// nothing.
+	e = &Editor{Formatter: IPv4Mask{}}
+	e.SetText("not an address")
+	if got, want := e.Text(), "not an address"; got != want {
+		t.Errorf("SetText: got %q, want %q", got, want)
+	}
+	e.SetText("192.168.0")
+	e.SetCaret(9, 9)
+	if n := e.Insert("x"); n != 0 {
+		t.Errorf("rejected Insert inserted %d runes", n)
+	}
+	if n := e.Insert(".1"); n != 2 {
+		t.Errorf("Insert inserted %d runes, want 2", n)
+	}
+	e.SetCaret(3, 3)
+	if n := e.Delete(1); n != 0 {
+		t.Errorf("rejected Delete deleted %d runes", n)
+	}
+	assertContents(t, e, "192.168.0.1", 3, 3)
+}
+
+func TestPatternMaskTyping(t *testing.T) {
+	for _, test := range []struct {
+		f     Formatter
+		typed string
+		want  string
+	}{
+		{PatternMask{Pattern: "+1 (999) 999-9999"}, "5551234567", "+1 (555) 123-4567"},
+		{DateMask{Layout: "02.01.2006"}, "29022024", "29.02.2024"},
+	} {
+		text, caret := "", 0
+		for _, r := range test.typed {
+			var ok bool
+			text, caret, ok = test.f.Format(Edit{Text: text, Start: caret, End: caret, Insert: string(r)})
+			if !ok {
+				t.Fatalf("%q rejected after %q", r, text)
+			}
+		}
+		if text != test.want || caret != len(test.want) {
+			t.Errorf("typed %q, got (%q, %d), want (%q, %d)", test.typed, text, caret, test.want, len(test.want))
+		}
+	}
+}
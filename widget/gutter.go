@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"bytes"
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+)
+
+// EditorLine describes a logical line of text, that is text ending in a line
+// break or at the end of the text, regardless of how many visual lines it is
+// wrapped into.
+type EditorLine struct {
+	// Index is the index of the line, counting from zero.
+	Index int
+	// Start and End are the rune offsets of the line, End including the line
+	// break.
+	Start, End int
+	// Baseline is the baseline of the first visual line of the line, and Top
+	// and Bottom the vertical bounds of all its visual lines, relative to the
+	// editor.
+	Baseline    int
+	Top, Bottom int
+	// Current reports whether the line contains the primary caret.
+	Current bool
+}
+
+// VisibleLines appends the logical lines visible in the text view to lines
+// and returns the result.
+func (e *textView) VisibleLines(lines []EditorLine) []EditorLine {
+	e.makeValid()
+	viewport := image.Rectangle{Min: e.scrollOff, Max: e.viewSize.Add(e.scrollOff)}
+	first, last := e.paragraphAtY(viewport.Min.Y), e.paragraphAtY(viewport.Max.Y)
+	start := len(lines)
+	for i := first; i <= last; i++ {
+		p := &e.paragraphs[i]
+		l := e.paragraphLayout(i)
+		e.updateOffsets(i)
+		// Paragraphs contain at most one logical line unless the text is laid
+		// out as a whole.
+		index, runes := i, p.runeOff
+		startGlyph := 0
+		for j, line := range l.index.lines {
+			top := p.yOff + line.yOff - line.ascent.Ceil() - e.scrollOff.Y
+			bottom := p.yOff + line.yOff + line.descent.Ceil() - e.scrollOff.Y
+			if j == 0 || l.index.glyphs[startGlyph-1].Flags&text.FlagParagraphBreak != 0 {
+				if j > 0 {
+					index++
+					lines[len(lines)-1].End = runes
+				}
+				lines = append(lines, EditorLine{
+					Index:    index,
+					Start:    runes,
+					Baseline: p.yOff + line.yOff - e.scrollOff.Y,
+					Top:      top,
+				})
+			}
+			lines[len(lines)-1].Bottom = bottom
+			for _, g := range l.index.glyphs[startGlyph : startGlyph+line.glyphs] {
+				if g.Flags&text.FlagClusterBreak != 0 {
+					runes += int(g.Runes)
+				}
+			}
+			startGlyph += line.glyphs
+		}
+		lines[len(lines)-1].End = p.runeOff + p.runes
+	}
+	// Drop the lines outside the viewport, and find the current line.
+	visible := lines[:start]
+	caret := e.caret.start
+	for i, line := range lines[start:] {
+		if line.Bottom <= 0 || line.Top >= e.viewSize.Y {
+			continue
+		}
+		lastLine := start+i == len(lines)-1 && last == len(e.paragraphs)-1
+		line.Current = line.Start <= caret && (caret < line.End || lastLine && caret == line.End)
+		visible = append(visible, line)
+	}
+	return visible
+}
+
+// LineCount returns the number of logical lines of the text.
+func (e *textView) LineCount() int {
+	e.makeValid()
+	if e.incremental() {
+		n := len(e.paragraphs)
+		if r, _, _ := e.ReadRuneBefore(e.rr.Size()); r == '\n' {
+			n++
+		}
+		return n
+	}
+	// The text is laid out as a single paragraph, whose index counts the
+	// line breaks unless truncated.
+	if l := e.paragraphs[0].layout; l != nil && !l.index.truncated {
+		return l.index.paragraphBreaks + 1
+	}
+	var buf [4096]byte
+	n := 1
+	for off := int64(0); ; {
+		m, _ := e.rr.ReadAt(buf[:], off)
+		if m == 0 {
+			break
+		}
+		n += bytes.Count(buf[:m], []byte{'\n'})
+		off += int64(m)
+	}
+	return n
+}
+
+// VisibleLines appends the logical lines visible in the editor to lines and
+// returns the result.
+func (e *Editor) VisibleLines(lines []EditorLine) []EditorLine {
+	e.initBuffer()
+	return e.text.VisibleLines(lines)
+}
+
+// LineCount returns the number of logical lines of the text.
+func (e *Editor) LineCount() int {
+	e.initBuffer()
+	return e.text.LineCount()
+}
+
+// LayoutGutter lays out a column of the height of the visible text, for
+// displaying information about its logical lines such as line numbers and
+// breakpoints. The line widget is laid out for every visible logical line,
+// offset to the top of the line, and the column is as wide as the widest
+// line widget.
+func (e *Editor) LayoutGutter(gtx layout.Context, line func(gtx layout.Context, l EditorLine) layout.Dimensions) layout.Dimensions {
+	e.initBuffer()
+	e.gutterLines = e.VisibleLines(e.gutterLines[:0])
+	size := image.Point{Y: e.text.Dimensions().Size.Y}
+	gtx.Constraints.Min = image.Point{}
+	for _, l := range e.gutterLines {
+		trans := op.Offset(image.Pt(0, l.Top)).Push(gtx.Ops)
+		dims := line(gtx, l)
+		trans.Pop()
+		size.X = max(size.X, dims.Size.X)
+	}
+	return layout.Dimensions{Size: size}
+}
@@ -14,12 +14,16 @@ import (
 	"golang.org/x/image/math/fixed"
 )
 
+// lineInfo describes a line of text. For vertical lines, xOff and width
+// describe the line along the Y axis and yOff is the X coordinate of the
+// baseline.
 type lineInfo struct {
 	xOff            fixed.Int26_6
 	yOff            int
 	width           fixed.Int26_6
 	ascent, descent fixed.Int26_6
 	glyphs          int
+	vertical        bool
 }
 
 type glyphIndex struct {
@@ -46,6 +50,8 @@ type glyphIndex struct {
 	clusterAdvance fixed.Int26_6
 	// truncated indicates that the text was truncated by the shaper.
 	truncated bool
+	// paragraphBreaks counts the glyphs that end a paragraph.
+	paragraphBreaks int
 	// midCluster tracks whether the next glyph processed is not the first glyph in a
 	// cluster.
 	midCluster bool
@@ -63,6 +69,7 @@ func (g *glyphIndex) reset() {
 	g.prog = 0
 	g.clusterAdvance = 0
 	g.truncated = false
+	g.paragraphBreaks = 0
 	g.midCluster = false
 }
 
@@ -95,6 +102,21 @@ type combinedPos struct {
 	// towardOrigin tracks whether this glyph's run is progressing toward the
 	// origin or away from it.
 	towardOrigin bool
+	// vertical tracks whether this position is within a vertical line, whose
+	// baseline is at x.
+	vertical bool
+}
+
+// place sets the pixel coordinates of the position at the distance along the
+// line of the provided glyph.
+func (p *combinedPos) place(gl text.Glyph, along fixed.Int26_6) {
+	if p.vertical {
+		p.x = gl.X
+		p.y = along.Round()
+		return
+	}
+	p.x = along
+	p.y = int(gl.Y)
 }
 
 // incrementPosition returns the next position after pos (if any). Pos _must_ be
@@ -116,7 +138,14 @@ func (g *glyphIndex) insertPosition(pos combinedPos) {
 	lastIdx := len(g.positions) - 1
 	if lastIdx >= 0 {
 		lastPos := g.positions[lastIdx]
-		if lastPos.runes == pos.runes && (lastPos.y != pos.y || (lastPos.x == pos.x)) {
+		newLine, samePos := lastPos.y != pos.y, lastPos.x == pos.x
+		if pos.vertical {
+			// Vertical lines have no bidi runs, so consecutive positions
+			// on a line with the same logical position are the same,
+			// regardless of rounding of the glyph Y coordinates.
+			newLine, samePos = lastPos.x != pos.x, true
+		}
+		if lastPos.runes == pos.runes && (newLine || samePos) {
 			// If we insert a consecutive position with the same logical position,
 			// overwrite the previous position with the new one.
 			g.positions[lastIdx] = pos
@@ -130,15 +159,24 @@ func (g *glyphIndex) insertPosition(pos combinedPos) {
 func (g *glyphIndex) Glyph(gl text.Glyph) {
 	g.glyphs = append(g.glyphs, gl)
 	g.currentLineGlyphs++
+	if gl.Flags&text.FlagParagraphBreak != 0 {
+		g.paragraphBreaks++
+	}
 	if len(g.positions) == 0 {
 		// First-iteration setup.
 		g.currentLineMin = math.MaxInt32
 		g.currentLineMax = 0
 	}
-	if gl.X < g.currentLineMin {
-		g.currentLineMin = gl.X
+	// along is the position of the glyph's dot along its line.
+	along := gl.X
+	g.pos.vertical = gl.Flags&text.FlagVertical != 0
+	if g.pos.vertical {
+		along = fixed.I(int(gl.Y))
+	}
+	if along < g.currentLineMin {
+		g.currentLineMin = along
 	}
-	if end := gl.X + gl.Advance; end > g.currentLineMax {
+	if end := along + gl.Advance; end > g.currentLineMax {
 		g.currentLineMax = end
 	}
 
@@ -155,13 +193,13 @@ func (g *glyphIndex) Glyph(gl text.Glyph) {
 	g.pos.towardOrigin = g.prog == text.FlagTowardOrigin
 	if !g.midCluster {
 		// Create the text position prior to the glyph.
-		g.pos.x = gl.X
-		g.pos.y = int(gl.Y)
-		g.pos.ascent = gl.Ascent
-		g.pos.descent = gl.Descent
+		start := along
 		if g.pos.towardOrigin {
-			g.pos.x += gl.Advance
+			start += gl.Advance
 		}
+		g.pos.place(gl, start)
+		g.pos.ascent = gl.Ascent
+		g.pos.descent = gl.Descent
 		g.insertPosition(g.pos)
 	}
 
@@ -181,7 +219,6 @@ func (g *glyphIndex) Glyph(gl text.Glyph) {
 	g.clusterAdvance += gl.Advance
 	if insertPositionsWithin {
 		// Construct the text positions _within_ gl.
-		g.pos.y = int(gl.Y)
 		g.pos.ascent = gl.Ascent
 		g.pos.descent = gl.Descent
 		width := g.clusterAdvance
@@ -202,7 +239,7 @@ func (g *glyphIndex) Glyph(gl text.Glyph) {
 			perRune = -perRune
 		}
 		for i := 1; i <= positionCount; i++ {
-			g.pos.x = gl.X + adjust + perRune*fixed.Int26_6(i)
+			g.pos.place(gl, along+adjust+perRune*fixed.Int26_6(i))
 			g.pos.runes += runesPerPosition
 			g.pos.lineCol.col += runesPerPosition
 			g.insertPosition(g.pos)
@@ -213,13 +250,18 @@ func (g *glyphIndex) Glyph(gl text.Glyph) {
 		g.pos.runIndex++
 	}
 	if needsNewLine {
+		yOff := int(gl.Y)
+		if g.pos.vertical {
+			yOff = gl.X.Round()
+		}
 		g.lines = append(g.lines, lineInfo{
-			xOff:    g.currentLineMin,
-			yOff:    int(gl.Y),
-			width:   g.currentLineMax - g.currentLineMin,
-			ascent:  g.positions[len(g.positions)-1].ascent,
-			descent: g.positions[len(g.positions)-1].descent,
-			glyphs:  g.currentLineGlyphs,
+			xOff:     g.currentLineMin,
+			yOff:     yOff,
+			width:    g.currentLineMax - g.currentLineMin,
+			ascent:   g.positions[len(g.positions)-1].ascent,
+			descent:  g.positions[len(g.positions)-1].descent,
+			glyphs:   g.currentLineGlyphs,
+			vertical: g.pos.vertical,
 		})
 		g.pos.lineCol.line++
 		g.pos.lineCol.col = 0
@@ -227,6 +269,9 @@ func (g *glyphIndex) Glyph(gl text.Glyph) {
 		g.currentLineMin = math.MaxInt32
 		g.currentLineMax = 0
 		g.currentLineGlyphs = 0
+		// Glyphs without runes, like the hyphen ending a hyphenated line, never
+		// insert positions. Don't let their advance leak into the next line.
+		g.clusterAdvance = 0
 	}
 }
 
@@ -284,6 +329,9 @@ func (g *glyphIndex) closestToXY(x fixed.Int26_6, y int) combinedPos {
 	if len(g.positions) == 0 {
 		return combinedPos{}
 	}
+	if g.positions[0].vertical {
+		return g.closestToXYVertical(x, y)
+	}
 	i := sort.Search(len(g.positions), func(i int) bool {
 		pos := g.positions[i]
 		return pos.y+pos.descent.Round() >= y
@@ -318,6 +366,43 @@ func (g *glyphIndex) closestToXY(x fixed.Int26_6, y int) combinedPos {
 	return g.positions[closest]
 }
 
+// closestToXYVertical is like closestToXY for vertical lines, which may be
+// stacked from left to right or from right to left.
+func (g *glyphIndex) closestToXYVertical(x fixed.Int26_6, y int) combinedPos {
+	// Find the line closest to x.
+	line := len(g.lines) - 1
+	var lineDist fixed.Int26_6 = math.MaxInt32
+	for i, l := range g.lines {
+		base := fixed.I(l.yOff)
+		var d fixed.Int26_6
+		switch {
+		case x < base-l.descent:
+			d = base - l.descent - x
+		case x > base+l.ascent:
+			d = x - base - l.ascent
+		}
+		if d < lineDist {
+			line, lineDist = i, d
+		}
+	}
+	// Find the best Y coordinate on the line.
+	i := sort.Search(len(g.positions), func(i int) bool {
+		return g.positions[i].lineCol.line >= line
+	})
+	if i == len(g.positions) {
+		return g.positions[i-1]
+	}
+	closest := i
+	closestDist := dist(fixed.I(g.positions[i].y), fixed.I(y))
+	for i := i + 1; i < len(g.positions) && g.positions[i].lineCol.line == line; i++ {
+		if distance := dist(fixed.I(g.positions[i].y), fixed.I(y)); distance < closestDist {
+			closestDist = distance
+			closest = i
+		}
+	}
+	return g.positions[closest]
+}
+
 // makeRegion creates a text-aligned rectangle from start to end. The vertical
 // dimensions of the rectangle are derived from the provided line's ascent and
 // descent, and the y offset of the line's baseline is provided as y.
@@ -336,6 +421,21 @@ func makeRegion(line lineInfo, y int, start, end fixed.Int26_6) Region {
 	}
 }
 
+// makeVerticalRegion creates a text-aligned rectangle from start to end
+// on the Y axis of a vertical line. The horizontal dimensions of the
+// rectangle are derived from the line's baseline, ascent and descent.
+func makeVerticalRegion(line lineInfo, start, end int) Region {
+	if start > end {
+		start, end = end, start
+	}
+	return Region{
+		Bounds: image.Rectangle{
+			Min: image.Pt(line.yOff-line.descent.Ceil(), start),
+			Max: image.Pt(line.yOff+line.ascent.Ceil(), end),
+		},
+	}
+}
+
 // Region describes the position and baseline of an area of interest within
 // shaped text.
 type Region struct {
@@ -343,7 +443,7 @@ type Region struct {
 	// widget.
 	Bounds image.Rectangle
 	// Baseline is the quantity of vertical pixels between the baseline and
-	// the bottom of bounds.
+	// the bottom of bounds. It is zero for regions of vertical lines.
 	Baseline int
 }
 
@@ -365,6 +465,22 @@ func (g *glyphIndex) locate(viewport image.Rectangle, startRune, endRune int, re
 			break
 		}
 		pos := g.closestToLineCol(screenPos{line: lineIdx})
+		if line := g.lines[lineIdx]; line.vertical {
+			// Vertical lines have no bidi runs, so the selection is a
+			// single region per line.
+			if line.yOff+line.ascent.Ceil() < viewport.Min.X || line.yOff-line.descent.Ceil() > viewport.Max.X {
+				continue
+			}
+			start, end := caretStart, caretEnd
+			if lineIdx != caretStart.lineCol.line {
+				start = pos
+			}
+			if lineIdx != caretEnd.lineCol.line {
+				end = g.closestToLineCol(screenPos{line: lineIdx, col: math.MaxInt})
+			}
+			rects = append(rects, makeVerticalRegion(line, start.y, end.y))
+			continue
+		}
 		if int(pos.y)+pos.descent.Ceil() < viewport.Min.Y {
 			continue
 		}
@@ -453,7 +569,11 @@ type graphemeReader struct {
 func (p *graphemeReader) SetSource(source io.ReaderAt) {
 	p.source = source
 	p.cursor = 0
-	p.reader = bufio.NewReader(p)
+	if p.reader == nil {
+		p.reader = bufio.NewReader(p)
+	} else {
+		p.reader.Reset(p)
+	}
 	p.runeOffset = 0
 }
 
@@ -2,12 +2,14 @@ package widget
 
 import (
 	"bytes"
+	"image"
 	"io"
 	"testing"
 
 	nsareg "eliasnaur.com/font/noto/sans/arabic/regular"
 	"gioui.org/font"
 	"gioui.org/font/opentype"
+	"gioui.org/io/system"
 	"gioui.org/text"
 	"golang.org/x/image/font/gofont/goregular"
 	"golang.org/x/image/math/fixed"
@@ -628,6 +630,65 @@ func TestIndexPositionRunes(t *testing.T) {
 		})
 	}
 }
+
+// TestIndexPositionVertical checks that the index generates cursor positions
+// and regions along vertical lines.
+func TestIndexPositionVertical(t *testing.T) {
+	face, _ := opentype.Parse(goregular.TTF)
+	shaper := text.NewShaper(text.NoSystemFonts(), text.WithCollection([]font.FontFace{{Face: face}}))
+	shaper.LayoutString(text.Parameters{
+		PxPerEm:  fixed.I(16),
+		MaxWidth: 40,
+		Locale:   system.Locale{Direction: system.VerticalRL},
+	}, "abc def ghi")
+	var gi glyphIndex
+	gi.reset()
+	for g, ok := shaper.NextGlyph(); ok; g, ok = shaper.NextGlyph() {
+		gi.Glyph(g)
+	}
+	printPositions(t, gi.positions)
+	if len(gi.lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(gi.lines))
+	}
+	if len(gi.positions) != 12 {
+		t.Fatalf("got %d positions, want 12", len(gi.positions))
+	}
+	for i, l := range gi.lines {
+		if !l.vertical {
+			t.Errorf("line %d is not vertical", i)
+		}
+		if i > 0 && l.yOff >= gi.lines[i-1].yOff {
+			t.Errorf("line %d at x %d is not left of line at x %d", i, l.yOff, gi.lines[i-1].yOff)
+		}
+	}
+	for i := 1; i < len(gi.positions); i++ {
+		prev, pos := gi.positions[i-1], gi.positions[i]
+		if pos.lineCol.line == prev.lineCol.line && pos.y <= prev.y {
+			t.Errorf("position %d at y %d is not below position at y %d", i, pos.y, prev.y)
+		}
+	}
+	for _, want := range gi.positions {
+		line := gi.lines[want.lineCol.line]
+		if got := gi.closestToXY(fixed.I(line.yOff), want.y); got.runes != want.runes {
+			t.Errorf("closest to (%d, %d): got rune %d, want %d", line.yOff, want.y, got.runes, want.runes)
+		}
+	}
+	// Select "c d", which spans the first two lines.
+	regions := gi.locate(image.Rect(0, 0, 100, 100), 2, 5, nil)
+	if len(regions) != 2 {
+		t.Fatalf("got %d regions, want 2", len(regions))
+	}
+	for i, r := range regions {
+		l := gi.lines[i]
+		if r.Bounds.Min.X >= l.yOff || r.Bounds.Max.X <= l.yOff {
+			t.Errorf("region %d: %v does not cover line at x %d", i, r.Bounds, l.yOff)
+		}
+		if r.Bounds.Dy() <= 0 {
+			t.Errorf("region %d: %v is empty", i, r.Bounds)
+		}
+	}
+}
+
 func printPositions(t *testing.T, positions []combinedPos) {
 	t.Helper()
 	for i, p := range positions {
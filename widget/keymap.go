@@ -0,0 +1,324 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"runtime"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+)
+
+// Action names an editing action of a text widget, such as moving the caret
+// or copying the selection. Applications may bind keys to actions of their
+// own, which an Editor reports as ActionEvents.
+type Action string
+
+// Movement actions. Left and right are visual directions, and the Select
+// variants extend the selection instead of clearing it.
+const (
+	ActionMoveLeft      Action = "move-left"
+	ActionMoveRight     Action = "move-right"
+	ActionMoveUp        Action = "move-up"
+	ActionMoveDown      Action = "move-down"
+	ActionMoveWordLeft  Action = "move-word-left"
+	ActionMoveWordRight Action = "move-word-right"
+	ActionMoveLineStart Action = "move-line-start"
+	ActionMoveLineEnd   Action = "move-line-end"
+	ActionMoveTextStart Action = "move-text-start"
+	ActionMoveTextEnd   Action = "move-text-end"
+	ActionMovePageUp    Action = "move-page-up"
+	ActionMovePageDown  Action = "move-page-down"
+
+	ActionSelectLeft      Action = "select-left"
+	ActionSelectRight     Action = "select-right"
+	ActionSelectUp        Action = "select-up"
+	ActionSelectDown      Action = "select-down"
+	ActionSelectWordLeft  Action = "select-word-left"
+	ActionSelectWordRight Action = "select-word-right"
+	ActionSelectLineStart Action = "select-line-start"
+	ActionSelectLineEnd   Action = "select-line-end"
+	ActionSelectTextStart Action = "select-text-start"
+	ActionSelectTextEnd   Action = "select-text-end"
+	ActionSelectPageUp    Action = "select-page-up"
+	ActionSelectPageDown  Action = "select-page-down"
+)
+
+// Editing actions. Only ActionCopy and ActionSelectAll apply to a
+// Selectable.
+const (
+	ActionDeleteBackward     Action = "delete-backward"
+	ActionDeleteForward      Action = "delete-forward"
+	ActionDeleteWordBackward Action = "delete-word-backward"
+	ActionDeleteWordForward  Action = "delete-word-forward"
+	// ActionDeleteLineEnd deletes to the end of the line, or the line break
+	// at the end of the line.
+	ActionDeleteLineEnd Action = "delete-line-end"
+	ActionInsertNewline Action = "insert-newline"
+	ActionCopy          Action = "copy"
+	ActionCut           Action = "cut"
+	ActionPaste         Action = "paste"
+	ActionSelectAll     Action = "select-all"
+	ActionUndo          Action = "undo"
+	ActionRedo          Action = "redo"
+	// ActionSelectNextOccurrence adds a caret as by
+	// Editor.SelectNextOccurrence.
+	ActionSelectNextOccurrence Action = "select-next-occurrence"
+	// ActionClearCarets removes all carets but the primary caret.
+	ActionClearCarets Action = "clear-carets"
+	// ActionAcceptSuggestion inserts the suggestion of an Editor, as by
+	// Editor.AcceptSuggestion. Its keys are handled only while a suggestion
+	// is shown.
+	ActionAcceptSuggestion Action = "accept-suggestion"
+)
+
+// KeyBinding is a key and the exact modifiers that trigger an action.
+type KeyBinding struct {
+	Name      key.Name
+	Modifiers key.Modifiers
+}
+
+// Keymap maps key bindings to the actions of text widgets. Applications
+// customize a keymap with Bind and Unbind.
+type Keymap struct {
+	bindings map[KeyBinding]Action
+	// gen counts the changes of the bindings, for widgets to rebuild their
+	// key filters.
+	gen int
+}
+
+// ActionEvent is generated by an Editor for key bindings to actions it
+// doesn't implement.
+type ActionEvent struct {
+	Action Action
+}
+
+var defaultKeymap = DefaultKeymap()
+
+// selectActions maps the selection actions to their movement.
+var selectActions = map[Action]Action{
+	ActionSelectLeft:      ActionMoveLeft,
+	ActionSelectRight:     ActionMoveRight,
+	ActionSelectUp:        ActionMoveUp,
+	ActionSelectDown:      ActionMoveDown,
+	ActionSelectWordLeft:  ActionMoveWordLeft,
+	ActionSelectWordRight: ActionMoveWordRight,
+	ActionSelectLineStart: ActionMoveLineStart,
+	ActionSelectLineEnd:   ActionMoveLineEnd,
+	ActionSelectTextStart: ActionMoveTextStart,
+	ActionSelectTextEnd:   ActionMoveTextEnd,
+	ActionSelectPageUp:    ActionMovePageUp,
+	ActionSelectPageDown:  ActionMovePageDown,
+}
+
+// DefaultKeymap returns a new keymap with the bindings of the platform,
+// MacKeymap on macOS and iOS and WindowsKeymap elsewhere.
+func DefaultKeymap() *Keymap {
+	switch runtime.GOOS {
+	case "darwin", "ios":
+		return MacKeymap()
+	default:
+		return WindowsKeymap()
+	}
+}
+
+// WindowsKeymap returns a new keymap with the bindings of Windows and most
+// Linux desktops.
+func WindowsKeymap() *Keymap {
+	k := new(Keymap)
+	k.bindCommon(key.ModCtrl, key.ModCtrl)
+	k.bind("Y", key.ModCtrl, ActionRedo)
+	return k
+}
+
+// MacKeymap returns a new keymap with the bindings of macOS, including its
+// Emacs-style Control bindings.
+func MacKeymap() *Keymap {
+	k := new(Keymap)
+	k.bindCommon(key.ModCommand, key.ModAlt)
+	k.bindMove(key.NameLeftArrow, key.ModCommand, ActionMoveLineStart, ActionSelectLineStart)
+	k.bindMove(key.NameRightArrow, key.ModCommand, ActionMoveLineEnd, ActionSelectLineEnd)
+	k.bindMove(key.NameUpArrow, key.ModCommand, ActionMoveTextStart, ActionSelectTextStart)
+	k.bindMove(key.NameDownArrow, key.ModCommand, ActionMoveTextEnd, ActionSelectTextEnd)
+	k.bindEmacs()
+	return k
+}
+
+// EmacsKeymap returns a new keymap with the bindings of WindowsKeymap
+// overridden by Emacs-style bindings, such as Control-A and Control-E for
+// moving to the start and end of the line, and Control-K for deleting to the
+// end of the line.
+func EmacsKeymap() *Keymap {
+	k := WindowsKeymap()
+	k.bindEmacs()
+	k.bindMove("F", key.ModAlt, ActionMoveWordRight, ActionSelectWordRight)
+	k.bindMove("B", key.ModAlt, ActionMoveWordLeft, ActionSelectWordLeft)
+	k.bind("D", key.ModAlt, ActionDeleteWordForward)
+	k.bind("Y", key.ModCtrl, ActionPaste)
+	k.bind("W", key.ModCtrl, ActionCut)
+	k.bind("W", key.ModAlt, ActionCopy)
+	k.bind("/", key.ModCtrl, ActionUndo)
+	return k
+}
+
+// bindCommon binds the keys common to all platforms, with shortcut as the
+// modifier of shortcuts such as copy and paste, and word as the modifier for
+// moving by words.
+func (k *Keymap) bindCommon(shortcut, word key.Modifiers) {
+	k.bindMove(key.NameLeftArrow, 0, ActionMoveLeft, ActionSelectLeft)
+	k.bindMove(key.NameRightArrow, 0, ActionMoveRight, ActionSelectRight)
+	k.bindMove(key.NameLeftArrow, word, ActionMoveWordLeft, ActionSelectWordLeft)
+	k.bindMove(key.NameRightArrow, word, ActionMoveWordRight, ActionSelectWordRight)
+	for _, mods := range []key.Modifiers{0, word} {
+		k.bindMove(key.NameUpArrow, mods, ActionMoveUp, ActionSelectUp)
+		k.bindMove(key.NameDownArrow, mods, ActionMoveDown, ActionSelectDown)
+	}
+	k.bindMove(key.NamePageUp, 0, ActionMovePageUp, ActionSelectPageUp)
+	k.bindMove(key.NamePageDown, 0, ActionMovePageDown, ActionSelectPageDown)
+	k.bindMove(key.NameHome, 0, ActionMoveLineStart, ActionSelectLineStart)
+	k.bindMove(key.NameEnd, 0, ActionMoveLineEnd, ActionSelectLineEnd)
+	k.bindMove(key.NameHome, shortcut, ActionMoveTextStart, ActionSelectTextStart)
+	k.bindMove(key.NameEnd, shortcut, ActionMoveTextEnd, ActionSelectTextEnd)
+
+	k.bindMove(key.NameDeleteBackward, 0, ActionDeleteBackward, ActionDeleteBackward)
+	k.bindMove(key.NameDeleteForward, 0, ActionDeleteForward, ActionDeleteForward)
+	k.bindMove(key.NameDeleteBackward, word, ActionDeleteWordBackward, ActionDeleteWordBackward)
+	k.bindMove(key.NameDeleteForward, word, ActionDeleteWordForward, ActionDeleteWordForward)
+	k.bindMove(key.NameEnter, 0, ActionInsertNewline, ActionInsertNewline)
+	k.bindMove(key.NameReturn, 0, ActionInsertNewline, ActionInsertNewline)
+
+	k.bind("C", shortcut, ActionCopy)
+	k.bind("X", shortcut, ActionCut)
+	k.bind("V", shortcut, ActionPaste)
+	k.bind("A", shortcut, ActionSelectAll)
+	k.bind("Z", shortcut, ActionUndo)
+	k.bind("Z", shortcut|key.ModShift, ActionRedo)
+	k.bind("D", shortcut, ActionSelectNextOccurrence)
+	k.bind(key.NameEscape, 0, ActionClearCarets)
+	k.bind(key.NameTab, 0, ActionAcceptSuggestion)
+}
+
+// bindEmacs binds the Emacs-style Control keys.
+func (k *Keymap) bindEmacs() {
+	k.bindMove("A", key.ModCtrl, ActionMoveLineStart, ActionSelectLineStart)
+	k.bindMove("E", key.ModCtrl, ActionMoveLineEnd, ActionSelectLineEnd)
+	k.bindMove("F", key.ModCtrl, ActionMoveRight, ActionSelectRight)
+	k.bindMove("B", key.ModCtrl, ActionMoveLeft, ActionSelectLeft)
+	k.bindMove("N", key.ModCtrl, ActionMoveDown, ActionSelectDown)
+	k.bindMove("P", key.ModCtrl, ActionMoveUp, ActionSelectUp)
+	k.bind("D", key.ModCtrl, ActionDeleteForward)
+	k.bind("H", key.ModCtrl, ActionDeleteBackward)
+	k.bind("K", key.ModCtrl, ActionDeleteLineEnd)
+}
+
+// Bind binds b to the action a, replacing the action it was bound to, if
+// any.
+func (k *Keymap) Bind(b KeyBinding, a Action) {
+	if k.bindings == nil {
+		k.bindings = make(map[KeyBinding]Action)
+	}
+	k.bindings[b] = a
+	k.gen++
+}
+
+// Unbind removes the binding b.
+func (k *Keymap) Unbind(b KeyBinding) {
+	delete(k.bindings, b)
+	k.gen++
+}
+
+// Action returns the action bound to b, if any.
+func (k *Keymap) Action(b KeyBinding) (Action, bool) {
+	a, ok := k.bindings[b]
+	return a, ok
+}
+
+func (k *Keymap) bind(name key.Name, mods key.Modifiers, a Action) {
+	k.Bind(KeyBinding{Name: name, Modifiers: mods}, a)
+}
+
+// bindMove binds name with mods to move, and with mods and Shift to sel.
+func (k *Keymap) bindMove(name key.Name, mods key.Modifiers, move, sel Action) {
+	k.bind(name, mods, move)
+	k.bind(name, mods|key.ModShift, sel)
+}
+
+// filters appends filters for the bindings accepted by accept to filters,
+// and returns the result.
+func (k *Keymap) filters(focus event.Tag, filters []event.Filter, accept func(b KeyBinding, a Action) bool) []event.Filter {
+	for b, a := range k.bindings {
+		if accept(b, a) {
+			filters = append(filters, key.Filter{Focus: focus, Name: b.Name, Required: b.Modifiers})
+		}
+	}
+	return filters
+}
+
+// keyFilters caches the event filters for a keymap.
+type keyFilters struct {
+	keymap  *Keymap
+	gen     int
+	conds   int
+	filters []event.Filter
+}
+
+// update returns the filters for k under the widget conditions conds,
+// rebuilding them with build if k, its bindings or conds changed.
+func (c *keyFilters) update(k *Keymap, conds int, build func(filters []event.Filter) []event.Filter) []event.Filter {
+	if c.filters == nil || k != c.keymap || k.gen != c.gen || conds != c.conds {
+		c.keymap, c.gen, c.conds = k, k.gen, conds
+		c.filters = build(c.filters[:0])
+	}
+	return c.filters
+}
+
+// movement resolves a movement action to the movement and whether it clears
+// or extends the selection. It reports whether a is a movement action.
+func movement(a Action) (Action, selectionAction, bool) {
+	if move, ok := selectActions[a]; ok {
+		return move, selectionExtend, true
+	}
+	for _, move := range selectActions {
+		if a == move {
+			return a, selectionClear, true
+		}
+	}
+	return "", 0, false
+}
+
+// moveText performs the movement move of the caret of t. Directions are
+// reversed for right-to-left text when direction is -1.
+func moveText(t *textView, move Action, selAct selectionAction, direction int) {
+	switch move {
+	case ActionMoveUp:
+		t.MoveLines(-1, selAct)
+	case ActionMoveDown:
+		t.MoveLines(+1, selAct)
+	case ActionMoveLeft:
+		if selAct == selectionClear {
+			t.ClearSelection()
+		}
+		t.MoveCaret(-1*direction, -1*direction*int(selAct))
+	case ActionMoveRight:
+		if selAct == selectionClear {
+			t.ClearSelection()
+		}
+		t.MoveCaret(1*direction, int(selAct)*direction)
+	case ActionMoveWordLeft:
+		t.MoveWord(-1*direction, selAct)
+	case ActionMoveWordRight:
+		t.MoveWord(1*direction, selAct)
+	case ActionMovePageUp:
+		t.MovePages(-1, selAct)
+	case ActionMovePageDown:
+		t.MovePages(+1, selAct)
+	case ActionMoveLineStart:
+		t.MoveLineStart(selAct)
+	case ActionMoveLineEnd:
+		t.MoveLineEnd(selAct)
+	case ActionMoveTextStart:
+		t.MoveTextStart(selAct)
+	case ActionMoveTextEnd:
+		t.MoveTextEnd(selAct)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"gioui.org/f32"
 	"gioui.org/font"
 	"gioui.org/io/semantic"
+	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -36,6 +37,11 @@ type Label struct {
 	// LineHeightScale applies a scaling factor to the LineHeight. If zero, a
 	// sensible default will be used.
 	LineHeightScale float32
+	// Orientation controls the orientation of glyphs when the text direction
+	// of the locale is vertical.
+	Orientation text.Orientation
+	// Decoration selects the lines, such as underlines, drawn along the text.
+	Decoration text.Decoration
 }
 
 // Layout the label with the given shaper, font, size, text, and material.
@@ -56,6 +62,11 @@ func (l Label) LayoutDetailed(gtx layout.Context, lt *text.Shaper, font font.Fon
 	cs := gtx.Constraints
 	textSize := fixed.I(gtx.Sp(size))
 	lineHeight := fixed.I(gtx.Sp(l.LineHeight))
+	minWidth, maxWidth := cs.Min.X, cs.Max.X
+	if gtx.Locale.Direction.Axis() == system.Vertical {
+		// Vertical lines are wrapped to the available height.
+		minWidth, maxWidth = cs.Min.Y, cs.Max.Y
+	}
 	lt.LayoutString(text.Parameters{
 		Font:            font,
 		PxPerEm:         textSize,
@@ -63,29 +74,38 @@ func (l Label) LayoutDetailed(gtx layout.Context, lt *text.Shaper, font font.Fon
 		Truncator:       l.Truncator,
 		Alignment:       l.Alignment,
 		WrapPolicy:      l.WrapPolicy,
-		MaxWidth:        cs.Max.X,
-		MinWidth:        cs.Min.X,
+		MaxWidth:        maxWidth,
+		MinWidth:        minWidth,
 		Locale:          gtx.Locale,
 		LineHeight:      lineHeight,
 		LineHeightScale: l.LineHeightScale,
+		Orientation:     l.Orientation,
 	}, txt)
 	m := op.Record(gtx.Ops)
 	viewport := image.Rectangle{Max: cs.Max}
 	it := textIterator{
-		viewport: viewport,
-		maxLines: l.MaxLines,
-		material: textMaterial,
+		viewport:   viewport,
+		maxLines:   l.MaxLines,
+		material:   textMaterial,
+		decoration: l.Decoration,
 	}
 	semantic.LabelOp(txt).Add(gtx.Ops)
 	var glyphs [32]text.Glyph
 	line := glyphs[:0]
 	for g, ok := lt.NextGlyph(); ok; g, ok = lt.NextGlyph() {
 		var ok bool
-		if line, ok = it.paintGlyph(gtx, lt, g, line); !ok {
+		if gtx.Measuring() {
+			// Measuring doesn't need the glyph outlines.
+			ok = it.processGlyph(g, true)
+		} else {
+			line, ok = it.paintGlyph(gtx, lt, g, line)
+		}
+		if !ok {
 			break
 		}
 	}
 	call := m.Stop()
+	viewport = it.viewport
 	viewport.Min = viewport.Min.Add(it.padding.Min)
 	viewport.Max = viewport.Max.Add(it.padding.Max)
 	clipStack := clip.Rect(viewport).Push(gtx.Ops)
@@ -93,6 +113,9 @@ func (l Label) LayoutDetailed(gtx layout.Context, lt *text.Shaper, font font.Fon
 	dims := layout.Dimensions{Size: it.bounds.Size()}
 	dims.Size = cs.Constrain(dims.Size)
 	dims.Baseline = dims.Size.Y - it.baseline
+	if it.lastBaseline != it.baseline {
+		dims.LastBaseline = dims.Size.Y - it.lastBaseline
+	}
 	clipStack.Pop()
 	return dims, TextInfo{Truncated: it.truncated}
 }
@@ -108,6 +131,8 @@ type textIterator struct {
 	// the color of the glyphs is undefined and may change unpredictably if the
 	// text contains color glyphs.
 	material op.CallOp
+	// decoration selects the lines drawn along the text with its material.
+	decoration text.Decoration
 	// truncated tracks the count of truncated runes in the text.
 	truncated int
 	// linesSeen tracks the quantity of line endings this iterator has seen.
@@ -124,8 +149,9 @@ type textIterator struct {
 	visible bool
 	// first tracks whether the iterator has processed a glyph yet.
 	first bool
-	// baseline tracks the location of the first line of text's baseline.
-	baseline int
+	// baseline tracks the location of the first line of text's baseline,
+	// and lastBaseline the baseline of the last visible line.
+	baseline, lastBaseline int
 }
 
 // processGlyph checks whether the glyph is visible within the iterator's configured
@@ -143,6 +169,9 @@ func (it *textIterator) processGlyph(g text.Glyph, ok bool) (visibleOrBefore boo
 			return false
 		}
 	}
+	if g.Flags&text.FlagVertical != 0 {
+		return it.processVerticalGlyph(g, ok)
+	}
 	// Compute the maximum extent to which glyphs overhang on the horizontal
 	// axis.
 	if d := g.Bounds.Min.X.Floor(); d < it.padding.Min.X {
@@ -173,6 +202,7 @@ func (it *textIterator) processGlyph(g text.Glyph, ok bool) (visibleOrBefore boo
 	if !it.first {
 		it.first = true
 		it.baseline = int(g.Y)
+		it.lastBaseline = it.baseline
 		it.bounds = logicalBounds
 	}
 
@@ -186,10 +216,60 @@ func (it *textIterator) processGlyph(g text.Glyph, ok bool) (visibleOrBefore boo
 		it.bounds.Min.Y = min(it.bounds.Min.Y, logicalBounds.Min.Y)
 		it.bounds.Max.X = max(it.bounds.Max.X, logicalBounds.Max.X)
 		it.bounds.Max.Y = max(it.bounds.Max.Y, logicalBounds.Max.Y)
+		it.lastBaseline = int(g.Y)
 	}
 	return ok && !below
 }
 
+// processVerticalGlyph is like processGlyph for glyphs of vertical lines,
+// whose dot is on the vertical baseline of their line.
+func (it *textIterator) processVerticalGlyph(g text.Glyph, ok bool) (visibleOrBefore bool) {
+	// Compute the maximum extent to which glyphs overhang the edges of their
+	// line and their logical height.
+	if d := (g.Bounds.Min.X + g.Descent).Floor(); d < it.padding.Min.X {
+		it.padding.Min.X = d
+	}
+	if d := (g.Bounds.Max.X - g.Ascent).Ceil(); d > it.padding.Max.X {
+		it.padding.Max.X = d
+	}
+	if d := g.Bounds.Min.Y.Floor(); d < it.padding.Min.Y {
+		it.padding.Min.Y = d
+	}
+	if d := (g.Bounds.Max.Y - g.Advance).Ceil(); d > it.padding.Max.Y {
+		it.padding.Max.Y = d
+	}
+	logicalBounds := image.Rectangle{
+		Min: image.Pt((g.X - g.Descent).Floor(), int(g.Y)),
+		Max: image.Pt((g.X + g.Ascent).Ceil(), int(g.Y)+g.Advance.Ceil()),
+	}
+	if !it.first {
+		it.first = true
+		it.baseline = int(g.Y)
+		it.lastBaseline = it.baseline
+		it.bounds = logicalBounds
+		// Lines stacked from right to left start at the right edge of the
+		// text. Keep the first line in view if the text is too wide.
+		if d := logicalBounds.Max.X - it.viewport.Max.X; d > 0 {
+			it.viewport = it.viewport.Add(image.Pt(d, 0))
+		}
+	}
+
+	above := logicalBounds.Max.Y < it.viewport.Min.Y
+	below := logicalBounds.Min.Y > it.viewport.Max.Y
+	left := logicalBounds.Max.X < it.viewport.Min.X
+	right := logicalBounds.Min.X > it.viewport.Max.X
+	it.visible = !above && !below && !left && !right
+	if it.visible {
+		it.bounds.Min.X = min(it.bounds.Min.X, logicalBounds.Min.X)
+		it.bounds.Min.Y = min(it.bounds.Min.Y, logicalBounds.Min.Y)
+		it.bounds.Max.X = max(it.bounds.Max.X, logicalBounds.Max.X)
+		it.bounds.Max.Y = max(it.bounds.Max.Y, logicalBounds.Max.Y)
+	}
+	// The first line is in view, so lines outside of the viewport follow
+	// the visible lines.
+	return ok && !left && !right
+}
+
 func fixedToFloat(i fixed.Int26_6) float32 {
 	return float32(i) / 64.0
 }
@@ -210,17 +290,29 @@ func (it *textIterator) paintGlyph(gtx layout.Context, shaper *text.Shaper, glyp
 		line = append(line, glyph)
 	}
 	if glyph.Flags&text.FlagLineBreak != 0 || cap(line)-len(line) == 0 || !visibleOrBefore {
-		t := op.Affine(f32.Affine2D{}.Offset(it.lineOff)).Push(gtx.Ops)
-		path := shaper.Shape(line)
-		outline := clip.Outline{Path: path}.Op().Push(gtx.Ops)
+		line = it.flush(gtx, shaper, line)
+	}
+	return line, visibleOrBefore
+}
+
+// flush paints the buffered glyphs of line, and returns the emptied line.
+func (it *textIterator) flush(gtx layout.Context, shaper *text.Shaper, line []text.Glyph) []text.Glyph {
+	t := op.Affine(f32.Affine2D{}.Offset(it.lineOff)).Push(gtx.Ops)
+	path := shaper.Shape(line)
+	outline := clip.Outline{Path: path}.Op().Push(gtx.Ops)
+	it.material.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	outline.Pop()
+	if it.decoration != 0 {
+		path := shaper.Decorations(line, it.decoration)
+		deco := clip.Outline{Path: path}.Op().Push(gtx.Ops)
 		it.material.Add(gtx.Ops)
 		paint.PaintOp{}.Add(gtx.Ops)
-		outline.Pop()
-		if call := shaper.Bitmaps(line); call != (op.CallOp{}) {
-			call.Add(gtx.Ops)
-		}
-		t.Pop()
-		line = line[:0]
+		deco.Pop()
 	}
-	return line, visibleOrBefore
+	if call := shaper.Bitmaps(line); call != (op.CallOp{}) {
+		call.Add(gtx.Ops)
+	}
+	t.Pop()
+	return line[:0]
 }
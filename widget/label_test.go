@@ -5,6 +5,10 @@ import (
 	"math"
 	"testing"
 
+	"gioui.org/font"
+	"gioui.org/font/gofont"
+	"gioui.org/layout"
+	"gioui.org/op"
 	"gioui.org/text"
 	"golang.org/x/image/math/fixed"
 )
@@ -24,7 +28,10 @@ func TestGlyphIterator(t *testing.T) {
 		viewport         image.Rectangle
 		expectedDims     image.Rectangle
 		expectedBaseline int
-		stopAtGlyph      int
+		// expectedLastBaseline is the baseline of the last visible line,
+		// if different from expectedBaseline.
+		expectedLastBaseline int
+		stopAtGlyph          int
 	}
 	for _, tc := range []testcase{
 		{
@@ -66,8 +73,9 @@ func TestGlyphIterator(t *testing.T) {
 			expectedDims: image.Rectangle{
 				Max: image.Point{X: 14, Y: 39},
 			},
-			expectedBaseline: fontSize,
-			stopAtGlyph:      4,
+			expectedBaseline:     fontSize,
+			expectedLastBaseline: 35,
+			stopAtGlyph:          4,
 		},
 		{
 			name:     "simple truncated",
@@ -98,8 +106,9 @@ func TestGlyphIterator(t *testing.T) {
 			expectedDims: image.Rectangle{
 				Max: image.Point{X: 12, Y: 39},
 			},
-			expectedBaseline: fontSize,
-			stopAtGlyph:      3,
+			expectedBaseline:     fontSize,
+			expectedLastBaseline: 35,
+			stopAtGlyph:          3,
 		},
 		{
 			name:     "multi-line with soft newline",
@@ -109,8 +118,9 @@ func TestGlyphIterator(t *testing.T) {
 			expectedDims: image.Rectangle{
 				Max: image.Point{X: 12, Y: 39},
 			},
-			expectedBaseline: fontSize,
-			stopAtGlyph:      2,
+			expectedBaseline:     fontSize,
+			expectedLastBaseline: 35,
+			stopAtGlyph:          2,
 		},
 		{
 			name:     "trailing hard newline",
@@ -121,8 +131,9 @@ func TestGlyphIterator(t *testing.T) {
 			expectedDims: image.Rectangle{
 				Max: image.Point{X: 14, Y: 39},
 			},
-			expectedBaseline: fontSize,
-			stopAtGlyph:      1,
+			expectedBaseline:     fontSize,
+			expectedLastBaseline: 35,
+			stopAtGlyph:          1,
 		},
 		{
 			name:     "truncated trailing hard newline",
@@ -160,6 +171,13 @@ func TestGlyphIterator(t *testing.T) {
 			if it.baseline != tc.expectedBaseline {
 				t.Errorf("expected baseline %d, got %d", tc.expectedBaseline, it.baseline)
 			}
+			last := tc.expectedLastBaseline
+			if last == 0 {
+				last = tc.expectedBaseline
+			}
+			if it.lastBaseline != last {
+				t.Errorf("expected last baseline %d, got %d", last, it.lastBaseline)
+			}
 		})
 	}
 }
@@ -211,6 +229,43 @@ func TestGlyphIteratorPadding(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "vertical",
+			glyph: text.Glyph{
+				X:       fixed.I(50),
+				Y:       0,
+				Advance: fixed.I(50),
+				Ascent:  fixed.I(25),
+				Descent: fixed.I(25),
+				Bounds: fixed.Rectangle26_6{
+					Min: fixed.Point26_6{
+						X: fixed.I(-30),
+						Y: fixed.I(-3),
+					},
+					Max: fixed.Point26_6{
+						X: fixed.I(28),
+						Y: fixed.I(54),
+					},
+				},
+				Flags: text.FlagVertical,
+			},
+			viewport: image.Rectangle{Max: image.Pt(math.MaxInt, math.MaxInt)},
+			expectedDims: image.Rectangle{
+				Min: image.Point{X: 25},
+				Max: image.Point{X: 75, Y: 50},
+			},
+			expectedBaseline: 0,
+			expectedPadding: image.Rectangle{
+				Min: image.Point{
+					X: -5,
+					Y: -3,
+				},
+				Max: image.Point{
+					X: 3,
+					Y: 4,
+				},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			it := textIterator{viewport: tc.viewport}
@@ -227,3 +282,21 @@ func TestGlyphIteratorPadding(t *testing.T) {
 		})
 	}
 }
+
+func TestLabelMeasure(t *testing.T) {
+	shaper := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(100, 1000)},
+	}
+	w := func(gtx layout.Context) layout.Dimensions {
+		return Label{}.Layout(gtx, shaper, font.Font{}, 16, "a label wrapped over several lines", op.CallOp{})
+	}
+	measured := gtx.Measure(w)
+	if dims := w(gtx); measured != dims {
+		t.Errorf("measured %+v, laid out %+v", measured, dims)
+	}
+	if measured.LastBaseline == 0 {
+		t.Error("the wrapped label has no last baseline")
+	}
+}
@@ -3,12 +3,16 @@
 package material
 
 import (
+	"image"
 	"image/color"
+	"strconv"
+	"strings"
 
 	"gioui.org/font"
 	"gioui.org/internal/f32color"
 	"gioui.org/layout"
 	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
@@ -28,11 +32,23 @@ type EditorStyle struct {
 	Color color.NRGBA
 	// Hint contains the text displayed when the editor is empty.
 	Hint string
-	// HintColor is the color of hint text.
+	// HintColor is the color of hint text and of the suggestion of the editor.
 	HintColor color.NRGBA
 	// SelectionColor is the color of the background for selected text.
 	SelectionColor color.NRGBA
-	Editor         *widget.Editor
+	// LineNumbers enables a gutter with the numbers of the logical lines of
+	// the text, beside the editor.
+	LineNumbers bool
+	// LineMarker, if set, enables the gutter and returns the marker of the
+	// logical line at index line, such as a breakpoint or a diff sign, or nil
+	// for no marker. Markers are laid out in a square as tall as a line.
+	LineMarker func(line int) layout.Widget
+	// LineNumberColor is the color of the line numbers.
+	LineNumberColor color.NRGBA
+	// CurrentLineColor is the color of the background for the line
+	// containing the caret, painted when the gutter is enabled.
+	CurrentLineColor color.NRGBA
+	Editor           *widget.Editor
 
 	shaper *text.Shaper
 }
@@ -49,6 +65,9 @@ func Editor(th *Theme, editor *widget.Editor, hint string) EditorStyle {
 		Hint:           hint,
 		HintColor:      f32color.MulAlpha(th.Palette.Fg, 0xbb),
 		SelectionColor: f32color.MulAlpha(th.Palette.ContrastBg, 0x60),
+
+		LineNumberColor:  f32color.MulAlpha(th.Palette.Fg, 0x80),
+		CurrentLineColor: f32color.MulAlpha(th.Palette.ContrastBg, 0x20),
 	}
 }
 
@@ -87,13 +106,108 @@ func (e EditorStyle) Layout(gtx layout.Context) layout.Dimensions {
 	}
 	e.Editor.LineHeight = e.LineHeight
 	e.Editor.LineHeightScale = e.LineHeightScale
-	dims = e.Editor.Layout(gtx, e.shaper, e.Font, e.TextSize, textColor, selectionColor)
+	if !e.LineNumbers && e.LineMarker == nil {
+		dims = e.Editor.Layout(gtx, e.shaper, e.Font, e.TextSize, textColor, selectionColor)
+		if e.Editor.Len() == 0 {
+			call.Add(gtx.Ops)
+		}
+		e.Editor.PaintSuggestion(gtx, hintColor)
+		return dims
+	}
+
+	// Reserve space for the gutter, and lay out the editor beside it.
+	g := e.gutter(gtx)
+	width := g.width()
+	egtx := gtx
+	egtx.Constraints.Max.X = max(0, gtx.Constraints.Max.X-width)
+	egtx.Constraints.Min.X = max(0, gtx.Constraints.Min.X-width)
+	macro = op.Record(gtx.Ops)
+	trans := op.Offset(image.Pt(width, 0)).Push(gtx.Ops)
+	dims = e.Editor.Layout(egtx, e.shaper, e.Font, e.TextSize, textColor, selectionColor)
 	if e.Editor.Len() == 0 {
 		call.Add(gtx.Ops)
 	}
+	e.Editor.PaintSuggestion(egtx, hintColor)
+	trans.Pop()
+	editor := macro.Stop()
+
+	dims.Size.X += width
+	defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+	g.lineWidth = dims.Size.X
+	e.Editor.LayoutGutter(gtx, g.layoutLine)
+	editor.Add(gtx.Ops)
 	return dims
 }
 
+// gutter lays out the lines of the gutter of an editor.
+type gutter struct {
+	style EditorStyle
+	// numberWidth is the width of the widest line number, and markerSize
+	// the size of markers.
+	numberWidth, markerSize int
+	padding                 int
+	// lineWidth is the width of the highlight of the current line.
+	lineWidth int
+}
+
+// gutter measures the gutter for the lines of the editor.
+func (e EditorStyle) gutter(gtx layout.Context) gutter {
+	g := gutter{style: e, padding: gtx.Dp(8)}
+	gtx.Constraints.Min = image.Point{}
+	digits := strings.Repeat("0", len(strconv.Itoa(e.Editor.LineCount())))
+	dims := gtx.Measure(func(gtx layout.Context) layout.Dimensions {
+		return widget.Label{MaxLines: 1}.Layout(gtx, e.shaper, e.Font, e.TextSize, digits, op.CallOp{})
+	})
+	if e.LineNumbers {
+		g.numberWidth = dims.Size.X
+	}
+	if e.LineMarker != nil {
+		g.markerSize = dims.Size.Y
+	}
+	return g
+}
+
+func (g gutter) width() int {
+	return g.markerSize + g.numberWidth + g.padding
+}
+
+func (g gutter) layoutLine(gtx layout.Context, l widget.EditorLine) layout.Dimensions {
+	e := g.style
+	if l.Current {
+		rect := clip.Rect{Max: image.Pt(g.lineWidth, l.Bottom-l.Top)}
+		paint.FillShape(gtx.Ops, e.CurrentLineColor, rect.Op())
+	}
+	if g.markerSize > 0 {
+		if marker := e.LineMarker(l.Index); marker != nil {
+			mgtx := gtx
+			mgtx.Constraints = layout.Exact(image.Pt(g.markerSize, g.markerSize))
+			marker(mgtx)
+		}
+	}
+	if g.numberWidth > 0 {
+		c := e.LineNumberColor
+		if l.Current {
+			c = e.Color
+		}
+		colorMacro := op.Record(gtx.Ops)
+		paint.ColorOp{Color: c}.Add(gtx.Ops)
+		material := colorMacro.Stop()
+		ngtx := gtx
+		ngtx.Constraints.Min = image.Pt(g.numberWidth, 0)
+		ngtx.Constraints.Max.X = g.numberWidth
+		macro := op.Record(gtx.Ops)
+		dims := widget.Label{Alignment: text.End, MaxLines: 1, LineHeight: e.LineHeight, LineHeightScale: e.LineHeightScale}.
+			Layout(ngtx, e.shaper, e.Font, e.TextSize, strconv.Itoa(l.Index+1), material)
+		number := macro.Stop()
+		// Align the baselines of the number and the line.
+		y := l.Baseline - l.Top - (dims.Size.Y - dims.Baseline)
+		trans := op.Offset(image.Pt(g.markerSize, y)).Push(gtx.Ops)
+		number.Add(gtx.Ops)
+		trans.Pop()
+	}
+	return layout.Dimensions{Size: image.Pt(g.width(), l.Bottom-l.Top)}
+}
+
 func blendDisabledColor(disabled bool, c color.NRGBA) color.NRGBA {
 	if disabled {
 		return f32color.Disabled(c)
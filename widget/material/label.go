@@ -31,6 +31,8 @@ type LabelStyle struct {
 	MaxLines int
 	// WrapPolicy configures how displayed text will be broken into lines.
 	WrapPolicy text.WrapPolicy
+	// Decoration selects the lines, such as underlines, drawn along the text.
+	Decoration text.Decoration
 	// Truncator is the text that will be shown at the end of the final
 	// line if MaxLines is exceeded. Defaults to "…" if empty.
 	Truncator string
@@ -44,6 +46,10 @@ type LabelStyle struct {
 	// LineHeightScale applies a scaling factor to the LineHeight. If zero, a
 	// sensible default will be used.
 	LineHeightScale float32
+	// Orientation controls the orientation of glyphs when the text direction
+	// of the locale is vertical. Selectable labels don't support vertical
+	// text.
+	Orientation text.Orientation
 
 	// Shaper is the text shaper used to display this labe. This field is automatically
 	// set using by all constructor functions. If constructing a LabelStyle literal, you
@@ -138,6 +144,7 @@ func (l LabelStyle) Layout(gtx layout.Context) layout.Dimensions {
 		l.State.MaxLines = l.MaxLines
 		l.State.Truncator = l.Truncator
 		l.State.WrapPolicy = l.WrapPolicy
+		l.State.Decoration = l.Decoration
 		l.State.LineHeight = l.LineHeight
 		l.State.LineHeightScale = l.LineHeightScale
 		return l.State.Layout(gtx, l.Shaper, l.Font, l.TextSize, textColor, selectColor)
@@ -149,6 +156,8 @@ func (l LabelStyle) Layout(gtx layout.Context) layout.Dimensions {
 		WrapPolicy:      l.WrapPolicy,
 		LineHeight:      l.LineHeight,
 		LineHeightScale: l.LineHeightScale,
+		Orientation:     l.Orientation,
+		Decoration:      l.Decoration,
 	}
 	return tl.Layout(gtx, l.Shaper, l.Font, l.TextSize, l.Text, textColor)
 }
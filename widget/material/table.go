@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package material
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/f32"
+	"gioui.org/internal/f32color"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+)
+
+// TableStyle defines the presentation of a table.
+type TableStyle struct {
+	Table *widget.Table
+	// RowHeight and HeaderHeight are the default heights of the rows and of
+	// the header row, used unless set in Table.
+	RowHeight, HeaderHeight unit.Dp
+	// Inset is the padding of the headers and cells.
+	Inset layout.Inset
+	// HeaderColor is the background color of the header row.
+	HeaderColor color.NRGBA
+	// IndicatorColor is the color of the sort indicators.
+	IndicatorColor color.NRGBA
+	// SelectionColor is the background color of the selected rows.
+	SelectionColor color.NRGBA
+	// FocusColor is the color of the outline of the cursor row, drawn when
+	// the table is focused.
+	FocusColor color.NRGBA
+	// DividerColor is the color of the lines between the rows and the
+	// headers.
+	DividerColor color.NRGBA
+}
+
+// Table returns a TableStyle for table.
+func Table(th *Theme, table *widget.Table) TableStyle {
+	return TableStyle{
+		Table:          table,
+		RowHeight:      32,
+		HeaderHeight:   40,
+		Inset:          layout.Inset{Left: 8, Right: 8},
+		HeaderColor:    f32color.MulAlpha(th.Palette.Fg, 0x10),
+		IndicatorColor: f32color.MulAlpha(th.Palette.Fg, 0xaa),
+		SelectionColor: f32color.MulAlpha(th.Palette.ContrastBg, 0x60),
+		FocusColor:     th.Palette.ContrastBg,
+		DividerColor:   f32color.MulAlpha(th.Palette.Fg, 0x30),
+	}
+}
+
+// Layout a table of rows, where header lays out the content of the header of
+// a column and cell the content of a cell.
+func (t TableStyle) Layout(gtx layout.Context, rows int, header widget.TableHeader, cell layout.GridCell) layout.Dimensions {
+	tbl := t.Table
+	if tbl.RowHeight == 0 {
+		tbl.RowHeight = t.RowHeight
+	}
+	if tbl.HeaderHeight == 0 {
+		tbl.HeaderHeight = t.HeaderHeight
+	}
+	return tbl.Layout(gtx, rows, func(gtx layout.Context, col int) layout.Dimensions {
+		size := gtx.Constraints.Min
+		paint.FillShape(gtx.Ops, t.HeaderColor, clip.Rect{Max: size}.Op())
+		t.divider(gtx, image.Rect(0, size.Y-1, size.X, size.Y))
+		t.divider(gtx, image.Rect(size.X-1, 0, size.X, size.Y))
+		inset := t.Inset
+		if s := tbl.Sorting; s.Column == col && s.Order != widget.Unsorted {
+			// Make room for the sort indicator.
+			d := gtx.Dp(t.Inset.Right)
+			w := gtx.Sp(unit.Sp(8))
+			t.sortIndicator(gtx, image.Pt(size.X-d-w, size.Y/2), w, s.Order)
+			inset.Right += gtx.Metric.PxToDp(w + d)
+		}
+		return inset.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.W.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return header(gtx, col)
+			})
+		})
+	}, func(gtx layout.Context, row, col int) layout.Dimensions {
+		size := gtx.Constraints.Min
+		if tbl.Selected(row) {
+			paint.FillShape(gtx.Ops, t.SelectionColor, clip.Rect{Max: size}.Op())
+		}
+		t.divider(gtx, image.Rect(0, size.Y-1, size.X, size.Y))
+		dims := t.Inset.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return layout.W.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return cell(gtx, row, col)
+			})
+		})
+		if tbl.Focused() && row == tbl.Cursor() {
+			w := max(gtx.Dp(1), 1)
+			paint.FillShape(gtx.Ops, t.FocusColor, clip.Rect{Max: image.Pt(size.X, w)}.Op())
+			paint.FillShape(gtx.Ops, t.FocusColor, clip.Rect{Min: image.Pt(0, size.Y-w), Max: size}.Op())
+		}
+		return dims
+	})
+}
+
+// divider paints a divider line in r.
+func (t TableStyle) divider(gtx layout.Context, r image.Rectangle) {
+	paint.FillShape(gtx.Ops, t.DividerColor, clip.Rect(r).Op())
+}
+
+// sortIndicator paints a triangle of width w pointing up for ascending order
+// and down for descending order, with its left corner at pos.
+func (t TableStyle) sortIndicator(gtx layout.Context, pos image.Point, w int, order widget.SortOrder) {
+	defer op.Offset(pos).Push(gtx.Ops).Pop()
+	fw := float32(w)
+	tip, base := -fw/4, fw/4
+	if order == widget.Descending {
+		tip, base = base, tip
+	}
+	var p clip.Path
+	p.Begin(gtx.Ops)
+	p.MoveTo(f32.Pt(0, base))
+	p.LineTo(f32.Pt(fw, base))
+	p.LineTo(f32.Pt(fw/2, tip))
+	p.Close()
+	paint.FillShape(gtx.Ops, t.IndicatorColor, clip.Outline{Path: p.End()}.Op())
+}
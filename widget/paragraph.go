@@ -0,0 +1,420 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"io"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"gioui.org/io/system"
+	"gioui.org/layout"
+	"gioui.org/text"
+)
+
+// maxShapedParagraphs is the number of paragraph layouts a textView keeps
+// before releasing those far from its viewport.
+const maxShapedParagraphs = 1024
+
+// paragraph describes a range of text ending after a newline or at the end of
+// the text, laid out independently of the rest of the text. When the text
+// can't be laid out incrementally, a single paragraph covers all of it.
+//
+// Paragraphs are shaped on demand. Until then, they are assumed to have the
+// layout of an empty line. The coordinates of a paragraph are relative to its
+// own layout, which is placed in the text by its offsets.
+type paragraph struct {
+	// bytes and runes measure the text of the paragraph.
+	bytes, runes int
+	// byteOff and runeOff locate the start of the paragraph in the text.
+	byteOff, runeOff int
+	// lineOff is the index of the first line of the paragraph in the text,
+	// and yOff the vertical offset of its layout.
+	lineOff, yOff int
+	// lines is the number of lines of the paragraph.
+	lines int
+	// firstY and lastY are the baselines of the first and last lines of the
+	// paragraph, and bottom the bottom of the last line.
+	firstY, lastY, bottom int
+	// bounds is the logical bounding box of the paragraph.
+	bounds image.Rectangle
+	// layout is the shaped layout of the paragraph, or nil if the paragraph
+	// has not been shaped yet or its layout was released. Released paragraphs
+	// keep their metrics.
+	layout *paragraphLayout
+}
+
+// paragraphLayout is the shaped layout of a paragraph.
+type paragraphLayout struct {
+	index glyphIndex
+	// graphemes holds the indices of the grapheme cluster boundaries of the
+	// paragraph.
+	graphemes []int
+	// styles holds the styled spans of the paragraph.
+	styles []styleSpan
+}
+
+// toText converts a position within the paragraph to a position within the
+// text.
+func (p *paragraph) toText(pos combinedPos) combinedPos {
+	pos.runes += p.runeOff
+	pos.lineCol.line += p.lineOff
+	pos.y += p.yOff
+	return pos
+}
+
+// incremental reports whether the paragraphs of the text are laid out
+// independently. Truncated text, vertical text, text whose alignment depends
+// on the width of its widest line and text without a shaper are laid out as a
+// whole.
+func (e *textView) incremental() bool {
+	p := e.params
+	// Text aligned to its start is placed at the origin, regardless of the
+	// width of the text.
+	atStart := p.Alignment.Align(p.Locale.Direction, 0, 1) == 0
+	return e.shaper != nil && atStart && p.MaxLines == 0 && p.Locale.Direction.Axis() == system.Horizontal
+}
+
+// resetParagraphs splits the text into unshaped paragraphs.
+func (e *textView) resetParagraphs() {
+	e.measureEmptyLine()
+	e.paragraphs = e.splitParagraphs(e.paragraphs[:0], 0, e.rr.Size())
+	if len(e.paragraphs) == 0 {
+		e.paragraphs = append(e.paragraphs, e.emptyLine)
+	}
+	e.shaped = 0
+	e.updateRuneOffsets(0)
+	e.invalidateOffsets(0)
+}
+
+// measureEmptyLine lays out empty paragraphs to determine the layout assumed
+// for unshaped paragraphs, and the distance between the last line of a
+// paragraph and the first line of the next.
+func (e *textView) measureEmptyLine() {
+	e.emptyLine = paragraph{lines: 1}
+	e.lineAdvance = 0
+	lt := e.shaper
+	if lt == nil {
+		return
+	}
+	lt.LayoutString(e.params, "\n\n")
+	g, ok := lt.NextGlyph()
+	if !ok {
+		return
+	}
+	y := int(g.Y)
+	e.emptyLine.firstY = y
+	e.emptyLine.lastY = y
+	e.emptyLine.bottom = y + g.Descent.Round()
+	e.emptyLine.bounds = image.Rect(0, y-g.Ascent.Ceil(), 0, y+g.Descent.Ceil())
+	for g, ok := lt.NextGlyph(); ok; g, ok = lt.NextGlyph() {
+		if g.Flags&text.FlagParagraphStart != 0 {
+			e.lineAdvance = int(g.Y) - y
+			break
+		}
+	}
+}
+
+// splitParagraphs appends the unshaped paragraphs of the size bytes of text
+// at offset off to ps. The text must start a paragraph, and a trailing
+// paragraph is only appended if it is non-empty.
+func (e *textView) splitParagraphs(ps []paragraph, off, size int64) []paragraph {
+	incremental := e.incremental()
+	p := e.emptyLine
+	var buf [4096]byte
+	for end := off + size; off < end; {
+		n, _ := e.rr.ReadAt(buf[:min(int64(len(buf)), end-off)], off)
+		if n == 0 {
+			break
+		}
+		b := buf[:n]
+		more := off+int64(n) < end
+		for len(b) > 0 {
+			if more && !utf8.FullRune(b) {
+				// Decode the rune split by the end of the buffer from the
+				// next read.
+				break
+			}
+			r, s := utf8.DecodeRune(b)
+			b = b[s:]
+			off += int64(s)
+			p.bytes += s
+			p.runes++
+			if r == '\n' && incremental {
+				ps = append(ps, p)
+				p = e.emptyLine
+			}
+		}
+	}
+	if p.bytes > 0 {
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// spliceParagraphs updates the paragraphs after the text between the byte
+// offsets start and end was replaced with text ending at newEnd.
+func (e *textView) spliceParagraphs(start, end, newEnd int64) {
+	i := e.paragraphAtByte(start)
+	j := e.paragraphAtByte(end)
+	first, last := e.paragraphs[i], e.paragraphs[j]
+	from := int64(first.byteOff)
+	size := int64(last.byteOff+last.bytes) + newEnd - end - from
+	var ps []paragraph
+	ps = e.splitParagraphs(ps, from, size)
+	for k := i; k <= j; k++ {
+		if e.paragraphs[k].layout != nil {
+			e.shaped--
+		}
+	}
+	e.paragraphs = append(e.paragraphs[:i], append(ps, e.paragraphs[j+1:]...)...)
+	if len(e.paragraphs) == 0 {
+		e.paragraphs = append(e.paragraphs, e.emptyLine)
+	}
+	e.updateRuneOffsets(i)
+	e.invalidateOffsets(i)
+}
+
+// updateRuneOffsets updates the byte and rune offsets of the paragraphs
+// starting at index i.
+func (e *textView) updateRuneOffsets(i int) {
+	for ; i < len(e.paragraphs); i++ {
+		p := &e.paragraphs[i]
+		p.byteOff, p.runeOff = 0, 0
+		if i > 0 {
+			prev := &e.paragraphs[i-1]
+			p.byteOff = prev.byteOff + prev.bytes
+			p.runeOff = prev.runeOff + prev.runes
+		}
+	}
+}
+
+// invalidateOffsets marks the line and vertical offsets of the paragraphs
+// starting at index i out of date.
+func (e *textView) invalidateOffsets(i int) {
+	e.dirtyFrom = min(e.dirtyFrom, i)
+	e.dimsValid = false
+}
+
+// updateOffsets brings the line and vertical offsets of the paragraphs up to
+// and including the paragraph at index to up to date.
+func (e *textView) updateOffsets(to int) {
+	i := e.dirtyFrom
+	for ; i <= to && i < len(e.paragraphs); i++ {
+		p := &e.paragraphs[i]
+		if i == 0 {
+			p.lineOff, p.yOff = 0, 0
+			continue
+		}
+		prev := &e.paragraphs[i-1]
+		p.lineOff = prev.lineOff + prev.lines
+		p.yOff = prev.yOff + prev.lastY + e.lineAdvance - p.firstY
+	}
+	e.dirtyFrom = i
+}
+
+// paragraphAtByte returns the index of the paragraph containing the byte at
+// offset off.
+func (e *textView) paragraphAtByte(off int64) int {
+	i := sort.Search(len(e.paragraphs), func(i int) bool {
+		p := &e.paragraphs[i]
+		return int64(p.byteOff+p.bytes) > off
+	})
+	return min(i, len(e.paragraphs)-1)
+}
+
+// paragraphAtRune returns the index of the paragraph containing the rune at
+// index r.
+func (e *textView) paragraphAtRune(r int) int {
+	i := sort.Search(len(e.paragraphs), func(i int) bool {
+		p := &e.paragraphs[i]
+		return p.runeOff+p.runes > r
+	})
+	return min(i, len(e.paragraphs)-1)
+}
+
+// paragraphAtLine returns the index of the paragraph containing the line at
+// index line.
+func (e *textView) paragraphAtLine(line int) int {
+	e.updateOffsets(len(e.paragraphs))
+	i := sort.Search(len(e.paragraphs), func(i int) bool {
+		p := &e.paragraphs[i]
+		return p.lineOff+p.lines > line
+	})
+	return min(i, len(e.paragraphs)-1)
+}
+
+// paragraphAtY returns the index of the first paragraph extending below y.
+func (e *textView) paragraphAtY(y int) int {
+	e.updateOffsets(len(e.paragraphs))
+	i := sort.Search(len(e.paragraphs), func(i int) bool {
+		p := &e.paragraphs[i]
+		return p.yOff+p.bottom >= y
+	})
+	return min(i, len(e.paragraphs)-1)
+}
+
+// paragraphLayout returns the layout of the paragraph at index i, shaping the
+// paragraph if necessary.
+func (e *textView) paragraphLayout(i int) *paragraphLayout {
+	p := &e.paragraphs[i]
+	if p.layout != nil {
+		return p.layout
+	}
+	e.updateOffsets(i)
+	// Keep the text in the viewport in place when the height of a paragraph
+	// above it changes.
+	above := !e.SingleLine && p.yOff+p.bottom <= e.scrollOff.Y
+	old := *p
+
+	if cap(e.paragraphBuf) < p.bytes {
+		e.paragraphBuf = make([]byte, p.bytes)
+	}
+	buf := e.paragraphBuf[:p.bytes]
+	n, _ := e.rr.ReadAt(buf, int64(p.byteOff))
+	buf = buf[:n]
+	e.paragraphText.Reset(buf)
+	var r io.Reader = &e.paragraphText
+	if e.Mask != 0 {
+		e.maskReader.Reset(r, e.Mask)
+		r = &e.maskReader
+	}
+	l := new(paragraphLayout)
+	l.styles = e.paragraphStyles(i, buf, nil)
+	params := e.params
+	if spans := fontSpans(params.Font, l.styles); len(spans) > 0 {
+		params.FontSpans = spans
+	}
+	it := textIterator{viewport: image.Rectangle{Max: image.Point{X: math.MaxInt, Y: math.MaxInt}}}
+	// The layout of a paragraph ends with the empty line started by its
+	// newline, which is the first line of the next paragraph unless this is
+	// the last one.
+	last := i == len(e.paragraphs)-1
+	if lt := e.shaper; lt != nil {
+		lt.Layout(params, r)
+		for {
+			g, ok := lt.NextGlyph()
+			if ok && !last && g.Flags&text.FlagParagraphStart != 0 {
+				continue
+			}
+			if !it.processGlyph(g, ok) {
+				break
+			}
+			l.index.Glyph(g)
+		}
+	} else {
+		// Make a fake glyph for every rune in the paragraph.
+		for b := buf; len(b) > 0; {
+			_, s := utf8.DecodeRune(b)
+			b = b[s:]
+			g := text.Glyph{Runes: 1, Flags: text.FlagClusterBreak}
+			_ = it.processGlyph(g, true)
+			l.index.Glyph(g)
+		}
+	}
+	e.paragraphText.Reset(buf)
+	e.paragraphReader.SetSource(&e.paragraphText)
+	for g := e.paragraphReader.Graphemes(); len(g) > 0; g = e.paragraphReader.Graphemes() {
+		if len(l.graphemes) > 0 && g[0] == l.graphemes[len(l.graphemes)-1] {
+			g = g[1:]
+		}
+		l.graphemes = append(l.graphemes, g...)
+	}
+
+	p.layout = l
+	e.shaped++
+	p.lines = len(l.index.lines)
+	p.bounds = it.bounds
+	p.firstY, p.lastY, p.bottom = it.baseline, it.baseline, it.baseline
+	if n := len(l.index.lines); n > 0 {
+		line := l.index.lines[n-1]
+		p.lastY = line.yOff
+		p.bottom = line.yOff + line.descent.Round()
+	}
+	switch {
+	case p.firstY != old.firstY:
+		e.invalidateOffsets(i)
+	case p.lines != old.lines || p.lastY != old.lastY:
+		e.invalidateOffsets(i + 1)
+	}
+	e.dimsValid = false
+	if above {
+		e.scrollOff.Y += (p.lastY - p.firstY) - (old.lastY - old.firstY)
+	}
+	return l
+}
+
+// layoutVisible shapes the paragraphs within the viewport, and within a
+// viewport height above and below it.
+func (e *textView) layoutVisible() {
+	margin := e.viewSize.Y
+	minY, maxY := e.scrollOff.Y-margin, e.scrollOff.Y+e.viewSize.Y+margin
+	for {
+		first, last := 0, len(e.paragraphs)-1
+		if !e.SingleLine {
+			first = e.paragraphAtY(minY)
+			last = e.paragraphAtY(maxY)
+		}
+		shaped := false
+		for i := first; i <= last; i++ {
+			if e.paragraphs[i].layout == nil {
+				e.paragraphLayout(i)
+				shaped = true
+			}
+		}
+		if !shaped {
+			e.releaseParagraphs(first, last)
+			return
+		}
+	}
+}
+
+// releaseParagraphs releases the layouts of the paragraphs outside the range
+// from first to last that don't contain the caret, if too many paragraphs are
+// shaped.
+func (e *textView) releaseParagraphs(first, last int) {
+	if e.shaped <= maxShapedParagraphs {
+		return
+	}
+	start, end := e.paragraphAtRune(e.caret.start), e.paragraphAtRune(e.caret.end)
+	for i := range e.paragraphs {
+		p := &e.paragraphs[i]
+		if p.layout == nil || (i >= first && i <= last) || i == start || i == end {
+			continue
+		}
+		p.layout = nil
+		e.shaped--
+	}
+}
+
+// updateDims computes the dimensions of the text from the metrics of its
+// paragraphs.
+func (e *textView) updateDims() {
+	if e.dimsValid {
+		return
+	}
+	e.updateOffsets(len(e.paragraphs))
+	var bounds image.Rectangle
+	for i := range e.paragraphs {
+		p := &e.paragraphs[i]
+		b := p.bounds.Add(image.Pt(0, p.yOff))
+		if i == 0 {
+			bounds = b
+			continue
+		}
+		bounds.Min.X = min(bounds.Min.X, b.Min.X)
+		bounds.Min.Y = min(bounds.Min.Y, b.Min.Y)
+		bounds.Max.X = max(bounds.Max.X, b.Max.X)
+		bounds.Max.Y = max(bounds.Max.Y, b.Max.Y)
+	}
+	dims := layout.Dimensions{Size: bounds.Size()}
+	dims.Baseline = dims.Size.Y - e.paragraphs[0].firstY
+	last := e.paragraphs[len(e.paragraphs)-1]
+	if lastY := last.yOff + last.lastY; lastY != e.paragraphs[0].firstY {
+		dims.LastBaseline = dims.Size.Y - lastY
+	}
+	e.dims = dims
+	e.dimsValid = true
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+)
+
+// PieceTable is a TextStorage suited to large texts. It never modifies the
+// original text, and reads it only on demand. Edits are recorded as a
+// sequence of pieces, each a span of either the original text or of an
+// append-only buffer of inserted text. Opening a text is thus free of copying,
+// and the cost of an edit is proportional to the number of earlier edits
+// rather than to the size of the text.
+type PieceTable struct {
+	original io.ReaderAt
+	added    []byte
+	pieces   []piece
+
+	// changed tracks whether the text has changed since the last call to
+	// Changed.
+	changed bool
+}
+
+// piece is a span of text in the original or added buffer.
+type piece struct {
+	added bool
+	// off and len locate the span in its buffer.
+	off, len int64
+	// end is the offset of the end of the piece in the text.
+	end int64
+}
+
+var _ TextStorage = (*PieceTable)(nil)
+
+// NewPieceTable returns a PieceTable for the size bytes of original text,
+// which must not change while in use by the table. An *os.File opened for
+// reading is a suitable original.
+func NewPieceTable(original io.ReaderAt, size int64) *PieceTable {
+	p := &PieceTable{original: original}
+	if size > 0 {
+		p.pieces = append(p.pieces, piece{len: size, end: size})
+	}
+	return p
+}
+
+func (p *PieceTable) Changed() bool {
+	c := p.changed
+	p.changed = false
+	return c
+}
+
+func (p *PieceTable) Size() int64 {
+	if len(p.pieces) == 0 {
+		return 0
+	}
+	return p.pieces[len(p.pieces)-1].end
+}
+
+// find returns the index of the piece containing the text at offset off.
+func (p *PieceTable) find(off int64) int {
+	return sort.Search(len(p.pieces), func(i int) bool {
+		return p.pieces[i].end > off
+	})
+}
+
+func (p *PieceTable) ReadAt(b []byte, off int64) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	var total int
+	for i := p.find(off); i < len(p.pieces) && len(b) > 0; i++ {
+		pc := p.pieces[i]
+		start := off - (pc.end - pc.len)
+		n := int(min(int64(len(b)), pc.len-start))
+		if pc.added {
+			copy(b, p.added[pc.off+start:pc.off+start+int64(n)])
+		} else if m, err := p.original.ReadAt(b[:n], pc.off+start); m < n {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return total + m, err
+		}
+		b = b[n:]
+		off += int64(n)
+		total += n
+	}
+	if len(b) > 0 {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+func (p *PieceTable) ReplaceRunes(byteOffset, runeCount int64, s string) {
+	if n := p.runeBytes(byteOffset, runeCount); n > 0 {
+		p.delete(byteOffset, n)
+	}
+	if len(s) > 0 {
+		p.insert(byteOffset, s)
+	}
+}
+
+// runeBytes returns the length in bytes of the count runes starting at
+// offset off.
+func (p *PieceTable) runeBytes(off, count int64) int64 {
+	var buf [4096]byte
+	var n int64
+	for count > 0 {
+		m, _ := p.ReadAt(buf[:], off+n)
+		if m == 0 {
+			break
+		}
+		b := buf[:m]
+		for count > 0 && len(b) > 0 {
+			if m == len(buf) && !utf8.FullRune(b) {
+				// Decode the rune split by the end of the buffer from the
+				// next read.
+				break
+			}
+			_, s := utf8.DecodeRune(b)
+			b = b[s:]
+			n += int64(s)
+			count--
+		}
+	}
+	return n
+}
+
+// split ensures that a piece starts at offset off, and returns its index.
+func (p *PieceTable) split(off int64) int {
+	i := p.find(off)
+	if i == len(p.pieces) {
+		return i
+	}
+	pc := p.pieces[i]
+	start := pc.end - pc.len
+	if start == off {
+		return i
+	}
+	head, tail := pc, pc
+	head.len = off - start
+	head.end = off
+	tail.off += head.len
+	tail.len -= head.len
+	p.pieces = append(p.pieces, piece{})
+	copy(p.pieces[i+2:], p.pieces[i+1:])
+	p.pieces[i], p.pieces[i+1] = head, tail
+	return i + 1
+}
+
+func (p *PieceTable) delete(off, n int64) {
+	i := p.split(off)
+	j := p.split(off + n)
+	p.pieces = append(p.pieces[:i], p.pieces[j:]...)
+	p.updateEnds(i)
+	p.changed = true
+}
+
+func (p *PieceTable) insert(off int64, s string) {
+	if !utf8.ValidString(s) {
+		s = runes.ReplaceIllFormed().String(s)
+	}
+	i := p.split(off)
+	n := int64(len(s))
+	if i > 0 {
+		if prev := &p.pieces[i-1]; prev.added && prev.off+prev.len == int64(len(p.added)) {
+			// Extend the piece of the previous insertion, as when typing.
+			p.added = append(p.added, s...)
+			prev.len += n
+			p.updateEnds(i - 1)
+			p.changed = true
+			return
+		}
+	}
+	pc := piece{added: true, off: int64(len(p.added)), len: n}
+	p.added = append(p.added, s...)
+	p.pieces = append(p.pieces, piece{})
+	copy(p.pieces[i+1:], p.pieces[i:])
+	p.pieces[i] = pc
+	p.updateEnds(i)
+	p.changed = true
+}
+
+// updateEnds recomputes the text offsets of the pieces from index i.
+func (p *PieceTable) updateEnds(i int) {
+	var end int64
+	if i > 0 {
+		end = p.pieces[i-1].end
+	}
+	for ; i < len(p.pieces); i++ {
+		end += p.pieces[i].len
+		p.pieces[i].end = end
+	}
+}
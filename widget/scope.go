@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"slices"
+	"strings"
+)
+
+// SelectionScope groups Selectables whose text is selected together, such as
+// the paragraphs of a document. Dragging from one Selectable of a scope over
+// others extends the selection across them in the order of their
+// ScopeIndex, and copying yields the selected text of all of them.
+//
+// The scope knows the Selectables laid out at least once, so a selection
+// survives its Selectables scrolling out of view in a List as long as each
+// index keeps its Selectable.
+type SelectionScope struct {
+	// Separator joins the selected texts of the Selectables when copied. If
+	// empty, "\n" is used.
+	Separator string
+
+	// members maps the indices of the Selectables laid out so far to them.
+	members map[int]*Selectable
+	// anchor is the Selectable where the selection started and focus the
+	// Selectable it was last extended to, with their indices at the time.
+	anchor, focus           *Selectable
+	anchorIndex, focusIndex int
+	// pressAt is the position in the text of anchor where the selection
+	// started.
+	pressAt int
+}
+
+// add records the layout of l, selecting it if it lies within the
+// selection.
+func (s *SelectionScope) add(l *Selectable) {
+	if s.members == nil {
+		s.members = make(map[int]*Selectable)
+	}
+	if l.scopeIndex != l.ScopeIndex && s.members[l.scopeIndex] == l {
+		delete(s.members, l.scopeIndex)
+	}
+	l.scopeIndex = l.ScopeIndex
+	if s.members[l.scopeIndex] != l {
+		s.members[l.scopeIndex] = l
+		s.update(l)
+	}
+}
+
+// press starts a selection at l, clearing the selections of the other
+// Selectables.
+func (s *SelectionScope) press(l *Selectable) {
+	s.anchor, s.focus = l, l
+	s.anchorIndex, s.focusIndex = l.scopeIndex, l.scopeIndex
+	_, s.pressAt = l.text.Selection()
+	s.updateAll()
+}
+
+// dragTo extends the selection from the anchor to the point pos of l.
+func (s *SelectionScope) dragTo(l *Selectable, pos image.Point) {
+	a := s.anchor
+	if a == nil {
+		return
+	}
+	s.focus, s.focusIndex = l, l.scopeIndex
+	l.text.MoveCoord(pos)
+	caret, _ := l.text.Selection()
+	switch {
+	case l == a:
+		a.text.SetCaret(caret, s.pressAt)
+	case s.focusIndex > s.anchorIndex:
+		a.text.SetCaret(a.text.Len(), s.pressAt)
+		l.text.SetCaret(caret, 0)
+	default:
+		a.text.SetCaret(0, s.pressAt)
+		l.text.SetCaret(caret, l.text.Len())
+	}
+	s.updateAll()
+}
+
+// updateAll updates the selections of the members to the selection.
+func (s *SelectionScope) updateAll() {
+	for _, m := range s.members {
+		s.update(m)
+	}
+}
+
+// update selects all of m if it lies strictly between the anchor and the
+// focus and clears its selection if it lies outside of them. The anchor and
+// focus select their parts of the selection themselves.
+func (s *SelectionScope) update(m *Selectable) {
+	if s.anchor == nil || m == s.anchor || m == s.focus {
+		return
+	}
+	lo, hi := min(s.anchorIndex, s.focusIndex), max(s.anchorIndex, s.focusIndex)
+	switch i := m.scopeIndex; {
+	case i <= lo || i >= hi:
+		if m.text.SelectionLen() > 0 {
+			m.text.ClearSelection()
+		}
+	case s.anchorIndex < s.focusIndex:
+		m.text.SetCaret(m.text.Len(), 0)
+	default:
+		m.text.SetCaret(0, m.text.Len())
+	}
+}
+
+// focused reports whether the Selectable where the selection started is
+// focused.
+func (s *SelectionScope) focused() bool {
+	return s.anchor != nil && s.anchor.focused
+}
+
+// SelectedText returns the selected text of the Selectables of the scope in
+// the order of their indices, joined by the Separator.
+func (s *SelectionScope) SelectedText() string {
+	if s.anchor == nil {
+		return ""
+	}
+	sep := s.Separator
+	if sep == "" {
+		sep = "\n"
+	}
+	lo, hi := min(s.anchorIndex, s.focusIndex), max(s.anchorIndex, s.focusIndex)
+	var indices []int
+	for i := range s.members {
+		if lo <= i && i <= hi {
+			indices = append(indices, i)
+		}
+	}
+	slices.Sort(indices)
+	var texts []string
+	for _, i := range indices {
+		if t := s.members[i].SelectedText(); t != "" {
+			texts = append(texts, t)
+		}
+	}
+	return strings.Join(texts, sep)
+}
+
+// ClearSelection clears the selections of the Selectables of the scope.
+func (s *SelectionScope) ClearSelection() {
+	for _, m := range s.members {
+		if m.text.SelectionLen() > 0 {
+			m.text.ClearSelection()
+		}
+	}
+	s.anchor, s.focus = nil, nil
+}
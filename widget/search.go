@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchOptions configures a Search.
+type SearchOptions struct {
+	// Regexp interprets the query as a regular expression in the syntax of
+	// package regexp, instead of literal text.
+	Regexp bool
+	// MatchCase distinguishes upper and lower case letters.
+	MatchCase bool
+	// WholeWord restricts matches to those neither preceded nor followed by
+	// a letter, digit or underscore.
+	WholeWord bool
+}
+
+// Match is the range of runes [Start, End) of text matched by a Search.
+type Match struct {
+	Start, End int
+}
+
+// Search finds the matches of a query in text, such as the text of an Editor
+// or a Selectable. Empty matches are ignored.
+type Search struct {
+	re *regexp.Regexp
+	// resume matches the query preceded by any rune, for resuming a search
+	// within the text while keeping the rune before as the context of
+	// assertions such as ^ and \b. It is only set for whole word searches.
+	resume    *regexp.Regexp
+	literal   bool
+	wholeWord bool
+}
+
+// NewSearch returns a Search for query, or an error if the query is an invalid
+// regular expression.
+func NewSearch(query string, opts SearchOptions) (*Search, error) {
+	if !opts.Regexp {
+		query = regexp.QuoteMeta(query)
+	}
+	if !opts.MatchCase {
+		query = "(?i)" + query
+	}
+	re, err := regexp.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	s := &Search{re: re, literal: !opts.Regexp, wholeWord: opts.WholeWord}
+	if opts.WholeWord {
+		s.resume = regexp.MustCompile("(?s:.)(?:" + query + ")")
+	}
+	return s, nil
+}
+
+// FindAll appends the matches of s in text to matches and returns the result.
+func (s *Search) FindAll(text []byte, matches []Match) []Match {
+	s.each(text, func(m Match, _ []int) {
+		matches = append(matches, m)
+	})
+	return matches
+}
+
+// each calls f with the matches in text, in order, and the byte offsets of
+// their submatches.
+func (s *Search) each(text []byte, f func(m Match, submatches []int)) {
+	// Count runes incrementally from the previous match.
+	off, runes := 0, 0
+	emit := func(loc []int) {
+		start, end := loc[0], loc[1]
+		runes += utf8.RuneCount(text[off:start])
+		m := Match{Start: runes, End: runes + utf8.RuneCount(text[start:end])}
+		off, runes = end, m.End
+		f(m, loc)
+	}
+	if !s.wholeWord {
+		for _, loc := range s.re.FindAllSubmatchIndex(text, -1) {
+			if loc[0] != loc[1] {
+				emit(loc)
+			}
+		}
+		return
+	}
+	// Find one match at a time, because a match that is not a whole word
+	// may hide a whole word match overlapping it.
+	for pos := 0; pos < len(text); {
+		loc := s.find(text, pos)
+		if loc == nil {
+			break
+		}
+		start, end := loc[0], loc[1]
+		if start == end || !isWordBoundary(text, start, end) {
+			// Resume after the first rune of the rejected match.
+			_, n := utf8.DecodeRune(text[start:])
+			pos = start + max(n, 1)
+			continue
+		}
+		emit(loc)
+		pos = end
+	}
+}
+
+// expand returns the replacement of the match of the submatches in text. The
+// template is literal for literal searches, and expanded as by
+// regexp.Regexp.Expand otherwise.
+func (s *Search) expand(text []byte, template string, submatches []int) string {
+	if s.literal {
+		return template
+	}
+	return string(s.re.Expand(nil, []byte(template), text, submatches))
+}
+
+// replacements calls f with every match in text from last to first, and its
+// replacement by template.
+func (s *Search) replacements(text []byte, template string, f func(m Match, repl string)) {
+	type replacement struct {
+		m    Match
+		repl string
+	}
+	var repls []replacement
+	s.each(text, func(m Match, submatches []int) {
+		repls = append(repls, replacement{m, s.expand(text, template, submatches)})
+	})
+	for i := len(repls) - 1; i >= 0; i-- {
+		f(repls[i].m, repls[i].repl)
+	}
+}
+
+// isWordBoundary reports whether the bytes [start, end) of text are
+// surrounded by non-word runes.
+func isWordBoundary(text []byte, start, end int) bool {
+	before, _ := utf8.DecodeLastRune(text[:start])
+	after, _ := utf8.DecodeRune(text[end:])
+	return (start == 0 || !isWordRune(before)) && (end == len(text) || !isWordRune(after))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// find returns the submatch byte offsets of the first match of s in text
+// starting at or after pos, matched in the context of the whole text.
+func (s *Search) find(text []byte, pos int) []int {
+	if pos == 0 {
+		return s.re.FindSubmatchIndex(text)
+	}
+	// Match from the rune before pos, and skip the rune matched before the
+	// query.
+	_, n := utf8.DecodeLastRune(text[:pos])
+	base := pos - n
+	loc := s.resume.FindSubmatchIndex(text[base:])
+	if loc == nil {
+		return nil
+	}
+	for i := range loc {
+		if loc[i] != -1 {
+			loc[i] += base
+		}
+	}
+	_, n = utf8.DecodeRune(text[loc[0]:])
+	loc[0] += n
+	return loc
+}
+
+// findNext returns the first match of s in text starting at or after rune
+// offset from, wrapping around to the first match.
+func (s *Search) findNext(text []byte, from int) (Match, bool) {
+	var first, next Match
+	hasFirst, hasNext := false, false
+	s.each(text, func(m Match, _ []int) {
+		if !hasFirst {
+			first, hasFirst = m, true
+		}
+		if !hasNext && m.Start >= from {
+			next, hasNext = m, true
+		}
+	})
+	if hasNext {
+		return next, true
+	}
+	return first, hasFirst
+}
+
+// findPrevious returns the last match of s in text ending at or before rune
+// offset before, wrapping around to the last match.
+func (s *Search) findPrevious(text []byte, before int) (Match, bool) {
+	var last, prev Match
+	hasLast, hasPrev := false, false
+	s.each(text, func(m Match, _ []int) {
+		last, hasLast = m, true
+		if m.End <= before {
+			prev, hasPrev = m, true
+		}
+	})
+	if hasPrev {
+		return prev, true
+	}
+	return last, hasLast
+}
+
+// matchRegions appends the visible regions of matches in t to regions and
+// returns the result.
+func matchRegions(t *textView, matches []Match, regions []Region) []Region {
+	for _, m := range matches {
+		// Locate into the spare capacity of regions.
+		regions = append(regions, t.Regions(m.Start, m.End, regions[len(regions):])...)
+	}
+	return regions
+}
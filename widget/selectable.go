@@ -20,13 +20,13 @@ import (
 	"gioui.org/unit"
 )
 
-// stringSource is an immutable textSource with a fixed string
+// stringSource is an immutable TextStorage with a fixed string
 // value.
 type stringSource struct {
 	reader *strings.Reader
 }
 
-var _ textSource = stringSource{}
+var _ TextStorage = stringSource{}
 
 func newStringSource(str string) stringSource {
 	return stringSource{
@@ -61,14 +61,28 @@ type Selectable struct {
 	Truncator string
 	// WrapPolicy configures how displayed text will be broken into lines.
 	WrapPolicy text.WrapPolicy
+	// Decoration selects the lines, such as underlines, drawn along the text.
+	Decoration text.Decoration
 	// LineHeight controls the distance between the baselines of lines of text.
 	// If zero, a sensible default will be used.
 	LineHeight unit.Sp
 	// LineHeightScale applies a scaling factor to the LineHeight. If zero, a
 	// sensible default will be used.
 	LineHeightScale float32
-	initialized     bool
-	source          stringSource
+	// Keymap maps keys to the actions of the text. If nil, the keymap
+	// returned by DefaultKeymap is used.
+	Keymap *Keymap
+	// Scope, if set, extends the selection across the Selectables of the
+	// scope.
+	Scope *SelectionScope
+	// ScopeIndex orders the Selectable among those of its Scope, such as
+	// the index of its paragraph in a document. The Selectables of a scope
+	// must have distinct indices.
+	ScopeIndex int
+	// scopeIndex is the ScopeIndex the Selectable was last laid out with.
+	scopeIndex  int
+	initialized bool
+	source      stringSource
 	// scratch is a buffer reused to efficiently read text out of the
 	// textView.
 	scratch   []byte
@@ -77,6 +91,8 @@ type Selectable struct {
 	focused   bool
 	dragging  bool
 	dragger   gesture.Drag
+	// keyFilters caches the key filters of the keymap.
+	keyFilters keyFilters
 
 	clicker gesture.Click
 }
@@ -100,7 +116,7 @@ func (l *Selectable) Focused() bool {
 // paintSelection paints the contrasting background for selected text.
 func (l *Selectable) paintSelection(gtx layout.Context, material op.CallOp) {
 	l.initialize()
-	if !l.focused {
+	if !l.focused && (l.Scope == nil || !l.Scope.focused()) {
 		return
 	}
 	l.text.PaintSelection(gtx, material)
@@ -182,13 +198,24 @@ func (l *Selectable) Update(gtx layout.Context) bool {
 // the text and selection rectangles. The provided textMaterial and selectionMaterial ops are used to set the
 // paint material for the text and selection rectangles, respectively.
 func (l *Selectable) Layout(gtx layout.Context, lt *text.Shaper, font font.Font, size unit.Sp, textMaterial, selectionMaterial op.CallOp) layout.Dimensions {
-	l.Update(gtx)
+	if !gtx.Measuring() {
+		if l.Scope != nil {
+			l.initialize()
+			l.Scope.add(l)
+		}
+		l.Update(gtx)
+	}
 	l.text.LineHeight = l.LineHeight
 	l.text.LineHeightScale = l.LineHeightScale
 	l.text.Alignment = l.Alignment
 	l.text.MaxLines = l.MaxLines
 	l.text.Truncator = l.Truncator
 	l.text.WrapPolicy = l.WrapPolicy
+	l.text.Decoration = l.Decoration
+	if gtx.Measuring() {
+		l.initialize()
+		return l.text.Measure(gtx, lt, font, size)
+	}
 	l.text.Layout(gtx, lt, font, size)
 	dims := l.text.Dimensions()
 	defer clip.Rect(image.Rectangle{Max: dims.Size}).Push(gtx.Ops).Pop()
@@ -211,6 +238,7 @@ func (l *Selectable) handleEvents(gtx layout.Context) (selectionChanged bool) {
 		}
 	}()
 	l.processPointer(gtx)
+	l.processDragOver(gtx)
 	l.processKey(gtx)
 	return selectionChanged
 }
@@ -239,6 +267,9 @@ func (e *Selectable) processPointer(gtx layout.Context) {
 					e.text.ClearSelection()
 				}
 				e.dragging = true
+				if e.Scope != nil {
+					e.Scope.press(e)
+				}
 
 				// Process multi-clicks.
 				switch {
@@ -260,10 +291,17 @@ func (e *Selectable) processPointer(gtx layout.Context) {
 				fallthrough
 			case evt.Kind == pointer.Drag && evt.Source == pointer.Mouse:
 				if e.dragging {
-					e.text.MoveCoord(image.Point{
+					pos := image.Point{
 						X: int(math.Round(float64(evt.Position.X))),
 						Y: int(math.Round(float64(evt.Position.Y))),
-					})
+					}
+					switch {
+					case e.Scope == nil:
+						e.text.MoveCoord(pos)
+					case pos.In(image.Rectangle{Max: e.text.Dimensions().Size}) || e.Scope.focus == e:
+						// Leave positions over other Selectables to them.
+						e.Scope.dragTo(e, pos)
+					}
 
 					if release {
 						e.dragging = false
@@ -274,6 +312,27 @@ func (e *Selectable) processPointer(gtx layout.Context) {
 	}
 }
 
+// processDragOver extends the selection of the scope of e to the pointer
+// dragged over e.
+func (e *Selectable) processDragOver(gtx layout.Context) {
+	if e.Scope == nil {
+		return
+	}
+	for {
+		evt, ok := gtx.Event(pointer.Filter{Target: e, Kinds: pointer.Drag, DragOver: true})
+		if !ok {
+			break
+		}
+		a := e.Scope.anchor
+		if evt, ok := evt.(pointer.Event); ok && a != nil && a != e && a.dragging {
+			e.Scope.dragTo(e, image.Point{
+				X: int(math.Round(float64(evt.Position.X))),
+				Y: int(math.Round(float64(evt.Position.Y))),
+			})
+		}
+	}
+}
+
 func (e *Selectable) clickDragEvents(gtx layout.Context) []event.Event {
 	var combinedEvents []event.Event
 	for {
@@ -294,23 +353,15 @@ func (e *Selectable) clickDragEvents(gtx layout.Context) []event.Event {
 }
 
 func (e *Selectable) processKey(gtx layout.Context) {
+	filters := e.keyFilters.update(e.keymap(), 0, func(filters []event.Filter) []event.Filter {
+		filters = append(filters, key.FocusFilter{Target: e})
+		return e.keymap().filters(e, filters, func(_ KeyBinding, a Action) bool {
+			_, _, move := movement(a)
+			return move || a == ActionCopy || a == ActionCut || a == ActionSelectAll
+		})
+	})
 	for {
-		ke, ok := gtx.Event(
-			key.FocusFilter{Target: e},
-			key.Filter{Focus: e, Name: key.NameLeftArrow, Optional: key.ModShortcutAlt | key.ModShift},
-			key.Filter{Focus: e, Name: key.NameRightArrow, Optional: key.ModShortcutAlt | key.ModShift},
-			key.Filter{Focus: e, Name: key.NameUpArrow, Optional: key.ModShortcutAlt | key.ModShift},
-			key.Filter{Focus: e, Name: key.NameDownArrow, Optional: key.ModShortcutAlt | key.ModShift},
-
-			key.Filter{Focus: e, Name: key.NamePageUp, Optional: key.ModShift},
-			key.Filter{Focus: e, Name: key.NamePageDown, Optional: key.ModShift},
-			key.Filter{Focus: e, Name: key.NameEnd, Optional: key.ModShift},
-			key.Filter{Focus: e, Name: key.NameHome, Optional: key.ModShift},
-
-			key.Filter{Focus: e, Name: "C", Required: key.ModShortcut},
-			key.Filter{Focus: e, Name: "X", Required: key.ModShortcut},
-			key.Filter{Focus: e, Name: "A", Required: key.ModShortcut},
-		)
+		ke, ok := gtx.Event(filters...)
 		if !ok {
 			break
 		}
@@ -326,61 +377,41 @@ func (e *Selectable) processKey(gtx layout.Context) {
 	}
 }
 
-func (e *Selectable) command(gtx layout.Context, k key.Event) {
-	direction := 1
-	if gtx.Locale.Direction.Progression() == system.TowardOrigin {
-		direction = -1
-	}
-	moveByWord := k.Modifiers.Contain(key.ModShortcutAlt)
-	selAct := selectionClear
-	if k.Modifiers.Contain(key.ModShift) {
-		selAct = selectionExtend
+// keymap returns the keymap of the text.
+func (e *Selectable) keymap() *Keymap {
+	if e.Keymap != nil {
+		return e.Keymap
 	}
-	if k.Modifiers == key.ModShortcut {
-		switch k.Name {
-		// Copy or Cut selection -- ignored if nothing selected.
-		case "C", "X":
-			e.scratch = e.text.SelectedText(e.scratch)
-			if text := string(e.scratch); text != "" {
-				gtx.Execute(clipboard.WriteCmd{Type: "application/text", Data: io.NopCloser(strings.NewReader(text))})
-			}
-		// Select all
-		case "A":
-			e.text.SetCaret(0, e.text.Len())
+	return defaultKeymap
+}
+
+func (e *Selectable) command(gtx layout.Context, k key.Event) {
+	a, _ := e.keymap().Action(KeyBinding{Name: k.Name, Modifiers: k.Modifiers})
+	if move, selAct, ok := movement(a); ok {
+		direction := 1
+		if gtx.Locale.Direction.Progression() == system.TowardOrigin {
+			direction = -1
 		}
+		moveText(&e.text, move, selAct, direction)
 		return
 	}
-	switch k.Name {
-	case key.NameUpArrow:
-		e.text.MoveLines(-1, selAct)
-	case key.NameDownArrow:
-		e.text.MoveLines(+1, selAct)
-	case key.NameLeftArrow:
-		if moveByWord {
-			e.text.MoveWord(-1*direction, selAct)
-		} else {
-			if selAct == selectionClear {
-				e.text.ClearSelection()
-			}
-			e.text.MoveCaret(-1*direction, -1*direction*int(selAct))
+	switch a {
+	// Copy selection -- ignored if nothing selected. The text can't be
+	// cut, so cutting copies.
+	case ActionCopy, ActionCut:
+		e.scratch = e.text.SelectedText(e.scratch)
+		text := string(e.scratch)
+		if e.Scope != nil && e.Scope.anchor == e {
+			text = e.Scope.SelectedText()
 		}
-	case key.NameRightArrow:
-		if moveByWord {
-			e.text.MoveWord(1*direction, selAct)
-		} else {
-			if selAct == selectionClear {
-				e.text.ClearSelection()
-			}
-			e.text.MoveCaret(1*direction, int(selAct)*direction)
+		if text != "" {
+			gtx.Execute(clipboard.WriteCmd{Type: "application/text", Data: io.NopCloser(strings.NewReader(text))})
+		}
+	case ActionSelectAll:
+		if e.Scope != nil {
+			e.Scope.press(e)
 		}
-	case key.NamePageUp:
-		e.text.MovePages(-1, selAct)
-	case key.NamePageDown:
-		e.text.MovePages(+1, selAct)
-	case key.NameHome:
-		e.text.MoveLineStart(selAct)
-	case key.NameEnd:
-		e.text.MoveLineEnd(selAct)
+		e.text.SetCaret(0, e.text.Len())
 	}
 }
 
@@ -389,3 +420,34 @@ func (l *Selectable) Regions(start, end int, regions []Region) []Region {
 	l.initialize()
 	return l.text.Regions(start, end, regions)
 }
+
+// FindAll appends the matches of s in the text to matches and returns the
+// result.
+func (l *Selectable) FindAll(s *Search, matches []Match) []Match {
+	l.initialize()
+	l.scratch = l.text.Text(l.scratch)
+	return s.FindAll(l.scratch, matches)
+}
+
+// FindNext returns the first match of s starting at or after the rune offset
+// from, wrapping around the end of the text.
+func (l *Selectable) FindNext(s *Search, from int) (Match, bool) {
+	l.initialize()
+	l.scratch = l.text.Text(l.scratch)
+	return s.findNext(l.scratch, from)
+}
+
+// FindPrevious returns the last match of s ending at or before the rune
+// offset before, wrapping around the start of the text.
+func (l *Selectable) FindPrevious(s *Search, before int) (Match, bool) {
+	l.initialize()
+	l.scratch = l.text.Text(l.scratch)
+	return s.findPrevious(l.scratch, before)
+}
+
+// MatchRegions appends the visible regions covering matches to regions and
+// returns the result, such as for highlighting the matches of a search.
+func (l *Selectable) MatchRegions(matches []Match, regions []Region) []Region {
+	l.initialize()
+	return matchRegions(&l.text, matches, regions)
+}
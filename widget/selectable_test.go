@@ -5,10 +5,12 @@ import (
 	"image"
 	"testing"
 
+	"gioui.org/f32"
 	"gioui.org/font"
 	"gioui.org/font/gofont"
 	"gioui.org/io/input"
 	"gioui.org/io/key"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/text"
@@ -32,6 +34,107 @@ func TestSelectableZeroValue(t *testing.T) {
 	}
 }
 
+func TestSelectableSearch(t *testing.T) {
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(300, 300)),
+		Locale:      english,
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	s := new(Selectable)
+	s.SetText("one two one\nthree one")
+	s.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{})
+	search, err := NewSearch("one", SearchOptions{WholeWord: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := s.FindAll(search, nil)
+	if len(matches) != 3 {
+		t.Fatalf("got %d matches, want 3", len(matches))
+	}
+	regions := s.MatchRegions(matches, nil)
+	if len(regions) != 3 {
+		t.Fatalf("got %d regions, want 3", len(regions))
+	}
+	for i, r := range regions {
+		want := s.Regions(matches[i].Start, matches[i].End, nil)
+		if len(want) != 1 || r != want[0] {
+			t.Errorf("region %d: got %v, want %v", i, r, want)
+		}
+	}
+	if m, _ := s.FindNext(search, 1); m != matches[1] {
+		t.Errorf("FindNext got %v, want %v", m, matches[1])
+	}
+}
+
+func TestSelectionScope(t *testing.T) {
+	r := new(input.Router)
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Constraints{Max: image.Pt(300, 300)},
+		Locale:      english,
+		Source:      r.Source(),
+	}
+	cache := text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
+	scope := new(SelectionScope)
+	labels := make([]*Selectable, 3)
+	for i, txt := range []string{"alpha", "bravo", "charlie"} {
+		labels[i] = &Selectable{Scope: scope, ScopeIndex: i}
+		labels[i].SetText(txt)
+	}
+	const spacing = 30
+	var dims []layout.Dimensions
+	// layoutFrom lays out the labels from first, as if the labels before
+	// were scrolled out of view.
+	layoutFrom := func(first int) {
+		dims = dims[:0]
+		for i, l := range labels[first:] {
+			stack := op.Offset(image.Pt(0, i*spacing)).Push(gtx.Ops)
+			dims = append(dims, l.Layout(gtx, cache, font.Font{}, unit.Sp(10), op.CallOp{}, op.CallOp{}))
+			stack.Pop()
+		}
+		r.Frame(gtx.Ops)
+		gtx.Ops.Reset()
+	}
+	layoutLabels := func() { layoutFrom(0) }
+	layoutLabels()
+	layoutLabels()
+	// Drag from the start of the first label to the end of the last.
+	end := f32.Pt(float32(dims[2].Size.X)-.5, 2*spacing+2)
+	r.Queue(
+		pointer.Event{Kind: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: f32.Pt(0, 2)},
+		pointer.Event{Kind: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: f32.Pt(20, 2)},
+	)
+	layoutLabels()
+	r.Queue(pointer.Event{Kind: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: end})
+	layoutLabels()
+	if got, want := scope.SelectedText(), "alpha\nbravo\ncharlie"; got != want {
+		t.Errorf("got selection %q, want %q", got, want)
+	}
+	// Dragging back over the middle label shrinks the selection.
+	r.Queue(pointer.Event{Kind: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: f32.Pt(0, spacing+2)})
+	layoutLabels()
+	if got, want := scope.SelectedText(), "alpha"; got != want {
+		t.Errorf("got selection %q, want %q", got, want)
+	}
+	if got := labels[2].SelectedText(); got != "" {
+		t.Errorf("label left out of the selection has selection %q", got)
+	}
+	// Scroll the anchor out of view and drag to the end of the last label,
+	// now laid out second.
+	layoutFrom(1)
+	r.Queue(pointer.Event{Kind: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: f32.Pt(float32(dims[1].Size.X)-.5, spacing+2)})
+	layoutFrom(1)
+	if got, want := scope.SelectedText(), "alpha\nbravo\ncharlie"; got != want {
+		t.Errorf("got selection %q with the anchor out of view, want %q", got, want)
+	}
+	r.Queue(pointer.Event{Kind: pointer.Release, Source: pointer.Mouse, Position: f32.Pt(float32(dims[1].Size.X)-.5, spacing+2)})
+	layoutLabels()
+	if got, want := scope.SelectedText(), "alpha\nbravo\ncharlie"; got != want {
+		t.Errorf("got selection %q after scrolling back, want %q", got, want)
+	}
+}
+
 // Verify that an existing selection is dismissed when you press arrow keys.
 func TestSelectableMove(t *testing.T) {
 	r := new(input.Router)
@@ -0,0 +1,577 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"slices"
+	"sort"
+
+	"gioui.org/gesture"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/io/semantic"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/unit"
+)
+
+// Table holds the state of a table of rows below a header row, laid out by
+// a virtualized grid. The columns of a table can be resized by dragging the
+// edges of their headers and sorted by clicking them, and its rows can be
+// selected with the pointer or the keyboard.
+type Table struct {
+	// Grid lays out the cells of the table. Its first row is the header
+	// row, and its LockedCols columns stay in view when scrolling.
+	layout.Grid
+	// Columns describe the columns of the table.
+	Columns []TableColumn
+	// RowHeight and HeaderHeight are the heights of the rows and of the
+	// header row.
+	RowHeight, HeaderHeight unit.Dp
+	// Multiple allows selecting several rows, by clicking rows with the
+	// shortcut modifier or extending the selection with the Shift modifier.
+	Multiple bool
+	// Sorting is the order of the rows, updated when the user clicks the
+	// header of a Sortable column. Sorting the rows is up to the program.
+	Sorting TableSort
+
+	cols []tableColumn
+	// rows holds the state of the visible rows, by row index.
+	rows map[int]*tableRow
+	len  int
+	// rowHeight is the height of the rows, and bodyHeight the height of
+	// the rows area in the last layout.
+	rowHeight, bodyHeight int
+
+	// selected holds the selected rows as sorted, disjoint and
+	// non-adjacent ranges.
+	selected       []rowRange
+	anchor, cursor int
+	focused        bool
+	pending        []TableEvent
+}
+
+// TableColumn describes a column of a Table.
+type TableColumn struct {
+	// Width is the initial width of the column. MinWidth and MaxWidth, if
+	// not zero, bound its width when resized. A column with equal MinWidth
+	// and MaxWidth can't be resized.
+	Width, MinWidth, MaxWidth unit.Dp
+	// Sortable columns sort the rows when their header is clicked.
+	Sortable bool
+}
+
+// TableSort describes the order of the rows of a Table.
+type TableSort struct {
+	// Column is the index of the column the rows are sorted by.
+	Column int
+	Order  SortOrder
+}
+
+// SortOrder is the sort order of a column.
+type SortOrder uint8
+
+const (
+	Unsorted SortOrder = iota
+	Ascending
+	Descending
+)
+
+// TableHeader is a function that computes the dimensions of the header of a
+// column.
+type TableHeader func(gtx layout.Context, col int) layout.Dimensions
+
+// TableEvent is generated by a Table.
+type TableEvent interface {
+	isTableEvent()
+}
+
+// SortEvent is generated when the user changes the Sorting of a Table. The
+// selection of the rows is cleared, because sorting moves the rows to other
+// indices.
+type SortEvent struct {
+	TableSort
+}
+
+// ActivateEvent is generated when a row of a Table is double-clicked, or
+// the Return, Enter or Space key is pressed.
+type ActivateEvent struct {
+	Row int
+}
+
+// RowSelectEvent is generated when the user changes the selected rows of a
+// Table.
+type RowSelectEvent struct{}
+
+// tableColumn is the state of a column.
+type tableColumn struct {
+	// width is the width of the column, and layoutWidth its width in the
+	// last layout.
+	width, layoutWidth int
+	sized              bool
+	click              gesture.Click
+	drag               gesture.Drag
+	// pressX is the position of the drag press in the resize handle.
+	pressX float32
+}
+
+// rowRange is the range of rows [first, last].
+type rowRange struct {
+	first, last int
+}
+
+// tableRow is the state of a visible row.
+type tableRow struct {
+	click gesture.Click
+	// visible is set for the rows of the current layout.
+	visible bool
+}
+
+// Update the state of the table, and return the next event, if any.
+func (t *Table) Update(gtx layout.Context) (TableEvent, bool) {
+	if len(t.pending) == 0 {
+		t.update(gtx)
+	}
+	if len(t.pending) > 0 {
+		e := t.pending[0]
+		t.pending = append(t.pending[:0], t.pending[1:]...)
+		return e, true
+	}
+	return nil, false
+}
+
+func (t *Table) update(gtx layout.Context) {
+	t.initColumns(gtx)
+	if !gtx.Enabled() {
+		t.focused = false
+	}
+	for i := range t.cols {
+		t.updateColumn(gtx, i)
+	}
+	for row, r := range t.rows {
+		t.updateRow(gtx, row, r)
+	}
+	for {
+		e, ok := gtx.Event(
+			key.FocusFilter{Target: t},
+			key.Filter{Focus: t, Name: key.NameUpArrow, Optional: key.ModShift},
+			key.Filter{Focus: t, Name: key.NameDownArrow, Optional: key.ModShift},
+			key.Filter{Focus: t, Name: key.NamePageUp, Optional: key.ModShift},
+			key.Filter{Focus: t, Name: key.NamePageDown, Optional: key.ModShift},
+			key.Filter{Focus: t, Name: key.NameHome, Optional: key.ModShift},
+			key.Filter{Focus: t, Name: key.NameEnd, Optional: key.ModShift},
+			key.Filter{Focus: t, Name: key.NameSpace, Optional: key.ModShortcut},
+			key.Filter{Focus: t, Name: key.NameReturn},
+			key.Filter{Focus: t, Name: key.NameEnter},
+			key.Filter{Focus: t, Name: "A", Required: key.ModShortcut},
+		)
+		if !ok {
+			break
+		}
+		switch e := e.(type) {
+		case key.FocusEvent:
+			t.focused = e.Focus
+		case key.Event:
+			if e.State == key.Press {
+				t.command(e)
+			}
+		}
+	}
+}
+
+// updateRow processes the clicks of row.
+func (t *Table) updateRow(gtx layout.Context, row int, r *tableRow) {
+	for {
+		e, ok := r.click.Update(gtx.Source)
+		if !ok {
+			break
+		}
+		switch e.Kind {
+		case gesture.KindPress:
+			gtx.Execute(key.FocusCmd{Tag: t})
+		case gesture.KindClick:
+			t.clickRow(row, e.Modifiers)
+			if e.NumClicks == 2 {
+				t.pending = append(t.pending, ActivateEvent{Row: row})
+			}
+		}
+	}
+}
+
+// updateColumn processes the sort clicks and resize drags of column i.
+func (t *Table) updateColumn(gtx layout.Context, i int) {
+	c := &t.cols[i]
+	for {
+		e, ok := c.click.Update(gtx.Source)
+		if !ok {
+			break
+		}
+		if e.Kind != gesture.KindClick || !t.Columns[i].Sortable {
+			continue
+		}
+		if t.Sorting.Column == i && t.Sorting.Order == Ascending {
+			t.Sorting.Order = Descending
+		} else {
+			t.Sorting = TableSort{Column: i, Order: Ascending}
+		}
+		t.pending = append(t.pending, SortEvent{TableSort: t.Sorting})
+		if len(t.selected) > 0 {
+			t.ClearSelection()
+			t.pending = append(t.pending, RowSelectEvent{})
+		}
+	}
+	for {
+		e, ok := c.drag.Update(gtx.Metric, gtx.Source, gesture.Horizontal)
+		if !ok {
+			break
+		}
+		switch e.Kind {
+		case pointer.Press:
+			c.pressX = e.Position.X
+		case pointer.Drag:
+			// The handle moves with the edge of the column, and the
+			// positions are relative to its last layout.
+			c.width = t.clampWidth(gtx, i, c.layoutWidth+int(e.Position.X-c.pressX))
+		}
+	}
+}
+
+// clampWidth bounds w by the minimum and maximum width of column i.
+func (t *Table) clampWidth(gtx layout.Context, i, w int) int {
+	col := t.Columns[i]
+	if col.MaxWidth != 0 {
+		w = min(w, gtx.Dp(col.MaxWidth))
+	}
+	return max(w, gtx.Dp(col.MinWidth))
+}
+
+// resizable reports whether column i can be resized.
+func (t *Table) resizable(i int) bool {
+	col := t.Columns[i]
+	return col.MinWidth == 0 || col.MinWidth != col.MaxWidth
+}
+
+func (t *Table) initColumns(gtx layout.Context) {
+	for len(t.cols) < len(t.Columns) {
+		t.cols = append(t.cols, tableColumn{})
+	}
+	t.cols = t.cols[:len(t.Columns)]
+	for i := range t.cols {
+		if c := &t.cols[i]; !c.sized {
+			c.sized = true
+			c.width = t.clampWidth(gtx, i, gtx.Dp(t.Columns[i].Width))
+		}
+	}
+}
+
+// command performs the action of the key press e.
+func (t *Table) command(e key.Event) {
+	if t.len == 0 {
+		return
+	}
+	page := max(t.Position.Rows-1, 1)
+	extend := e.Modifiers.Contain(key.ModShift) && t.Multiple
+	switch e.Name {
+	case key.NameUpArrow:
+		t.moveCursor(t.cursor-1, extend)
+	case key.NameDownArrow:
+		t.moveCursor(t.cursor+1, extend)
+	case key.NamePageUp:
+		t.moveCursor(t.cursor-page, extend)
+	case key.NamePageDown:
+		t.moveCursor(t.cursor+page, extend)
+	case key.NameHome:
+		t.moveCursor(0, extend)
+	case key.NameEnd:
+		t.moveCursor(t.len-1, extend)
+	case key.NameSpace:
+		if e.Modifiers.Contain(key.ModShortcut) {
+			// Toggle the selection of the cursor row.
+			t.clickRow(t.cursor, e.Modifiers)
+			break
+		}
+		fallthrough
+	case key.NameReturn, key.NameEnter:
+		t.pending = append(t.pending, ActivateEvent{Row: t.cursor})
+	case "A":
+		if t.Multiple {
+			t.selectRange(0, t.len-1)
+		}
+	}
+}
+
+// moveCursor moves the cursor to row, selecting it or extending the
+// selection to it, and scrolls it into view.
+func (t *Table) moveCursor(row int, extend bool) {
+	row = max(0, min(row, t.len-1))
+	if extend {
+		t.cursor = row
+		t.selectRange(t.anchor, row)
+	} else {
+		t.selectRow(row)
+	}
+	t.scrollToRow(row)
+}
+
+// clickRow updates the selection for a click on row with the modifiers
+// mods.
+func (t *Table) clickRow(row int, mods key.Modifiers) {
+	switch {
+	case t.Multiple && mods.Contain(key.ModShift):
+		t.cursor = row
+		t.selectRange(t.anchor, row)
+	case t.Multiple && mods.Contain(key.ModShortcut):
+		t.cursor, t.anchor = row, row
+		t.SetSelected(row, !t.Selected(row))
+		t.pending = append(t.pending, RowSelectEvent{})
+	default:
+		t.selectRow(row)
+	}
+}
+
+// selectRow selects row only, and moves the cursor and anchor to it.
+func (t *Table) selectRow(row int) {
+	t.cursor, t.anchor = row, row
+	if len(t.selected) == 1 && t.selected[0] == (rowRange{row, row}) {
+		return
+	}
+	t.selected = append(t.selected[:0], rowRange{row, row})
+	t.pending = append(t.pending, RowSelectEvent{})
+}
+
+// selectRange selects the rows between from and to only.
+func (t *Table) selectRange(from, to int) {
+	if from > to {
+		from, to = to, from
+	}
+	t.selected = append(t.selected[:0], rowRange{from, to})
+	t.pending = append(t.pending, RowSelectEvent{})
+}
+
+// scrollToRow scrolls the minimum distance to bring row into view.
+func (t *Table) scrollToRow(row int) {
+	h := t.rowHeight
+	if h <= 0 {
+		return
+	}
+	// The header is the first row of the grid.
+	row++
+	p := &t.Position
+	if row < p.Row || row == p.Row && p.OffsetY > 0 {
+		p.Row, p.OffsetY = row, 0
+		return
+	}
+	// Align the bottom of the row with the bottom of the table.
+	d := t.bodyHeight - h
+	if y := (row-p.Row)*h - p.OffsetY; y <= d {
+		return
+	}
+	p.Row = row - (d+h-1)/h
+	p.OffsetY = (row-p.Row)*h - d
+}
+
+// Selected reports whether row is selected.
+func (t *Table) Selected(row int) bool {
+	i := t.rangeAt(row)
+	return i < len(t.selected) && t.selected[i].first <= row
+}
+
+// rangeAt returns the index of the first selected range not before row.
+func (t *Table) rangeAt(row int) int {
+	return sort.Search(len(t.selected), func(i int) bool {
+		return t.selected[i].last >= row
+	})
+}
+
+// SetSelected selects or deselects row.
+func (t *Table) SetSelected(row int, selected bool) {
+	i := t.rangeAt(row)
+	sel := t.selected
+	in := i < len(sel) && sel[i].first <= row
+	switch {
+	case selected == in:
+		// Nothing to do.
+	case !selected:
+		r := sel[i]
+		switch {
+		case r.first == r.last:
+			t.selected = slices.Delete(sel, i, i+1)
+		case row == r.first:
+			sel[i].first++
+		case row == r.last:
+			sel[i].last--
+		default:
+			// Split the range around row.
+			sel[i].last = row - 1
+			t.selected = slices.Insert(sel, i+1, rowRange{row + 1, r.last})
+		}
+	default:
+		// Merge row with the ranges next to it.
+		joinPrev := i > 0 && sel[i-1].last == row-1
+		joinNext := i < len(sel) && sel[i].first == row+1
+		switch {
+		case joinPrev && joinNext:
+			sel[i-1].last = sel[i].last
+			t.selected = slices.Delete(sel, i, i+1)
+		case joinPrev:
+			sel[i-1].last = row
+		case joinNext:
+			sel[i].first = row
+		default:
+			t.selected = slices.Insert(sel, i, rowRange{row, row})
+		}
+	}
+}
+
+// SelectedRows returns the selected rows, in increasing order.
+func (t *Table) SelectedRows() []int {
+	var rows []int
+	for _, r := range t.selected {
+		for row := r.first; row <= r.last; row++ {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// ClearSelection deselects all rows.
+func (t *Table) ClearSelection() {
+	t.selected = t.selected[:0]
+}
+
+// Cursor returns the row moved by the keyboard, the last row clicked.
+func (t *Table) Cursor() int {
+	return t.cursor
+}
+
+// Focused reports whether the table has keyboard focus.
+func (t *Table) Focused() bool {
+	return t.focused
+}
+
+// ColumnWidth returns the width of column i, in pixels.
+func (t *Table) ColumnWidth(i int) int {
+	if i < len(t.cols) {
+		return t.cols[i].width
+	}
+	return 0
+}
+
+// Layout a table of rows below the header row. The constraints of the
+// headers and cells are the size of their column and row.
+func (t *Table) Layout(gtx layout.Context, rows int, header TableHeader, cell layout.GridCell) layout.Dimensions {
+	for {
+		_, ok := t.Update(gtx)
+		if !ok {
+			break
+		}
+	}
+	t.len = rows
+	t.cursor = max(0, min(t.cursor, rows-1))
+	t.rowHeight = gtx.Dp(t.RowHeight)
+	headerHeight := gtx.Dp(t.HeaderHeight)
+	t.Grid.LockedRows = 1
+	t.Grid.RowHeight = func(gtx layout.Context, row int) int {
+		if row == 0 {
+			return headerHeight
+		}
+		return t.rowHeight
+	}
+	t.Grid.ColWidth = func(gtx layout.Context, col int) int {
+		return t.cols[col].width
+	}
+	if t.rows == nil {
+		t.rows = make(map[int]*tableRow)
+	}
+	for _, r := range t.rows {
+		r.visible = false
+	}
+
+	// The areas of the rows contain their cells, so that clicks on the
+	// cells reach the rows too.
+	t.Grid.RowArea = func(gtx layout.Context, r int) {
+		if r == 0 {
+			return
+		}
+		row := r - 1
+		state := t.rows[row]
+		if state == nil {
+			state = new(tableRow)
+			t.rows[row] = state
+			// Register the row for the events of the next frame.
+			t.updateRow(gtx, row, state)
+		}
+		state.visible = true
+		semantic.Row.Add(gtx.Ops)
+		semantic.SelectedOp(t.Selected(row)).Add(gtx.Ops)
+		state.click.Add(gtx.Ops)
+	}
+
+	m := op.Record(gtx.Ops)
+	dims := t.Grid.Layout(gtx, rows+1, len(t.cols), func(gtx layout.Context, row, col int) layout.Dimensions {
+		if row == 0 {
+			return t.layoutHeader(gtx, col, header)
+		}
+		defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+		semantic.Cell.Add(gtx.Ops)
+		return cell(gtx, row-1, col)
+	})
+	call := m.Stop()
+	t.bodyHeight = dims.Size.Y - headerHeight
+
+	defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+	event.Op(gtx.Ops, t)
+	// Describe the table in an area of its own, because the semantic tree
+	// leaves out areas whose handlers have no gestures, such as the key
+	// handler of the table.
+	defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+	semantic.Table.Add(gtx.Ops)
+	call.Add(gtx.Ops)
+	for row, r := range t.rows {
+		if !r.visible {
+			delete(t.rows, row)
+		}
+	}
+	return dims
+}
+
+// layoutHeader lays out the header of column col, and its resize handle.
+func (t *Table) layoutHeader(gtx layout.Context, col int, header TableHeader) layout.Dimensions {
+	c := &t.cols[col]
+	c.layoutWidth = c.width
+	size := gtx.Constraints.Min
+	cl := clip.Rect{Max: size}.Push(gtx.Ops)
+	semantic.ColumnHeader.Add(gtx.Ops)
+	if t.Columns[col].Sortable {
+		if t.Sorting.Column == col {
+			switch t.Sorting.Order {
+			case Ascending:
+				semantic.DescriptionOp("sorted ascending").Add(gtx.Ops)
+			case Descending:
+				semantic.DescriptionOp("sorted descending").Add(gtx.Ops)
+			}
+		}
+		c.click.Add(gtx.Ops)
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
+	dims := header(gtx, col)
+	cl.Pop()
+	if t.resizable(col) {
+		w := gtx.Dp(tableHandleWidth)
+		defer clip.Rect{Min: image.Pt(size.X-w, 0), Max: size}.Push(gtx.Ops).Pop()
+		c.drag.Add(gtx.Ops)
+		pointer.CursorColResize.Add(gtx.Ops)
+	}
+	return dims
+}
+
+// tableHandleWidth is the width of the resize handles at the edges of the
+// column headers.
+const tableHandleWidth = unit.Dp(6)
+
+func (SortEvent) isTableEvent()      {}
+func (ActivateEvent) isTableEvent()  {}
+func (RowSelectEvent) isTableEvent() {}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget_test
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/io/input"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/io/semantic"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/widget"
+)
+
+func TestTable(t *testing.T) {
+	var r input.Router
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Constraints: layout.Exact(image.Pt(150, 100)),
+		Source:      r.Source(),
+	}
+	col := widget.TableColumn{Width: 50, MinWidth: 20, MaxWidth: 100, Sortable: true}
+	tbl := &widget.Table{
+		Columns:      []widget.TableColumn{col, col, col},
+		RowHeight:    10,
+		HeaderHeight: 20,
+		Multiple:     true,
+	}
+	const rows = 100
+	var events []widget.TableEvent
+	frame := func() {
+		for {
+			e, ok := tbl.Update(gtx)
+			if !ok {
+				break
+			}
+			events = append(events, e)
+		}
+		gtx.Reset()
+		tbl.Layout(gtx, rows, func(gtx layout.Context, col int) layout.Dimensions {
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		}, func(gtx layout.Context, row, col int) layout.Dimensions {
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		})
+		r.Frame(gtx.Ops)
+	}
+	click := func(pos f32.Point, mods key.Modifiers) {
+		r.Queue(
+			pointer.Event{Kind: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: pos, Modifiers: mods},
+			pointer.Event{Kind: pointer.Release, Source: pointer.Mouse, Position: pos, Modifiers: mods},
+		)
+		frame()
+	}
+	rowPos := func(row int) f32.Point {
+		return f32.Pt(75, float32(20+row*10+5))
+	}
+	press := func(name key.Name, mods key.Modifiers) {
+		r.Queue(key.Event{Name: name, State: key.Press, Modifiers: mods})
+		frame()
+	}
+	checkSelection := func(want ...int) {
+		t.Helper()
+		if got := tbl.SelectedRows(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got selection %v, want %v", got, want)
+		}
+	}
+	frame()
+
+	click(rowPos(2), 0)
+	checkSelection(2)
+	if !gtx.Focused(tbl) {
+		t.Error("clicking a row didn't focus the table")
+	}
+	click(rowPos(4), key.ModShift)
+	checkSelection(2, 3, 4)
+	click(rowPos(3), key.ModShortcut)
+	checkSelection(2, 4)
+
+	press(key.NameDownArrow, 0)
+	checkSelection(4)
+	press(key.NameDownArrow, key.ModShift)
+	press(key.NameDownArrow, key.ModShift)
+	checkSelection(4, 5, 6)
+	press(key.NameEnd, 0)
+	checkSelection(rows - 1)
+	if p := tbl.Position; p.Row+p.Rows != rows+1 {
+		t.Errorf("the last row is not in view: %+v", p)
+	}
+	events = events[:0]
+	press(key.NameReturn, 0)
+	press(key.NameEnter, 0)
+	press(key.NameSpace, 0)
+	frame()
+	activate := widget.ActivateEvent{Row: rows - 1}
+	if want := []widget.TableEvent{activate, activate, activate}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %v, want %v", events, want)
+	}
+
+	// Click the header of the second column twice.
+	events = events[:0]
+	click(f32.Pt(75, 10), 0)
+	click(f32.Pt(75, 10), 0)
+	frame()
+	// Sorting clears the selection.
+	want := []widget.TableEvent{
+		widget.SortEvent{TableSort: widget.TableSort{Column: 1, Order: widget.Ascending}},
+		widget.RowSelectEvent{},
+		widget.SortEvent{TableSort: widget.TableSort{Column: 1, Order: widget.Descending}},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %v, want %v", events, want)
+	}
+	checkSelection()
+	press(key.NameSpace, key.ModShortcut)
+	checkSelection(rows - 1)
+
+	// Drag the edge of the first column, beyond its maximum width.
+	r.Queue(
+		pointer.Event{Kind: pointer.Press, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: f32.Pt(48, 10)},
+		pointer.Event{Kind: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: f32.Pt(68, 10)},
+	)
+	frame()
+	if got := tbl.ColumnWidth(0); got != 70 {
+		t.Errorf("got width %d after resize, want 70", got)
+	}
+	r.Queue(
+		pointer.Event{Kind: pointer.Move, Source: pointer.Mouse, Buttons: pointer.ButtonPrimary, Position: f32.Pt(148, 10)},
+		pointer.Event{Kind: pointer.Release, Source: pointer.Mouse, Position: f32.Pt(148, 10)},
+	)
+	frame()
+	if got := tbl.ColumnWidth(0); got != 100 {
+		t.Errorf("got width %d after resize, want 100", got)
+	}
+	if tbl.Sorting.Column != 1 {
+		t.Error("resizing a column sorted the table")
+	}
+
+	// The widened first column leaves room for two columns.
+	classes := make(map[semantic.ClassOp]int)
+	selected := 0
+	nodes := r.AppendSemantics(nil)
+	byID := make(map[input.SemanticID]input.SemanticNode)
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	// parent returns the class of the closest ancestor of n with a class,
+	// skipping areas such as the scroll area of the grid.
+	parent := func(n input.SemanticNode) semantic.ClassOp {
+		for {
+			p, ok := byID[n.ParentID]
+			if !ok || p.Desc.Class != semantic.Unknown {
+				return p.Desc.Class
+			}
+			n = p
+		}
+	}
+	sorted := 0
+	for _, n := range nodes {
+		classes[n.Desc.Class]++
+		if n.Desc.Class == semantic.Row && n.Desc.Selected {
+			selected++
+		}
+		if n.Desc.Class == semantic.ColumnHeader && n.Desc.Description == "sorted descending" {
+			sorted++
+		}
+		// The tree is Table, Row, Cell.
+		switch n.Desc.Class {
+		case semantic.Row:
+			if p := parent(n); p != semantic.Table {
+				t.Errorf("row parent is %v, want table", p)
+			}
+		case semantic.Cell:
+			if p := parent(n); p != semantic.Row {
+				t.Errorf("cell parent is %v, want row", p)
+			}
+		}
+	}
+	if classes[semantic.Table] != 1 || classes[semantic.ColumnHeader] != 2 || classes[semantic.Row] != 8 || classes[semantic.Cell] == 0 {
+		t.Errorf("got semantic classes %v", classes)
+	}
+	if selected != 1 {
+		t.Errorf("got %d selected rows, want 1", selected)
+	}
+	if sorted != 1 {
+		t.Errorf("got %d headers described as sorted, want 1", sorted)
+	}
+
+	// Selecting all rows and deselecting rows in and around the selection.
+	press("A", key.ModShortcut)
+	if n := len(tbl.SelectedRows()); n != rows {
+		t.Errorf("selected %d rows, want %d", n, rows)
+	}
+	tbl.ClearSelection()
+	for _, r := range []int{3, 4, 5, 6, 8} {
+		tbl.SetSelected(r, true)
+	}
+	tbl.SetSelected(7, true)
+	tbl.SetSelected(5, false)
+	tbl.SetSelected(3, false)
+	tbl.SetSelected(10, false)
+	checkSelection(4, 6, 7, 8)
+	if tbl.Selected(5) || !tbl.Selected(7) {
+		t.Error("wrong rows selected")
+	}
+}
@@ -1,7 +1,7 @@
 package widget
 
 import (
-	"bufio"
+	"bytes"
 	"image"
 	"io"
 	"math"
@@ -21,11 +21,14 @@ import (
 	"golang.org/x/image/math/fixed"
 )
 
-// textSource provides text data for use in widgets. If the underlying data type
-// can fail due to I/O errors, it is the responsibility of that type to provide
-// its own mechanism to surface and handle those errors. They will not always
-// be returned by widgets using these functions.
-type textSource interface {
+// TextStorage holds the text of a widget such as an [Editor]. If the
+// underlying data type can fail due to I/O errors, it is the responsibility of
+// that type to provide its own mechanism to surface and handle those errors.
+// They will not always be returned by widgets using these functions.
+//
+// Widgets assume that they are the only writer of their storage, and that the
+// text only changes through ReplaceRunes.
+type TextStorage interface {
 	io.ReaderAt
 	// Size returns the total length of the data in bytes.
 	Size() int64
@@ -61,6 +64,10 @@ type textView struct {
 	Truncator string
 	// WrapPolicy configures how displayed text will be broken into lines.
 	WrapPolicy text.WrapPolicy
+	// Decoration selects the lines, such as underlines, drawn along the text.
+	Decoration text.Decoration
+	// Highlighter, if set, styles the paragraphs of the text.
+	Highlighter Highlighter
 	// Mask replaces the visual display of each rune in the contents with the given rune.
 	// Newline characters are not masked. When non-zero, the unmasked contents
 	// are accessed by Len, Text, and SetText.
@@ -69,36 +76,58 @@ type textView struct {
 	params     text.Parameters
 	shaper     *text.Shaper
 	seekCursor int64
-	rr         textSource
+	rr         TextStorage
 	maskReader maskReader
-	// graphemes tracks the indices of grapheme cluster boundaries within rr.
-	graphemes []int
-	// paragraphReader is used to populate graphemes.
+	// paragraphReader is used to find the grapheme cluster boundaries of
+	// paragraphs.
 	paragraphReader graphemeReader
 	lastMask        rune
+	lastHighlighter Highlighter
 	viewSize        image.Point
 	valid           bool
 	regions         []Region
 	dims            layout.Dimensions
+	// dimsValid tracks whether dims is up to date with the metrics of the
+	// paragraphs.
+	dimsValid bool
 
 	// offIndex is an index of rune index to byte offsets.
 	offIndex []offEntry
 
-	index glyphIndex
-
-	caret struct {
-		// xoff is the offset to the current position when moving between lines.
-		xoff fixed.Int26_6
-		// start is the current caret position in runes, and also the start position of
-		// selected text. end is the end position of selected text. If start
-		// == end, then there's no selection. Note that it's possible (and
-		// common) that the caret (start) is after the end, e.g. after
-		// Shift-DownArrow.
-		start int
-		end   int
-	}
+	// paragraphs holds the layout of the text, split into paragraphs.
+	paragraphs []paragraph
+	// size is the length in bytes of the text split into paragraphs.
+	size int64
+	// shaped counts the paragraphs with a layout.
+	shaped int
+	// dirtyFrom is the index of the first paragraph whose line and vertical
+	// offsets are out of date.
+	dirtyFrom int
+	// emptyLine is the layout assumed for unshaped paragraphs, and
+	// lineAdvance the distance between the last baseline of a paragraph and
+	// the first baseline of the next.
+	emptyLine   paragraph
+	lineAdvance int
+	// paragraphBuf and paragraphText hold the text of the paragraph being
+	// shaped.
+	paragraphBuf  []byte
+	paragraphText bytes.Reader
+	// localRegions holds the regions of a single paragraph.
+	localRegions []Region
+	// styles holds the style ranges of the text, and styleScratch the ranges
+	// of the paragraph being shaped.
+	styles       []StyleRange
+	styleScratch []StyleRange
+
+	// caret is the primary caret, and carets the additional carets of
+	// multi-caret editing.
+	caret  textCaret
+	carets []textCaret
 
 	scrollOff image.Point
+
+	// measurer lays out the text for Measure.
+	measurer *textView
 }
 
 func (e *textView) Changed() bool {
@@ -107,19 +136,27 @@ func (e *textView) Changed() bool {
 
 // Dimensions returns the dimensions of the visible text.
 func (e *textView) Dimensions() layout.Dimensions {
-	basePos := e.dims.Size.Y - e.dims.Baseline
-	return layout.Dimensions{Size: e.viewSize, Baseline: e.viewSize.Y - basePos}
+	dims := e.FullDimensions()
+	basePos := dims.Size.Y - dims.Baseline
+	vdims := layout.Dimensions{Size: e.viewSize, Baseline: e.viewSize.Y - basePos}
+	if dims.LastBaseline != 0 {
+		lastPos := dims.Size.Y - dims.LastBaseline
+		vdims.LastBaseline = e.viewSize.Y - lastPos
+	}
+	return vdims
 }
 
 // FullDimensions returns the dimensions of all shaped text, including
 // text that isn't visible within the current viewport.
 func (e *textView) FullDimensions() layout.Dimensions {
+	e.makeValid()
+	e.updateDims()
 	return e.dims
 }
 
 // SetSource initializes the underlying data source for the Text. This
 // must be done before invoking any other methods on Text.
-func (e *textView) SetSource(source textSource) {
+func (e *textView) SetSource(source TextStorage) {
 	e.rr = source
 	e.invalidate()
 	e.seekCursor = 0
@@ -152,27 +189,48 @@ func (e *textView) ReadRuneBefore(off int64) (rune, int, error) {
 }
 
 func (e *textView) makeValid() {
-	if e.valid {
+	if e.valid && e.size == e.rr.Size() {
 		return
 	}
-	e.layoutText(e.shaper)
+	e.resetParagraphs()
+	e.size = e.rr.Size()
 	e.valid = true
 }
 
 func (e *textView) closestToRune(runeIdx int) combinedPos {
 	e.makeValid()
-	pos, _ := e.index.closestToRune(runeIdx)
-	return pos
+	i := e.paragraphAtRune(runeIdx)
+	p := &e.paragraphs[i]
+	pos, _ := e.paragraphLayout(i).index.closestToRune(runeIdx - p.runeOff)
+	e.updateOffsets(i)
+	return p.toText(pos)
 }
 
 func (e *textView) closestToLineCol(line, col int) combinedPos {
 	e.makeValid()
-	return e.index.closestToLineCol(screenPos{line: line, col: col})
+	for {
+		i := e.paragraphAtLine(line)
+		p := &e.paragraphs[i]
+		if p.layout == nil {
+			e.paragraphLayout(i)
+			continue
+		}
+		pos := p.layout.index.closestToLineCol(screenPos{line: line - p.lineOff, col: col})
+		return p.toText(pos)
+	}
 }
 
 func (e *textView) closestToXY(x fixed.Int26_6, y int) combinedPos {
 	e.makeValid()
-	return e.index.closestToXY(x, y)
+	for {
+		i := e.paragraphAtY(y)
+		p := &e.paragraphs[i]
+		if p.layout == nil {
+			e.paragraphLayout(i)
+			continue
+		}
+		return p.toText(p.layout.index.closestToXY(x, y-p.yOff))
+	}
 }
 
 func (e *textView) closestToXYGraphemes(x fixed.Int26_6, y int) combinedPos {
@@ -221,7 +279,7 @@ func (e *textView) MoveLines(distance int, selAct selectionAction) {
 // ensuring that even if there is no text content, some space is reserved
 // for the caret.
 func (e *textView) calculateViewSize(gtx layout.Context) image.Point {
-	base := e.dims.Size
+	base := e.FullDimensions().Size
 	if caretWidth := e.caretWidth(gtx); base.X < caretWidth {
 		base.X = caretWidth
 	}
@@ -261,6 +319,10 @@ func (e *textView) Layout(gtx layout.Context, lt *text.Shaper, font font.Font, s
 		e.lastMask = e.Mask
 		e.invalidate()
 	}
+	if e.Highlighter != e.lastHighlighter {
+		e.lastHighlighter = e.Highlighter
+		e.invalidate()
+	}
 	if e.Alignment != e.params.Alignment {
 		e.params.Alignment = e.Alignment
 		e.invalidate()
@@ -287,12 +349,43 @@ func (e *textView) Layout(gtx layout.Context, lt *text.Shaper, font font.Font, s
 	}
 
 	e.makeValid()
-
-	if viewSize := e.calculateViewSize(gtx); viewSize != e.viewSize {
+	// Shape the text around the viewport, whose size depends on the
+	// dimensions of the shaped text.
+	for {
+		e.layoutVisible()
+		viewSize := e.calculateViewSize(gtx)
+		if viewSize == e.viewSize {
+			break
+		}
 		e.viewSize = viewSize
-		e.invalidate()
 	}
-	e.makeValid()
+}
+
+// Measure returns the dimensions of the text laid out with the constraints
+// of gtx. It lays out the text with a separate textView, leaving the layout,
+// viewport and scroll offset of e unchanged.
+func (e *textView) Measure(gtx layout.Context, lt *text.Shaper, font font.Font, size unit.Sp) layout.Dimensions {
+	m := e.measurer
+	if m == nil {
+		m = new(textView)
+		e.measurer = m
+	}
+	m.Alignment = e.Alignment
+	m.LineHeight = e.LineHeight
+	m.LineHeightScale = e.LineHeightScale
+	m.SingleLine = e.SingleLine
+	m.MaxLines = e.MaxLines
+	m.Truncator = e.Truncator
+	m.WrapPolicy = e.WrapPolicy
+	m.Decoration = e.Decoration
+	m.Highlighter = e.Highlighter
+	m.Mask = e.Mask
+	m.styles = append(m.styles[:0], e.styles...)
+	// The text may have changed without changing size since the last
+	// measurement.
+	m.SetSource(e.rr)
+	m.Layout(gtx, lt, font, size)
+	return m.Dimensions()
 }
 
 // PaintSelection clips and paints the visible text selection rectangles using
@@ -301,12 +394,15 @@ func (e *textView) PaintSelection(gtx layout.Context, material op.CallOp) {
 	localViewport := image.Rectangle{Max: e.viewSize}
 	docViewport := image.Rectangle{Max: e.viewSize}.Add(e.scrollOff)
 	defer clip.Rect(localViewport).Push(gtx.Ops).Pop()
-	e.regions = e.index.locate(docViewport, e.caret.start, e.caret.end, e.regions)
-	for _, region := range e.regions {
-		area := clip.Rect(region.Bounds).Push(gtx.Ops)
-		material.Add(gtx.Ops)
-		paint.PaintOp{}.Add(gtx.Ops)
-		area.Pop()
+	for i := 0; i <= len(e.carets); i++ {
+		c := e.caretAt(i)
+		e.regions = e.locate(docViewport, c.start, c.end, e.regions)
+		for _, region := range e.regions {
+			area := clip.Rect(region.Bounds).Push(gtx.Ops)
+			material.Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			area.Pop()
+		}
 	}
 }
 
@@ -319,23 +415,54 @@ func (e *textView) PaintText(gtx layout.Context, material op.CallOp) {
 		Max: e.viewSize.Add(e.scrollOff),
 	}
 	it := textIterator{
-		viewport: viewport,
-		material: material,
+		viewport:   viewport,
+		material:   material,
+		decoration: e.Decoration,
 	}
 
-	startGlyph := 0
-	for _, line := range e.index.lines {
-		if line.descent.Ceil()+line.yOff >= viewport.Min.Y {
-			break
-		}
-		startGlyph += line.glyphs
-	}
+	e.makeValid()
 	var glyphs [32]text.Glyph
 	line := glyphs[:0]
-	for _, g := range e.index.glyphs[startGlyph:] {
-		var ok bool
-		if line, ok = it.paintGlyph(gtx, e.shaper, g, line); !ok {
-			break
+	first, last := e.paragraphAtY(viewport.Min.Y), e.paragraphAtY(viewport.Max.Y)
+	var style TextStyle
+paragraphs:
+	for i := first; i <= last; i++ {
+		p := &e.paragraphs[i]
+		l := e.paragraphLayout(i)
+		e.updateOffsets(i)
+		// Track the index of the first rune of the cluster of each glyph to
+		// find its style.
+		startGlyph, r, span := 0, 0, 0
+		for _, line := range l.index.lines {
+			if line.descent.Ceil()+line.yOff+p.yOff >= viewport.Min.Y {
+				break
+			}
+			for _, g := range l.index.glyphs[startGlyph : startGlyph+line.glyphs] {
+				if g.Flags&text.FlagClusterBreak != 0 {
+					r += int(g.Runes)
+				}
+			}
+			startGlyph += line.glyphs
+		}
+		for _, g := range l.index.glyphs[startGlyph:] {
+			var s TextStyle
+			s, span = styleAt(l.styles, r, span)
+			if s != style {
+				if len(line) > 0 {
+					line = it.flush(gtx, e.shaper, line)
+				}
+				style = s
+				it.material = styleMaterial(gtx, style, material)
+				it.decoration = e.Decoration | style.Decoration
+			}
+			if g.Flags&text.FlagClusterBreak != 0 {
+				r += int(g.Runes)
+			}
+			g.Y += int32(p.yOff)
+			var ok bool
+			if line, ok = it.paintGlyph(gtx, e.shaper, g, line); !ok {
+				break paragraphs
+			}
 		}
 	}
 
@@ -356,27 +483,34 @@ func (e *textView) caretWidth(gtx layout.Context) int {
 	return carWidth2
 }
 
-// PaintCaret clips and paints the caret rectangle, adding material immediately
+// PaintCaret clips and paints the caret rectangles, adding material immediately
 // before painting to set the appropriate paint material.
 func (e *textView) PaintCaret(gtx layout.Context, material op.CallOp) {
 	carWidth2 := e.caretWidth(gtx)
-	caretPos, carAsc, carDesc := e.CaretInfo()
+	for i := 0; i <= len(e.carets); i++ {
+		caretPos, carAsc, carDesc := e.caretInfo(e.caretAt(i))
 
-	carRect := image.Rectangle{
-		Min: caretPos.Sub(image.Pt(carWidth2, carAsc)),
-		Max: caretPos.Add(image.Pt(carWidth2, carDesc)),
-	}
-	cl := image.Rectangle{Max: e.viewSize}
-	carRect = cl.Intersect(carRect)
-	if !carRect.Empty() {
-		defer clip.Rect(carRect).Push(gtx.Ops).Pop()
-		material.Add(gtx.Ops)
-		paint.PaintOp{}.Add(gtx.Ops)
+		carRect := image.Rectangle{
+			Min: caretPos.Sub(image.Pt(carWidth2, carAsc)),
+			Max: caretPos.Add(image.Pt(carWidth2, carDesc)),
+		}
+		cl := image.Rectangle{Max: e.viewSize}
+		carRect = cl.Intersect(carRect)
+		if !carRect.Empty() {
+			stack := clip.Rect(carRect).Push(gtx.Ops)
+			material.Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			stack.Pop()
+		}
 	}
 }
 
 func (e *textView) CaretInfo() (pos image.Point, ascent, descent int) {
-	caretStart := e.closestToRune(e.caret.start)
+	return e.caretInfo(e.caret)
+}
+
+func (e *textView) caretInfo(c textCaret) (pos image.Point, ascent, descent int) {
+	caretStart := e.closestToRune(c.start)
 
 	ascent = caretStart.ascent.Ceil()
 	descent = caretStart.descent.Ceil()
@@ -398,7 +532,8 @@ func (e *textView) ByteOffset(runeOffset int) int64 {
 // Len is the length of the editor contents, in runes.
 func (e *textView) Len() int {
 	e.makeValid()
-	return e.closestToRune(math.MaxInt).runes
+	p := &e.paragraphs[len(e.paragraphs)-1]
+	return p.runeOff + p.runes
 }
 
 // Text returns the contents of the editor. If the provided buf is large enough, it will
@@ -418,17 +553,18 @@ func (e *textView) Text(buf []byte) []byte {
 
 func (e *textView) ScrollBounds() image.Rectangle {
 	var b image.Rectangle
+	dims := e.FullDimensions()
 	if e.SingleLine {
-		if len(e.index.lines) > 0 {
-			line := e.index.lines[0]
+		if lines := e.paragraphLayout(0).index.lines; len(lines) > 0 {
+			line := lines[0]
 			b.Min.X = line.xOff.Floor()
 			if b.Min.X > 0 {
 				b.Min.X = 0
 			}
 		}
-		b.Max.X = e.dims.Size.X + b.Min.X - e.viewSize.X
+		b.Max.X = dims.Size.X + b.Min.X - e.viewSize.X
 	} else {
-		b.Max.Y = e.dims.Size.Y - e.viewSize.Y
+		b.Max.Y = dims.Size.Y - e.viewSize.Y
 	}
 	return b
 }
@@ -472,47 +608,12 @@ func (e *textView) MoveCoord(pos image.Point) {
 // Truncated returns whether the text in the textView is currently
 // truncated due to a restriction on the number of lines.
 func (e *textView) Truncated() bool {
-	return e.index.truncated
-}
-
-func (e *textView) layoutText(lt *text.Shaper) {
-	e.Seek(0, io.SeekStart)
-	var r io.Reader = e
-	if e.Mask != 0 {
-		e.maskReader.Reset(e, e.Mask)
-		r = &e.maskReader
-	}
-	e.index.reset()
-	it := textIterator{viewport: image.Rectangle{Max: image.Point{X: math.MaxInt, Y: math.MaxInt}}}
-	if lt != nil {
-		lt.Layout(e.params, r)
-		for {
-			g, ok := lt.NextGlyph()
-			if !it.processGlyph(g, ok) {
-				break
-			}
-			e.index.Glyph(g)
-		}
-	} else {
-		// Make a fake glyph for every rune in the reader.
-		b := bufio.NewReader(r)
-		for _, _, err := b.ReadRune(); err != io.EOF; _, _, err = b.ReadRune() {
-			g := text.Glyph{Runes: 1, Flags: text.FlagClusterBreak}
-			_ = it.processGlyph(g, true)
-			e.index.Glyph(g)
-		}
-	}
-	e.paragraphReader.SetSource(e.rr)
-	e.graphemes = e.graphemes[:0]
-	for g := e.paragraphReader.Graphemes(); len(g) > 0; g = e.paragraphReader.Graphemes() {
-		if len(e.graphemes) > 0 && g[0] == e.graphemes[len(e.graphemes)-1] {
-			g = g[1:]
+	for i := range e.paragraphs {
+		if l := e.paragraphs[i].layout; l != nil && l.index.truncated {
+			return true
 		}
-		e.graphemes = append(e.graphemes, g...)
 	}
-	dims := layout.Dimensions{Size: it.bounds.Size()}
-	dims.Baseline = dims.Size.Y - it.baseline
-	e.dims = dims
+	return false
 }
 
 // CaretPos returns the line & column numbers of the caret.
@@ -550,6 +651,12 @@ func (e *textView) indexRune(r int) offEntry {
 func (e *textView) runeOffset(r int) int {
 	const runesPerIndexEntry = 50
 	entry := e.indexRune(r)
+	if e.valid {
+		// Start from the paragraph of the rune if it is closer.
+		if p := &e.paragraphs[e.paragraphAtRune(r)]; p.runeOff > entry.runes {
+			entry = offEntry{runes: p.runeOff, bytes: p.byteOff}
+		}
+	}
 	lastEntry := e.offIndex[len(e.offIndex)-1].runes
 	for entry.runes < r {
 		if entry.runes > lastEntry && entry.runes%runesPerIndexEntry == runesPerIndexEntry-1 {
@@ -576,11 +683,26 @@ func (e *textView) Replace(start, end int, s string) int {
 	startPos := e.closestToRune(start)
 	endPos := e.closestToRune(end)
 	startOff := e.runeOffset(startPos.runes)
+	endOff := e.runeOffset(endPos.runes)
 	replaceSize := endPos.runes - startPos.runes
 	sc := utf8.RuneCountInString(s)
 	newEnd := startPos.runes + sc
 
+	size := e.rr.Size()
 	e.rr.ReplaceRunes(int64(startOff), int64(replaceSize), s)
+	if e.valid && e.size == size {
+		// Lay out the replaced paragraphs anew, keeping the index of byte
+		// offsets before the replacement.
+		newEndOff := int64(endOff) + e.rr.Size() - size
+		e.spliceParagraphs(int64(startOff), int64(endOff), newEndOff)
+		e.size = e.rr.Size()
+		i := sort.Search(len(e.offIndex), func(i int) bool {
+			return e.offIndex[i].runes > startPos.runes
+		})
+		e.offIndex = e.offIndex[:i]
+	} else {
+		e.invalidate()
+	}
 	adjust := func(pos int) int {
 		switch {
 		case newEnd < pos && pos <= endPos.runes:
@@ -593,7 +715,11 @@ func (e *textView) Replace(start, end int, s string) int {
 	}
 	e.caret.start = adjust(e.caret.start)
 	e.caret.end = adjust(e.caret.end)
-	e.invalidate()
+	for i := range e.carets {
+		c := &e.carets[i]
+		c.start, c.end = adjust(c.start), adjust(c.end)
+	}
+	e.styles = adjustStyles(e.styles, endPos.runes, newEnd)
 	return sc
 }
 
@@ -612,14 +738,31 @@ func (e *textView) MovePages(pages int, selAct selectionAction) {
 // moveByGraphemes returns the rune index resulting from moving the
 // specified number of grapheme clusters from startRuneidx.
 func (e *textView) moveByGraphemes(startRuneidx, graphemes int) int {
-	if len(e.graphemes) == 0 {
+	e.makeValid()
+	i := e.paragraphAtRune(startRuneidx)
+	p := &e.paragraphs[i]
+	boundaries := e.paragraphLayout(i).graphemes
+	if len(boundaries) == 0 {
 		return startRuneidx
 	}
-	startGraphemeIdx, _ := slices.BinarySearch(e.graphemes, startRuneidx)
-	startGraphemeIdx = max(startGraphemeIdx+graphemes, 0)
-	startGraphemeIdx = min(startGraphemeIdx, len(e.graphemes)-1)
-	startRuneIdx := e.graphemes[startGraphemeIdx]
-	return e.closestToRune(startRuneIdx).runes
+	idx, _ := slices.BinarySearch(boundaries, startRuneidx-p.runeOff)
+	idx += graphemes
+	// The last boundary of a paragraph is the first boundary of the next.
+	for idx < 0 && i > 0 {
+		i--
+		p = &e.paragraphs[i]
+		boundaries = e.paragraphLayout(i).graphemes
+		idx += len(boundaries) - 1
+	}
+	for idx >= len(boundaries) && i < len(e.paragraphs)-1 {
+		idx -= len(boundaries) - 1
+		i++
+		p = &e.paragraphs[i]
+		boundaries = e.paragraphLayout(i).graphemes
+	}
+	idx = max(idx, 0)
+	idx = min(idx, len(boundaries)-1)
+	return e.closestToRune(p.runeOff + boundaries[idx]).runes
 }
 
 // clampCursorToGraphemes ensures that the final start/end positions of
@@ -786,7 +929,7 @@ func (e *textView) SelectedText(buf []byte) []byte {
 	buf = buf[:end-start]
 	n, _ := e.rr.ReadAt(buf, int64(start))
 	// There is no way to reasonably handle a read error here. We rely upon
-	// implementations of textSource to provide other ways to signal errors
+	// implementations of TextStorage to provide other ways to signal errors
 	// if the user cares about that, and here we use whatever data we were
 	// able to read.
 	return buf[:n]
@@ -835,11 +978,67 @@ func (e *textView) ReadAt(p []byte, offset int64) (int, error) {
 	return e.rr.ReadAt(p, offset)
 }
 
+// indexBytes returns the byte offset of the first occurrence of needle that
+// lies within the byte range [start, end) of the text, or -1.
+func (e *textView) indexBytes(needle []byte, start, end int64) int64 {
+	const chunkSize = 4096
+	n := int64(len(needle))
+	if n == 0 {
+		return -1
+	}
+	// Overlap the chunks to find occurrences straddling them.
+	buf := make([]byte, chunkSize+n-1)
+	for off := start; off+n <= end; off += chunkSize {
+		m, _ := e.rr.ReadAt(buf[:min(end-off, int64(len(buf)))], off)
+		if i := bytes.Index(buf[:m], needle); i != -1 {
+			return off + int64(i)
+		}
+	}
+	return -1
+}
+
+// runeAtByte returns the rune offset of the byte offset off, which must start
+// a rune.
+func (e *textView) runeAtByte(off int64) int {
+	e.makeValid()
+	p := &e.paragraphs[e.paragraphAtByte(off)]
+	runes := p.runeOff
+	for b := int64(p.byteOff); b < off; runes++ {
+		_, s, _ := e.ReadRuneAt(b)
+		if s == 0 {
+			break
+		}
+		b += int64(s)
+	}
+	return runes
+}
+
 // Regions returns visible regions covering the rune range [start,end).
 func (e *textView) Regions(start, end int, regions []Region) []Region {
 	viewport := image.Rectangle{
 		Min: e.scrollOff,
 		Max: e.viewSize.Add(e.scrollOff),
 	}
-	return e.index.locate(viewport, start, end, regions)
+	return e.locate(viewport, start, end, regions)
+}
+
+// locate returns the regions covering the rune range [start,end) within the
+// viewport, relative to the viewport.
+func (e *textView) locate(viewport image.Rectangle, start, end int, regions []Region) []Region {
+	e.makeValid()
+	if start > end {
+		start, end = end, start
+	}
+	regions = regions[:0]
+	first := max(e.paragraphAtRune(start), e.paragraphAtY(viewport.Min.Y))
+	last := min(e.paragraphAtRune(end), e.paragraphAtY(viewport.Max.Y))
+	for i := first; i <= last; i++ {
+		p := &e.paragraphs[i]
+		index := &e.paragraphLayout(i).index
+		e.updateOffsets(i)
+		local := viewport.Sub(image.Pt(0, p.yOff))
+		e.localRegions = index.locate(local, start-p.runeOff, end-p.runeOff, e.localRegions)
+		regions = append(regions, e.localRegions...)
+	}
+	return regions
 }
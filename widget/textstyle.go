@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package widget
+
+import (
+	"image"
+	"image/color"
+	"sort"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+)
+
+// TextStyle describes the appearance of a range of text. The zero value of a
+// field leaves the appearance of the text unchanged.
+type TextStyle struct {
+	// Color is the color of the text.
+	Color color.NRGBA
+	// Background is the color painted behind the text.
+	Background color.NRGBA
+	// Weight and Style override the weight and style of the font.
+	Weight font.Weight
+	Style  font.Style
+	// Decoration selects lines drawn along the text, in addition to the
+	// decoration of the widget.
+	Decoration text.Decoration
+}
+
+// StyleRange applies a style to the runes in [Start, End). Where ranges
+// overlap, the fields of later ranges take precedence.
+type StyleRange struct {
+	Start, End int
+	Style      TextStyle
+}
+
+// Highlighter styles text such as source code or misspelled words.
+type Highlighter interface {
+	// Highlight appends the styles of the text to styles and returns the
+	// result. The text is a paragraph ending in a newline or at the end of the
+	// text of the widget, and the ranges are relative to its start. Text that
+	// can't be laid out paragraph by paragraph is highlighted as a whole.
+	Highlight(text []byte, styles []StyleRange) []StyleRange
+}
+
+// styleSpan is a span of runes in a single style.
+type styleSpan struct {
+	start, end int
+	style      TextStyle
+}
+
+// merge overrides the fields of s with the non-zero fields of o.
+func (s *TextStyle) merge(o TextStyle) {
+	if o.Color != (color.NRGBA{}) {
+		s.Color = o.Color
+	}
+	if o.Background != (color.NRGBA{}) {
+		s.Background = o.Background
+	}
+	if o.Weight != 0 {
+		s.Weight = o.Weight
+	}
+	if o.Style != 0 {
+		s.Style = o.Style
+	}
+	s.Decoration |= o.Decoration
+}
+
+// flattenStyles divides the runes in [0, n) covered by ranges into spans of a
+// single style, and appends them to spans.
+func flattenStyles(ranges []StyleRange, n int, spans []styleSpan) []styleSpan {
+	var bounds []int
+	for _, r := range ranges {
+		if r.Start < n && r.End > 0 && r.Start < r.End {
+			bounds = append(bounds, max(r.Start, 0), min(r.End, n))
+		}
+	}
+	sort.Ints(bounds)
+	for i := 1; i < len(bounds); i++ {
+		start, end := bounds[i-1], bounds[i]
+		if start == end {
+			continue
+		}
+		var style TextStyle
+		for _, r := range ranges {
+			if r.Start <= start && end <= r.End {
+				style.merge(r.Style)
+			}
+		}
+		if style == (TextStyle{}) {
+			continue
+		}
+		if l := len(spans) - 1; l >= 0 && spans[l].end == start && spans[l].style == style {
+			spans[l].end = end
+			continue
+		}
+		spans = append(spans, styleSpan{start: start, end: end, style: style})
+	}
+	return spans
+}
+
+// styleAt returns the style of the rune at index r, and the index of the span
+// to search from for later runes.
+func styleAt(spans []styleSpan, r, from int) (TextStyle, int) {
+	for ; from < len(spans); from++ {
+		s := spans[from]
+		if r < s.start {
+			break
+		}
+		if r < s.end {
+			return s.style, from
+		}
+	}
+	return TextStyle{}, from
+}
+
+// fontSpans returns the spans of f changed by the weight and style of spans.
+func fontSpans(f font.Font, spans []styleSpan) []text.FontSpan {
+	var fs []text.FontSpan
+	for _, s := range spans {
+		if s.style.Weight == 0 && s.style.Style == 0 {
+			continue
+		}
+		sf := f
+		if s.style.Weight != 0 {
+			sf.Weight = s.style.Weight
+		}
+		if s.style.Style != 0 {
+			sf.Style = s.style.Style
+		}
+		fs = append(fs, text.FontSpan{Start: s.start, End: s.end, Font: sf})
+	}
+	return fs
+}
+
+// adjustStyles adjusts the ranges after a span of runes ending at end was
+// replaced by runes ending at newEnd, and removes the ranges left empty.
+// Runes inserted at the boundaries of a range are outside it.
+func adjustStyles(ranges []StyleRange, end, newEnd int) []StyleRange {
+	adjust := func(pos int, after bool) int {
+		switch {
+		case end < pos || (after && end == pos):
+			pos += newEnd - end
+		case newEnd < pos:
+			pos = newEnd
+		}
+		return pos
+	}
+	kept := ranges[:0]
+	for _, r := range ranges {
+		r.Start, r.End = adjust(r.Start, true), adjust(r.End, false)
+		if r.Start < r.End {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// SetStyles replaces the styles of the text with a copy of ranges, whose
+// offsets are in runes. The ranges are adjusted as the text is edited.
+func (e *textView) SetStyles(ranges []StyleRange) {
+	e.styles = append(e.styles[:0], ranges...)
+	e.Restyle()
+}
+
+// AddStyle adds a style range to the text.
+func (e *textView) AddStyle(r StyleRange) {
+	e.styles = append(e.styles, r)
+	e.restyleRunes(r.Start, r.End)
+}
+
+// Styles appends the style ranges of the text to ranges and returns the
+// result.
+func (e *textView) Styles(ranges []StyleRange) []StyleRange {
+	return append(ranges, e.styles...)
+}
+
+// Restyle releases the layouts of all paragraphs, to lay them out with
+// new styles.
+func (e *textView) Restyle() {
+	e.restyleRunes(0, e.Len())
+}
+
+// restyleRunes releases the layouts of the paragraphs containing runes in
+// [start, end].
+func (e *textView) restyleRunes(start, end int) {
+	e.makeValid()
+	for i := e.paragraphAtRune(start); i <= e.paragraphAtRune(end); i++ {
+		if e.paragraphs[i].layout != nil {
+			e.paragraphs[i].layout = nil
+			e.shaped--
+		}
+	}
+}
+
+// paragraphStyles computes the style spans of the paragraph at index i from
+// the styles of the text and the highlighter, if any.
+func (e *textView) paragraphStyles(i int, text []byte, spans []styleSpan) []styleSpan {
+	p := &e.paragraphs[i]
+	ranges := e.styleScratch[:0]
+	if e.Highlighter != nil {
+		ranges = e.Highlighter.Highlight(text, ranges)
+	}
+	for _, r := range e.styles {
+		if r.End > p.runeOff && r.Start < p.runeOff+p.runes {
+			r.Start -= p.runeOff
+			r.End -= p.runeOff
+			ranges = append(ranges, r)
+		}
+	}
+	e.styleScratch = ranges
+	return flattenStyles(ranges, p.runes, spans)
+}
+
+// PaintBackgrounds paints the visible backgrounds of styled text.
+func (e *textView) PaintBackgrounds(gtx layout.Context) {
+	e.makeValid()
+	localViewport := image.Rectangle{Max: e.viewSize}
+	docViewport := localViewport.Add(e.scrollOff)
+	defer clip.Rect(localViewport).Push(gtx.Ops).Pop()
+	first, last := e.paragraphAtY(docViewport.Min.Y), e.paragraphAtY(docViewport.Max.Y)
+	for i := first; i <= last; i++ {
+		p := &e.paragraphs[i]
+		l := e.paragraphLayout(i)
+		e.updateOffsets(i)
+		viewport := docViewport.Sub(image.Pt(0, p.yOff))
+		for _, s := range l.styles {
+			if s.style.Background == (color.NRGBA{}) {
+				continue
+			}
+			e.localRegions = l.index.locate(viewport, s.start, s.end, e.localRegions)
+			for _, region := range e.localRegions {
+				area := clip.Rect(region.Bounds).Push(gtx.Ops)
+				paint.ColorOp{Color: s.style.Background}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+				area.Pop()
+			}
+		}
+	}
+}
+
+// styleMaterial returns the material for text in style, defaulting to
+// material.
+func styleMaterial(gtx layout.Context, style TextStyle, material op.CallOp) op.CallOp {
+	if style.Color == (color.NRGBA{}) {
+		return material
+	}
+	m := op.Record(gtx.Ops)
+	paint.ColorOp{Color: style.Color}.Add(gtx.Ops)
+	return m.Stop()
+}